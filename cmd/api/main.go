@@ -5,20 +5,65 @@ import (
 
 	"github.com/mohit4bug/mo-sh/internal/workers"
 	"github.com/mohit4bug/mo-sh/pkg/api"
+	"github.com/mohit4bug/mo-sh/pkg/config"
 	"github.com/mohit4bug/mo-sh/pkg/db"
+	"github.com/mohit4bug/mo-sh/pkg/mail"
 	"github.com/mohit4bug/mo-sh/pkg/redis"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
 )
 
 func main() {
 	ctx := context.Background()
 
+	cfg := config.Load()
+
 	db := db.NewDatabase()
 	redisClient := redis.NewRedisClient()
 
 	dockerWorker := workers.NewDockerInstallationWorker(db, redisClient, ctx)
 	dockerWorker.Start(3)
 
-	r := api.NewRouter(db, redisClient, ctx)
+	mailWorker := workers.NewMailWorker(redisClient, mail.NewMailer(cfg.SMTP), ctx)
+	mailWorker.Start(1)
+
+	githubWebhookWorker := workers.NewGithubWebhookWorker(db, redisClient, ctx)
+	githubWebhookWorker.Start(2)
+
+	sourceWebhookWorker := workers.NewSourceWebhookWorker(db, redisClient, ctx)
+	sourceWebhookWorker.Start(2)
+
+	gitPollWorker := workers.NewGitPollWorker(db, redisClient, ctx)
+	gitPollWorker.Start()
+
+	settingsStore := settings.NewStore(db, cfg, ctx)
+	secretCipher := secrets.NewCipher(cfg.EncryptionKey)
+
+	deploymentWorker := workers.NewDeploymentWorker(db, redisClient, settingsStore, secretCipher, ctx)
+	deploymentWorker.Start(2)
+
+	proxyWorker := workers.NewProxyWorker(db, redisClient, ctx)
+	proxyWorker.Start(1)
+
+	databaseWorker := workers.NewDatabaseWorker(db, redisClient, secretCipher, ctx)
+	databaseWorker.Start(2)
+
+	backupWorker := workers.NewBackupWorker(db, redisClient, secretCipher, ctx)
+	backupWorker.Start(2)
+
+	backupScheduleWorker := workers.NewBackupScheduleWorker(db, redisClient, ctx)
+	backupScheduleWorker.Start()
+
+	restoreWorker := workers.NewRestoreWorker(db, redisClient, secretCipher, ctx)
+	restoreWorker.Start(1)
+
+	volumeSnapshotWorker := workers.NewVolumeSnapshotWorker(db, redisClient, secretCipher, ctx)
+	volumeSnapshotWorker.Start(2)
+
+	volumeSnapshotScheduleWorker := workers.NewVolumeSnapshotScheduleWorker(db, redisClient, ctx)
+	volumeSnapshotScheduleWorker.Start()
+
+	r := api.NewRouter(db, redisClient, cfg, settingsStore, secretCipher, ctx)
 
 	r.Run(":8000")
 }
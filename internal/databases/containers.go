@@ -0,0 +1,141 @@
+package databases
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+)
+
+// startupGrace is how long a started container must keep running to count
+// as up; images that can't initialise exit well within it.
+const startupGrace = 5 * time.Second
+
+// Start runs the database's container, creating it and its volume the first
+// time.
+func Start(sshClient *ssh.Client, database *models.Database, password string) error {
+	name := git.ShellQuote(ContainerName(database.ID))
+
+	if sshClient.CheckCommand("docker container inspect " + name + " >/dev/null 2>&1") {
+		if _, stderr, err := sshClient.RunCommand("docker start " + name + " >/dev/null"); err != nil {
+			return fmt.Errorf("failed to start the container: %w: %s", err, strings.TrimSpace(stderr))
+		}
+	} else if err := run(sshClient, database, password); err != nil {
+		return err
+	}
+
+	return waitRunning(sshClient, database)
+}
+
+// Stop stops the database's container, keeping it for the next start.
+func Stop(sshClient *ssh.Client, database *models.Database) error {
+	name := git.ShellQuote(ContainerName(database.ID))
+	if _, stderr, err := sshClient.RunCommand("docker stop " + name + " >/dev/null"); err != nil {
+		return fmt.Errorf("failed to stop the container: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+func Restart(sshClient *ssh.Client, database *models.Database, password string) error {
+	name := git.ShellQuote(ContainerName(database.ID))
+	if !sshClient.CheckCommand("docker container inspect " + name + " >/dev/null 2>&1") {
+		return Start(sshClient, database, password)
+	}
+
+	if _, stderr, err := sshClient.RunCommand("docker restart " + name + " >/dev/null"); err != nil {
+		return fmt.Errorf("failed to restart the container: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return waitRunning(sshClient, database)
+}
+
+// Recreate replaces the container, to pick up a new version or port, and
+// starts it on the same volume.
+func Recreate(sshClient *ssh.Client, database *models.Database, password string) error {
+	name := git.ShellQuote(ContainerName(database.ID))
+	if _, stderr, err := sshClient.RunCommand("docker rm -f " + name + " >/dev/null 2>&1 || true"); err != nil {
+		return fmt.Errorf("failed to remove the container: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return Start(sshClient, database, password)
+}
+
+// Remove deletes the database's container and its data.
+func Remove(sshClient *ssh.Client, database *models.Database) error {
+	script := fmt.Sprintf(
+		"docker rm -f %s >/dev/null 2>&1 || true\ndocker volume rm -f %s >/dev/null\n",
+		git.ShellQuote(ContainerName(database.ID)),
+		git.ShellQuote(VolumeName(database.ID)),
+	)
+	if _, stderr, err := sshClient.RunCommand(script); err != nil {
+		return fmt.Errorf("failed to remove the database: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// run creates the container. Credentials are only passed in its environment,
+// which docker reads from stdin, so they stay off the command line.
+func run(sshClient *ssh.Client, database *models.Database, password string) error {
+	engine, ok := EngineFor(database.Type)
+	if !ok {
+		return fmt.Errorf("unsupported database type %q", database.Type)
+	}
+
+	name := ContainerName(database.ID)
+	args := []string{
+		"docker", "run", "-d",
+		"--name", git.ShellQuote(name),
+		"--restart", "unless-stopped",
+		"--network", deploy.Network,
+		"--network-alias", git.ShellQuote(name),
+		"--label", "mo-sh.managed=true",
+		"--label", git.ShellQuote("mo-sh.database=" + database.ID),
+		"-v", git.ShellQuote(VolumeName(database.ID) + ":" + engine.DataDir),
+		"--env-file", "/dev/stdin",
+	}
+	if database.PublicPort != nil {
+		args = append(args, "-p", fmt.Sprintf("%d:%d", *database.PublicPort, engine.Port))
+	}
+	args = append(args, git.ShellQuote(engine.Image+":"+database.Version))
+	for _, arg := range engine.Args {
+		args = append(args, git.ShellQuote(arg))
+	}
+
+	var env strings.Builder
+	for key, value := range engine.Env(database, password) {
+		fmt.Fprintf(&env, "%s=%s\n", key, value)
+	}
+
+	script := fmt.Sprintf(
+		"set -e\n%s\ndocker volume create %s >/dev/null\n%s >/dev/null\n",
+		deploy.EnsureNetworkCommand,
+		git.ShellQuote(VolumeName(database.ID)),
+		strings.Join(args, " "),
+	)
+	if stderr, err := sshClient.RunWithStdin(script, strings.NewReader(env.String())); err != nil {
+		return fmt.Errorf("failed to create the container: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// waitRunning checks that the container is still up after startupGrace,
+// returning its last log lines if not.
+func waitRunning(sshClient *ssh.Client, database *models.Database) error {
+	name := git.ShellQuote(ContainerName(database.ID))
+
+	time.Sleep(startupGrace)
+	state, _, err := sshClient.RunCommand("docker inspect -f '{{.State.Running}} {{.State.Restarting}}' " + name)
+	if err == nil && strings.TrimSpace(state) == "true false" {
+		return nil
+	}
+
+	logs, _, _ := sshClient.RunCommand("docker logs --tail 20 " + name + " 2>&1")
+	message := "the container exited after starting"
+	if logs = strings.TrimSpace(logs); logs != "" {
+		message += ":\n" + logs
+	}
+	return errors.New(message)
+}
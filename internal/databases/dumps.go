@@ -0,0 +1,83 @@
+package databases
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+)
+
+// dump is how a database type is dumped inside its container. The script
+// reads the password from its first line of stdin, so it stays off the
+// command line, and gets the username and database name as $1 and $2.
+type dump struct {
+	Script string
+	// Compressed is set for tools that gzip their own output.
+	Compressed bool
+	Extension  string
+}
+
+var dumps = map[string]dump{
+	models.DatabaseTypePostgreSQL: {
+		Script:    `read -r PGPASSWORD; export PGPASSWORD; exec pg_dump --clean --if-exists --no-owner -U "$1" -d "$2"`,
+		Extension: "sql.gz",
+	},
+	models.DatabaseTypeMySQL: {
+		Script:    `read -r MYSQL_PWD; export MYSQL_PWD; exec mysqldump --single-transaction --routines --triggers --no-tablespaces -u "$1" "$2"`,
+		Extension: "sql.gz",
+	},
+	models.DatabaseTypeMariaDB: {
+		Script:    `read -r MYSQL_PWD; export MYSQL_PWD; exec mariadb-dump --single-transaction --routines --triggers --no-tablespaces -u "$1" "$2"`,
+		Extension: "sql.gz",
+	},
+	models.DatabaseTypeMongoDB: {
+		Script:     `read -r password; exec mongodump --quiet --archive --gzip --username "$1" --password "$password" --authenticationDatabase admin --db "$2"`,
+		Compressed: true,
+		Extension:  "archive.gz",
+	},
+	// Redis has a single dataset, copied as an RDB snapshot.
+	models.DatabaseTypeRedis: {
+		Script:    `read -r REDISCLI_AUTH; export REDISCLI_AUTH; redis-cli --rdb /tmp/mo-sh-backup.rdb >/dev/null && cat /tmp/mo-sh-backup.rdb; status=$?; rm -f /tmp/mo-sh-backup.rdb; exit $status`,
+		Extension: "rdb.gz",
+	},
+}
+
+// DumpExtension is the file extension of a database type's compressed dumps.
+func DumpExtension(databaseType string) string {
+	return dumps[databaseType].Extension
+}
+
+// Dump streams a gzip-compressed dump of the running database to w.
+func Dump(sshClient *ssh.Client, database *models.Database, password string, w io.Writer) error {
+	d, ok := dumps[database.Type]
+	if !ok {
+		return fmt.Errorf("unsupported database type %q", database.Type)
+	}
+
+	databaseName := ""
+	if database.DatabaseName != nil {
+		databaseName = *database.DatabaseName
+	}
+
+	command := fmt.Sprintf(
+		"docker exec -i %s sh -c %s sh %s %s",
+		git.ShellQuote(ContainerName(database.ID)),
+		git.ShellQuote(d.Script),
+		git.ShellQuote(database.Username),
+		git.ShellQuote(databaseName),
+	)
+	if !d.Compressed {
+		command += " | gzip -c"
+	}
+
+	// pipefail makes a failed dump fail the command rather than leave a
+	// truncated archive.
+	script := "bash -c " + git.ShellQuote("set -o pipefail\n"+command)
+	if stderr, err := sshClient.RunWithOutput(script, strings.NewReader(password+"\n"), w); err != nil {
+		return fmt.Errorf("failed to dump the database: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
@@ -0,0 +1,174 @@
+// Package databases runs managed databases as containers on servers.
+package databases
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+)
+
+// Engine is how a database type runs in Docker.
+type Engine struct {
+	Image          string
+	DefaultVersion string
+	Port           int
+	// DataDir is where the image keeps its data, mounted from a volume.
+	DataDir string
+	Scheme  string
+	// Env is the container's environment, which creates the user and
+	// database on first start.
+	Env func(database *models.Database, password string) map[string]string
+	// Args are passed to the image's entrypoint, if any. They end up in
+	// the server's process list, so secrets go in Env.
+	Args []string
+}
+
+var engines = map[string]*Engine{
+	models.DatabaseTypePostgreSQL: {
+		Image:          "postgres",
+		DefaultVersion: "16",
+		Port:           5432,
+		DataDir:        "/var/lib/postgresql/data",
+		Scheme:         "postgres",
+		Env: func(database *models.Database, password string) map[string]string {
+			return map[string]string{
+				"POSTGRES_USER":     database.Username,
+				"POSTGRES_PASSWORD": password,
+				"POSTGRES_DB":       *database.DatabaseName,
+			}
+		},
+	},
+	models.DatabaseTypeMySQL: {
+		Image:          "mysql",
+		DefaultVersion: "8.4",
+		Port:           3306,
+		DataDir:        "/var/lib/mysql",
+		Scheme:         "mysql",
+		Env: func(database *models.Database, password string) map[string]string {
+			return map[string]string{
+				"MYSQL_USER":                 database.Username,
+				"MYSQL_PASSWORD":             password,
+				"MYSQL_DATABASE":             *database.DatabaseName,
+				"MYSQL_RANDOM_ROOT_PASSWORD": "yes",
+			}
+		},
+	},
+	models.DatabaseTypeMariaDB: {
+		Image:          "mariadb",
+		DefaultVersion: "11",
+		Port:           3306,
+		DataDir:        "/var/lib/mysql",
+		Scheme:         "mysql",
+		Env: func(database *models.Database, password string) map[string]string {
+			return map[string]string{
+				"MARIADB_USER":                 database.Username,
+				"MARIADB_PASSWORD":             password,
+				"MARIADB_DATABASE":             *database.DatabaseName,
+				"MARIADB_RANDOM_ROOT_PASSWORD": "yes",
+			}
+		},
+	},
+	models.DatabaseTypeRedis: {
+		Image:          "redis",
+		DefaultVersion: "7",
+		Port:           6379,
+		DataDir:        "/data",
+		Scheme:         "redis",
+		Env: func(database *models.Database, password string) map[string]string {
+			return map[string]string{"REDIS_PASSWORD": password}
+		},
+		// Redis only takes its password as an argument or from a config
+		// file, so one is written from the environment on each start.
+		Args: []string{
+			"sh", "-c",
+			`umask 077 && printf 'requirepass "%s"\n' "$REDIS_PASSWORD" > /tmp/mo-sh-redis.conf && exec redis-server /tmp/mo-sh-redis.conf --appendonly yes`,
+		},
+	},
+	models.DatabaseTypeMongoDB: {
+		Image:          "mongo",
+		DefaultVersion: "7",
+		Port:           27017,
+		DataDir:        "/data/db",
+		Scheme:         "mongodb",
+		Env: func(database *models.Database, password string) map[string]string {
+			return map[string]string{
+				"MONGO_INITDB_ROOT_USERNAME": database.Username,
+				"MONGO_INITDB_ROOT_PASSWORD": password,
+				"MONGO_INITDB_DATABASE":      *database.DatabaseName,
+			}
+		},
+	},
+}
+
+// EngineFor returns the engine for a database type.
+func EngineFor(databaseType string) (*Engine, bool) {
+	engine, ok := engines[databaseType]
+	return engine, ok
+}
+
+var versionPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// ValidVersion reports whether version can be used as an image tag.
+func ValidVersion(version string) bool {
+	return versionPattern.MatchString(version)
+}
+
+// ContainerName is the container a database runs in, and its host name on
+// the Mo-SH network.
+func ContainerName(databaseID string) string {
+	return "mo-sh-db-" + databaseID
+}
+
+// VolumeName is the volume holding a database's data.
+func VolumeName(databaseID string) string {
+	return ContainerName(databaseID) + "-data"
+}
+
+// Connection builds the connection details of database. serverHost is where
+// its public port, if any, is reached.
+func Connection(database *models.Database, password, serverHost string) *models.DatabaseConnection {
+	engine := engines[database.Type]
+	host := ContainerName(database.ID)
+
+	connection := &models.DatabaseConnection{
+		Host:        host,
+		Port:        engine.Port,
+		Password:    password,
+		InternalURL: connectionURL(engine, database, password, fmt.Sprintf("%s:%d", host, engine.Port)),
+	}
+	if database.PublicPort != nil {
+		publicURL := connectionURL(engine, database, password, fmt.Sprintf("%s:%d", serverHost, *database.PublicPort))
+		connection.PublicURL = &publicURL
+	}
+	return connection
+}
+
+func connectionURL(engine *Engine, database *models.Database, password, host string) string {
+	u := url.URL{
+		Scheme: engine.Scheme,
+		User:   url.UserPassword(database.Username, password),
+		Host:   host,
+	}
+
+	switch database.Type {
+	case models.DatabaseTypeRedis:
+		u.Path = "/0"
+	case models.DatabaseTypeMongoDB:
+		u.Path = "/" + *database.DatabaseName
+		u.RawQuery = "authSource=admin"
+	default:
+		u.Path = "/" + *database.DatabaseName
+	}
+
+	return u.String()
+}
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]{0,62}$`)
+
+// ValidDatabaseName reports whether name can be used for the database
+// created inside an instance.
+func ValidDatabaseName(name string) bool {
+	return namePattern.MatchString(name)
+}
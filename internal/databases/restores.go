@@ -0,0 +1,113 @@
+package databases
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+)
+
+// restores are how dumps made by Dump are loaded back, with the same
+// conventions: the password on the first line of stdin, the username and
+// database name as $1 and $2. Redis is restored by replacing its data files
+// instead.
+var restores = map[string]string{
+	models.DatabaseTypePostgreSQL: `read -r PGPASSWORD; export PGPASSWORD; exec psql -q -v ON_ERROR_STOP=1 -U "$1" -d "$2"`,
+	models.DatabaseTypeMySQL:      `read -r MYSQL_PWD; export MYSQL_PWD; exec mysql -u "$1" "$2"`,
+	models.DatabaseTypeMariaDB:    `read -r MYSQL_PWD; export MYSQL_PWD; exec mariadb -u "$1" "$2"`,
+	models.DatabaseTypeMongoDB:    `read -r password; exec mongorestore --archive --gzip --drop --username "$1" --password "$password" --authenticationDatabase admin`,
+}
+
+// UploadDump copies a dump read from r to a private temp file on the server
+// and returns its path and the SHA-256 of what arrived, so the dump can be
+// verified before anything is restored. The caller removes the file.
+func UploadDump(sshClient *ssh.Client, r io.Reader) (string, string, error) {
+	command := `f=$(mktemp -p /var/tmp mo-sh-dump.XXXXXX) && { cat > "$f" || { rm -f "$f"; exit 1; }; } && echo "$f" && sha256sum < "$f"`
+
+	var stdout strings.Builder
+	if stderr, err := sshClient.RunWithOutput(command, r, &stdout); err != nil {
+		return "", "", fmt.Errorf("failed to upload the dump: %w: %s", err, strings.TrimSpace(stderr))
+	}
+
+	fields := strings.Fields(stdout.String())
+	if len(fields) < 2 {
+		return "", "", fmt.Errorf("failed to upload the dump: unexpected output %q", stdout.String())
+	}
+	return fields[0], fields[1], nil
+}
+
+// Restore loads a dump made by Dump, stored on the server at dumpPath, into
+// the running database, replacing the data the dump covers. Output goes to
+// logf line by line.
+func Restore(sshClient *ssh.Client, database *models.Database, password, dumpPath string, logf func(line string)) error {
+	if database.Type == models.DatabaseTypeRedis {
+		return restoreRedis(sshClient, database, password, dumpPath, logf)
+	}
+
+	script, ok := restores[database.Type]
+	if !ok {
+		return fmt.Errorf("unsupported database type %q", database.Type)
+	}
+
+	databaseName := ""
+	if database.DatabaseName != nil {
+		databaseName = *database.DatabaseName
+	}
+
+	command := fmt.Sprintf(
+		"docker exec -i %s sh -c %s sh %s %s",
+		git.ShellQuote(ContainerName(database.ID)),
+		git.ShellQuote(script),
+		git.ShellQuote(database.Username),
+		git.ShellQuote(databaseName),
+	)
+
+	// The password line is passed through as is and the rest decompressed,
+	// except for archives the restore tool decompresses itself.
+	input := "gunzip -c"
+	if dumps[database.Type].Compressed {
+		input = "cat"
+	}
+	command = fmt.Sprintf("{ IFS= read -r password && printf '%%s\\n' \"$password\" && %s < %s; } | %s", input, git.ShellQuote(dumpPath), command)
+
+	stdin := strings.NewReader(password + "\n")
+	if err := sshClient.ExecuteWithStdin("bash -c "+git.ShellQuote("set -o pipefail\n"+command), stdin, logf, logf); err != nil {
+		return fmt.Errorf("failed to restore the database: %w", err)
+	}
+	return nil
+}
+
+// restoreRedis swaps the RDB snapshot into the stopped container's volume.
+// The snapshot is decompressed in full before anything is replaced, and the
+// append-only files are removed so that Redis starts from it.
+func restoreRedis(sshClient *ssh.Client, database *models.Database, password, dumpPath string, logf func(line string)) error {
+	engine := engines[database.Type]
+
+	logf("Stopping the database.")
+	if err := Stop(sshClient, database); err != nil {
+		return err
+	}
+
+	command := fmt.Sprintf(
+		"docker run --rm -i -v %s --entrypoint sh %s -c %s < %s",
+		git.ShellQuote(VolumeName(database.ID)+":/data"),
+		git.ShellQuote(engine.Image+":"+database.Version),
+		git.ShellQuote("gunzip -c > /data/restore.rdb && rm -rf /data/appendonlydir && mv /data/restore.rdb /data/dump.rdb || { rm -f /data/restore.rdb; exit 1; }"),
+		git.ShellQuote(dumpPath),
+	)
+	logf("Replacing the data files.")
+	err := sshClient.ExecuteWithStreams(command, logf, logf)
+
+	// Start the database again either way; on failure it has its old data.
+	logf("Starting the database.")
+	if startErr := Start(sshClient, database, password); startErr != nil && err == nil {
+		err = startErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to restore the database: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,461 @@
+package deploy
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/volumes"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+)
+
+// composeOverrideFile is written next to the compose file and layered on top
+// of it to add Mo-SH's env vars and labels to every service.
+const composeOverrideFile = "docker-compose.mo-sh.yml"
+
+// ComposeDownCommand stops and removes a compose project.
+func ComposeDownCommand(project string) string {
+	return "docker compose -p " + git.ShellQuote(project) + " down --remove-orphans"
+}
+
+// RemoveApplicationCommand removes every container Mo-SH started for an
+// application, previews included, taking down their compose projects first
+// so networks go with them, and then its named volumes. Bind mounted
+// directories are left on the server.
+func RemoveApplicationCommand(applicationID string) string {
+	filter := git.ShellQuote("label=mo-sh.application=" + applicationID)
+	return fmt.Sprintf(`docker ps -a --filter %[1]s --format '{{.Label "com.docker.compose.project"}}' | sort -u | while read -r project; do
+	[ -n "$project" ] && docker compose -p "$project" down --remove-orphans
+done
+docker ps -aq --filter %[1]s | xargs -r docker rm -f >/dev/null
+docker volume ls -q --filter %[1]s | xargs -r docker volume rm >/dev/null
+`, filter)
+}
+
+func (r *Runner) deployCompose(j *job) error {
+	rollback := j.Deployment.RollbackOf != nil
+	directory := path.Join(j.WorkDir, j.Config.BaseDirectory)
+	composeFile := path.Join(directory, j.Config.ComposePath)
+
+	if j.Config.ComposeFile != "" {
+		composeFile = path.Join(directory, "docker-compose.mo-sh-inline.yml")
+		j.Logger.System("Uploading compose file.")
+		if err := r.upload(j, composeFile, []byte(j.Config.ComposeFile)); err != nil {
+			return fmt.Errorf("failed to upload compose file: %w", err)
+		}
+	} else {
+		// Keep the file as deployed, for rollbacks.
+		content, stderr, err := j.SSH.RunCommand("cat " + git.ShellQuote(composeFile))
+		if err != nil {
+			j.Logger.Error(stderr)
+			return fmt.Errorf("compose file %s not found: %w", j.Config.ComposePath, err)
+		}
+		j.Config.ComposeFile = content
+	}
+
+	// The project name stays the same across deployments so each one updates
+	// the same containers in place.
+	project := git.ShellQuote(j.name())
+	compose := "docker compose -p " + project + " --project-directory " + git.ShellQuote(directory) + " -f " + git.ShellQuote(composeFile)
+
+	stdout, stderr, err := j.SSH.RunCommand(compose + " config --format json")
+	if err != nil {
+		j.Logger.Error(stderr)
+		return fmt.Errorf("invalid compose file: %w", err)
+	}
+	var config struct {
+		Services map[string]struct {
+			Build       any    `json:"build"`
+			NetworkMode string `json:"network_mode"`
+		} `json:"services"`
+	}
+	if err := json.Unmarshal([]byte(stdout), &config); err != nil {
+		return fmt.Errorf("invalid compose file: %w", err)
+	}
+	if len(config.Services) == 0 {
+		return errors.New("compose file defines no services")
+	}
+
+	var services []string
+	built := map[string]bool{}
+	// Services also join the Mo-SH network under a stable alias, for the
+	// proxy, unless they set their own network mode.
+	aliases := map[string]string{}
+	for service, definition := range config.Services {
+		services = append(services, service)
+		built[service] = definition.Build != nil
+		if definition.NetworkMode == "" {
+			aliases[service] = ServiceAlias(j.name(), service)
+		}
+	}
+	sort.Strings(services)
+
+	mounts := map[string][]models.Volume{}
+	for _, volume := range j.Volumes {
+		if volume.Service == nil {
+			return fmt.Errorf("volume %s names no service to mount into", volume.Name)
+		}
+		if _, ok := config.Services[*volume.Service]; !ok {
+			return fmt.Errorf("volume %s is mounted into %s, which the compose file doesn't define", volume.Name, *volume.Service)
+		}
+		mounts[*volume.Service] = append(mounts[*volume.Service], volume)
+	}
+
+	if err := r.ensureNetwork(j); err != nil {
+		return err
+	}
+	if err := volumes.Ensure(j.SSH, j.Volumes); err != nil {
+		return err
+	}
+
+	// Rollbacks run the images kept from the original deployment.
+	var images map[string]string
+	if rollback {
+		images = j.Config.Images
+		for service := range built {
+			if built[service] && images[service] == "" {
+				return fmt.Errorf("no image was kept for service %s", service)
+			}
+		}
+	}
+
+	content, err := composeOverride(&override{
+		Services:  services,
+		Env:       managedEnv(j),
+		Inherited: sortedKeys(j.RuntimeEnv),
+		BuildArgs: sortedKeys(j.BuildEnv),
+		Built:     built,
+		Labels:    managedLabels(j),
+		Images:    images,
+		Aliases:   aliases,
+		Mounts:    mounts,
+		DropPorts: j.Preview != nil,
+	})
+	if err != nil {
+		return err
+	}
+	overrideFile := path.Join(directory, composeOverrideFile)
+	if err := r.upload(j, overrideFile, content); err != nil {
+		return fmt.Errorf("failed to upload compose override: %w", err)
+	}
+	compose += " -f " + git.ShellQuote(overrideFile)
+
+	up := " up -d --build --remove-orphans"
+	if rollback {
+		up = " up -d --no-build --remove-orphans"
+	}
+
+	// A variable can be both; at build time its runtime value is the same.
+	env := map[string]string{}
+	for key, value := range j.BuildEnv {
+		env[key] = value
+	}
+	for key, value := range j.RuntimeEnv {
+		env[key] = value
+	}
+	source, err := r.sourceEnv(j, "compose", env)
+	if err != nil {
+		return err
+	}
+
+	j.Logger.System("Starting services %s.", strings.Join(services, ", "))
+	if err := r.stream(j, source+compose+up, nil); err != nil {
+		return fmt.Errorf("docker compose up failed: %w", err)
+	}
+
+	states, err := r.serviceStates(j, compose)
+	if err != nil {
+		return fmt.Errorf("failed to read service states: %w", err)
+	}
+
+	if !rollback {
+		if err := r.keepComposeImages(j, states, built); err != nil {
+			return err
+		}
+	}
+	if _, err := r.DB.ExecContext(r.Ctx, "update deployments set services = $1 where id = $2", states, j.Deployment.ID); err != nil {
+		return fmt.Errorf("failed to record service states: %w", err)
+	}
+
+	var unhealthy []string
+	for _, state := range states {
+		status := state.Status
+		if state.Health != "" {
+			status += ", " + state.Health
+		}
+		j.Logger.System("%s: %s (%s)", state.Service, state.State, status)
+
+		if serviceFailed(state) {
+			unhealthy = append(unhealthy, state.Service)
+		}
+	}
+	if len(unhealthy) > 0 {
+		return fmt.Errorf("services not running: %s", strings.Join(unhealthy, ", "))
+	}
+
+	return nil
+}
+
+// serviceStates lists the project's containers with `docker compose ps`.
+// Compose prints one JSON object per line, while releases before 2.21 print a
+// single array.
+func (r *Runner) serviceStates(j *job, compose string) (models.Services, error) {
+	stdout, stderr, err := j.SSH.RunCommand(compose + " ps -a --format json")
+	if err != nil {
+		j.Logger.Error(stderr)
+		return nil, err
+	}
+
+	type container struct {
+		Name     string `json:"Name"`
+		Service  string `json:"Service"`
+		Image    string `json:"Image"`
+		State    string `json:"State"`
+		Status   string `json:"Status"`
+		Health   string `json:"Health"`
+		ExitCode int    `json:"ExitCode"`
+	}
+
+	var containers []container
+	stdout = strings.TrimSpace(stdout)
+	if strings.HasPrefix(stdout, "[") {
+		if err := json.Unmarshal([]byte(stdout), &containers); err != nil {
+			return nil, err
+		}
+	} else {
+		for _, line := range strings.Split(stdout, "\n") {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			var c container
+			if err := json.Unmarshal([]byte(line), &c); err != nil {
+				return nil, err
+			}
+			containers = append(containers, c)
+		}
+	}
+
+	states := models.Services{}
+	for _, c := range containers {
+		states = append(states, models.ServiceState{
+			Service:   c.Service,
+			Container: c.Name,
+			Image:     c.Image,
+			State:     c.State,
+			Status:    c.Status,
+			Health:    c.Health,
+			ExitCode:  c.ExitCode,
+		})
+	}
+	sort.Slice(states, func(a, b int) bool { return states[a].Container < states[b].Container })
+
+	return states, nil
+}
+
+// serviceFailed reports whether a service didn't come up. One-off services
+// that exited cleanly, such as migrations, count as fine.
+func serviceFailed(state models.ServiceState) bool {
+	switch state.State {
+	case "running":
+		return state.Health == "unhealthy"
+	case "exited":
+		return state.ExitCode != 0
+	default:
+		return true
+	}
+}
+
+// keepComposeImages tags the images built for this deployment under the
+// application's repository, so later builds don't replace them and they can
+// be rolled back to.
+func (r *Runner) keepComposeImages(j *job, states models.Services, built map[string]bool) error {
+	images := map[string]string{}
+	for _, state := range states {
+		if !built[state.Service] || images[state.Service] != "" {
+			continue
+		}
+
+		image := ComposeImageRepository(j.Application.ID, state.Service) + ":" + j.Deployment.ID
+		if _, stderr, err := j.SSH.RunCommand("docker tag " + git.ShellQuote(state.Image) + " " + git.ShellQuote(image)); err != nil {
+			j.Logger.Error(stderr)
+			return fmt.Errorf("failed to tag image for service %s: %w", state.Service, err)
+		}
+		images[state.Service] = image
+	}
+
+	j.Config.Images = images
+	return nil
+}
+
+// ComposeImageRepository is where the image built for a compose service is
+// kept. Docker only allows lowercase repository names.
+func ComposeImageRepository(applicationID, service string) string {
+	return ImageRepository(applicationID) + "-" + strings.ToLower(service)
+}
+
+// ServiceAlias is a compose service's name on the Mo-SH network. Plain
+// service names would clash between projects sharing the network.
+func ServiceAlias(project, service string) string {
+	return project + "-" + service
+}
+
+var servicePattern = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+
+// ValidServiceName reports whether service can be a compose service's name.
+func ValidServiceName(service string) bool {
+	return servicePattern.MatchString(service)
+}
+
+// override is what Mo-SH layers on top of a compose file.
+type override struct {
+	Services []string
+	Env      map[string]string
+	// Inherited names variables services take from compose's environment,
+	// and BuildArgs those passed to services Mo-SH builds.
+	Inherited []string
+	BuildArgs []string
+	Built     map[string]bool
+	Labels    map[string]string
+	// Images pins services to an image, for rollbacks.
+	Images  map[string]string
+	Aliases map[string]string
+	// Mounts are the volumes mounted into each service, which the compose
+	// file sees as external.
+	Mounts map[string][]models.Volume
+	// DropPorts removes published ports, which previews can't take from the
+	// application itself; `!reset` needs Compose 2.24 or later.
+	DropPorts bool
+}
+
+// composeOverride builds an override file adding env, labels and network
+// aliases to each service, and pinning images when given. Values are written
+// as JSON, which is valid YAML. Inherited variables and build args are
+// written without values, which compose reads from its own environment.
+func composeOverride(o *override) ([]byte, error) {
+	env := map[string]*string{}
+	for key, value := range o.Env {
+		env[key] = &value
+	}
+	for _, key := range o.Inherited {
+		env[key] = nil
+	}
+	envJSON, err := json.Marshal(env)
+	if err != nil {
+		return nil, err
+	}
+	labelsJSON, err := json.Marshal(o.Labels)
+	if err != nil {
+		return nil, err
+	}
+	buildArgs := map[string]*string{}
+	for _, key := range o.BuildArgs {
+		buildArgs[key] = nil
+	}
+	buildArgsJSON, err := json.Marshal(buildArgs)
+	if err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	b.WriteString("services:\n")
+	for _, service := range o.Services {
+		name, err := json.Marshal(service)
+		if err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "  %s:\n", name)
+		fmt.Fprintf(&b, "    environment: %s\n", envJSON)
+		fmt.Fprintf(&b, "    labels: %s\n", labelsJSON)
+		if image, ok := o.Images[service]; ok {
+			imageJSON, err := json.Marshal(image)
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "    image: %s\n", imageJSON)
+		}
+		if o.Built[service] && len(o.BuildArgs) > 0 {
+			fmt.Fprintf(&b, "    build:\n      args: %s\n", buildArgsJSON)
+		}
+		if o.DropPorts {
+			b.WriteString("    ports: !reset []\n")
+		}
+		if mounts := o.Mounts[service]; len(mounts) > 0 {
+			mountsJSON, err := json.Marshal(composeMounts(mounts))
+			if err != nil {
+				return nil, err
+			}
+			fmt.Fprintf(&b, "    volumes: %s\n", mountsJSON)
+		}
+		if alias, ok := o.Aliases[service]; ok {
+			aliasJSON, err := json.Marshal([]string{alias})
+			if err != nil {
+				return nil, err
+			}
+			b.WriteString("    networks:\n")
+			b.WriteString("      default: {}\n")
+			fmt.Fprintf(&b, "      %q:\n", Network)
+			fmt.Fprintf(&b, "        aliases: %s\n", aliasJSON)
+		}
+	}
+	if len(o.Aliases) > 0 {
+		fmt.Fprintf(&b, "networks:\n  %q:\n    external: true\n", Network)
+	}
+	var named []string
+	for _, mounts := range o.Mounts {
+		for _, volume := range mounts {
+			if volume.Type == models.VolumeTypeVolume {
+				named = append(named, volumes.Name(volume.ID))
+			}
+		}
+	}
+	if len(named) > 0 {
+		sort.Strings(named)
+		b.WriteString("volumes:\n")
+		for _, name := range named {
+			fmt.Fprintf(&b, "  %q:\n    external: true\n", name)
+		}
+	}
+
+	return []byte(b.String()), nil
+}
+
+// composeMount is a volume in compose's long syntax.
+type composeMount struct {
+	Type     string `json:"type"`
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	ReadOnly bool   `json:"read_only,omitempty"`
+}
+
+func composeMounts(mounts []models.Volume) []composeMount {
+	result := make([]composeMount, 0, len(mounts))
+	for _, volume := range mounts {
+		mount := composeMount{
+			Type:     volume.Type,
+			Source:   volumes.Name(volume.ID),
+			Target:   volume.MountPath,
+			ReadOnly: volume.ReadOnly,
+		}
+		if volume.Type == models.VolumeTypeBind {
+			mount.Source = *volume.HostPath
+		}
+		result = append(result, mount)
+	}
+	return result
+}
+
+// upload writes content to file on the server.
+func (r *Runner) upload(j *job, file string, content []byte) error {
+	script := fmt.Sprintf("mkdir -p %s && cat > %s", git.ShellQuote(path.Dir(file)), git.ShellQuote(file))
+	stderr, err := j.SSH.RunWithStdin(script, bytes.NewReader(content))
+	if err != nil && strings.TrimSpace(stderr) != "" {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+	}
+	return err
+}
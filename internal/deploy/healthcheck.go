@@ -0,0 +1,142 @@
+package deploy
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+)
+
+const (
+	defaultHealthCheckInterval = 5
+	defaultHealthCheckTimeout  = 5
+	defaultHealthCheckRetries  = 10
+
+	// Without a health check, a container only has to stay up this long.
+	minimumUptime = 5 * time.Second
+
+	// Lines of container output logged when a container doesn't come up.
+	containerLogTail = 50
+)
+
+// waitHealthy waits for container to pass the deployment's health check,
+// logging every attempt's output.
+func (r *Runner) waitHealthy(j *job, container string) error {
+	check := j.Config.HealthCheck
+	if check == nil {
+		j.Logger.System("Waiting for the container to stay up.")
+		time.Sleep(minimumUptime)
+		if !r.containerRunning(j, container) {
+			r.logContainerOutput(j, container)
+			return errors.New("the container exited")
+		}
+		return nil
+	}
+
+	interval := orDefault(check.Interval, defaultHealthCheckInterval)
+	timeout := orDefault(check.Timeout, defaultHealthCheckTimeout)
+	retries := orDefault(check.Retries, defaultHealthCheckRetries)
+
+	probe, err := healthProbe(check, container, timeout, j.Config.Ports)
+	if err != nil {
+		return err
+	}
+
+	if check.StartPeriod > 0 {
+		j.Logger.System("Waiting %ds before the first health check.", check.StartPeriod)
+		time.Sleep(time.Duration(check.StartPeriod) * time.Second)
+	}
+
+	for attempt := 1; attempt <= retries; attempt++ {
+		if !r.containerRunning(j, container) {
+			r.logContainerOutput(j, container)
+			return errors.New("the container exited before it was healthy")
+		}
+
+		stdout, stderr, err := j.SSH.RunCommand(probe)
+		output := strings.TrimSpace(strings.TrimSpace(stdout) + " " + strings.TrimSpace(stderr))
+		healthy := err == nil
+		if check.Type == models.HealthCheckHTTP {
+			code, _ := strconv.Atoi(strings.TrimSpace(stdout))
+			healthy = healthy && code >= 200 && code < 400
+			output = "HTTP " + output
+		}
+
+		if healthy {
+			j.Logger.System("Health check passed (attempt %d/%d): %s", attempt, retries, output)
+			return nil
+		}
+		j.Logger.Error(fmt.Sprintf("Health check failed (attempt %d/%d): %s", attempt, retries, output))
+
+		if attempt < retries {
+			time.Sleep(time.Duration(interval) * time.Second)
+		}
+	}
+
+	r.logContainerOutput(j, container)
+	return fmt.Errorf("health check failed after %d attempts", retries)
+}
+
+// healthProbe builds the script for one health check attempt. HTTP and TCP
+// checks run on the host against the container's address on the Mo-SH
+// network; command checks run inside the container.
+func healthProbe(check *models.HealthCheck, container string, timeout int, ports models.Ports) (string, error) {
+	quoted := git.ShellQuote(container)
+
+	if check.Type == models.HealthCheckCommand {
+		if strings.TrimSpace(check.Command) == "" {
+			return "", errors.New("the health check has no command")
+		}
+		return fmt.Sprintf("timeout %d docker exec %s sh -c %s 2>&1", timeout, quoted, git.ShellQuote(check.Command)), nil
+	}
+
+	port := check.Port
+	if port == 0 && len(ports) > 0 {
+		port = ports[0].ContainerPort
+	}
+	if port == 0 {
+		return "", errors.New("the health check needs a port")
+	}
+
+	address := fmt.Sprintf(`ip=$(docker inspect -f '{{(index .NetworkSettings.Networks %q).IPAddress}}' %s) || exit 1`, Network, quoted)
+
+	switch check.Type {
+	case models.HealthCheckHTTP:
+		path := check.Path
+		if !strings.HasPrefix(path, "/") {
+			path = "/" + path
+		}
+		return fmt.Sprintf(
+			"%s\ncurl -sS -o /dev/null -w '%%{http_code}' --max-time %d \"http://$ip:%d\"%s",
+			address, timeout, port, git.ShellQuote(path),
+		), nil
+	case models.HealthCheckTCP:
+		return fmt.Sprintf(
+			"%s\ntimeout %d bash -c \"</dev/tcp/$ip/%d\" && echo \"port %d is open\"",
+			address, timeout, port, port,
+		), nil
+	default:
+		return "", fmt.Errorf("unknown health check type %q", check.Type)
+	}
+}
+
+func (r *Runner) containerRunning(j *job, container string) bool {
+	stdout, _, err := j.SSH.RunCommand("docker inspect -f '{{.State.Running}}' " + git.ShellQuote(container))
+	return err == nil && strings.TrimSpace(stdout) == "true"
+}
+
+func (r *Runner) logContainerOutput(j *job, container string) {
+	j.Logger.System("Last output of %s:", container)
+	r.stream(j, fmt.Sprintf("docker logs --tail %d %s", containerLogTail, git.ShellQuote(container)), nil)
+}
+
+func orDefault(value, fallback int) int {
+	if value <= 0 {
+		return fallback
+	}
+	return value
+}
@@ -0,0 +1,62 @@
+package deploy
+
+import (
+	"log"
+	"strings"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+)
+
+// pruneImages removes the application's images except those of its latest
+// deployments, as many as its image retention allows, so rollbacks stay
+// possible without filling the disk. Failures are only logged.
+func (r *Runner) pruneImages(j *job) {
+	// A rollback runs an older deployment's images, which are tagged with
+	// that deployment's id.
+	var keep []string
+	query := `
+		select coalesce(rollback_of, id) from deployments
+		where application_id = $1 and preview_id is null and status = $2 and id <> $3
+		order by finished_at desc
+		limit $4
+	`
+	if err := r.DB.SelectContext(r.Ctx, &keep, query, j.Application.ID, models.DeploymentSucceeded, j.Deployment.ID, j.Application.ImageRetention); err != nil {
+		log.Println("deploy.Runner: failed to list retained deployments", j.Deployment.ID, err)
+		return
+	}
+
+	kept := map[string]bool{j.Deployment.ID: true}
+	if j.Deployment.RollbackOf != nil {
+		kept[*j.Deployment.RollbackOf] = true
+	}
+	for _, id := range keep {
+		kept[id] = true
+	}
+
+	stdout, _, err := j.SSH.RunCommand("docker images --format '{{.Repository}}:{{.Tag}}'")
+	if err != nil {
+		log.Println("deploy.Runner: failed to list images", j.Deployment.ID, err)
+		return
+	}
+
+	repository := ImageRepository(j.Application.ID)
+	var remove []string
+	for _, image := range strings.Fields(stdout) {
+		name, tag, ok := strings.Cut(image, ":")
+		if !ok || (name != repository && !strings.HasPrefix(name, repository+"-")) {
+			continue
+		}
+		if !kept[tag] {
+			remove = append(remove, git.ShellQuote(image))
+		}
+	}
+	if len(remove) == 0 {
+		return
+	}
+
+	j.Logger.System("Removing %d old images.", len(remove))
+	// Images still used by a container, such as a preview's, fail to remove
+	// and are kept.
+	j.SSH.RunCommand("docker rmi " + strings.Join(remove, " ") + " >/dev/null 2>&1")
+}
@@ -0,0 +1,164 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+)
+
+const logFlushInterval = time.Second
+
+// Logger records a deployment's log lines. Lines are buffered and written in
+// batches so chatty builds don't turn into one insert per line.
+type Logger struct {
+	DB  *sqlx.DB
+	Ctx context.Context
+	// OwnerID is the deployment, or other job, the lines belong to.
+	OwnerID string
+
+	insert    string
+	mu        sync.Mutex
+	buf       []logLine
+	redacted  []string
+	done      chan struct{}
+	flushDone chan struct{}
+}
+
+type logLine struct {
+	OwnerID string         `db:"owner_id"`
+	Type    models.LogType `db:"type"`
+	Content string         `db:"content"`
+}
+
+func NewLogger(db *sqlx.DB, ctx context.Context, deploymentID string) *Logger {
+	return NewTableLogger(db, ctx, "deployment_logs", "deployment_id", deploymentID)
+}
+
+// NewTableLogger records log lines for other jobs, such as restores, in
+// table, which has the same type and content columns as deployment_logs and
+// refers to the job in column.
+func NewTableLogger(db *sqlx.DB, ctx context.Context, table, column, ownerID string) *Logger {
+	l := &Logger{
+		DB:        db,
+		Ctx:       ctx,
+		OwnerID:   ownerID,
+		insert:    fmt.Sprintf("insert into %s (%s, type, content) values (:owner_id, :type, :content)", table, column),
+		done:      make(chan struct{}),
+		flushDone: make(chan struct{}),
+	}
+	go l.flushLoop()
+	return l
+}
+
+// Redact masks values, such as tokens in clone URLs and secrets, wherever
+// they show up in later lines. Output is logged line by line, so each line of
+// a multi-line value, such as a private key, is masked on its own too.
+func (l *Logger) Redact(values ...string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for _, value := range values {
+		l.addRedacted(value)
+		if !strings.Contains(value, "\n") {
+			continue
+		}
+		for _, line := range strings.Split(value, "\n") {
+			l.addRedacted(strings.TrimSpace(line))
+		}
+	}
+}
+
+func (l *Logger) addRedacted(value string) {
+	// Very short values would mask unrelated output.
+	if len(value) >= 4 {
+		l.redacted = append(l.redacted, value)
+	}
+}
+
+// RedactError masks redacted values in err's message, for errors stored
+// outside the log. It returns nil for a nil err.
+func (l *Logger) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	return errors.New(l.mask(err.Error()))
+}
+
+func (l *Logger) mask(line string) string {
+	for _, value := range l.redacted {
+		line = strings.ReplaceAll(line, value, "********")
+	}
+	return line
+}
+
+func (l *Logger) Info(line string) {
+	l.append(models.LogTypeInfo, line)
+}
+
+func (l *Logger) Error(line string) {
+	l.append(models.LogTypeError, line)
+}
+
+func (l *Logger) System(format string, args ...any) {
+	l.append(models.LogTypeSystem, fmt.Sprintf(format, args...))
+}
+
+// Close writes out whatever is still buffered.
+func (l *Logger) Close() {
+	close(l.done)
+	<-l.flushDone
+}
+
+func (l *Logger) append(logType models.LogType, line string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.buf = append(l.buf, logLine{
+		OwnerID: l.OwnerID,
+		Type:    logType,
+		Content: l.mask(line),
+	})
+}
+
+func (l *Logger) flushLoop() {
+	defer close(l.flushDone)
+
+	ticker := time.NewTicker(logFlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			l.flush()
+		case <-l.done:
+			l.flush()
+			return
+		}
+	}
+}
+
+func (l *Logger) flush() {
+	l.mu.Lock()
+	logs := l.buf
+	l.buf = nil
+	l.mu.Unlock()
+
+	if len(logs) == 0 {
+		return
+	}
+
+	if _, err := l.DB.NamedExecContext(l.Ctx, l.insert, logs); err != nil {
+		log.Println("deploy.Logger: failed to write logs", l.OwnerID, err)
+	}
+}
@@ -0,0 +1,156 @@
+package deploy
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/sources"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+	"github.com/redis/go-redis/v9"
+)
+
+// setCommitStatus reports the deployment next to its commit, on providers
+// that support it. Failures are only logged; the deployment goes on.
+func (r *Runner) setCommitStatus(j *job, state, description string) {
+	reporter, ok := j.Provider.(sources.StatusReporter)
+	if !ok || j.Deployment.CommitSHA == nil {
+		return
+	}
+
+	status := &sources.CommitStatus{
+		State:       state,
+		Context:     "Mo-SH / " + j.Application.Name,
+		Description: description,
+	}
+	if j.Preview != nil {
+		status.Context += " (preview)"
+	}
+	if instanceSettings, err := r.Settings.Get(); err == nil && instanceSettings.UIURL != "" {
+		status.TargetURL = fmt.Sprintf("%s/applications/%s/deployments/%s", instanceSettings.UIURL, j.Application.ID, j.Deployment.ID)
+	}
+
+	if err := reporter.SetCommitStatus(j.Application.RepositoryID, *j.Deployment.CommitSHA, status); err != nil {
+		log.Println("deploy.Runner: failed to set commit status", j.Deployment.ID, err)
+	}
+}
+
+// report sends the outcome back to the provider: a commit status, and for
+// previews a pull request comment with the preview URL.
+func (r *Runner) report(j *job, deployErr error) {
+	if j.Provider == nil {
+		return
+	}
+
+	if deployErr != nil {
+		r.setCommitStatus(j, sources.CommitStatusFailure, "Deployment failed")
+	} else {
+		r.setCommitStatus(j, sources.CommitStatusSuccess, "Deployed")
+	}
+
+	if j.Preview == nil {
+		return
+	}
+
+	body := fmt.Sprintf("**Preview for %s**\n\n", j.Application.Name)
+	if deployErr != nil {
+		body += "The latest preview deployment failed."
+	} else {
+		body += fmt.Sprintf("Deployed to https://%s", j.Preview.Domain)
+	}
+	if j.Deployment.CommitSHA != nil {
+		body += fmt.Sprintf(" (commit %s)", *j.Deployment.CommitSHA)
+	}
+	body += "."
+
+	commentOnPreview(r.DB, r.Ctx, j.Provider, j.Application, j.Preview, body)
+}
+
+func commentOnPreview(db *sqlx.DB, ctx context.Context, provider sources.Provider, application *models.Application, preview *models.Preview, body string) {
+	reporter, ok := provider.(sources.StatusReporter)
+	if !ok {
+		return
+	}
+
+	var commentID string
+	if preview.CommentID != nil {
+		commentID = *preview.CommentID
+	}
+
+	commentID, err := reporter.CommentOnPullRequest(application.RepositoryID, preview.PullRequestNumber, commentID, body)
+	if err != nil {
+		log.Println("deploy: failed to comment on pull request", preview.ID, err)
+		return
+	}
+
+	if _, err := db.ExecContext(ctx, "update previews set comment_id = $1 where id = $2", commentID, preview.ID); err != nil {
+		log.Println("deploy: failed to save preview comment", preview.ID, err)
+	}
+}
+
+// previewClosed reports whether the job's pull request was closed while it
+// was deploying.
+func (r *Runner) previewClosed(j *job) bool {
+	var closed bool
+	if err := r.DB.QueryRowContext(r.Ctx, "select closed_at is not null from previews where id = $1", j.Preview.ID).Scan(&closed); err != nil {
+		// Deleted along with its application.
+		return true
+	}
+	return closed
+}
+
+// RemovePreview tears down a closed pull request's preview and says so on the
+// pull request. Deployments that were still running clean up after
+// themselves once they notice the preview is closed.
+func RemovePreview(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, previewID string) error {
+	var preview models.Preview
+	if err := db.GetContext(ctx, &preview, "update previews set closed_at = now(), updated_at = now() where id = $1 returning *", previewID); err != nil {
+		return err
+	}
+
+	var application models.Application
+	if err := db.GetContext(ctx, &application, "select * from applications where id = $1", preview.ApplicationID); err != nil {
+		return err
+	}
+
+	var server serverWithKey
+	query := `
+		select
+			s.*, k.key
+		from
+			servers s
+		inner join
+			keys k ON s.key_id = k.id
+		where
+			s.id = $1
+	`
+	if err := db.GetContext(ctx, &server, query, application.ServerID); err != nil {
+		return err
+	}
+
+	sshClient := ssh.NewClient(server.Hostname, server.Port, "root", []byte(server.Key))
+	if err := sshClient.Connect(); err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	if _, stderr, err := sshClient.RunCommand(removePreviewCommand(&application, &preview)); err != nil {
+		return fmt.Errorf("failed to remove preview: %w: %s", err, stderr)
+	}
+
+	if provider, err := sources.Load(db, redisClient, ctx, application.SourceID); err == nil {
+		commentOnPreview(db, ctx, provider, &application, &preview, fmt.Sprintf("**Preview for %s**\n\nThe preview was removed when the pull request closed.", application.Name))
+	}
+
+	return nil
+}
+
+func removePreviewCommand(application *models.Application, preview *models.Preview) string {
+	name := PreviewName(application.ID, preview.PullRequestNumber)
+	if application.BuildType == models.BuildTypeCompose {
+		return ComposeDownCommand(name)
+	}
+	return removeSlotCommand(name, "")
+}
@@ -0,0 +1,507 @@
+package deploy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/sources"
+	"github.com/mohit4bug/mo-sh/internal/volumes"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+	"github.com/redis/go-redis/v9"
+)
+
+// Builds are cloned here on the target server and removed afterwards.
+const buildRoot = "/var/lib/mo-sh/builds"
+
+// ContainerName is the name of an application's running container.
+func ContainerName(applicationID string) string {
+	return "mo-sh-" + applicationID
+}
+
+// PreviewName is the container and compose project name of a pull request
+// preview, kept apart from the application's own.
+func PreviewName(applicationID string, number int) string {
+	return fmt.Sprintf("%s-pr-%d", ContainerName(applicationID), number)
+}
+
+// PreviewDomain is the host a pull request preview is served on.
+func PreviewDomain(baseDomain string, number int) string {
+	return fmt.Sprintf("pr-%d.%s", number, baseDomain)
+}
+
+// ImageRepository is the local image name an application's builds are tagged
+// under.
+func ImageRepository(applicationID string) string {
+	return "mo-sh/" + applicationID
+}
+
+// Runner carries out queued deployments.
+type Runner struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Settings    settings.Store
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewRunner(db *sqlx.DB, redisClient *redis.Client, settingsStore settings.Store, secretCipher *secrets.Cipher, ctx context.Context) *Runner {
+	return &Runner{
+		DB:          db,
+		RedisClient: redisClient,
+		Settings:    settingsStore,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+// job is everything a single deployment run needs.
+type job struct {
+	Deployment  *models.Deployment
+	Application *models.Application
+	// Config is what gets deployed: a snapshot of the application, or for a
+	// rollback, of the deployment being rolled back to.
+	Config *models.DeploymentConfig
+	// Preview is set when deploying a pull request preview.
+	Preview  *models.Preview
+	Server   *serverWithKey
+	Provider sources.Provider
+	Logger   *Logger
+	SSH      *ssh.Client
+	WorkDir  string
+	// BuildEnv and RuntimeEnv are the application's variables, decrypted.
+	BuildEnv   map[string]string
+	RuntimeEnv map[string]string
+	// Volumes are mounted as they are now, rollbacks included. Previews
+	// don't get the application's volumes.
+	Volumes []models.Volume
+}
+
+// name is the container, or compose project, the job deploys to.
+func (j *job) name() string {
+	if j.Preview != nil {
+		return PreviewName(j.Application.ID, j.Preview.PullRequestNumber)
+	}
+	return ContainerName(j.Application.ID)
+}
+
+type serverWithKey struct {
+	models.Server
+	Key string `db:"key"`
+}
+
+// Run deploys deploymentID if it is still queued, recording its status and
+// logs as it goes.
+func (r *Runner) Run(deploymentID string) {
+	var deployment models.Deployment
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&deployment,
+		`update deployments set status = $1, started_at = now()
+		where id = $2 and status = $3
+		returning *`,
+		models.DeploymentRunning, deploymentID, models.DeploymentQueued,
+	); err != nil {
+		log.Println("deploy.Runner: deployment is not queued", deploymentID, err)
+		return
+	}
+
+	logger := NewLogger(r.DB, r.Ctx, deploymentID)
+	defer logger.Close()
+
+	j := &job{Deployment: &deployment, Logger: logger}
+	err := logger.RedactError(r.run(j))
+	if j.SSH != nil {
+		if j.WorkDir != "" {
+			j.SSH.RunCommand("rm -rf " + git.ShellQuote(j.WorkDir))
+		}
+		j.SSH.RunCommand("rm -rf " + git.ShellQuote(envDir(deploymentID)))
+		j.SSH.Close()
+	}
+
+	if err != nil {
+		logger.Error(err.Error())
+		logger.System("Deployment failed.")
+		r.finish(deploymentID, models.DeploymentFailed, err)
+		r.report(j, err)
+		return
+	}
+
+	logger.System("Deployment finished.")
+	r.finish(deploymentID, models.DeploymentSucceeded, nil)
+	r.report(j, nil)
+}
+
+func (r *Runner) run(j *job) error {
+	var application models.Application
+	if err := r.DB.GetContext(r.Ctx, &application, "select * from applications where id = $1", j.Deployment.ApplicationID); err != nil {
+		return fmt.Errorf("failed to load application: %w", err)
+	}
+	j.Application = &application
+
+	if j.Deployment.PreviewID != nil {
+		var preview models.Preview
+		if err := r.DB.GetContext(r.Ctx, &preview, "select * from previews where id = $1", *j.Deployment.PreviewID); err != nil {
+			return fmt.Errorf("failed to load preview: %w", err)
+		}
+		if preview.ClosedAt != nil {
+			return errors.New("the pull request is closed")
+		}
+		j.Preview = &preview
+	}
+
+	if j.Deployment.RollbackOf != nil {
+		var target models.Deployment
+		if err := r.DB.GetContext(r.Ctx, &target, "select * from deployments where id = $1", *j.Deployment.RollbackOf); err != nil {
+			return fmt.Errorf("failed to load the deployment to roll back to: %w", err)
+		}
+		if target.Config == nil || target.CommitSHA == nil {
+			return errors.New("the deployment to roll back to has no recorded config")
+		}
+		j.Config = target.Config
+		j.Deployment.CommitSHA = target.CommitSHA
+		j.Deployment.ImageTag = target.ImageTag
+		j.Logger.System("Rolling back to deployment %s (commit %s).", target.ID, *target.CommitSHA)
+	} else {
+		j.Config = snapshotConfig(&application)
+	}
+
+	var server serverWithKey
+	query := `
+		select
+			s.*, k.key
+		from
+			servers s
+		inner join
+			keys k ON s.key_id = k.id
+		where
+			s.id = $1
+	`
+	if err := r.DB.GetContext(r.Ctx, &server, query, application.ServerID); err != nil {
+		return fmt.Errorf("failed to load server: %w", err)
+	}
+	j.Server = &server
+
+	provider, err := sources.Load(r.DB, r.RedisClient, r.Ctx, application.SourceID)
+	if err != nil {
+		return fmt.Errorf("failed to load source: %w", err)
+	}
+	j.Provider = provider
+
+	if err := r.loadVariables(j); err != nil {
+		return err
+	}
+
+	if j.Preview == nil {
+		if err := r.DB.SelectContext(r.Ctx, &j.Volumes, "select * from volumes where application_id = $1 order by created_at", application.ID); err != nil {
+			return fmt.Errorf("failed to load volumes: %w", err)
+		}
+	}
+
+	j.Logger.System("Connecting to %s.", server.Name)
+	j.SSH = ssh.NewClient(server.Hostname, server.Port, "root", []byte(server.Key))
+	if err := j.SSH.Connect(); err != nil {
+		return fmt.Errorf("failed to connect to server: %w", err)
+	}
+
+	// Rolling back a Dockerfile build reuses its image as is. Compose
+	// rollbacks still check the commit out for the files services mount.
+	if j.Deployment.RollbackOf == nil || j.Config.BuildType != models.BuildTypeDockerfile {
+		if err := r.checkout(j); err != nil {
+			return err
+		}
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "update deployments set commit_sha = $1 where id = $2", *j.Deployment.CommitSHA, j.Deployment.ID); err != nil {
+		return fmt.Errorf("failed to record commit: %w", err)
+	}
+	r.setCommitStatus(j, sources.CommitStatusPending, "Deploying")
+
+	switch j.Config.BuildType {
+	case models.BuildTypeDockerfile:
+		err = r.deployDockerfile(j)
+	case models.BuildTypeCompose:
+		err = r.deployCompose(j)
+	default:
+		err = fmt.Errorf("unsupported build type %q", j.Config.BuildType)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "update deployments set config = $1 where id = $2", j.Config, j.Deployment.ID); err != nil {
+		return fmt.Errorf("failed to record config: %w", err)
+	}
+
+	// The pull request may have closed, and its preview been torn down, while
+	// this was building.
+	if j.Preview != nil && r.previewClosed(j) {
+		j.SSH.RunCommand(removePreviewCommand(j.Application, j.Preview))
+		return errors.New("the pull request closed during the deployment")
+	}
+
+	if j.Preview == nil {
+		r.pruneImages(j)
+
+		// Volumes deleted while the old containers used them can go now.
+		// The list is read again to spare volumes added during the deployment.
+		var current []models.Volume
+		if err := r.DB.SelectContext(r.Ctx, &current, "select * from volumes where application_id = $1", application.ID); err == nil {
+			j.SSH.RunCommand(volumes.PruneCommand(application.ID, current))
+		}
+	}
+
+	return nil
+}
+
+// checkout clones the deployment's commit, or the head of the branch, into
+// the job's work directory and records the commit it got.
+func (r *Runner) checkout(j *job) error {
+	cloneURL, err := j.Provider.CloneURL(j.Application.RepositoryID)
+	if err != nil {
+		return fmt.Errorf("failed to get clone URL: %w", err)
+	}
+	// Credentials reach git on stdin rather than in the URL, which would put
+	// them on the server's command lines.
+	remote, auth, err := git.SplitCredentials(cloneURL)
+	if err != nil {
+		return fmt.Errorf("failed to parse clone URL: %w", err)
+	}
+	j.Logger.Redact(auth.Password)
+
+	if keyProvider, ok := j.Provider.(sources.DeployKeyProvider); ok {
+		auth.PrivateKey = keyProvider.DeployKey()
+	}
+
+	ref := j.Application.Branch
+	if j.Preview != nil {
+		ref = j.Preview.Branch
+	}
+	if j.Deployment.CommitSHA != nil {
+		ref = *j.Deployment.CommitSHA
+	}
+
+	j.WorkDir = path.Join(buildRoot, j.Deployment.ID)
+	j.Logger.System("Cloning %s.", ref)
+
+	var commitSHA string
+	err = r.streamWithStdin(j, "set -e\n"+git.CloneCommand(remote, auth, j.WorkDir, ref), git.CredentialsInput(auth), func(line string) bool {
+		sha, ok := strings.CutPrefix(line, git.CommitMarker)
+		if ok {
+			commitSHA = sha
+		}
+		return ok
+	})
+	if err != nil {
+		return fmt.Errorf("failed to clone repository: %w", err)
+	}
+	if commitSHA == "" {
+		return errors.New("failed to resolve the cloned commit")
+	}
+
+	j.Deployment.CommitSHA = &commitSHA
+	return nil
+}
+
+// snapshotConfig captures the parts of application that shape a deployment.
+func snapshotConfig(application *models.Application) *models.DeploymentConfig {
+	config := &models.DeploymentConfig{
+		BuildType:     application.BuildType,
+		BaseDirectory: application.BaseDirectory,
+		Ports:         application.Ports,
+		HealthCheck:   application.HealthCheck,
+	}
+
+	switch application.BuildType {
+	case models.BuildTypeDockerfile:
+		config.DockerfilePath = application.DockerfilePath
+	case models.BuildTypeCompose:
+		config.ComposePath = application.ComposePath
+		if application.ComposeFile != nil {
+			config.ComposeFile = *application.ComposeFile
+		}
+	}
+
+	return config
+}
+
+func (r *Runner) deployDockerfile(j *job) error {
+	application := j.Application
+
+	var imageTag string
+	if j.Deployment.RollbackOf != nil {
+		if j.Deployment.ImageTag == nil {
+			return errors.New("the deployment to roll back to has no image")
+		}
+		imageTag = *j.Deployment.ImageTag
+
+		j.Logger.System("Reusing image %s.", imageTag)
+		if !j.SSH.CheckCommand("docker image inspect " + git.ShellQuote(imageTag) + " >/dev/null 2>&1") {
+			return fmt.Errorf("image %s is no longer on the server", imageTag)
+		}
+	} else {
+		buildContext := path.Join(j.WorkDir, j.Config.BaseDirectory)
+		dockerfile := path.Join(buildContext, j.Config.DockerfilePath)
+		imageTag = ImageRepository(application.ID) + ":" + j.Deployment.ID
+
+		source, err := r.sourceEnv(j, "build", j.BuildEnv)
+		if err != nil {
+			return err
+		}
+		var buildArgs string
+		for _, key := range sortedKeys(j.BuildEnv) {
+			buildArgs += " --build-arg " + key
+		}
+
+		j.Logger.System("Building image %s.", imageTag)
+		build := fmt.Sprintf(
+			"%sdocker build --label %s%s -t %s -f %s %s",
+			source,
+			git.ShellQuote("mo-sh.application="+application.ID),
+			buildArgs,
+			git.ShellQuote(imageTag),
+			git.ShellQuote(dockerfile),
+			git.ShellQuote(buildContext),
+		)
+		if err := r.stream(j, build, nil); err != nil {
+			return fmt.Errorf("docker build failed: %w", err)
+		}
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "update deployments set image_tag = $1 where id = $2", imageTag, j.Deployment.ID); err != nil {
+		return fmt.Errorf("failed to record image tag: %w", err)
+	}
+	j.Deployment.ImageTag = &imageTag
+
+	return r.swapContainer(j, imageTag)
+}
+
+// managedEnv is the env Mo-SH sets on every deployed container.
+func managedEnv(j *job) map[string]string {
+	env := map[string]string{
+		"MO_SH_APPLICATION_ID": j.Application.ID,
+		"MO_SH_DEPLOYMENT_ID":  j.Deployment.ID,
+		"MO_SH_BRANCH":         j.Application.Branch,
+	}
+	if j.Deployment.CommitSHA != nil {
+		env["MO_SH_COMMIT_SHA"] = *j.Deployment.CommitSHA
+	}
+	if j.Preview != nil {
+		env["MO_SH_BRANCH"] = j.Preview.Branch
+		env["MO_SH_PULL_REQUEST"] = strconv.Itoa(j.Preview.PullRequestNumber)
+		env["MO_SH_DOMAIN"] = j.Preview.Domain
+	}
+	return env
+}
+
+// managedLabels mark containers as belonging to an application, so they can
+// be found again for cleanup.
+func managedLabels(j *job) map[string]string {
+	labels := map[string]string{
+		"mo-sh.managed":     "true",
+		"mo-sh.application": j.Application.ID,
+		"mo-sh.deployment":  j.Deployment.ID,
+	}
+	if j.Preview != nil {
+		labels["mo-sh.preview"] = strconv.Itoa(j.Preview.PullRequestNumber)
+		labels["mo-sh.domain"] = j.Preview.Domain
+	}
+	return labels
+}
+
+// runContainerCommand replaces the container called containerName with one
+// running image, reachable on the Mo-SH network as alias unless it is empty.
+// inherited names variables the container takes from the script's
+// environment.
+func runContainerCommand(containerName, alias, image string, ports models.Ports, env, labels map[string]string, inherited []string, mounts []models.Volume) string {
+	name := git.ShellQuote(containerName)
+
+	args := []string{
+		"docker", "run", "-d",
+		"--name", name,
+		"--restart", "unless-stopped",
+		"--network", Network,
+	}
+	if alias != "" {
+		args = append(args, "--network-alias", git.ShellQuote(alias))
+	}
+	for _, key := range sortedKeys(labels) {
+		args = append(args, "--label", git.ShellQuote(key+"="+labels[key]))
+	}
+	for _, key := range sortedKeys(env) {
+		args = append(args, "-e", git.ShellQuote(key+"="+env[key]))
+	}
+	for _, key := range inherited {
+		args = append(args, "-e", key)
+	}
+	for _, port := range ports {
+		mapping := fmt.Sprintf("%d:%d", port.HostPort, port.ContainerPort)
+		if port.Protocol != "" {
+			mapping += "/" + port.Protocol
+		}
+		args = append(args, "-p", mapping)
+	}
+	for _, volume := range mounts {
+		args = append(args, "--mount", git.ShellQuote(volumes.Mount(&volume)))
+	}
+	args = append(args, git.ShellQuote(image))
+
+	return fmt.Sprintf("set -e\ndocker rm -f %s >/dev/null 2>&1 || true\n%s\n", name, strings.Join(args, " "))
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// stream runs script on the server, logging its output. intercept, when set,
+// sees stdout lines first and keeps those it returns true for out of the log.
+func (r *Runner) stream(j *job, script string, intercept func(string) bool) error {
+	return r.streamWithStdin(j, script, nil, intercept)
+}
+
+// streamWithStdin is stream with the script's stdin connected to stdin.
+func (r *Runner) streamWithStdin(j *job, script string, stdin io.Reader, intercept func(string) bool) error {
+	return j.SSH.ExecuteWithStdin(script, stdin,
+		func(line string) {
+			if intercept != nil && intercept(line) {
+				return
+			}
+			j.Logger.Info(line)
+		},
+		func(line string) {
+			j.Logger.Error(line)
+		},
+	)
+}
+
+func (r *Runner) finish(deploymentID, status string, err error) {
+	var message *string
+	if err != nil {
+		value := err.Error()
+		message = &value
+	}
+
+	if _, err := r.DB.ExecContext(
+		r.Ctx,
+		"update deployments set status = $1, error = $2, finished_at = now() where id = $3",
+		status, message, deploymentID,
+	); err != nil {
+		log.Println("deploy.Runner: failed to update deployment", deploymentID, err)
+	}
+}
@@ -0,0 +1,170 @@
+package deploy
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mohit4bug/mo-sh/internal/volumes"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+)
+
+// Network is the Docker network every Mo-SH container joins, so health
+// checks and the proxy can reach containers that don't publish ports.
+const Network = "mo-sh"
+
+// swapContainer starts image next to the running container, waits for it to
+// pass its health check and only then gives it the slot's alias and removes
+// the old one. If the check fails, the old container keeps serving.
+//
+// Ports published on the host can't be bound twice, so for those the new
+// container is checked first and then recreated with the ports while the old
+// one is stopped, which briefly interrupts connections to them. The old one
+// is only removed once the recreated container passes its check too.
+func (r *Runner) swapContainer(j *job, image string) error {
+	slot := j.name()
+	candidate := slot + "-" + j.Deployment.ID
+	env, labels := managedEnv(j), managedLabels(j)
+	labels["mo-sh.slot"] = slot
+
+	if err := r.ensureNetwork(j); err != nil {
+		return err
+	}
+
+	source, err := r.sourceEnv(j, "runtime", j.RuntimeEnv)
+	if err != nil {
+		return err
+	}
+	inherited := sortedKeys(j.RuntimeEnv)
+
+	// Both containers mount the volumes while they overlap.
+	if err := volumes.Ensure(j.SSH, j.Volumes); err != nil {
+		return err
+	}
+
+	// The candidate only joins the slot's alias once it is healthy, so the
+	// proxy never sends it traffic before then.
+	j.Logger.System("Starting container %s.", candidate)
+	if err := r.stream(j, source+runContainerCommand(candidate, "", image, nil, env, labels, inherited, j.Volumes), nil); err != nil {
+		return fmt.Errorf("failed to start container: %w", err)
+	}
+
+	if err := r.waitHealthy(j, candidate); err != nil {
+		j.Logger.System("Removing %s; the running container keeps serving.", candidate)
+		j.SSH.RunCommand("docker rm -f " + git.ShellQuote(candidate) + " >/dev/null 2>&1")
+		return err
+	}
+
+	// Previews never publish ports, which the application itself holds.
+	ports := j.Config.Ports
+	if j.Preview != nil {
+		ports = nil
+	}
+
+	if len(ports) > 0 {
+		return r.movePorts(j, slot, candidate, source+runContainerCommand(candidate, slot, image, ports, env, labels, inherited, j.Volumes))
+	}
+
+	// Both containers answer to the alias until the old one is removed.
+	j.Logger.System("Switching traffic to %s.", candidate)
+	if _, stderr, err := j.SSH.RunCommand(connectAliasCommand(candidate, slot)); err != nil {
+		j.SSH.RunCommand("docker rm -f " + git.ShellQuote(candidate) + " >/dev/null 2>&1")
+		return fmt.Errorf("failed to add %s to the %s network: %w: %s", candidate, Network, err, stderr)
+	}
+	if _, stderr, err := j.SSH.RunCommand(removeSlotCommand(slot, candidate)); err != nil {
+		return fmt.Errorf("failed to remove the old container: %w: %s", err, stderr)
+	}
+
+	return nil
+}
+
+// movePorts replaces the slot's old containers with candidate, recreated by
+// run to publish their ports and join the slot's alias. The running ones are
+// only stopped until it passes its health check, and started again if it
+// doesn't.
+func (r *Runner) movePorts(j *job, slot, candidate, run string) error {
+	removeCandidate := "docker rm -f " + git.ShellQuote(candidate) + " >/dev/null 2>&1"
+
+	stdout, stderr, err := j.SSH.RunCommand(runningSlotCommand(slot, candidate))
+	if err != nil {
+		j.SSH.RunCommand(removeCandidate)
+		return fmt.Errorf("failed to list the running containers: %w: %s", err, stderr)
+	}
+	old := strings.Fields(stdout)
+	for i, name := range old {
+		old[i] = git.ShellQuote(name)
+	}
+	names := strings.Join(old, " ")
+
+	j.Logger.System("Moving published ports to %s.", candidate)
+	if len(old) > 0 {
+		if _, stderr, err := j.SSH.RunCommand("docker stop " + names + " >/dev/null"); err != nil {
+			j.SSH.RunCommand(removeCandidate + "\ndocker start " + names + " >/dev/null")
+			return fmt.Errorf("failed to stop the old container: %w: %s", err, stderr)
+		}
+	}
+
+	if err := r.stream(j, run, nil); err != nil {
+		return r.restoreSlot(j, removeCandidate, names, fmt.Errorf("failed to start container with its published ports: %w", err))
+	}
+	if err := r.waitHealthy(j, candidate); err != nil {
+		return r.restoreSlot(j, removeCandidate, names, fmt.Errorf("the container failed its health check after its ports moved: %w", err))
+	}
+
+	if _, stderr, err := j.SSH.RunCommand(removeSlotCommand(slot, candidate)); err != nil {
+		return fmt.Errorf("failed to remove the old container: %w: %s", err, stderr)
+	}
+	return nil
+}
+
+// restoreSlot removes a candidate that failed with the published ports and
+// starts the stopped old containers again.
+func (r *Runner) restoreSlot(j *job, removeCandidate, names string, err error) error {
+	j.SSH.RunCommand(removeCandidate)
+	if names == "" {
+		return err
+	}
+
+	j.Logger.System("Starting the previous container again.")
+	if _, stderr, startErr := j.SSH.RunCommand("docker start " + names + " >/dev/null"); startErr != nil {
+		return fmt.Errorf("%w; the previous container failed to start again, so the application is down: %v: %s", err, startErr, stderr)
+	}
+	return err
+}
+
+// connectAliasCommand makes container answer to alias on the Mo-SH network.
+// Aliases can only be added when connecting, so it is reconnected.
+func connectAliasCommand(container, alias string) string {
+	return fmt.Sprintf(
+		"docker network disconnect %[1]s %[2]s && docker network connect --alias %[3]s %[1]s %[2]s",
+		Network, git.ShellQuote(container), git.ShellQuote(alias),
+	)
+}
+
+func (r *Runner) ensureNetwork(j *job) error {
+	if _, stderr, err := j.SSH.RunCommand(EnsureNetworkCommand); err != nil {
+		return fmt.Errorf("failed to create the %s network: %w: %s", Network, err, stderr)
+	}
+	return nil
+}
+
+// EnsureNetworkCommand creates the Mo-SH network unless it exists.
+const EnsureNetworkCommand = "docker network inspect " + Network + " >/dev/null 2>&1 || docker network create " + Network + " >/dev/null"
+
+// runningSlotCommand lists the running containers deployed to slot other
+// than keep, as removeSlotCommand finds them.
+func runningSlotCommand(slot, keep string) string {
+	return fmt.Sprintf(
+		"{ docker ps --filter %s --format '{{.Names}}'; docker ps --filter %s --format '{{.Names}}'; } | grep -vxF %s | sort -u || true\n",
+		git.ShellQuote("label=mo-sh.slot="+slot), git.ShellQuote("name=^/?"+slot+"$"), git.ShellQuote(keep),
+	)
+}
+
+// removeSlotCommand removes the containers deployed to slot other than keep,
+// including one named after the slot itself, as deployments before blue/green
+// swaps named them.
+func removeSlotCommand(slot, keep string) string {
+	return fmt.Sprintf(
+		"docker ps -a --filter %s --format '{{.Names}}' | grep -vxF %s | xargs -r docker rm -f >/dev/null\ndocker rm -f %s >/dev/null 2>&1 || true\n",
+		git.ShellQuote("label=mo-sh.slot="+slot), git.ShellQuote(keep), git.ShellQuote(slot),
+	)
+}
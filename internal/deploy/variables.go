@@ -0,0 +1,159 @@
+package deploy
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/dotenv"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+)
+
+// Variables reach Docker by name only: their values are exported from a
+// script sourced right before docker build, run or compose, and Docker reads
+// them from its own environment. That keeps them out of command lines,
+// compose files and logs.
+
+// Reserved names would break the deploy scripts, or clash with the
+// variables Mo-SH sets itself, if exported.
+var (
+	reservedVariables        = []string{"PATH", "HOME", "IFS", "PWD", "SHELL", "USER"}
+	reservedVariablePrefixes = []string{"MO_SH_", "DOCKER_", "COMPOSE_", "BUILDKIT_", "LD_"}
+)
+
+// ValidateVariableKey checks that key can be used for an application's
+// variable.
+func ValidateVariableKey(key string) error {
+	if !dotenv.ValidKey(key) {
+		return fmt.Errorf("%s is not a valid variable name", key)
+	}
+	upper := strings.ToUpper(key)
+	for _, reserved := range reservedVariables {
+		if upper == reserved {
+			return fmt.Errorf("%s is reserved", key)
+		}
+	}
+	for _, prefix := range reservedVariablePrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return fmt.Errorf("variables starting with %s are reserved", prefix)
+		}
+	}
+	return nil
+}
+
+// loadVariables decrypts the variables of the application, and those shared
+// by its environment, into the job and records them in its config. The
+// application's own take precedence, and in previews preview variables take
+// precedence over the others at the same level. Rollbacks run the variables
+// recorded with the deployment rolled back to, and fail if one of its
+// secrets has changed since, as secret values aren't kept with deployments.
+func (r *Runner) loadVariables(j *job) error {
+	var variables []models.Variable
+	query := `
+		select * from variables
+		where application_id = $1 or environment_id = $2
+		order by application_id is not null, is_preview, key
+	`
+	if err := r.DB.SelectContext(r.Ctx, &variables, query, j.Application.ID, j.Application.EnvironmentID); err != nil {
+		return fmt.Errorf("failed to load variables: %w", err)
+	}
+
+	env := &models.DeploymentEnv{Build: map[string]string{}, Runtime: map[string]string{}, Secrets: map[string]string{}}
+	secretValues := map[string]string{}
+	for _, variable := range variables {
+		if variable.IsPreview && j.Preview == nil {
+			continue
+		}
+
+		// Later variables replace earlier ones.
+		delete(env.Build, variable.Key)
+		delete(env.Runtime, variable.Key)
+		delete(env.Secrets, variable.Key)
+
+		value := variable.Value
+		if variable.IsSecret {
+			plaintext, err := r.Secrets.Decrypt(value)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s: %w", variable.Key, err)
+			}
+			j.Logger.Redact(plaintext)
+			secretValues[variable.Key] = plaintext
+			env.Secrets[variable.Key] = secretVersion(variable.Value)
+			value = ""
+		}
+
+		if variable.IsBuildTime {
+			env.Build[variable.Key] = value
+		}
+		if variable.IsRuntime {
+			env.Runtime[variable.Key] = value
+		}
+	}
+
+	if j.Deployment.RollbackOf != nil {
+		recorded := j.Config.Env
+		if recorded == nil {
+			return errors.New("the deployment to roll back to has no recorded variables")
+		}
+		for _, key := range sortedKeys(recorded.Secrets) {
+			if env.Secrets[key] != recorded.Secrets[key] {
+				return fmt.Errorf("secret %s has changed since the deployment to roll back to; deploy again instead", key)
+			}
+		}
+		env = recorded
+	}
+	j.Config.Env = env
+
+	j.BuildEnv = withSecrets(env.Build, env.Secrets, secretValues)
+	j.RuntimeEnv = withSecrets(env.Runtime, env.Secrets, secretValues)
+	return nil
+}
+
+// secretVersion identifies a secret's value without revealing it.
+func secretVersion(ciphertext string) string {
+	sum := sha256.Sum256([]byte(ciphertext))
+	return hex.EncodeToString(sum[:])
+}
+
+// withSecrets copies env with the values of the secrets in it filled in.
+func withSecrets(env, secrets, values map[string]string) map[string]string {
+	filled := make(map[string]string, len(env))
+	for key, value := range env {
+		if _, ok := secrets[key]; ok {
+			value = values[key]
+		}
+		filled[key] = value
+	}
+	return filled
+}
+
+// envDir holds a deployment's env scripts, next to its work directory.
+func envDir(deploymentID string) string {
+	return path.Join(buildRoot, deploymentID+"-env")
+}
+
+// sourceEnv writes env to a script only root can read and returns the line
+// that exports it, or nothing when env is empty.
+func (r *Runner) sourceEnv(j *job, name string, env map[string]string) (string, error) {
+	if len(env) == 0 {
+		return "", nil
+	}
+
+	var script bytes.Buffer
+	for _, key := range sortedKeys(env) {
+		fmt.Fprintf(&script, "export %s=%s\n", key, git.ShellQuote(env[key]))
+	}
+
+	file := path.Join(envDir(j.Deployment.ID), name)
+	command := fmt.Sprintf("umask 077 && mkdir -p %s && cat > %s", git.ShellQuote(path.Dir(file)), git.ShellQuote(file))
+	if stderr, err := j.SSH.RunWithStdin(command, &script); err != nil {
+		return "", errors.New("failed to write variables: " + strings.TrimSpace(stderr))
+	}
+
+	return ". " + git.ShellQuote(file) + "\n", nil
+}
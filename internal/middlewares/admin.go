@@ -0,0 +1,33 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+)
+
+// Admin restricts a route to instance administrators. It must run after Auth.
+func Admin(db *sqlx.DB, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := c.MustGet("session").(*session.Session)
+
+		var isAdmin bool
+		if err := db.QueryRowContext(ctx, "select is_admin from users where id = $1", session.UserID).Scan(&isAdmin); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			c.Abort()
+			return
+		}
+
+		if !isAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": shared.ErrForbidden})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,72 @@
+package middlewares
+
+import (
+	"log"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+)
+
+// Audit records every mutating request, and any other request a handler named
+// with audit.Action, once the handler has finished.
+func Audit(recorder audit.Recorder) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		action, named := audit.ActionFrom(c)
+		if !named {
+			if c.Request.Method == http.MethodGet || c.Request.Method == http.MethodHead || c.Request.Method == http.MethodOptions {
+				return
+			}
+			action = c.Request.Method + " " + strings.TrimPrefix(c.FullPath(), "/api/v1")
+		}
+
+		entry := &audit.Entry{
+			Action:     action,
+			Method:     c.Request.Method,
+			Path:       c.Request.URL.Path,
+			IP:         c.ClientIP(),
+			UserAgent:  c.Request.UserAgent(),
+			Outcome:    audit.Outcome(c),
+			StatusCode: c.Writer.Status(),
+		}
+
+		if actorID, ok := audit.ActorFrom(c); ok {
+			entry.ActorID = &actorID
+		} else if value, ok := c.Get("session"); ok {
+			entry.ActorID = &value.(*session.Session).UserID
+		}
+
+		if targetType, targetID, ok := audit.TargetFrom(c); ok {
+			entry.TargetType = &targetType
+			if targetID != "" {
+				entry.TargetID = &targetID
+			}
+		} else if targetType, targetID, ok := targetFromRoute(c); ok {
+			entry.TargetType = &targetType
+			entry.TargetID = &targetID
+		}
+
+		if err := recorder.Record(entry); err != nil {
+			log.Println("Audit() failed to record", entry.Action, err)
+		}
+	}
+}
+
+// targetFromRoute guesses the target from the last route parameter, e.g.
+// "/servers/:serverID/queue-docker-install" targets the "server" serverID.
+func targetFromRoute(c *gin.Context) (string, string, bool) {
+	segments := strings.Split(c.FullPath(), "/")
+	for i := len(segments) - 1; i > 0; i-- {
+		if !strings.HasPrefix(segments[i], ":") {
+			continue
+		}
+
+		targetType := strings.TrimSuffix(segments[i-1], "s")
+		return targetType, c.Param(segments[i][1:]), true
+	}
+	return "", "", false
+}
@@ -2,14 +2,22 @@ package middlewares
 
 import (
 	"github.com/gin-gonic/gin"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
 )
 
-func Cors() gin.HandlerFunc {
+func Cors(settingsStore settings.Store) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "http://localhost:3000")
+		instanceSettings, err := settingsStore.Get()
+		if err != nil {
+			c.AbortWithStatus(500)
+			return
+		}
+
+		c.Writer.Header().Set("Access-Control-Allow-Origin", instanceSettings.UIURL)
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
+		c.Writer.Header().Set("Access-Control-Expose-Headers", "Retry-After, X-RateLimit-Limit, X-RateLimit-Remaining")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -0,0 +1,86 @@
+package middlewares
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/ratelimit"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+	"github.com/redis/go-redis/v9"
+)
+
+type RateLimitKeyFunc func(c *gin.Context) string
+
+// RateLimit throttles requests sharing the same key to limit per window. A
+// limit of 0 disables it.
+func RateLimit(limiter ratelimit.Limiter, name string, limit int, window time.Duration, key RateLimitKeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		result, err := limiter.Allow(name+":"+key(c), limit, window)
+		if err != nil {
+			// Fail open: an unavailable Redis shouldn't take the API down with it.
+			c.Next()
+			return
+		}
+
+		if !WriteRateLimit(c, result) {
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// WriteRateLimit sets the rate limit headers for result and, when the request
+// is throttled, writes the 429 response. It reports whether the request may
+// proceed.
+func WriteRateLimit(c *gin.Context, result *ratelimit.Result) bool {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+
+	if result.Allowed {
+		return true
+	}
+
+	WriteRetryAfter(c, result.RetryAfter, shared.ErrTooManyRequests)
+	return false
+}
+
+func WriteRetryAfter(c *gin.Context, retryAfter time.Duration, message string) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(max(seconds, 1)))
+	c.JSON(http.StatusTooManyRequests, gin.H{"error": message})
+}
+
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// BySessionOrIP keys signed-in requests by user and everything else by IP. It
+// resolves the session itself because it runs before the per-route Auth.
+func BySessionOrIP(redisClient *redis.Client, ctx context.Context) RateLimitKeyFunc {
+	return func(c *gin.Context) string {
+		sessionID, err := c.Cookie("session_id")
+		if err != nil {
+			return ByIP(c)
+		}
+
+		sessionStore := session.NewSessionStore(redisClient, ctx)
+		session, err := sessionStore.FindByID(sessionID)
+		if err != nil {
+			return ByIP(c)
+		}
+
+		return "user:" + session.UserID
+	}
+}
@@ -0,0 +1,44 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+)
+
+// TwoFactor blocks users that belong to a team requiring two-factor
+// authentication until they have enabled it. It must run after Auth.
+func TwoFactor(db *sqlx.DB, ctx context.Context) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := c.MustGet("session").(*session.Session)
+
+		query := `
+			select exists(
+				select 1
+				from team_members tm
+				inner join teams t on t.id = tm.team_id
+				inner join users u on u.id = tm.user_id
+				where tm.user_id = $1 and t.require_two_factor and not u.totp_enabled
+			)
+		`
+
+		var blocked bool
+		if err := db.QueryRowContext(ctx, query, session.UserID).Scan(&blocked); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			c.Abort()
+			return
+		}
+
+		if blocked {
+			c.JSON(http.StatusForbidden, gin.H{"error": shared.ErrTwoFactorRequired})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
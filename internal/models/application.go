@@ -0,0 +1,107 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	BuildTypeDockerfile = "dockerfile"
+	BuildTypeCompose    = "compose"
+)
+
+type Port struct {
+	HostPort      int    `json:"hostPort" binding:"required,min=1,max=65535"`
+	ContainerPort int    `json:"containerPort" binding:"required,min=1,max=65535"`
+	Protocol      string `json:"protocol" binding:"omitempty,oneof=tcp udp"`
+}
+
+type Ports []Port
+
+func (p *Ports) Scan(src any) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte, got %T", src)
+	}
+	return json.Unmarshal(bytes, p)
+}
+
+func (p Ports) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(payload), nil
+}
+
+type Application struct {
+	ID                 string       `json:"id" db:"id"`
+	Name               string       `json:"name" db:"name"`
+	SourceID           string       `json:"sourceId" db:"source_id"`
+	RepositoryID       string       `json:"repositoryId" db:"repository_id"`
+	Branch             string       `json:"branch" db:"branch"`
+	ServerID           string       `json:"serverId" db:"server_id"`
+	EnvironmentID      string       `json:"environmentId" db:"environment_id"`
+	BuildType          string       `json:"buildType" db:"build_type"`
+	BaseDirectory      string       `json:"baseDirectory" db:"base_directory"`
+	DockerfilePath     string       `json:"dockerfilePath" db:"dockerfile_path"`
+	Ports              Ports        `json:"ports" db:"ports"`
+	ComposePath        string       `json:"composePath" db:"compose_path"`
+	ComposeFile        *string      `json:"composeFile" db:"compose_file"`
+	AutoDeploy         bool         `json:"autoDeploy" db:"auto_deploy"`
+	PreviewDeployments bool         `json:"previewDeployments" db:"preview_deployments"`
+	PreviewDomain      *string      `json:"previewDomain" db:"preview_domain"`
+	ImageRetention     int          `json:"imageRetention" db:"image_retention"`
+	HealthCheck        *HealthCheck `json:"healthCheck" db:"health_check"`
+	CreatedAt          time.Time    `json:"createdAt" db:"created_at"`
+	UpdatedAt          time.Time    `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateApplication struct {
+	Name           string `json:"name" binding:"required"`
+	SourceID       string `json:"sourceId" binding:"required"`
+	RepositoryID   string `json:"repositoryId" binding:"required"`
+	Branch         string `json:"branch" binding:"required"`
+	ServerID       string `json:"serverId" binding:"required"`
+	EnvironmentID  string `json:"environmentId" binding:"required"`
+	BuildType      string `json:"buildType" binding:"omitempty,oneof=dockerfile compose"`
+	BaseDirectory  string `json:"baseDirectory"`
+	DockerfilePath string `json:"dockerfilePath"`
+	Ports          Ports  `json:"ports" binding:"dive"`
+	ComposePath    string `json:"composePath"`
+	ComposeFile    string `json:"composeFile" binding:"max=262144"`
+	// AutoDeploy defaults to true.
+	AutoDeploy         *bool  `json:"autoDeploy"`
+	PreviewDeployments bool   `json:"previewDeployments"`
+	PreviewDomain      string `json:"previewDomain" binding:"omitempty,fqdn"`
+	// ImageRetention defaults to 5.
+	ImageRetention *int         `json:"imageRetention" binding:"omitempty,min=0,max=100"`
+	HealthCheck    *HealthCheck `json:"healthCheck"`
+}
+
+type UpdateApplication struct {
+	Name *string `json:"name"`
+	// EnvironmentID moves the application to another environment.
+	EnvironmentID  *string `json:"environmentId"`
+	Branch         *string `json:"branch"`
+	BaseDirectory  *string `json:"baseDirectory"`
+	DockerfilePath *string `json:"dockerfilePath"`
+	Ports          *Ports  `json:"ports" binding:"omitempty,dive"`
+	ComposePath    *string `json:"composePath"`
+	// ComposeFile replaces the inline compose file; an empty string goes back
+	// to the one in the repository.
+	ComposeFile        *string `json:"composeFile" binding:"omitempty,max=262144"`
+	AutoDeploy         *bool   `json:"autoDeploy"`
+	PreviewDeployments *bool   `json:"previewDeployments"`
+	// PreviewDomain set to an empty string clears it.
+	PreviewDomain  *string      `json:"previewDomain"`
+	ImageRetention *int         `json:"imageRetention" binding:"omitempty,min=0,max=100"`
+	HealthCheck    *HealthCheck `json:"healthCheck"`
+	// RemoveHealthCheck goes back to only checking that the container stays up.
+	RemoveHealthCheck bool `json:"removeHealthCheck"`
+}
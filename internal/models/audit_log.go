@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type AuditLog struct {
+	ID         string    `json:"id" db:"id"`
+	ActorID    *string   `json:"actorId" db:"actor_id"`
+	ActorEmail *string   `json:"actorEmail" db:"actor_email"`
+	Action     string    `json:"action" db:"action"`
+	TargetType *string   `json:"targetType" db:"target_type"`
+	TargetID   *string   `json:"targetId" db:"target_id"`
+	Method     string    `json:"method" db:"method"`
+	Path       string    `json:"path" db:"path"`
+	IP         string    `json:"ip" db:"ip"`
+	UserAgent  string    `json:"userAgent" db:"user_agent"`
+	Outcome    string    `json:"outcome" db:"outcome"`
+	StatusCode int       `json:"statusCode" db:"status_code"`
+	CreatedAt  time.Time `json:"createdAt" db:"created_at"`
+}
+
+type AuditLogFilter struct {
+	ActorID    string    `form:"actorId"`
+	Action     string    `form:"action"`
+	TargetType string    `form:"targetType"`
+	TargetID   string    `form:"targetId"`
+	Outcome    string    `form:"outcome"`
+	From       time.Time `form:"from" time_format:"2006-01-02T15:04:05Z07:00"`
+	To         time.Time `form:"to" time_format:"2006-01-02T15:04:05Z07:00"`
+	Page       int       `form:"page"`
+	PerPage    int       `form:"perPage"`
+}
@@ -0,0 +1,149 @@
+package models
+
+import "time"
+
+// Backups and restores share their statuses.
+const (
+	BackupPending   = "pending"
+	BackupRunning   = "running"
+	BackupSucceeded = "succeeded"
+	BackupFailed    = "failed"
+)
+
+// StorageDestination is an S3-compatible bucket that backups are stored in.
+type StorageDestination struct {
+	ID          string `json:"id" db:"id"`
+	Name        string `json:"name" db:"name"`
+	Endpoint    string `json:"endpoint" db:"endpoint"`
+	Region      string `json:"region" db:"region"`
+	Bucket      string `json:"bucket" db:"bucket"`
+	AccessKeyID string `json:"accessKeyId" db:"access_key_id"`
+	// SecretAccessKey is encrypted and never returned.
+	SecretAccessKey string    `json:"-" db:"secret_access_key"`
+	PathPrefix      string    `json:"pathPrefix" db:"path_prefix"`
+	PathStyle       bool      `json:"pathStyle" db:"path_style"`
+	CreatedAt       time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt       time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateStorageDestination struct {
+	Name            string `json:"name" binding:"required,max=255"`
+	Endpoint        string `json:"endpoint" binding:"required,url"`
+	Region          string `json:"region" binding:"max=64"`
+	Bucket          string `json:"bucket" binding:"required,max=63"`
+	AccessKeyID     string `json:"accessKeyId" binding:"required"`
+	SecretAccessKey string `json:"secretAccessKey" binding:"required"`
+	PathPrefix      string `json:"pathPrefix" binding:"max=255"`
+	// PathStyle defaults to true, which MinIO needs.
+	PathStyle *bool `json:"pathStyle"`
+}
+
+// UpdateStorageDestination holds the fields to change; nil fields are left
+// as they are.
+type UpdateStorageDestination struct {
+	Name            *string `json:"name" binding:"omitempty,min=1,max=255"`
+	Endpoint        *string `json:"endpoint" binding:"omitempty,url"`
+	Region          *string `json:"region" binding:"omitempty,max=64"`
+	Bucket          *string `json:"bucket" binding:"omitempty,min=1,max=63"`
+	AccessKeyID     *string `json:"accessKeyId" binding:"omitempty,min=1"`
+	SecretAccessKey *string `json:"secretAccessKey" binding:"omitempty,min=1"`
+	PathPrefix      *string `json:"pathPrefix" binding:"omitempty,max=255"`
+	PathStyle       *bool   `json:"pathStyle"`
+}
+
+// BackupSchedule backs a database up on a cron schedule. After each backup
+// it keeps the newest backup of each of the last KeepDaily days and of each
+// of the last KeepWeekly weeks, and deletes the rest it made.
+type BackupSchedule struct {
+	ID            string     `json:"id" db:"id"`
+	DatabaseID    string     `json:"databaseId" db:"database_id"`
+	DestinationID string     `json:"destinationId" db:"destination_id"`
+	Cron          string     `json:"cron" db:"cron"`
+	KeepDaily     int        `json:"keepDaily" db:"keep_daily"`
+	KeepWeekly    int        `json:"keepWeekly" db:"keep_weekly"`
+	Enabled       bool       `json:"enabled" db:"enabled"`
+	NextRunAt     time.Time  `json:"nextRunAt" db:"next_run_at"`
+	LastRunAt     *time.Time `json:"lastRunAt" db:"last_run_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateBackupSchedule struct {
+	DestinationID string `json:"destinationId" binding:"required"`
+	// Cron is a five-field expression in UTC, such as "0 3 * * *".
+	Cron       string `json:"cron" binding:"required"`
+	KeepDaily  *int   `json:"keepDaily" binding:"omitempty,min=0,max=365"`
+	KeepWeekly *int   `json:"keepWeekly" binding:"omitempty,min=0,max=520"`
+	Enabled    *bool  `json:"enabled"`
+}
+
+type UpdateBackupSchedule struct {
+	DestinationID *string `json:"destinationId" binding:"omitempty,min=1"`
+	Cron          *string `json:"cron" binding:"omitempty,min=1"`
+	KeepDaily     *int    `json:"keepDaily" binding:"omitempty,min=0,max=365"`
+	KeepWeekly    *int    `json:"keepWeekly" binding:"omitempty,min=0,max=520"`
+	Enabled       *bool   `json:"enabled"`
+}
+
+// Backup is a compressed dump of a database in a storage destination.
+type Backup struct {
+	ID            string     `json:"id" db:"id"`
+	DatabaseID    *string    `json:"databaseId" db:"database_id"`
+	DatabaseType  string     `json:"databaseType" db:"database_type"`
+	ScheduleID    *string    `json:"scheduleId" db:"schedule_id"`
+	DestinationID string     `json:"destinationId" db:"destination_id"`
+	Status        string     `json:"status" db:"status"`
+	ObjectKey     string     `json:"objectKey" db:"object_key"`
+	Uploaded      bool       `json:"uploaded" db:"uploaded"`
+	Size          *int64     `json:"size" db:"size"`
+	Checksum      *string    `json:"checksum" db:"checksum"`
+	Error         *string    `json:"error" db:"error"`
+	StartedAt     *time.Time `json:"startedAt" db:"started_at"`
+	FinishedAt    *time.Time `json:"finishedAt" db:"finished_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type CreateBackup struct {
+	DestinationID string `json:"destinationId" binding:"required"`
+}
+
+// Restore loads a backup into a database: the one it was asked for, or a new
+// sibling of it for checking the backup without touching the original.
+type Restore struct {
+	ID               string     `json:"id" db:"id"`
+	BackupID         *string    `json:"backupId" db:"backup_id"`
+	DatabaseID       string     `json:"databaseId" db:"database_id"`
+	Sibling          bool       `json:"sibling" db:"sibling"`
+	StopApplications bool       `json:"stopApplications" db:"stop_applications"`
+	Status           string     `json:"status" db:"status"`
+	Error            *string    `json:"error" db:"error"`
+	StartedAt        *time.Time `json:"startedAt" db:"started_at"`
+	FinishedAt       *time.Time `json:"finishedAt" db:"finished_at"`
+	CreatedAt        time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type RestoreLog struct {
+	ID        int64     `json:"id" db:"id"`
+	RestoreID string    `json:"restoreId" db:"restore_id"`
+	Type      LogType   `json:"type" db:"type"`
+	Content   string    `json:"content" db:"content"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type CreateRestore struct {
+	BackupID string `json:"backupId" binding:"required"`
+	// StopApplications stops the applications in the database's environment
+	// while the restore runs and starts them again afterwards.
+	StopApplications bool `json:"stopApplications"`
+	// Sibling restores into a new database next to this one.
+	Sibling bool `json:"sibling"`
+}
+
+// UploadRestore is the form that comes with an uploaded dump. The dump is in
+// the format backups use, gzip-compressed or not, and stored in the
+// destination before it is restored.
+type UploadRestore struct {
+	DestinationID    string `form:"destinationId" binding:"required"`
+	StopApplications bool   `form:"stopApplications"`
+	Sibling          bool   `form:"sibling"`
+}
@@ -0,0 +1,75 @@
+package models
+
+import "time"
+
+const (
+	DatabaseTypePostgreSQL = "postgresql"
+	DatabaseTypeMySQL      = "mysql"
+	DatabaseTypeMariaDB    = "mariadb"
+	DatabaseTypeRedis      = "redis"
+	DatabaseTypeMongoDB    = "mongodb"
+)
+
+// A database's status while a job runs is the action in progress; it
+// settles on running, stopped or failed.
+const (
+	DatabaseStarting   = "starting"
+	DatabaseRunning    = "running"
+	DatabaseStopping   = "stopping"
+	DatabaseStopped    = "stopped"
+	DatabaseRestarting = "restarting"
+	DatabaseDeleting   = "deleting"
+	DatabaseRestoring  = "restoring"
+	DatabaseFailed     = "failed"
+)
+
+// Database is a managed database running as a container on a server.
+type Database struct {
+	ID            string `json:"id" db:"id"`
+	Name          string `json:"name" db:"name"`
+	Type          string `json:"type" db:"type"`
+	Version       string `json:"version" db:"version"`
+	ServerID      string `json:"serverId" db:"server_id"`
+	EnvironmentID string `json:"environmentId" db:"environment_id"`
+	Username      string `json:"username" db:"username"`
+	// Password is encrypted; connection strings carry it in plain text.
+	Password     string    `json:"-" db:"password"`
+	DatabaseName *string   `json:"databaseName" db:"database_name"`
+	PublicPort   *int      `json:"publicPort" db:"public_port"`
+	Status       string    `json:"status" db:"status"`
+	Error        *string   `json:"error" db:"error"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateDatabase struct {
+	Name          string `json:"name" binding:"required,max=255"`
+	Type          string `json:"type" binding:"required,oneof=postgresql mysql mariadb redis mongodb"`
+	Version       string `json:"version" binding:"max=128"`
+	ServerID      string `json:"serverId" binding:"required"`
+	EnvironmentID string `json:"environmentId" binding:"required"`
+	DatabaseName  string `json:"databaseName" binding:"max=64"`
+	// PublicPort publishes the database on the server's host; leave it out
+	// to keep it reachable only by applications.
+	PublicPort int `json:"publicPort" binding:"omitempty,min=1,max=65535"`
+}
+
+// UpdateDatabase holds the fields to change; nil fields are left as they
+// are. Changing the version or public port recreates the container, keeping
+// its data.
+type UpdateDatabase struct {
+	Name             *string `json:"name" binding:"omitempty,min=1,max=255"`
+	Version          *string `json:"version" binding:"omitempty,max=128"`
+	PublicPort       *int    `json:"publicPort" binding:"omitempty,min=1,max=65535"`
+	RemovePublicPort bool    `json:"removePublicPort"`
+}
+
+// DatabaseConnection is how to reach a database: from applications on the
+// same server, and from outside when it has a public port.
+type DatabaseConnection struct {
+	Host        string  `json:"host"`
+	Port        int     `json:"port"`
+	Password    string  `json:"password"`
+	InternalURL string  `json:"internalUrl"`
+	PublicURL   *string `json:"publicUrl"`
+}
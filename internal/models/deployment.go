@@ -0,0 +1,138 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	DeploymentQueued    = "queued"
+	DeploymentRunning   = "running"
+	DeploymentSucceeded = "succeeded"
+	DeploymentFailed    = "failed"
+)
+
+// What started a deployment.
+const (
+	TriggerManual      = "manual"
+	TriggerPush        = "push"
+	TriggerPullRequest = "pull_request"
+	TriggerRollback    = "rollback"
+)
+
+type Deployment struct {
+	ID            string            `json:"id" db:"id"`
+	ApplicationID string            `json:"applicationId" db:"application_id"`
+	Status        string            `json:"status" db:"status"`
+	CommitSHA     *string           `json:"commitSha" db:"commit_sha"`
+	ImageTag      *string           `json:"imageTag" db:"image_tag"`
+	Error         *string           `json:"error" db:"error"`
+	CreatedAt     time.Time         `json:"createdAt" db:"created_at"`
+	StartedAt     *time.Time        `json:"startedAt" db:"started_at"`
+	FinishedAt    *time.Time        `json:"finishedAt" db:"finished_at"`
+	Services      Services          `json:"services" db:"services"`
+	Trigger       string            `json:"trigger" db:"trigger"`
+	PreviewID     *string           `json:"previewId" db:"preview_id"`
+	Config        *DeploymentConfig `json:"config" db:"config"`
+	TriggeredBy   *string           `json:"triggeredBy" db:"triggered_by"`
+	RollbackOf    *string           `json:"rollbackOf" db:"rollback_of"`
+}
+
+// DeploymentConfig is the snapshot of what a deployment ran, enough to run it
+// again without rebuilding.
+type DeploymentConfig struct {
+	BuildType      string `json:"buildType"`
+	BaseDirectory  string `json:"baseDirectory"`
+	DockerfilePath string `json:"dockerfilePath,omitempty"`
+	Ports          Ports  `json:"ports"`
+	ComposePath    string `json:"composePath,omitempty"`
+	// ComposeFile is the compose file's content as deployed, whether it came
+	// from the repository or was inline.
+	ComposeFile string `json:"composeFile,omitempty"`
+	// Images maps each compose service Mo-SH built to its retained image.
+	Images      map[string]string `json:"images,omitempty"`
+	HealthCheck *HealthCheck      `json:"healthCheck,omitempty"`
+	// Env is nil for deployments made before variables were recorded.
+	Env *DeploymentEnv `json:"env,omitempty"`
+}
+
+// DeploymentEnv is the variables a deployment ran with. Secret values aren't
+// kept with deployments: their keys appear in Build and Runtime with an empty
+// value, and Secrets holds the version each had.
+type DeploymentEnv struct {
+	Build   map[string]string `json:"build"`
+	Runtime map[string]string `json:"runtime"`
+	// Secrets maps secret keys to a hash of their ciphertext, which changes
+	// whenever the value is set.
+	Secrets map[string]string `json:"secrets"`
+}
+
+func (c *DeploymentConfig) Scan(src any) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte, got %T", src)
+	}
+	return json.Unmarshal(bytes, c)
+}
+
+func (c *DeploymentConfig) Value() (driver.Value, error) {
+	if c == nil {
+		return nil, nil
+	}
+	payload, err := json.Marshal(c)
+	if err != nil {
+		return nil, err
+	}
+	return string(payload), nil
+}
+
+// ServiceState is the state of one compose service after a deployment.
+type ServiceState struct {
+	Service   string `json:"service"`
+	Container string `json:"container"`
+	Image     string `json:"image"`
+	State     string `json:"state"`
+	Status    string `json:"status"`
+	Health    string `json:"health"`
+	ExitCode  int    `json:"exitCode"`
+}
+
+type Services []ServiceState
+
+func (s *Services) Scan(src any) error {
+	if src == nil {
+		*s = nil
+		return nil
+	}
+	bytes, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte, got %T", src)
+	}
+	return json.Unmarshal(bytes, s)
+}
+
+func (s Services) Value() (driver.Value, error) {
+	if s == nil {
+		return nil, nil
+	}
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return nil, err
+	}
+	return string(payload), nil
+}
+
+type DeploymentLog struct {
+	ID           int64     `json:"id" db:"id"`
+	DeploymentID string    `json:"deploymentId" db:"deployment_id"`
+	Type         LogType   `json:"type" db:"type"`
+	Content      string    `json:"content" db:"content"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
+
+type CreateDeployment struct {
+	// CommitSHA deploys a specific commit instead of the branch head.
+	CommitSHA string `json:"commitSha"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+type GitConnection struct {
+	SourceID      string     `json:"sourceId" db:"source_id"`
+	RepositoryURL string     `json:"repositoryUrl" db:"repository_url"`
+	KeyID         *string    `json:"keyId" db:"key_id"`
+	Username      *string    `json:"username" db:"username"`
+	Password      *string    `json:"-" db:"password"`
+	Branch        string     `json:"branch" db:"branch"`
+	PollInterval  int        `json:"pollInterval" db:"poll_interval"`
+	LastCommitSHA *string    `json:"lastCommitSha" db:"last_commit_sha"`
+	LastPolledAt  *time.Time `json:"lastPolledAt" db:"last_polled_at"`
+	LastPollError *string    `json:"lastPollError" db:"last_poll_error"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+type ConnectGit struct {
+	RepositoryURL string `json:"repositoryUrl" binding:"required"`
+	KeyID         string `json:"keyId"`
+	Username      string `json:"username"`
+	Password      string `json:"password"`
+	Branch        string `json:"branch" binding:"required"`
+	// PollInterval is in seconds; 0 disables polling.
+	PollInterval *int `json:"pollInterval"`
+}
+
+// CheckGitAccess runs git ls-remote from Mo-SH, or from ServerID when set.
+type CheckGitAccess struct {
+	ServerID string `json:"serverId"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type GiteaConnection struct {
+	SourceID      string    `json:"sourceId" db:"source_id"`
+	AccessToken   string    `json:"-" db:"access_token"`
+	WebhookSecret string    `json:"-" db:"webhook_secret"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type ConnectGitea struct {
+	AccessToken string `json:"accessToken" binding:"required"`
+}
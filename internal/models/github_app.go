@@ -25,27 +25,9 @@ type owner struct {
 
 type permissions map[string]string
 
-type GithubAppResponse struct {
-	ID            int64       `json:"id" db:"id"`
-	Slug          string      `json:"slug" db:"slug"`
-	ClientID      string      `json:"clientId" db:"client_id"`
-	NodeID        string      `json:"nodeId" db:"node_id"`
-	Owner         owner       `json:"owner" db:"owner"`
-	Name          string      `json:"name" db:"name"`
-	Description   string      `json:"description" db:"description"`
-	ExternalURL   string      `json:"externalUrl" db:"external_url"`
-	HTMLURL       string      `json:"htmlUrl" db:"html_url"`
-	CreatedAt     time.Time   `json:"createdAt" db:"created_at"`
-	UpdatedAt     time.Time   `json:"updatedAt" db:"updated_at"`
-	Permissions   permissions `json:"permissions" db:"permissions"`
-	Events        []string    `json:"events" db:"events"`
-	PEM           string      `json:"pem" db:"pem"`
-	ClientSecret  string      `json:"clientSecret" db:"client_secret"`
-	WebhookSecret string      `json:"webhookSecret" db:"webhook_secret"`
-}
-
 type GithubApp struct {
 	ID            int64       `json:"id" db:"id"`
+	AppID         *int64      `json:"appId" db:"app_id"`
 	Slug          string      `json:"slug" db:"slug"`
 	ClientID      string      `json:"clientId" db:"client_id"`
 	NodeID        string      `json:"nodeId" db:"node_id"`
@@ -0,0 +1,31 @@
+package models
+
+import "time"
+
+type GithubInstallation struct {
+	ID                  string     `json:"id" db:"id"`
+	SourceID            string     `json:"sourceId" db:"source_id"`
+	InstallationID      int64      `json:"installationId" db:"installation_id"`
+	AccountID           int64      `json:"accountId" db:"account_id"`
+	AccountLogin        string     `json:"accountLogin" db:"account_login"`
+	AccountType         string     `json:"accountType" db:"account_type"`
+	TargetType          string     `json:"targetType" db:"target_type"`
+	RepositorySelection string     `json:"repositorySelection" db:"repository_selection"`
+	SuspendedAt         *time.Time `json:"suspendedAt" db:"suspended_at"`
+	CreatedAt           time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt           time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+type GithubRepository struct {
+	ID             string    `json:"id" db:"id"`
+	InstallationID string    `json:"installationId" db:"installation_id"`
+	RepositoryID   int64     `json:"repositoryId" db:"repository_id"`
+	Name           string    `json:"name" db:"name"`
+	FullName       string    `json:"fullName" db:"full_name"`
+	Private        bool      `json:"private" db:"private"`
+	DefaultBranch  string    `json:"defaultBranch" db:"default_branch"`
+	HTMLURL        string    `json:"htmlUrl" db:"html_url"`
+	CloneURL       string    `json:"cloneUrl" db:"clone_url"`
+	CreatedAt      time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time `json:"updatedAt" db:"updated_at"`
+}
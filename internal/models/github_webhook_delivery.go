@@ -0,0 +1,26 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryProcessed = "processed"
+	WebhookDeliveryIgnored   = "ignored"
+	WebhookDeliveryFailed    = "failed"
+)
+
+type GithubWebhookDelivery struct {
+	ID          string          `json:"id" db:"id"`
+	SourceID    string          `json:"sourceId" db:"source_id"`
+	DeliveryID  string          `json:"deliveryId" db:"delivery_id"`
+	Event       string          `json:"event" db:"event"`
+	Action      *string         `json:"action" db:"action"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"`
+	Error       *string         `json:"error" db:"error"`
+	ReceivedAt  time.Time       `json:"receivedAt" db:"received_at"`
+	ProcessedAt *time.Time      `json:"processedAt" db:"processed_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+const (
+	GitlabAuthOAuth = "oauth"
+	GitlabAuthToken = "token"
+)
+
+type GitlabConnection struct {
+	SourceID       string     `json:"sourceId" db:"source_id"`
+	AuthType       string     `json:"authType" db:"auth_type"`
+	ClientID       *string    `json:"clientId" db:"client_id"`
+	ClientSecret   *string    `json:"-" db:"client_secret"`
+	RedirectURL    *string    `json:"-" db:"redirect_url"`
+	AccessToken    *string    `json:"-" db:"access_token"`
+	RefreshToken   *string    `json:"-" db:"refresh_token"`
+	TokenExpiresAt *time.Time `json:"tokenExpiresAt" db:"token_expires_at"`
+	WebhookSecret  string     `json:"-" db:"webhook_secret"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt      time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// ConnectGitlab connects a GitLab source with either an access token or an
+// OAuth application, which is then authorized in the browser.
+type ConnectGitlab struct {
+	AccessToken  string `json:"accessToken"`
+	ClientID     string `json:"clientId"`
+	ClientSecret string `json:"clientSecret"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+const (
+	HealthCheckHTTP    = "http"
+	HealthCheckTCP     = "tcp"
+	HealthCheckCommand = "command"
+)
+
+// HealthCheck decides when a new container is ready to take over from the
+// old one. Durations are in seconds. Compose services rely on their own
+// healthcheck instead.
+type HealthCheck struct {
+	Type string `json:"type" binding:"required,oneof=http tcp command"`
+	// Port defaults to the first container port.
+	Port int    `json:"port" binding:"omitempty,min=1,max=65535"`
+	Path string `json:"path"`
+	// Command runs inside the container; exit status 0 means healthy.
+	Command     string `json:"command"`
+	Interval    int    `json:"interval" binding:"omitempty,min=1,max=300"`
+	Timeout     int    `json:"timeout" binding:"omitempty,min=1,max=300"`
+	Retries     int    `json:"retries" binding:"omitempty,min=1,max=100"`
+	StartPeriod int    `json:"startPeriod" binding:"omitempty,min=0,max=600"`
+}
+
+func (h *HealthCheck) Scan(src any) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte, got %T", src)
+	}
+	return json.Unmarshal(bytes, h)
+}
+
+func (h *HealthCheck) Value() (driver.Value, error) {
+	if h == nil {
+		return nil, nil
+	}
+	payload, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(payload), nil
+}
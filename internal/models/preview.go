@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Preview is an ephemeral deployment of a pull request, served on its own
+// subdomain until the pull request closes.
+type Preview struct {
+	ID                string     `json:"id" db:"id"`
+	ApplicationID     string     `json:"applicationId" db:"application_id"`
+	PullRequestNumber int        `json:"pullRequestNumber" db:"pull_request_number"`
+	Branch            string     `json:"branch" db:"branch"`
+	CommitSHA         string     `json:"commitSha" db:"commit_sha"`
+	Domain            string     `json:"domain" db:"domain"`
+	CommentID         *string    `json:"-" db:"comment_id"`
+	CreatedAt         time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updatedAt" db:"updated_at"`
+	ClosedAt          *time.Time `json:"closedAt" db:"closed_at"`
+}
@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Project groups resources into environments, such as production and
+// staging.
+type Project struct {
+	ID          string    `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description *string   `json:"description" db:"description"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// Environment holds a project's applications and databases, along with
+// variables they all share.
+type Environment struct {
+	ID        string    `json:"id" db:"id"`
+	ProjectID string    `json:"projectId" db:"project_id"`
+	Name      string    `json:"name" db:"name"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateProject struct {
+	Name        string `json:"name" binding:"required,max=255"`
+	Description string `json:"description" binding:"max=1024"`
+	// Environments to create with the project; production if empty.
+	Environments []string `json:"environments" binding:"omitempty,dive,required,max=64"`
+}
+
+// UpdateProject holds the fields to change; nil fields are left as they are.
+type UpdateProject struct {
+	Name        *string `json:"name" binding:"omitempty,min=1,max=255"`
+	Description *string `json:"description" binding:"omitempty,max=1024"`
+}
+
+type CreateEnvironment struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+type UpdateEnvironment struct {
+	Name string `json:"name" binding:"required,max=64"`
+}
+
+// CloneEnvironment copies an environment's variables and applications into a
+// new environment, in the same project unless ProjectID is set.
+type CloneEnvironment struct {
+	Name      string `json:"name" binding:"required,max=64"`
+	ProjectID string `json:"projectId"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import "time"
+
+const (
+	ProxyTypeTraefik = "traefik"
+	ProxyTypeCaddy   = "caddy"
+
+	ProxyPending = "pending"
+	ProxyRunning = "running"
+	ProxyFailed  = "failed"
+
+	DefaultACMEDirectoryURL = "https://acme-v02.api.letsencrypt.org/directory"
+)
+
+// ServerProxy is the reverse proxy Mo-SH runs on a server to route domains
+// to applications and terminate TLS.
+type ServerProxy struct {
+	ServerID          string     `json:"serverId" db:"server_id"`
+	Type              string     `json:"type" db:"type"`
+	ACMEEmail         *string    `json:"acmeEmail" db:"acme_email"`
+	ACMEDirectoryURL  string     `json:"acmeDirectoryUrl" db:"acme_directory_url"`
+	ACMECACertificate *string    `json:"acmeCaCertificate" db:"acme_ca_certificate"`
+	Status            string     `json:"status" db:"status"`
+	Error             *string    `json:"error" db:"error"`
+	AppliedAt         *time.Time `json:"appliedAt" db:"applied_at"`
+	CreatedAt         time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+type ConfigureProxy struct {
+	Type      string `json:"type" binding:"required,oneof=traefik caddy"`
+	ACMEEmail string `json:"acmeEmail" binding:"omitempty,email"`
+	// ACMEDirectoryURL defaults to Let's Encrypt; point it at Pebble to test.
+	ACMEDirectoryURL  string `json:"acmeDirectoryUrl" binding:"omitempty,url"`
+	ACMECACertificate string `json:"acmeCaCertificate"`
+}
+
+type Domain struct {
+	ID            string    `json:"id" db:"id"`
+	ApplicationID string    `json:"applicationId" db:"application_id"`
+	Hostname      string    `json:"hostname" db:"hostname"`
+	Port          *int      `json:"port" db:"port"`
+	Service       *string   `json:"service" db:"service"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+}
+
+type CreateDomain struct {
+	Hostname string `json:"hostname" binding:"required,fqdn"`
+	Port     int    `json:"port" binding:"omitempty,min=1,max=65535"`
+	Service  string `json:"service"`
+}
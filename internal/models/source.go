@@ -2,6 +2,13 @@ package models
 
 import "time"
 
+const (
+	SourceTypeGithub = "github"
+	SourceTypeGitlab = "gitlab"
+	SourceTypeGitea  = "gitea"
+	SourceTypeGit    = "git"
+)
+
 type Source struct {
 	ID           string    `json:"id" db:"id"`
 	Name         string    `json:"name" db:"name"`
@@ -9,9 +16,26 @@ type Source struct {
 	HasGithubApp bool      `json:"hasGithubApp" db:"has_github_app"`
 	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
 	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+	HTMLURL      string    `json:"htmlUrl" db:"html_url"`
+	APIURL       string    `json:"apiUrl" db:"api_url"`
+	Organization *string   `json:"organization" db:"organization"`
 }
 
 type CreateSource struct {
 	Name string `json:"name" binding:"required"`
 	Type string `json:"type" binding:"required"`
+	// HTMLURL and APIURL point at a GitHub Enterprise Server instance. Both
+	// default to github.com.
+	HTMLURL string `json:"htmlUrl"`
+	APIURL  string `json:"apiUrl"`
+	// Organization registers the GitHub App under an organization instead of
+	// the user's personal account.
+	Organization string `json:"organization"`
+}
+
+type UpdateSource struct {
+	Name         *string `json:"name"`
+	HTMLURL      *string `json:"htmlUrl"`
+	APIURL       *string `json:"apiUrl"`
+	Organization *string `json:"organization"`
 }
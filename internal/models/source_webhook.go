@@ -0,0 +1,28 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type SourceWebhook struct {
+	ID           string    `json:"id" db:"id"`
+	SourceID     string    `json:"sourceId" db:"source_id"`
+	RepositoryID string    `json:"repositoryId" db:"repository_id"`
+	HookID       string    `json:"hookId" db:"hook_id"`
+	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
+}
+
+// SourceWebhookDelivery is a webhook received from a source that isn't a
+// GitHub App, such as a GitLab project.
+type SourceWebhookDelivery struct {
+	ID          string          `json:"id" db:"id"`
+	SourceID    string          `json:"sourceId" db:"source_id"`
+	DeliveryID  string          `json:"deliveryId" db:"delivery_id"`
+	Event       string          `json:"event" db:"event"`
+	Payload     json.RawMessage `json:"payload" db:"payload"`
+	Status      string          `json:"status" db:"status"`
+	Error       *string         `json:"error" db:"error"`
+	ReceivedAt  time.Time       `json:"receivedAt" db:"received_at"`
+	ProcessedAt *time.Time      `json:"processedAt" db:"processed_at"`
+}
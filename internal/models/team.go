@@ -0,0 +1,53 @@
+package models
+
+import "time"
+
+type TeamRole string
+
+const (
+	TeamRoleOwner  TeamRole = "owner"
+	TeamRoleAdmin  TeamRole = "admin"
+	TeamRoleMember TeamRole = "member"
+)
+
+type Team struct {
+	ID               string    `json:"id" db:"id"`
+	Name             string    `json:"name" db:"name"`
+	RequireTwoFactor bool      `json:"requireTwoFactor" db:"require_two_factor"`
+	CreatedAt        time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt        time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type TeamMember struct {
+	TeamID      string    `json:"teamId" db:"team_id"`
+	UserID      string    `json:"userId" db:"user_id"`
+	Email       string    `json:"email" db:"email"`
+	Role        TeamRole  `json:"role" db:"role"`
+	TotpEnabled bool      `json:"totpEnabled" db:"totp_enabled"`
+	CreatedAt   time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// TeamInvitation is an invitation for a user to join a team, which only
+// makes them a member once they accept it.
+type TeamInvitation struct {
+	TeamID    string    `json:"teamId" db:"team_id"`
+	TeamName  string    `json:"teamName" db:"team_name"`
+	UserID    string    `json:"userId" db:"user_id"`
+	Role      TeamRole  `json:"role" db:"role"`
+	InvitedBy *string   `json:"invitedBy" db:"invited_by"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+}
+
+type CreateTeam struct {
+	Name string `json:"name" binding:"required"`
+}
+
+type AddTeamMember struct {
+	Email string   `json:"email" binding:"required"`
+	Role  TeamRole `json:"role" binding:"required"`
+}
+
+type UpdateTeamSecurity struct {
+	RequireTwoFactor *bool `json:"requireTwoFactor" binding:"required"`
+}
@@ -1,17 +1,53 @@
 package models
 
-import "time"
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+type RecoveryCodes []string
+
+func (r *RecoveryCodes) Scan(src any) error {
+	bytes, ok := src.([]byte)
+	if !ok {
+		return fmt.Errorf("expected []byte, got %T", src)
+	}
+
+	var codes []string
+	if err := json.Unmarshal(bytes, &codes); err != nil {
+		return fmt.Errorf("failed to unmarshal: %w", err)
+	}
+	*r = codes
+	return nil
+}
+
+func (r RecoveryCodes) Value() (driver.Value, error) {
+	if r == nil {
+		return "[]", nil
+	}
+
+	// Strings rather than []byte, which lib/pq would send as bytea.
+	bytes, err := json.Marshal(r)
+	return string(bytes), err
+}
 
 type User struct {
-	ID           string    `json:"id" db:"id"`
-	Email        string    `json:"email" db:"email"`
-	PasswordHash string    `json:"passwordHash" db:"password_hash"`
-	CreatedAt    time.Time `json:"createdAt" db:"created_at"`
-	UpdatedAt    time.Time `json:"updatedAt" db:"updated_at"`
+	ID                string        `json:"id" db:"id"`
+	Email             string        `json:"email" db:"email"`
+	PasswordHash      string        `json:"passwordHash" db:"password_hash"`
+	EmailVerifiedAt   *time.Time    `json:"emailVerifiedAt" db:"email_verified_at"`
+	TotpSecret        *string       `json:"-" db:"totp_secret"`
+	TotpEnabled       bool          `json:"totpEnabled" db:"totp_enabled"`
+	TotpRecoveryCodes RecoveryCodes `json:"-" db:"totp_recovery_codes"`
+	IsAdmin           bool          `json:"isAdmin" db:"is_admin"`
+	CreatedAt         time.Time     `json:"createdAt" db:"created_at"`
+	UpdatedAt         time.Time     `json:"updatedAt" db:"updated_at"`
 }
 
 type RegisterUser struct {
-	Email    string `json:"email" binding:"required"`
+	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
 }
 
@@ -19,3 +55,32 @@ type LoginUser struct {
 	Email    string `json:"email" binding:"required"`
 	Password string `json:"password" binding:"required"`
 }
+
+type VerifyLoginTwoFactor struct {
+	// Token is sent in a cookie instead after SSO logins.
+	Token        string `json:"token"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recoveryCode"`
+}
+
+type ConfirmTwoFactor struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type DisableTwoFactor struct {
+	Password string `json:"password" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type VerifyEmail struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ForgotPassword struct {
+	Email string `json:"email" binding:"required"`
+}
+
+type ResetPassword struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Variable is an environment variable passed to an application's builds,
+// containers, or both. Variables set on an environment are shared by all of
+// its applications. Secret values are stored encrypted and never sent back
+// once set.
+type Variable struct {
+	ID            string    `json:"id" db:"id"`
+	ApplicationID *string   `json:"applicationId" db:"application_id"`
+	EnvironmentID *string   `json:"environmentId" db:"environment_id"`
+	Key           string    `json:"key" db:"key"`
+	Value         string    `json:"value" db:"value"`
+	IsSecret      bool      `json:"isSecret" db:"is_secret"`
+	IsBuildTime   bool      `json:"isBuildTime" db:"is_build_time"`
+	IsRuntime     bool      `json:"isRuntime" db:"is_runtime"`
+	IsPreview     bool      `json:"isPreview" db:"is_preview"`
+	CreatedAt     time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+// MarshalJSON leaves secret values out.
+func (v Variable) MarshalJSON() ([]byte, error) {
+	type variable Variable
+	if v.IsSecret {
+		return json.Marshal(struct {
+			variable
+			Value *string `json:"value"`
+		}{variable: variable(v)})
+	}
+	return json.Marshal(variable(v))
+}
+
+type CreateVariable struct {
+	Key         string `json:"key" binding:"required,max=255"`
+	Value       string `json:"value" binding:"max=65536"`
+	IsSecret    bool   `json:"isSecret"`
+	IsBuildTime *bool  `json:"isBuildTime"`
+	IsRuntime   *bool  `json:"isRuntime"`
+	IsPreview   bool   `json:"isPreview"`
+}
+
+// UpdateVariable holds the fields to change; nil fields are left as they
+// are. A secret's value can be replaced but not read back.
+type UpdateVariable struct {
+	Value       *string `json:"value" binding:"omitempty,max=65536"`
+	IsBuildTime *bool   `json:"isBuildTime"`
+	IsRuntime   *bool   `json:"isRuntime"`
+}
+
+// ImportVariables upserts every variable in a .env file with the same
+// flags.
+type ImportVariables struct {
+	Content     string `json:"content" binding:"required,max=1048576"`
+	IsSecret    bool   `json:"isSecret"`
+	IsBuildTime *bool  `json:"isBuildTime"`
+	IsRuntime   *bool  `json:"isRuntime"`
+	IsPreview   bool   `json:"isPreview"`
+}
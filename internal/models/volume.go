@@ -0,0 +1,90 @@
+package models
+
+import "time"
+
+const (
+	VolumeTypeVolume = "volume"
+	VolumeTypeBind   = "bind"
+)
+
+// Volume is persistent storage mounted into an application's containers:
+// a Docker volume Mo-SH manages, or a directory on the server.
+type Volume struct {
+	ID            string `json:"id" db:"id"`
+	ApplicationID string `json:"applicationId" db:"application_id"`
+	Name          string `json:"name" db:"name"`
+	Type          string `json:"type" db:"type"`
+	// HostPath is the directory bind mounts mount.
+	HostPath  *string `json:"hostPath" db:"host_path"`
+	MountPath string  `json:"mountPath" db:"mount_path"`
+	// Service is the compose service the volume is mounted into.
+	Service   *string   `json:"service" db:"service"`
+	ReadOnly  bool      `json:"readOnly" db:"read_only"`
+	CreatedAt time.Time `json:"createdAt" db:"created_at"`
+	UpdatedAt time.Time `json:"updatedAt" db:"updated_at"`
+}
+
+type CreateVolume struct {
+	Name      string `json:"name" binding:"required,max=255"`
+	Type      string `json:"type" binding:"required,oneof=volume bind"`
+	HostPath  string `json:"hostPath" binding:"max=4096"`
+	MountPath string `json:"mountPath" binding:"required,max=4096"`
+	Service   string `json:"service"`
+	ReadOnly  bool   `json:"readOnly"`
+}
+
+// UpdateVolume holds the fields to change; nil fields are left as they are.
+// Mounts change with the next deployment; a new host path moves the
+// directory on the server right away.
+type UpdateVolume struct {
+	Name      *string `json:"name" binding:"omitempty,min=1,max=255"`
+	HostPath  *string `json:"hostPath" binding:"omitempty,min=1,max=4096"`
+	MountPath *string `json:"mountPath" binding:"omitempty,min=1,max=4096"`
+	Service   *string `json:"service" binding:"omitempty,min=1"`
+	ReadOnly  *bool   `json:"readOnly"`
+}
+
+// VolumeUsage is how much disk a volume takes on the server. Size is nil
+// when the volume doesn't exist there yet.
+type VolumeUsage struct {
+	VolumeID string `json:"volumeId"`
+	Size     *int64 `json:"size"`
+}
+
+// VolumeSnapshotSchedule snapshots a volume on a cron schedule, keeping
+// snapshots the way BackupSchedule keeps backups. It is created and updated
+// with CreateBackupSchedule and UpdateBackupSchedule.
+type VolumeSnapshotSchedule struct {
+	ID            string     `json:"id" db:"id"`
+	VolumeID      string     `json:"volumeId" db:"volume_id"`
+	DestinationID string     `json:"destinationId" db:"destination_id"`
+	Cron          string     `json:"cron" db:"cron"`
+	KeepDaily     int        `json:"keepDaily" db:"keep_daily"`
+	KeepWeekly    int        `json:"keepWeekly" db:"keep_weekly"`
+	Enabled       bool       `json:"enabled" db:"enabled"`
+	NextRunAt     time.Time  `json:"nextRunAt" db:"next_run_at"`
+	LastRunAt     *time.Time `json:"lastRunAt" db:"last_run_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt     time.Time  `json:"updatedAt" db:"updated_at"`
+}
+
+// VolumeSnapshot is a gzipped tarball of a volume's contents in a storage
+// destination. It shares its statuses with Backup.
+type VolumeSnapshot struct {
+	ID            string     `json:"id" db:"id"`
+	VolumeID      *string    `json:"volumeId" db:"volume_id"`
+	ScheduleID    *string    `json:"scheduleId" db:"schedule_id"`
+	DestinationID string     `json:"destinationId" db:"destination_id"`
+	Status        string     `json:"status" db:"status"`
+	ObjectKey     string     `json:"objectKey" db:"object_key"`
+	Size          *int64     `json:"size" db:"size"`
+	Checksum      *string    `json:"checksum" db:"checksum"`
+	Error         *string    `json:"error" db:"error"`
+	StartedAt     *time.Time `json:"startedAt" db:"started_at"`
+	FinishedAt    *time.Time `json:"finishedAt" db:"finished_at"`
+	CreatedAt     time.Time  `json:"createdAt" db:"created_at"`
+}
+
+type CreateVolumeSnapshot struct {
+	DestinationID string `json:"destinationId" binding:"required"`
+}
@@ -0,0 +1,282 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+)
+
+const (
+	// ContainerName is the proxy's container on each server.
+	ContainerName = "mo-sh-proxy"
+
+	configDir  = "/etc/mo-sh/proxy"
+	dataVolume = "mo-sh-proxy-data"
+
+	traefikImage = "traefik:v3.1"
+	caddyImage   = "caddy:2"
+
+	// certResolver is the Traefik certificate resolver routes use.
+	certResolver = "mo-sh"
+)
+
+// Apply installs or updates the proxy configured for serverID and writes its
+// routes. It records the outcome on the server's proxy and does nothing if
+// the server has none.
+func Apply(ctx context.Context, db *sqlx.DB, sshClient *ssh.Client, serverID string) error {
+	var proxy models.ServerProxy
+	if err := db.GetContext(ctx, &proxy, "select * from server_proxies where server_id = $1", serverID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil
+		}
+		return err
+	}
+
+	err := apply(ctx, db, sshClient, &proxy)
+
+	status, message := models.ProxyRunning, (*string)(nil)
+	if err != nil {
+		status = models.ProxyFailed
+		value := err.Error()
+		message = &value
+	}
+	if _, dbErr := db.ExecContext(
+		ctx,
+		"update server_proxies set status = $1, error = $2, applied_at = now(), updated_at = now() where server_id = $3",
+		status, message, serverID,
+	); dbErr != nil && err == nil {
+		err = dbErr
+	}
+
+	return err
+}
+
+func apply(ctx context.Context, db *sqlx.DB, sshClient *ssh.Client, proxy *models.ServerProxy) error {
+	routes, err := LoadRoutes(ctx, db, proxy.ServerID)
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{}
+	var image, mountPoint string
+	var env []string
+
+	switch proxy.Type {
+	case models.ProxyTypeTraefik:
+		image, mountPoint = traefikImage, "/etc/traefik"
+		static, err := traefikStaticConfig(proxy)
+		if err != nil {
+			return err
+		}
+		dynamic, err := traefikDynamicConfig(routes)
+		if err != nil {
+			return err
+		}
+		files["traefik.yml"] = static
+		files["dynamic/mo-sh.yml"] = dynamic
+		if proxy.ACMECACertificate != nil {
+			env = append(env, "LEGO_CA_CERTIFICATES="+mountPoint+"/acme-ca.pem")
+		}
+	case models.ProxyTypeCaddy:
+		image, mountPoint = caddyImage, "/etc/caddy"
+		caddyConfig, err := caddyfile(proxy, routes, mountPoint)
+		if err != nil {
+			return err
+		}
+		files["Caddyfile"] = caddyConfig
+	default:
+		return fmt.Errorf("unknown proxy type %q", proxy.Type)
+	}
+	if proxy.ACMECACertificate != nil {
+		files["acme-ca.pem"] = []byte(*proxy.ACMECACertificate)
+	}
+
+	// Traefik needs a restart for static config changes; routes are picked
+	// up from the watched dynamic directory. Caddy reloads everything.
+	hash := sha256.New()
+	fmt.Fprintln(hash, proxy.Type, image, strings.Join(env, ","))
+	if proxy.Type == models.ProxyTypeTraefik {
+		hash.Write(files["traefik.yml"])
+	}
+	configHash := hex.EncodeToString(hash.Sum(nil))[:16]
+
+	if _, stderr, err := sshClient.RunCommand(deploy.EnsureNetworkCommand + "\nmkdir -p " + configDir + "/dynamic"); err != nil {
+		return fmt.Errorf("failed to prepare the server: %w: %s", err, stderr)
+	}
+
+	for name, content := range files {
+		path := configDir + "/" + name
+		if stderr, err := sshClient.RunWithStdin("cat > "+git.ShellQuote(path), bytes.NewReader(content)); err != nil {
+			return fmt.Errorf("failed to write %s: %w: %s", path, err, stderr)
+		}
+	}
+	if proxy.ACMECACertificate == nil {
+		sshClient.RunCommand("rm -f " + configDir + "/acme-ca.pem")
+	}
+
+	current, _, _ := sshClient.RunCommand(fmt.Sprintf("docker inspect -f '{{index .Config.Labels \"mo-sh.proxy-config\"}} {{.State.Running}}' %s 2>/dev/null", ContainerName))
+	if strings.TrimSpace(current) == configHash+" true" {
+		if proxy.Type == models.ProxyTypeCaddy {
+			if _, stderr, err := sshClient.RunCommand("docker exec " + ContainerName + " caddy reload --config /etc/caddy/Caddyfile --adapter caddyfile"); err != nil {
+				return fmt.Errorf("failed to reload Caddy: %w: %s", err, stderr)
+			}
+		}
+		return nil
+	}
+
+	args := []string{
+		"docker", "run", "-d",
+		"--name", ContainerName,
+		"--restart", "unless-stopped",
+		"--network", deploy.Network,
+		"-p", "80:80", "-p", "443:443", "-p", "443:443/udp",
+		"-v", configDir + ":" + mountPoint + ":ro",
+		"-v", dataVolume + ":/data",
+		"--label", "mo-sh.proxy-config=" + configHash,
+	}
+	for _, value := range env {
+		args = append(args, "-e", git.ShellQuote(value))
+	}
+	args = append(args, image)
+
+	run := fmt.Sprintf("set -e\ndocker rm -f %s >/dev/null 2>&1 || true\n%s >/dev/null\n", ContainerName, strings.Join(args, " "))
+	if _, stderr, err := sshClient.RunCommand(run); err != nil {
+		return fmt.Errorf("failed to start the proxy: %w: %s", err, strings.TrimSpace(stderr))
+	}
+
+	return nil
+}
+
+// Remove stops and removes the proxy on a server, keeping its certificates.
+func Remove(sshClient *ssh.Client) error {
+	_, stderr, err := sshClient.RunCommand("docker rm -f " + ContainerName + " >/dev/null 2>&1 || true")
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, stderr)
+	}
+	return nil
+}
+
+// Traefik reads YAML; JSON is valid YAML, so both config files are written
+// with encoding/json.
+
+func traefikStaticConfig(proxy *models.ServerProxy) ([]byte, error) {
+	acme := map[string]any{
+		"storage":       "/data/acme.json",
+		"caServer":      proxy.ACMEDirectoryURL,
+		"httpChallenge": map[string]any{"entryPoint": "web"},
+	}
+	if proxy.ACMEEmail != nil {
+		acme["email"] = *proxy.ACMEEmail
+	}
+
+	config := map[string]any{
+		"entryPoints": map[string]any{
+			"web": map[string]any{
+				"address": ":80",
+				"http": map[string]any{
+					"redirections": map[string]any{
+						"entryPoint": map[string]any{"to": "websecure", "scheme": "https"},
+					},
+				},
+			},
+			"websecure": map[string]any{"address": ":443"},
+		},
+		"providers": map[string]any{
+			"file": map[string]any{"directory": "/etc/traefik/dynamic", "watch": true},
+		},
+		"certificatesResolvers": map[string]any{
+			certResolver: map[string]any{"acme": acme},
+		},
+	}
+
+	return json.MarshalIndent(config, "", "  ")
+}
+
+func traefikDynamicConfig(routes []Route) ([]byte, error) {
+	routers := map[string]any{}
+	services := map[string]any{}
+	for _, route := range routes {
+		if err := checkRoute(route); err != nil {
+			return nil, err
+		}
+
+		rules := make([]string, len(route.Hosts))
+		for i, host := range route.Hosts {
+			rules[i] = "Host(`" + host + "`)"
+		}
+
+		routers[route.Name] = map[string]any{
+			"rule":        strings.Join(rules, " || "),
+			"entryPoints": []string{"websecure"},
+			"service":     route.Name,
+			"tls":         map[string]any{"certResolver": certResolver},
+		}
+		services[route.Name] = map[string]any{
+			"loadBalancer": map[string]any{
+				"servers": []map[string]string{{"url": "http://" + route.Upstream}},
+			},
+		}
+	}
+
+	config := map[string]any{}
+	if len(routes) > 0 {
+		config["http"] = map[string]any{"routers": routers, "services": services}
+	}
+	return json.MarshalIndent(config, "", "  ")
+}
+
+func caddyfile(proxy *models.ServerProxy, routes []Route, mountPoint string) ([]byte, error) {
+	var b strings.Builder
+
+	b.WriteString("{\n")
+	if proxy.ACMEEmail != nil {
+		fmt.Fprintf(&b, "\temail %s\n", *proxy.ACMEEmail)
+	}
+	fmt.Fprintf(&b, "\tacme_ca %s\n", proxy.ACMEDirectoryURL)
+	if proxy.ACMECACertificate != nil {
+		fmt.Fprintf(&b, "\tacme_ca_root %s/acme-ca.pem\n", mountPoint)
+	}
+	b.WriteString("}\n")
+
+	for _, route := range routes {
+		if err := checkRoute(route); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(&b, "\n%s {\n\treverse_proxy %s\n}\n", strings.Join(route.Hosts, ", "), route.Upstream)
+	}
+
+	return []byte(b.String()), nil
+}
+
+var (
+	hostPattern     = regexp.MustCompile(`^[A-Za-z0-9]([A-Za-z0-9.-]*[A-Za-z0-9])?$`)
+	upstreamPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_.-]*:[0-9]{1,5}$`)
+)
+
+// checkRoute rejects a route that is more than host names and a host:port,
+// since both configs take them verbatim and anything else could add
+// directives of its own.
+func checkRoute(route Route) error {
+	for _, host := range route.Hosts {
+		if !hostPattern.MatchString(host) {
+			return fmt.Errorf("route %s has an invalid host %q", route.Name, host)
+		}
+	}
+	if !upstreamPattern.MatchString(route.Upstream) {
+		return fmt.Errorf("route %s has an invalid upstream %q", route.Name, route.Upstream)
+	}
+	return nil
+}
@@ -0,0 +1,53 @@
+package proxy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+)
+
+func TestCaddyfile(t *testing.T) {
+	proxy := &models.ServerProxy{ACMEDirectoryURL: "https://acme.example.com/directory"}
+	routes := []Route{{Name: "app-0", Hosts: []string{"example.com", "www.example.com"}, Upstream: "mo-sh-app-web:8080"}}
+
+	config, err := caddyfile(proxy, routes, "/etc/caddy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(config), "\nexample.com, www.example.com {\n\treverse_proxy mo-sh-app-web:8080\n}\n") {
+		t.Fatalf("caddyfile() =\n%s", config)
+	}
+}
+
+func TestConfigsRejectInjectedRoutes(t *testing.T) {
+	proxy := &models.ServerProxy{ACMEDirectoryURL: "https://acme.example.com/directory"}
+	for _, route := range []Route{
+		{Name: "app-0", Hosts: []string{"example.com"}, Upstream: "web:80\n}\n:8443 {\n\tfile_server {\n\t\troot /data\n\t}\n}"},
+		{Name: "app-0", Hosts: []string{"example.com"}, Upstream: "web app:80"},
+		{Name: "app-0", Hosts: []string{"example.com {"}, Upstream: "web:80"},
+		{Name: "app-0", Hosts: []string{"example.com`) || PathPrefix(`/"}, Upstream: "web:80"},
+	} {
+		if _, err := caddyfile(proxy, []Route{route}, "/etc/caddy"); err == nil {
+			t.Errorf("caddyfile(%q, %q) = nil, want an error", route.Hosts, route.Upstream)
+		}
+		if _, err := traefikDynamicConfig([]Route{route}); err == nil {
+			t.Errorf("traefikDynamicConfig(%q, %q) = nil, want an error", route.Hosts, route.Upstream)
+		}
+	}
+}
+
+func TestUpstreamForSkipsInvalidServices(t *testing.T) {
+	application := &models.Application{BuildType: models.BuildTypeCompose}
+	port := 80
+
+	service := "web"
+	if upstream, ok := upstreamFor(application, "slot", &models.Domain{Port: &port, Service: &service}); !ok || upstream != "slot-web:80" {
+		t.Fatalf("upstreamFor() = %q, %v", upstream, ok)
+	}
+
+	service = "web:80\n}"
+	if upstream, ok := upstreamFor(application, "slot", &models.Domain{Port: &port, Service: &service}); ok {
+		t.Fatalf("upstreamFor() = %q, want no route", upstream)
+	}
+}
@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+)
+
+// Route sends requests for Hosts to Upstream, a host:port on the Mo-SH
+// network.
+type Route struct {
+	Name     string
+	Hosts    []string
+	Upstream string
+}
+
+// LoadRoutes builds the routes for every application domain and open
+// preview on serverID.
+func LoadRoutes(ctx context.Context, db *sqlx.DB, serverID string) ([]Route, error) {
+	var applications []models.Application
+	if err := db.SelectContext(ctx, &applications, "select * from applications where server_id = $1", serverID); err != nil {
+		return nil, err
+	}
+
+	var routes []Route
+	for i := range applications {
+		application := &applications[i]
+
+		var domains []models.Domain
+		if err := db.SelectContext(ctx, &domains, "select * from domains where application_id = $1 order by created_at", application.ID); err != nil {
+			return nil, err
+		}
+
+		// Domains pointing at the same place share a route, and so a
+		// certificate request.
+		byUpstream := map[string][]string{}
+		var upstreams []string
+		for _, domain := range domains {
+			upstream, ok := upstreamFor(application, deploy.ContainerName(application.ID), &domain)
+			if !ok {
+				continue
+			}
+			if _, seen := byUpstream[upstream]; !seen {
+				upstreams = append(upstreams, upstream)
+			}
+			byUpstream[upstream] = append(byUpstream[upstream], domain.Hostname)
+		}
+		for n, upstream := range upstreams {
+			routes = append(routes, Route{
+				Name:     fmt.Sprintf("%s-%d", deploy.ContainerName(application.ID), n),
+				Hosts:    byUpstream[upstream],
+				Upstream: upstream,
+			})
+		}
+
+		if !application.PreviewDeployments {
+			continue
+		}
+
+		var previews []models.Preview
+		if err := db.SelectContext(ctx, &previews, "select * from previews where application_id = $1 and closed_at is null", application.ID); err != nil {
+			return nil, err
+		}
+
+		// Previews are served like the application's first domain.
+		var primary *models.Domain
+		if len(domains) > 0 {
+			primary = &domains[0]
+		}
+		for _, preview := range previews {
+			name := deploy.PreviewName(application.ID, preview.PullRequestNumber)
+			upstream, ok := upstreamFor(application, name, primary)
+			if !ok {
+				continue
+			}
+			routes = append(routes, Route{Name: name, Hosts: []string{preview.Domain}, Upstream: upstream})
+		}
+	}
+
+	sort.Slice(routes, func(a, b int) bool { return routes[a].Name < routes[b].Name })
+	return routes, nil
+}
+
+// upstreamFor is where domain's traffic goes for the deployment named slot:
+// the stable network alias of its container, or of a compose service.
+func upstreamFor(application *models.Application, slot string, domain *models.Domain) (string, bool) {
+	port := 0
+	if domain != nil && domain.Port != nil {
+		port = *domain.Port
+	} else if len(application.Ports) > 0 {
+		port = application.Ports[0].ContainerPort
+	}
+	if port == 0 {
+		return "", false
+	}
+
+	if application.BuildType == models.BuildTypeCompose {
+		// Domains saved before services were validated may name anything.
+		if domain == nil || domain.Service == nil || !deploy.ValidServiceName(*domain.Service) {
+			return "", false
+		}
+		return fmt.Sprintf("%s:%d", deploy.ServiceAlias(slot, *domain.Service), port), true
+	}
+
+	return fmt.Sprintf("%s:%d", slot, port), true
+}
@@ -0,0 +1,13 @@
+package shared
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// HashToken is used for high-entropy secrets such as recovery codes, where a
+// fast hash is enough and lets us look the value up directly.
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
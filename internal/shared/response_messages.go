@@ -1,7 +1,18 @@
 package shared
 
 const (
-	ErrInternalServer = "Something went wrong on our end. Please try again later."
-	ErrNotFound       = "We couldn't find what you were looking for."
-	ErrSSHConnection  = "SSH connection failed. Please check your server's SSH settings."
+	ErrInternalServer     = "Something went wrong on our end. Please try again later."
+	ErrNotFound           = "We couldn't find what you were looking for."
+	ErrSSHConnection      = "SSH connection failed. Please check your server's SSH settings."
+	ErrForbidden          = "You don't have permission to do that."
+	ErrInvalidTwoFactor   = "Invalid two-factor authentication code."
+	ErrTwoFactorRequired  = "Your team requires two-factor authentication. Please enable it to continue."
+	ErrInvalidCredentials = "Invalid email or password"
+	ErrTooManyRequests    = "Too many requests. Please try again later."
+	ErrAccountLocked      = "Too many failed login attempts. Please try again later."
+	ErrInvalidToken       = "This link is invalid or has expired."
+	ErrGithubUnavailable  = "We couldn't reach GitHub. Please try again later."
+	ErrSourceUnavailable  = "We couldn't reach the Git provider. Please try again later."
+	ErrSourceNotConnected = "This source isn't connected to its Git provider yet."
+	ErrNoEncryptionKey    = "Secrets can't be stored until an encryption key is configured."
 )
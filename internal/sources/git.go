@@ -0,0 +1,131 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+)
+
+// gitProvider serves a plain Git remote. The source is a single repository,
+// identified by the source id.
+type gitProvider struct {
+	Ctx        context.Context
+	Source     *models.Source
+	Connection *models.GitConnection
+	Auth       git.Auth
+}
+
+// LoadGitConnection returns a git source's connection and the credentials to
+// reach its remote. It returns ErrNotConnected if none was set up.
+func LoadGitConnection(ctx context.Context, db sqlx.QueryerContext, sourceID string) (*models.GitConnection, git.Auth, error) {
+	var connection models.GitConnection
+	if err := sqlx.GetContext(ctx, db, &connection, "select * from git_connections where source_id = $1", sourceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, git.Auth{}, ErrNotConnected
+		}
+		return nil, git.Auth{}, err
+	}
+
+	auth := git.Auth{}
+	if connection.Username != nil {
+		auth.Username = *connection.Username
+	}
+	if connection.Password != nil {
+		auth.Password = *connection.Password
+	}
+	if connection.KeyID != nil {
+		var key string
+		if err := sqlx.GetContext(ctx, db, &key, "select key from keys where id = $1", *connection.KeyID); err != nil {
+			return nil, git.Auth{}, err
+		}
+		auth.PrivateKey = []byte(key)
+	}
+
+	return &connection, auth, nil
+}
+
+func loadGitProvider(db *sqlx.DB, ctx context.Context, source *models.Source) (*gitProvider, error) {
+	connection, auth, err := LoadGitConnection(ctx, db, source.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &gitProvider{
+		Ctx:        ctx,
+		Source:     source,
+		Connection: connection,
+		Auth:       auth,
+	}, nil
+}
+
+func (p *gitProvider) repository() Repository {
+	fullName := p.Connection.RepositoryURL
+	if i := strings.Index(fullName, "://"); i >= 0 {
+		fullName = fullName[i+3:]
+	}
+	if i := strings.LastIndex(fullName, "@"); i >= 0 {
+		fullName = fullName[i+1:]
+	}
+	fullName = strings.TrimSuffix(strings.Replace(fullName, ":", "/", 1), ".git")
+
+	return Repository{
+		ID:            p.Source.ID,
+		Name:          path.Base(fullName),
+		FullName:      fullName,
+		Private:       true,
+		DefaultBranch: p.Connection.Branch,
+		CloneURL:      p.Connection.RepositoryURL,
+	}
+}
+
+func (p *gitProvider) ListRepositories(query string) ([]Repository, error) {
+	repository := p.repository()
+	if !strings.Contains(strings.ToLower(repository.FullName), strings.ToLower(query)) {
+		return []Repository{}, nil
+	}
+	return []Repository{repository}, nil
+}
+
+func (p *gitProvider) GetRepository(repositoryID string) (*Repository, error) {
+	if repositoryID != p.Source.ID {
+		return nil, sql.ErrNoRows
+	}
+	repository := p.repository()
+	return &repository, nil
+}
+
+func (p *gitProvider) ListBranches(repositoryID string) ([]Branch, error) {
+	if repositoryID != p.Source.ID {
+		return nil, sql.ErrNoRows
+	}
+
+	refs, err := git.LsRemote(p.Ctx, p.Connection.RepositoryURL, p.Auth)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]Branch, 0, len(refs))
+	for _, ref := range refs {
+		if name := ref.Branch(); name != "" {
+			branches = append(branches, Branch{Name: name, SHA: ref.SHA})
+		}
+	}
+	return branches, nil
+}
+
+func (p *gitProvider) CloneURL(repositoryID string) (string, error) {
+	if repositoryID != p.Source.ID {
+		return "", sql.ErrNoRows
+	}
+	return git.AuthenticatedURL(p.Connection.RepositoryURL, p.Auth)
+}
+
+func (p *gitProvider) DeployKey() []byte {
+	return p.Auth.PrivateKey
+}
@@ -0,0 +1,119 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/gitea"
+)
+
+// giteaProvider serves Gitea and Forgejo sources, which share an API.
+type giteaProvider struct {
+	DB         *sqlx.DB
+	Ctx        context.Context
+	Source     *models.Source
+	Connection *models.GiteaConnection
+	Client     *gitea.Client
+}
+
+func loadGiteaProvider(db *sqlx.DB, ctx context.Context, source *models.Source) (*giteaProvider, error) {
+	var connection models.GiteaConnection
+	if err := db.GetContext(ctx, &connection, "select * from gitea_connections where source_id = $1", source.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotConnected
+		}
+		return nil, err
+	}
+
+	return &giteaProvider{
+		DB:         db,
+		Ctx:        ctx,
+		Source:     source,
+		Connection: &connection,
+		Client:     gitea.NewClient(ctx, source.APIURL, connection.AccessToken),
+	}, nil
+}
+
+func giteaRepository(repository *gitea.Repository) Repository {
+	return Repository{
+		ID:            strconv.FormatInt(repository.ID, 10),
+		Name:          repository.Name,
+		FullName:      repository.FullName,
+		Private:       repository.Private,
+		DefaultBranch: repository.DefaultBranch,
+		HTMLURL:       repository.HTMLURL,
+		CloneURL:      repository.CloneURL,
+	}
+}
+
+// ListRepositories filters client side: /user/repos has no search parameter,
+// and /repos/search would also return every public repository.
+func (p *giteaProvider) ListRepositories(query string) ([]Repository, error) {
+	giteaRepositories, err := p.Client.ListRepositories()
+	if err != nil {
+		return nil, err
+	}
+
+	query = strings.ToLower(query)
+	repositories := make([]Repository, 0, len(giteaRepositories))
+	for i := range giteaRepositories {
+		if !strings.Contains(strings.ToLower(giteaRepositories[i].FullName), query) {
+			continue
+		}
+		repositories = append(repositories, giteaRepository(&giteaRepositories[i]))
+	}
+	return repositories, nil
+}
+
+func (p *giteaProvider) GetRepository(repositoryID string) (*Repository, error) {
+	remote, err := p.Client.GetRepository(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	repository := giteaRepository(remote)
+	return &repository, nil
+}
+
+func (p *giteaProvider) ListBranches(repositoryID string) ([]Branch, error) {
+	repository, err := p.Client.GetRepository(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	giteaBranches, err := p.Client.ListBranches(repository.FullName)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]Branch, len(giteaBranches))
+	for i, branch := range giteaBranches {
+		branches[i] = Branch{Name: branch.Name, SHA: branch.Commit.ID, Protected: branch.Protected}
+	}
+	return branches, nil
+}
+
+func (p *giteaProvider) CloneURL(repositoryID string) (string, error) {
+	repository, err := p.Client.GetRepository(repositoryID)
+	if err != nil {
+		return "", err
+	}
+	return p.Client.CloneURL(repository.CloneURL)
+}
+
+func (p *giteaProvider) RegisterWebhook(repositoryID, hookURL string) (string, error) {
+	repository, err := p.Client.GetRepository(repositoryID)
+	if err != nil {
+		return "", err
+	}
+
+	hook, err := p.Client.CreateHook(repository.FullName, hookURL, p.Connection.WebhookSecret)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(hook.ID, 10), nil
+}
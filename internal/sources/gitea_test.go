@@ -0,0 +1,115 @@
+package sources
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/gitea"
+)
+
+// newTestGiteaProvider serves one repository, owner/app with id 7, and
+// records the hooks created on it.
+func newTestGiteaProvider(t *testing.T, hooks *[]map[string]any) *giteaProvider {
+	t.Helper()
+
+	repository := gitea.Repository{
+		ID:            7,
+		Name:          "app",
+		FullName:      "owner/app",
+		Private:       true,
+		DefaultBranch: "main",
+		HTMLURL:       "https://gitea.example/owner/app",
+		CloneURL:      "https://gitea.example/owner/app.git",
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/user/repos", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode([]gitea.Repository{repository, {ID: 8, FullName: "owner/other"}})
+	})
+	mux.HandleFunc("GET /api/v1/repositories/7", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(repository)
+	})
+	mux.HandleFunc("GET /api/v1/repos/owner/app/branches", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"name": "main", "commit": {"id": "abc"}, "protected": true}]`))
+	})
+	mux.HandleFunc("POST /api/v1/repos/owner/app/hooks", func(w http.ResponseWriter, r *http.Request) {
+		var hook map[string]any
+		json.NewDecoder(r.Body).Decode(&hook)
+		*hooks = append(*hooks, hook)
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(gitea.Hook{ID: 42})
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+
+	connection := &models.GiteaConnection{SourceID: "source", AccessToken: "token", WebhookSecret: "secret"}
+	return &giteaProvider{
+		Ctx:        context.Background(),
+		Source:     &models.Source{ID: "source", Type: models.SourceTypeGitea, APIURL: gitea.APIURLFor(server.URL)},
+		Connection: connection,
+		Client:     gitea.NewClient(context.Background(), gitea.APIURLFor(server.URL), connection.AccessToken),
+	}
+}
+
+func TestGiteaProvider(t *testing.T) {
+	var hooks []map[string]any
+	var provider Provider = newTestGiteaProvider(t, &hooks)
+
+	repositories, err := provider.ListRepositories("APP")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repositories) != 1 || repositories[0].ID != "7" || repositories[0].DefaultBranch != "main" || !repositories[0].Private {
+		t.Fatalf("repositories = %+v", repositories)
+	}
+
+	repository, err := provider.GetRepository("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repository.FullName != "owner/app" || repository.CloneURL != "https://gitea.example/owner/app.git" {
+		t.Fatalf("repository = %+v", repository)
+	}
+
+	branches, err := provider.ListBranches("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 1 || branches[0] != (Branch{Name: "main", SHA: "abc", Protected: true}) {
+		t.Fatalf("branches = %+v", branches)
+	}
+
+	if _, err := provider.GetRepository("9"); !IsNotFound(err) {
+		t.Fatalf("GetRepository() of a missing repository = %v, want a not found error", err)
+	}
+}
+
+func TestGiteaProviderRegisterWebhook(t *testing.T) {
+	var hooks []map[string]any
+	provider := newTestGiteaProvider(t, &hooks)
+
+	registrar, ok := Provider(provider).(WebhookRegistrar)
+	if !ok {
+		t.Fatal("the gitea provider does not register webhooks")
+	}
+
+	hookID, err := registrar.RegisterWebhook("7", "https://mo-sh.example/webhooks/source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hookID != "42" {
+		t.Fatalf("hook id = %q, want 42", hookID)
+	}
+
+	if len(hooks) != 1 {
+		t.Fatalf("created %d hooks, want 1", len(hooks))
+	}
+	config, _ := hooks[0]["config"].(map[string]any)
+	if config["url"] != "https://mo-sh.example/webhooks/source" || config["secret"] != provider.Connection.WebhookSecret {
+		t.Fatalf("hook config = %v", config)
+	}
+}
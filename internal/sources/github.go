@@ -0,0 +1,162 @@
+package sources
+
+import (
+	"context"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/pkg/github"
+)
+
+// SaveGithubInstallation stores or updates an installation of a source's
+// GitHub App and returns its row id.
+func SaveGithubInstallation(ctx context.Context, db sqlx.ExtContext, sourceID string, installation *github.Installation) (string, error) {
+	query := `
+		insert into github_installations (
+			source_id, installation_id, account_id, account_login, account_type, target_type, repository_selection
+		) values (
+			$1, $2, $3, $4, $5, $6, $7
+		)
+		on conflict (source_id, installation_id) do update set
+			account_id = excluded.account_id,
+			account_login = excluded.account_login,
+			account_type = excluded.account_type,
+			target_type = excluded.target_type,
+			repository_selection = excluded.repository_selection,
+			updated_at = now()
+		returning id
+	`
+
+	var id string
+	err := sqlx.GetContext(
+		ctx,
+		db,
+		&id,
+		query,
+		sourceID,
+		installation.ID,
+		installation.Account.ID,
+		installation.Account.Login,
+		installation.Account.Type,
+		installation.TargetType,
+		installation.RepositorySelection,
+	)
+	return id, err
+}
+
+func DeleteGithubInstallation(ctx context.Context, db sqlx.ExecerContext, sourceID string, installationID int64) error {
+	_, err := db.ExecContext(
+		ctx,
+		"delete from github_installations where source_id = $1 and installation_id = $2",
+		sourceID, installationID,
+	)
+	return err
+}
+
+func SetGithubInstallationSuspended(ctx context.Context, db sqlx.ExecerContext, sourceID string, installationID int64, suspended bool) error {
+	_, err := db.ExecContext(
+		ctx,
+		`update github_installations
+		set suspended_at = case when $3 then now() else null end, updated_at = now()
+		where source_id = $1 and installation_id = $2`,
+		sourceID, installationID, suspended,
+	)
+	return err
+}
+
+func AddGithubRepositories(ctx context.Context, db sqlx.ExecerContext, installationRowID string, repositories []github.Repository) error {
+	query := `
+		insert into github_repositories (
+			installation_id, repository_id, name, full_name, private, default_branch, html_url, clone_url
+		) values (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)
+		on conflict (installation_id, repository_id) do update set
+			name = excluded.name,
+			full_name = excluded.full_name,
+			private = excluded.private,
+			-- Webhook payloads only carry a few repository fields; keep what we
+			-- already know rather than blanking it.
+			default_branch = coalesce(nullif(excluded.default_branch, ''), github_repositories.default_branch),
+			html_url = coalesce(nullif(excluded.html_url, ''), github_repositories.html_url),
+			clone_url = coalesce(nullif(excluded.clone_url, ''), github_repositories.clone_url),
+			updated_at = now()
+	`
+
+	for _, repository := range repositories {
+		if _, err := db.ExecContext(
+			ctx,
+			query,
+			installationRowID,
+			repository.ID,
+			repository.Name,
+			repository.FullName,
+			repository.Private,
+			repository.DefaultBranch,
+			repository.HTMLURL,
+			repository.CloneURL,
+		); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func RemoveGithubRepositories(ctx context.Context, db sqlx.ExecerContext, installationRowID string, repositories []github.Repository) error {
+	for _, repository := range repositories {
+		if _, err := db.ExecContext(
+			ctx,
+			"delete from github_repositories where installation_id = $1 and repository_id = $2",
+			installationRowID, repository.ID,
+		); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SyncGithubInstallation refreshes an installation and its full repository
+// list from GitHub, dropping repositories it no longer has access to. It
+// changes nothing unless GitHub returned the complete list.
+func SyncGithubInstallation(ctx context.Context, db *sqlx.DB, client *github.Client, installationID int64) error {
+	installation, err := client.GetInstallation(installationID)
+	if err != nil {
+		return err
+	}
+
+	repositories, err := client.ListInstallationRepositories(installationID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	rowID, err := SaveGithubInstallation(ctx, tx, client.SourceID, installation)
+	if err != nil {
+		return err
+	}
+
+	repositoryIDs := make([]int64, len(repositories))
+	for i, repository := range repositories {
+		repositoryIDs[i] = repository.ID
+	}
+
+	if _, err := tx.ExecContext(
+		ctx,
+		"delete from github_repositories where installation_id = $1 and not (repository_id = any($2))",
+		rowID, pq.Array(repositoryIDs),
+	); err != nil {
+		return err
+	}
+
+	if err := AddGithubRepositories(ctx, tx, rowID, repositories); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,154 @@
+package sources
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/pkg/github"
+)
+
+// githubProvider serves repositories from the tables kept in sync by the
+// app's installation webhooks, and calls GitHub as the owning installation.
+type githubProvider struct {
+	DB     *sqlx.DB
+	Ctx    context.Context
+	Client *github.Client
+}
+
+type githubRepositoryRow struct {
+	RepositoryID   int64  `db:"repository_id"`
+	Name           string `db:"name"`
+	FullName       string `db:"full_name"`
+	Private        bool   `db:"private"`
+	DefaultBranch  string `db:"default_branch"`
+	HTMLURL        string `db:"html_url"`
+	CloneURL       string `db:"clone_url"`
+	InstallationID int64  `db:"installation_id"`
+}
+
+func (row *githubRepositoryRow) repository() Repository {
+	return Repository{
+		ID:            strconv.FormatInt(row.RepositoryID, 10),
+		Name:          row.Name,
+		FullName:      row.FullName,
+		Private:       row.Private,
+		DefaultBranch: row.DefaultBranch,
+		HTMLURL:       row.HTMLURL,
+		CloneURL:      row.CloneURL,
+	}
+}
+
+const githubRepositoryQuery = `
+	select
+		gr.repository_id, gr.name, gr.full_name, gr.private, gr.default_branch,
+		gr.html_url, gr.clone_url, gi.installation_id
+	from
+		github_repositories gr
+	inner join
+		github_installations gi ON gi.id = gr.installation_id
+	where
+		gi.source_id = $1
+`
+
+func (p *githubProvider) ListRepositories(query string) ([]Repository, error) {
+	var rows []githubRepositoryRow
+	if err := p.DB.SelectContext(
+		p.Ctx,
+		&rows,
+		githubRepositoryQuery+" and gr.full_name ilike '%' || $2 || '%' order by gr.full_name",
+		p.Client.SourceID, query,
+	); err != nil {
+		return nil, err
+	}
+
+	repositories := make([]Repository, len(rows))
+	for i := range rows {
+		repositories[i] = rows[i].repository()
+	}
+	return repositories, nil
+}
+
+func (p *githubProvider) GetRepository(repositoryID string) (*Repository, error) {
+	row, err := p.repositoryRow(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	repository := row.repository()
+	return &repository, nil
+}
+
+func (p *githubProvider) ListBranches(repositoryID string) ([]Branch, error) {
+	row, err := p.repositoryRow(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	githubBranches, err := p.Client.ListBranches(row.InstallationID, row.FullName)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]Branch, len(githubBranches))
+	for i, branch := range githubBranches {
+		branches[i] = Branch{Name: branch.Name, SHA: branch.Commit.SHA, Protected: branch.Protected}
+	}
+	return branches, nil
+}
+
+func (p *githubProvider) CloneURL(repositoryID string) (string, error) {
+	row, err := p.repositoryRow(repositoryID)
+	if err != nil {
+		return "", err
+	}
+	return p.Client.CloneURL(row.InstallationID, row.CloneURL)
+}
+
+func (p *githubProvider) SetCommitStatus(repositoryID, sha string, status *CommitStatus) error {
+	row, err := p.repositoryRow(repositoryID)
+	if err != nil {
+		return err
+	}
+	return p.Client.CreateCommitStatus(row.InstallationID, row.FullName, sha, &github.CommitStatus{
+		State:       status.State,
+		TargetURL:   status.TargetURL,
+		Description: status.Description,
+		Context:     status.Context,
+	})
+}
+
+func (p *githubProvider) CommentOnPullRequest(repositoryID string, number int, commentID, body string) (string, error) {
+	row, err := p.repositoryRow(repositoryID)
+	if err != nil {
+		return "", err
+	}
+
+	if commentID != "" {
+		id, err := strconv.ParseInt(commentID, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		comment, err := p.Client.UpdateIssueComment(row.InstallationID, row.FullName, id, body)
+		// Fall through and comment again if someone deleted it.
+		if err == nil {
+			return strconv.FormatInt(comment.ID, 10), nil
+		}
+		if !github.IsNotFound(err) {
+			return "", err
+		}
+	}
+
+	comment, err := p.Client.CreateIssueComment(row.InstallationID, row.FullName, number, body)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(comment.ID, 10), nil
+}
+
+func (p *githubProvider) repositoryRow(repositoryID string) (*githubRepositoryRow, error) {
+	var row githubRepositoryRow
+	if err := p.DB.GetContext(p.Ctx, &row, githubRepositoryQuery+" and gr.repository_id::text = $2", p.Client.SourceID, repositoryID); err != nil {
+		return nil, err
+	}
+	return &row, nil
+}
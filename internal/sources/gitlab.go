@@ -0,0 +1,155 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/gitlab"
+)
+
+// Refresh OAuth tokens this long before they expire so that a clone started
+// just before expiry still works.
+const gitlabTokenExpiryMargin = 5 * time.Minute
+
+type gitlabProvider struct {
+	DB     *sqlx.DB
+	Ctx    context.Context
+	Source *models.Source
+	Client *gitlab.Client
+}
+
+func loadGitlabProvider(db *sqlx.DB, ctx context.Context, source *models.Source) (*gitlabProvider, error) {
+	var connection models.GitlabConnection
+	if err := db.GetContext(ctx, &connection, "select * from gitlab_connections where source_id = $1", source.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotConnected
+		}
+		return nil, err
+	}
+
+	if connection.AccessToken == nil {
+		return nil, ErrNotConnected
+	}
+
+	if connection.AuthType == models.GitlabAuthOAuth && connection.TokenExpiresAt != nil &&
+		time.Until(*connection.TokenExpiresAt) < gitlabTokenExpiryMargin {
+		if err := refreshGitlabToken(db, ctx, source, &connection); err != nil {
+			return nil, err
+		}
+	}
+
+	return &gitlabProvider{
+		DB:     db,
+		Ctx:    ctx,
+		Source: source,
+		Client: gitlab.NewClient(ctx, source.APIURL, *connection.AccessToken),
+	}, nil
+}
+
+// SaveGitlabToken stores the OAuth token GitLab issued for a source.
+func SaveGitlabToken(ctx context.Context, db sqlx.ExecerContext, sourceID string, token *gitlab.Token) error {
+	var expiresAt *time.Time
+	if value := token.ExpiresAt(); !value.IsZero() {
+		expiresAt = &value
+	}
+
+	_, err := db.ExecContext(
+		ctx,
+		`update gitlab_connections
+		set access_token = $1, refresh_token = $2, token_expires_at = $3, updated_at = now()
+		where source_id = $4`,
+		token.AccessToken, token.RefreshToken, expiresAt, sourceID,
+	)
+	return err
+}
+
+func refreshGitlabToken(db *sqlx.DB, ctx context.Context, source *models.Source, connection *models.GitlabConnection) error {
+	if connection.ClientID == nil || connection.ClientSecret == nil || connection.RefreshToken == nil || connection.RedirectURL == nil {
+		return ErrNotConnected
+	}
+
+	token, err := gitlab.RefreshToken(ctx, source.HTMLURL, *connection.ClientID, *connection.ClientSecret, *connection.RedirectURL, *connection.RefreshToken)
+	if err != nil {
+		return err
+	}
+
+	if err := SaveGitlabToken(ctx, db, source.ID, token); err != nil {
+		return err
+	}
+
+	connection.AccessToken = &token.AccessToken
+	return nil
+}
+
+func gitlabRepository(project *gitlab.Project) Repository {
+	return Repository{
+		ID:            strconv.FormatInt(project.ID, 10),
+		Name:          project.Name,
+		FullName:      project.PathWithNamespace,
+		Private:       project.Visibility != "public",
+		DefaultBranch: project.DefaultBranch,
+		HTMLURL:       project.WebURL,
+		CloneURL:      project.HTTPURLToRepo,
+	}
+}
+
+func (p *gitlabProvider) ListRepositories(query string) ([]Repository, error) {
+	projects, err := p.Client.ListProjects(query)
+	if err != nil {
+		return nil, err
+	}
+
+	repositories := make([]Repository, len(projects))
+	for i := range projects {
+		repositories[i] = gitlabRepository(&projects[i])
+	}
+	return repositories, nil
+}
+
+func (p *gitlabProvider) GetRepository(repositoryID string) (*Repository, error) {
+	project, err := p.Client.GetProject(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+	repository := gitlabRepository(project)
+	return &repository, nil
+}
+
+func (p *gitlabProvider) ListBranches(repositoryID string) ([]Branch, error) {
+	gitlabBranches, err := p.Client.ListBranches(repositoryID)
+	if err != nil {
+		return nil, err
+	}
+
+	branches := make([]Branch, len(gitlabBranches))
+	for i, branch := range gitlabBranches {
+		branches[i] = Branch{Name: branch.Name, SHA: branch.Commit.ID, Protected: branch.Protected}
+	}
+	return branches, nil
+}
+
+func (p *gitlabProvider) CloneURL(repositoryID string) (string, error) {
+	project, err := p.Client.GetProject(repositoryID)
+	if err != nil {
+		return "", err
+	}
+	return p.Client.CloneURL(project.HTTPURLToRepo)
+}
+
+func (p *gitlabProvider) RegisterWebhook(repositoryID, hookURL string) (string, error) {
+	var secret string
+	if err := p.DB.GetContext(p.Ctx, &secret, "select webhook_secret from gitlab_connections where source_id = $1", p.Source.ID); err != nil {
+		return "", err
+	}
+
+	hook, err := p.Client.CreateHook(repositoryID, hookURL, secret)
+	if err != nil {
+		return "", err
+	}
+	return strconv.FormatInt(hook.ID, 10), nil
+}
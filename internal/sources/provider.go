@@ -0,0 +1,119 @@
+package sources
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/gitea"
+	"github.com/mohit4bug/mo-sh/pkg/github"
+	"github.com/mohit4bug/mo-sh/pkg/gitlab"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrNotConnected is returned when a source has no credentials yet, such as a
+// GitHub source without an app or a GitLab source that was never authorized.
+var ErrNotConnected = errors.New("sources: source is not connected")
+
+// Repository is a repository as every source type exposes it. ID is the
+// provider's own identifier.
+type Repository struct {
+	ID            string `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"fullName"`
+	Private       bool   `json:"private"`
+	DefaultBranch string `json:"defaultBranch"`
+	HTMLURL       string `json:"htmlUrl"`
+	CloneURL      string `json:"cloneUrl"`
+}
+
+type Branch struct {
+	Name      string `json:"name"`
+	SHA       string `json:"sha"`
+	Protected bool   `json:"protected"`
+}
+
+// Provider is what the rest of Mo-SH needs from a source, whichever Git host
+// it points at.
+type Provider interface {
+	ListRepositories(query string) ([]Repository, error)
+	GetRepository(repositoryID string) (*Repository, error)
+	ListBranches(repositoryID string) ([]Branch, error)
+	// CloneURL returns an HTTPS URL for repositoryID with credentials embedded,
+	// so git can clone private repositories. Split them off with
+	// git.SplitCredentials before running git.
+	CloneURL(repositoryID string) (string, error)
+}
+
+// WebhookRegistrar is implemented by providers whose webhooks are registered
+// on each repository. GitHub App webhooks cover every installation already.
+type WebhookRegistrar interface {
+	RegisterWebhook(repositoryID, hookURL string) (hookID string, err error)
+}
+
+// DeployKeyProvider is implemented by providers that clone over SSH with a
+// deploy key rather than with credentials in the clone URL. DeployKey returns
+// nil when no key is needed.
+type DeployKeyProvider interface {
+	DeployKey() []byte
+}
+
+// StatusReporter is implemented by providers that can report deployments back
+// to the repository host. commentID is empty for a new comment; the returned
+// id is used to update it later.
+type StatusReporter interface {
+	SetCommitStatus(repositoryID, sha string, status *CommitStatus) error
+	CommentOnPullRequest(repositoryID string, number int, commentID, body string) (string, error)
+}
+
+// CommitStatus is a deployment's state as shown next to a commit.
+type CommitStatus struct {
+	State       string
+	Context     string
+	Description string
+	TargetURL   string
+}
+
+const (
+	CommitStatusPending = "pending"
+	CommitStatusSuccess = "success"
+	CommitStatusFailure = "failure"
+)
+
+// Load returns the provider for sourceID. It returns sql.ErrNoRows if the
+// source doesn't exist.
+func Load(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, sourceID string) (Provider, error) {
+	var source models.Source
+	if err := db.GetContext(ctx, &source, "select * from sources where id = $1", sourceID); err != nil {
+		return nil, err
+	}
+
+	switch source.Type {
+	case models.SourceTypeGithub:
+		client, err := github.LoadClient(db, redisClient, ctx, sourceID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil, ErrNotConnected
+			}
+			return nil, err
+		}
+		return &githubProvider{DB: db, Ctx: ctx, Client: client}, nil
+	case models.SourceTypeGitlab:
+		return loadGitlabProvider(db, ctx, &source)
+	case models.SourceTypeGitea:
+		return loadGiteaProvider(db, ctx, &source)
+	case models.SourceTypeGit:
+		return loadGitProvider(db, ctx, &source)
+	default:
+		return nil, fmt.Errorf("sources: unknown source type %q", source.Type)
+	}
+}
+
+// IsNotFound reports whether err means the repository or branch doesn't exist
+// or isn't accessible to the source.
+func IsNotFound(err error) bool {
+	return errors.Is(err, sql.ErrNoRows) || github.IsNotFound(err) || gitlab.IsNotFound(err) || gitea.IsNotFound(err)
+}
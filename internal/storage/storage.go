@@ -0,0 +1,73 @@
+// Package storage connects to the S3-compatible storage destinations that
+// backups and snapshots are kept in.
+package storage
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/s3"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+)
+
+// httpClient bounds each request, not the transfer as a whole, so large
+// uploads and downloads aren't cut off.
+var httpClient = &http.Client{
+	Transport: &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		ResponseHeaderTimeout: 5 * time.Minute,
+		TLSHandshakeTimeout:   10 * time.Second,
+	},
+}
+
+// Client returns a client for destination's bucket.
+func Client(destination *models.StorageDestination, secretCipher *secrets.Cipher) (*s3.Client, error) {
+	secretAccessKey, err := secretCipher.Decrypt(destination.SecretAccessKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt the secret access key: %w", err)
+	}
+
+	return &s3.Client{
+		Endpoint:        destination.Endpoint,
+		Region:          destination.Region,
+		Bucket:          destination.Bucket,
+		AccessKeyID:     destination.AccessKeyID,
+		SecretAccessKey: secretAccessKey,
+		PathStyle:       destination.PathStyle,
+		HTTPClient:      httpClient,
+	}, nil
+}
+
+// Load returns the destination with the given id and a client for it.
+func Load(ctx context.Context, db *sqlx.DB, secretCipher *secrets.Cipher, destinationID string) (*models.StorageDestination, *s3.Client, error) {
+	var destination models.StorageDestination
+	if err := db.GetContext(ctx, &destination, "select * from storage_destinations where id = $1", destinationID); err != nil {
+		return nil, nil, fmt.Errorf("failed to load the storage destination: %w", err)
+	}
+
+	client, err := Client(&destination, secretCipher)
+	if err != nil {
+		return nil, nil, err
+	}
+	return &destination, client, nil
+}
+
+// ObjectKey joins parts under destination's path prefix.
+func ObjectKey(destination *models.StorageDestination, parts ...string) string {
+	return strings.TrimPrefix(path.Join(append([]string{destination.PathPrefix}, parts...)...), "/")
+}
+
+var prefixPattern = regexp.MustCompile(`^[A-Za-z0-9._-]+(/[A-Za-z0-9._-]+)*$`)
+
+// ValidPathPrefix reports whether prefix can start object keys: slash
+// separated segments of letters, digits, dots, dashes and underscores.
+func ValidPathPrefix(prefix string) bool {
+	return prefix == "" || prefixPattern.MatchString(prefix) && !strings.Contains("/"+prefix+"/", "/../")
+}
@@ -0,0 +1,188 @@
+package volumes
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+)
+
+// Name is the Docker volume behind a named volume. It is derived from the
+// id, so renaming the volume leaves its data where it is.
+func Name(volumeID string) string {
+	return "mo-sh-volume-" + volumeID
+}
+
+// ValidPath reports whether value is a clean, absolute path other than the
+// root that can be written into a --mount option, which splits on commas.
+func ValidPath(value string) bool {
+	return strings.HasPrefix(value, "/") &&
+		value != "/" &&
+		path.Clean(value) == value &&
+		!strings.ContainsAny(value, ",:\n\"")
+}
+
+// Mount is the volume's --mount option for docker run.
+func Mount(volume *models.Volume) string {
+	option := "type=volume,source=" + Name(volume.ID)
+	if volume.Type == models.VolumeTypeBind {
+		option = "type=bind,source=" + *volume.HostPath
+	}
+	option += ",target=" + volume.MountPath
+	if volume.ReadOnly {
+		option += ",readonly"
+	}
+	return option
+}
+
+// Ensure creates the volumes that don't exist on the server yet: Docker
+// volumes labelled with their application, and directories for bind mounts.
+func Ensure(sshClient *ssh.Client, volumes []models.Volume) error {
+	if len(volumes) == 0 {
+		return nil
+	}
+
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	for _, volume := range volumes {
+		if volume.Type == models.VolumeTypeBind {
+			fmt.Fprintf(&script, "mkdir -p %s\n", git.ShellQuote(*volume.HostPath))
+			continue
+		}
+
+		name := git.ShellQuote(Name(volume.ID))
+		fmt.Fprintf(
+			&script,
+			"docker volume inspect %s >/dev/null 2>&1 || docker volume create --label mo-sh.managed=true --label %s --label %s %s >/dev/null\n",
+			name,
+			git.ShellQuote("mo-sh.application="+volume.ApplicationID),
+			git.ShellQuote("mo-sh.volume="+volume.ID),
+			name,
+		)
+	}
+
+	if _, stderr, err := sshClient.RunCommand(script.String()); err != nil {
+		return fmt.Errorf("failed to create volumes: %w: %s", err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// Move moves a bind mount's directory from one host path to another. A
+// directory that was never created is created in its new place instead.
+func Move(sshClient *ssh.Client, from, to string) error {
+	script := fmt.Sprintf(
+		`set -e
+if [ -e %[2]s ]; then echo "the new host path already exists" >&2; exit 1; fi
+mkdir -p "$(dirname %[2]s)"
+if [ -e %[1]s ]; then mv %[1]s %[2]s; else mkdir %[2]s; fi
+`,
+		git.ShellQuote(from), git.ShellQuote(to),
+	)
+	if _, stderr, err := sshClient.RunCommand(script); err != nil {
+		return fmt.Errorf("failed to move %s: %w: %s", from, err, strings.TrimSpace(stderr))
+	}
+	return nil
+}
+
+// RemoveCommand removes the Docker volume behind a named volume. A volume a
+// container still uses is kept; PruneCommand removes it once the container is gone.
+func RemoveCommand(volumeID string) string {
+	return "docker volume rm " + git.ShellQuote(Name(volumeID)) + " >/dev/null 2>&1 || true"
+}
+
+// PruneCommand removes the application's Docker volumes other than those in
+// keep, which were deleted while a container still used them.
+func PruneCommand(applicationID string, keep []models.Volume) string {
+	filter := "cat"
+	for _, volume := range keep {
+		if volume.Type == models.VolumeTypeVolume {
+			if filter == "cat" {
+				filter = "grep -vxF"
+			}
+			filter += " -e " + git.ShellQuote(Name(volume.ID))
+		}
+	}
+	return fmt.Sprintf(
+		"docker volume ls -q --filter %s | %s | xargs -r docker volume rm >/dev/null 2>&1 || true\n",
+		git.ShellQuote("label=mo-sh.application="+applicationID), filter,
+	)
+}
+
+// Usage measures how much disk each volume takes on the server.
+func Usage(sshClient *ssh.Client, volumes []models.Volume) ([]models.VolumeUsage, error) {
+	usage := make([]models.VolumeUsage, 0, len(volumes))
+	if len(volumes) == 0 {
+		return usage, nil
+	}
+
+	var script strings.Builder
+	for _, volume := range volumes {
+		fmt.Fprintf(
+			&script,
+			"dir=%s; printf '%%s ' %s; { du -sb -- \"$dir\" 2>/dev/null || echo -; } | cut -f1\n",
+			directory(&volume), volume.ID,
+		)
+	}
+
+	stdout, stderr, err := sshClient.RunCommand(script.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to measure volumes: %w: %s", err, strings.TrimSpace(stderr))
+	}
+
+	sizes := map[string]int64{}
+	for _, line := range strings.Split(stdout, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		if size, err := strconv.ParseInt(fields[1], 10, 64); err == nil {
+			sizes[fields[0]] = size
+		}
+	}
+
+	for _, volume := range volumes {
+		entry := models.VolumeUsage{VolumeID: volume.ID}
+		if size, ok := sizes[volume.ID]; ok {
+			entry.Size = &size
+		}
+		usage = append(usage, entry)
+	}
+	return usage, nil
+}
+
+// Snapshot writes a gzipped tarball of the volume's contents to w. Files
+// that change while they are read are taken as they are; stop the
+// application first for a consistent snapshot.
+func Snapshot(sshClient *ssh.Client, volume *models.Volume, w io.Writer) error {
+	// GNU tar exits with 1 when files changed as it read them.
+	script := fmt.Sprintf(
+		`set -o pipefail
+dir=%s
+if [ ! -d "$dir" ]; then echo "the volume doesn't exist on the server" >&2; exit 1; fi
+{ tar --warning=no-file-changed -C "$dir" -cf - . || [ $? -eq 1 ]; } | gzip -c`,
+		directory(volume),
+	)
+
+	stderr, err := sshClient.RunWithOutput("bash -c "+git.ShellQuote(script), nil, w)
+	if err != nil {
+		if message := strings.TrimSpace(stderr); message != "" {
+			return errors.New(message)
+		}
+		return fmt.Errorf("failed to snapshot the volume: %w", err)
+	}
+	return nil
+}
+
+// directory is a shell expression for the volume's directory on the server.
+func directory(volume *models.Volume) string {
+	if volume.Type == models.VolumeTypeBind {
+		return git.ShellQuote(*volume.HostPath)
+	}
+	return fmt.Sprintf(`"$(docker volume inspect -f '{{.Mountpoint}}' %s 2>/dev/null)"`, git.ShellQuote(Name(volume.ID)))
+}
@@ -0,0 +1,139 @@
+package workers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/pkg/s3"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+)
+
+// Backups and volume snapshots are both archives streamed from a server to
+// storage and pruned by their schedule's retention.
+
+// uploadArchive stores everything produce writes under key and returns its
+// size and SHA-256. produce runs on sshClient, which is closed if the upload
+// gives up first, since produce blocks once nothing reads it.
+func uploadArchive(ctx context.Context, client *s3.Client, sshClient *ssh.Client, key string, produce func(w io.Writer) error) (int64, string, error) {
+	reader, writer := io.Pipe()
+	produced := make(chan error, 1)
+	go func() {
+		err := produce(writer)
+		writer.CloseWithError(err)
+		produced <- err
+	}()
+
+	hash := sha256.New()
+	size, err := client.Upload(ctx, key, io.TeeReader(reader, hash))
+	if err != nil {
+		reader.CloseWithError(err)
+		sshClient.Close()
+	}
+
+	// A failed produce fails the upload with the same error.
+	if produceErr := <-produced; produceErr != nil && (err == nil || errors.Is(err, produceErr)) {
+		return 0, "", produceErr
+	}
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to upload to storage: %w", err)
+	}
+
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// archive is the part of a backup or snapshot retention looks at.
+type archive struct {
+	ID            string    `db:"id"`
+	DestinationID string    `db:"destination_id"`
+	ObjectKey     string    `db:"object_key"`
+	CreatedAt     time.Time `db:"created_at"`
+}
+
+// applyRetention deletes the schedule's successful archives in table that
+// keepDaily and keepWeekly no longer cover, from storage and then from the
+// database. With both at zero it keeps everything.
+func applyRetention(ctx context.Context, db *sqlx.DB, secretCipher *secrets.Cipher, table, scheduleID string, keepDaily, keepWeekly int) error {
+	if keepDaily == 0 && keepWeekly == 0 {
+		return nil
+	}
+
+	var archives []archive
+	if err := db.SelectContext(
+		ctx,
+		&archives,
+		fmt.Sprintf("select id, destination_id, object_key, created_at from %s where schedule_id = $1 and status = $2 order by created_at desc", table),
+		scheduleID, models.BackupSucceeded,
+	); err != nil {
+		return err
+	}
+
+	created := make([]time.Time, len(archives))
+	for i, archive := range archives {
+		created[i] = archive.CreatedAt
+	}
+
+	keep := retained(created, keepDaily, keepWeekly)
+	clients := map[string]*s3.Client{}
+	for i, archive := range archives {
+		if keep[i] {
+			continue
+		}
+
+		client, ok := clients[archive.DestinationID]
+		if !ok {
+			var err error
+			if _, client, err = storage.Load(ctx, db, secretCipher, archive.DestinationID); err != nil {
+				return err
+			}
+			clients[archive.DestinationID] = client
+		}
+
+		if err := client.Delete(ctx, archive.ObjectKey); err != nil {
+			return fmt.Errorf("failed to delete %s: %w", archive.ObjectKey, err)
+		}
+		if _, err := db.ExecContext(ctx, fmt.Sprintf("delete from %s where id = $1", table), archive.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// retained picks the newest of each of the last keepDaily days and
+// keepWeekly ISO weeks from created, which is sorted newest first, by index.
+// The newest is always kept.
+func retained(created []time.Time, keepDaily, keepWeekly int) map[int]bool {
+	keep := map[int]bool{}
+	if len(created) > 0 {
+		keep[0] = true
+	}
+
+	days, weeks := map[string]bool{}, map[string]bool{}
+	for i, at := range created {
+		at = at.UTC()
+
+		day := at.Format("2006-01-02")
+		if !days[day] && len(days) < keepDaily {
+			days[day] = true
+			keep[i] = true
+		}
+
+		year, number := at.ISOWeek()
+		week := fmt.Sprintf("%d-%d", year, number)
+		if !weeks[week] && len(weeks) < keepWeekly {
+			weeks[week] = true
+			keep[i] = true
+		}
+	}
+
+	return keep
+}
@@ -0,0 +1,62 @@
+package workers
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetained(t *testing.T) {
+	at := func(day, hour int) time.Time {
+		return time.Date(2025, 1, day, hour, 0, 0, 0, time.UTC)
+	}
+
+	// Newest first. January 6th 2025 is a Monday, starting ISO week 2.
+	created := []time.Time{
+		at(8, 12), // 0
+		at(8, 1),  // 1
+		at(7, 12), // 2
+		at(6, 12), // 3
+		at(5, 23), // 4
+		at(5, 1),  // 5
+		at(1, 12), // 6
+	}
+
+	tests := []struct {
+		name                  string
+		keepDaily, keepWeekly int
+		want                  map[int]bool
+	}{
+		{"nothing configured keeps the newest", 0, 0, map[int]bool{0: true}},
+		{"newest of each day", 3, 0, map[int]bool{0: true, 2: true, 3: true}},
+		{"newest of each week", 0, 2, map[int]bool{0: true, 4: true}},
+		{"days and weeks overlap", 2, 3, map[int]bool{0: true, 2: true, 4: true}},
+		{"more than there are", 30, 30, map[int]bool{0: true, 2: true, 3: true, 4: true, 6: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := retained(created, tt.keepDaily, tt.keepWeekly); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("retained() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetainedEmpty(t *testing.T) {
+	if got := retained(nil, 7, 4); len(got) != 0 {
+		t.Fatalf("retained(nil) = %v, want nothing", got)
+	}
+}
+
+func TestRetainedUsesUTC(t *testing.T) {
+	// Both are on January 2nd in UTC, though not in UTC-5.
+	local := time.FixedZone("UTC-5", -5*60*60)
+	created := []time.Time{
+		time.Date(2025, 1, 2, 10, 0, 0, 0, time.UTC),
+		time.Date(2025, 1, 1, 20, 0, 0, 0, local),
+	}
+
+	if got := retained(created, 2, 0); !reflect.DeepEqual(got, map[int]bool{0: true}) {
+		t.Fatalf("retained() = %v, want only the newest", got)
+	}
+}
@@ -0,0 +1,321 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/databases"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/pkg/cron"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	BackupPendingQueue    = "backup:pending"
+	BackupProcessingQueue = "backup:processing"
+
+	// Backups hold the database's lock, so that it isn't stopped or
+	// recreated mid-dump. Large dumps take a while.
+	backupLockTimeout = 6 * time.Hour
+
+	backupScheduleTick = 30 * time.Second
+)
+
+type backupWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewBackupWorker(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *backupWorker {
+	return &backupWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+func (w *backupWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *backupWorker) worker() {
+	for {
+		backupID, err := w.RedisClient.BRPopLPush(w.Ctx, BackupPendingQueue, BackupProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(backupID)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, BackupProcessingQueue, 1, backupID).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *backupWorker) process(backupID string) {
+	var backup models.Backup
+	if err := w.DB.GetContext(w.Ctx, &backup, "select * from backups where id = $1", backupID); err != nil {
+		log.Println("backupWorker: failed to load backup", backupID, err)
+		return
+	}
+	if backup.Status != models.BackupPending {
+		return
+	}
+	if backup.DatabaseID == nil {
+		w.finish(&backup, errors.New("the database was deleted"))
+		return
+	}
+
+	lockKey := databaseLockPrefix + *backup.DatabaseID
+	locked, err := w.RedisClient.SetNX(w.Ctx, lockKey, "backup", backupLockTimeout).Result()
+	if err != nil {
+		log.Println("backupWorker: failed to lock database", *backup.DatabaseID, err)
+		return
+	}
+	if !locked {
+		time.Sleep(databaseRetryDelay)
+		w.RedisClient.LPush(w.Ctx, BackupPendingQueue, backupID)
+		return
+	}
+	defer w.RedisClient.Del(w.Ctx, lockKey)
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update backups set status = $1, started_at = now() where id = $2",
+		models.BackupRunning, backupID,
+	); err != nil {
+		log.Println("backupWorker: failed to update backup", backupID, err)
+		return
+	}
+
+	w.finish(&backup, w.run(&backup))
+
+	if backup.ScheduleID != nil && backup.Status == models.BackupSucceeded {
+		if err := ApplyRetention(w.Ctx, w.DB, w.Secrets, *backup.ScheduleID); err != nil {
+			log.Println("backupWorker: failed to apply retention for schedule", *backup.ScheduleID, err)
+		}
+	}
+}
+
+// run streams a compressed dump from the server to the destination,
+// recording its size and checksum on backup.
+func (w *backupWorker) run(backup *models.Backup) error {
+	var database models.Database
+	if err := w.DB.GetContext(w.Ctx, &database, "select * from databases where id = $1", *backup.DatabaseID); err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	if database.Status != models.DatabaseRunning {
+		return fmt.Errorf("the database is %s, not running", database.Status)
+	}
+
+	password, err := w.Secrets.Decrypt(database.Password)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the password: %w", err)
+	}
+
+	_, client, err := storage.Load(w.Ctx, w.DB, w.Secrets, backup.DestinationID)
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := ConnectServer(w.Ctx, w.DB, database.ServerID)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	size, checksum, err := uploadArchive(w.Ctx, client, sshClient, backup.ObjectKey, func(writer io.Writer) error {
+		return databases.Dump(sshClient, &database, password, writer)
+	})
+	if err != nil {
+		return err
+	}
+
+	backup.Size, backup.Checksum = &size, &checksum
+	return nil
+}
+
+func (w *backupWorker) finish(backup *models.Backup, err error) {
+	var message *string
+	backup.Status = models.BackupSucceeded
+	if err != nil {
+		log.Println("backupWorker: backup failed", backup.ID, err)
+		value := err.Error()
+		message = &value
+		backup.Status = models.BackupFailed
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update backups set status = $1, size = $2, checksum = $3, error = $4, finished_at = now() where id = $5",
+		backup.Status, backup.Size, backup.Checksum, message, backup.ID,
+	); err != nil {
+		log.Println("backupWorker: failed to update backup", backup.ID, err)
+	}
+}
+
+// QueueBackup records a pending backup of the database to the destination
+// and hands it to the backup worker. scheduleID is nil for backups taken on
+// demand, which retention never deletes.
+func QueueBackup(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, databaseID, destinationID string, scheduleID *string) (*models.Backup, error) {
+	var database models.Database
+	if err := db.GetContext(ctx, &database, "select * from databases where id = $1", databaseID); err != nil {
+		return nil, err
+	}
+
+	var destination models.StorageDestination
+	if err := db.GetContext(ctx, &destination, "select * from storage_destinations where id = $1", destinationID); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf(
+		"%s-%s.%s",
+		time.Now().UTC().Format("20060102T150405Z"),
+		shared.GenerateRandomString(8),
+		databases.DumpExtension(database.Type),
+	)
+
+	var backup models.Backup
+	query := `
+		insert into backups (
+			database_id, database_type, schedule_id, destination_id, status, object_key
+		) values (
+			$1, $2, $3, $4, $5, $6
+		)
+		returning *
+	`
+	if err := db.GetContext(
+		ctx,
+		&backup,
+		query,
+		database.ID,
+		database.Type,
+		scheduleID,
+		destination.ID,
+		models.BackupPending,
+		storage.ObjectKey(&destination, "databases", database.ID, name),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := redisClient.LPush(ctx, BackupPendingQueue, backup.ID).Err(); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+// ApplyRetention deletes the schedule's successful backups that its
+// keepDaily and keepWeekly settings no longer cover, from storage and then
+// from the database. With both at zero it keeps everything.
+func ApplyRetention(ctx context.Context, db *sqlx.DB, secretCipher *secrets.Cipher, scheduleID string) error {
+	var schedule models.BackupSchedule
+	if err := db.GetContext(ctx, &schedule, "select * from backup_schedules where id = $1", scheduleID); err != nil {
+		return err
+	}
+	return applyRetention(ctx, db, secretCipher, "backups", scheduleID, schedule.KeepDaily, schedule.KeepWeekly)
+}
+
+// backupScheduleWorker queues the backups of schedules that are due.
+type backupScheduleWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewBackupScheduleWorker(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *backupScheduleWorker {
+	return &backupScheduleWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (w *backupScheduleWorker) Start() {
+	go func() {
+		ticker := time.NewTicker(backupScheduleTick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.Ctx.Done():
+				return
+			case <-ticker.C:
+				w.queueDue()
+			}
+		}
+	}()
+}
+
+func (w *backupScheduleWorker) queueDue() {
+	now := time.Now().UTC()
+
+	var schedules []models.BackupSchedule
+	if err := w.DB.SelectContext(
+		w.Ctx,
+		&schedules,
+		"select * from backup_schedules where enabled and next_run_at <= $1",
+		now,
+	); err != nil {
+		log.Println("backupScheduleWorker: failed to list due schedules", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		claimed, err := claimRun(w.Ctx, w.DB, "backup_schedules", schedule.ID, schedule.Cron, schedule.NextRunAt, now)
+		if err != nil {
+			log.Println("backupScheduleWorker: failed to claim schedule", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if _, err := QueueBackup(w.DB, w.RedisClient, w.Ctx, schedule.DatabaseID, schedule.DestinationID, &schedule.ID); err != nil {
+			log.Println("backupScheduleWorker: failed to queue backup for schedule", schedule.ID, err)
+		}
+	}
+}
+
+// claimRun moves a due schedule in table on to its next run. Claiming the
+// run this way keeps several API instances from queueing it twice; it
+// reports false if another one got there first. A schedule can't be parsed
+// only if it was edited by hand, so it is disabled rather than retried every
+// tick, and false is returned too.
+func claimRun(ctx context.Context, db *sqlx.DB, table, scheduleID, expression string, nextRunAt, now time.Time) (bool, error) {
+	next := time.Time{}
+	if parsed, err := cron.Parse(expression); err == nil {
+		next = parsed.Next(now)
+	}
+	enabled := !next.IsZero()
+	if !enabled {
+		log.Println("workers: disabling schedule that never fires", table, scheduleID)
+		next = nextRunAt
+	}
+
+	result, err := db.ExecContext(
+		ctx,
+		"update "+table+" set next_run_at = $1, last_run_at = $2, enabled = $3 where id = $4 and next_run_at = $5",
+		next, now, enabled, scheduleID, nextRunAt,
+	)
+	if err != nil {
+		return false, err
+	}
+	claimed, _ := result.RowsAffected()
+	return claimed > 0 && enabled, nil
+}
@@ -0,0 +1,194 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/databases"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DatabasePendingQueue    = "database:pending"
+	DatabaseProcessingQueue = "database:processing"
+
+	// Jobs for the same database run one at a time, like deployments.
+	databaseLockPrefix  = "database_lock:"
+	databaseLockTimeout = 30 * time.Minute
+	databaseRetryDelay  = 2 * time.Second
+)
+
+// What a database job does.
+const (
+	DatabaseActionStart    = "start"
+	DatabaseActionStop     = "stop"
+	DatabaseActionRestart  = "restart"
+	DatabaseActionRecreate = "recreate"
+	DatabaseActionDelete   = "delete"
+)
+
+type databaseWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewDatabaseWorker(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *databaseWorker {
+	return &databaseWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+func (w *databaseWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *databaseWorker) worker() {
+	for {
+		task, err := w.RedisClient.BRPopLPush(w.Ctx, DatabasePendingQueue, DatabaseProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(task)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, DatabaseProcessingQueue, 1, task).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *databaseWorker) process(task string) {
+	databaseID, action, ok := strings.Cut(task, ":")
+	if !ok {
+		log.Println("databaseWorker: malformed task", task)
+		return
+	}
+
+	lockKey := databaseLockPrefix + databaseID
+	locked, err := w.RedisClient.SetNX(w.Ctx, lockKey, action, databaseLockTimeout).Result()
+	if err != nil {
+		log.Println("databaseWorker: failed to lock database", databaseID, err)
+		return
+	}
+	if !locked {
+		time.Sleep(databaseRetryDelay)
+		w.RedisClient.LPush(w.Ctx, DatabasePendingQueue, task)
+		return
+	}
+	defer w.RedisClient.Del(w.Ctx, lockKey)
+
+	var database models.Database
+	if err := w.DB.GetContext(w.Ctx, &database, "select * from databases where id = $1", databaseID); err != nil {
+		log.Println("databaseWorker: failed to load database", databaseID, err)
+		return
+	}
+
+	status, err := w.run(&database, action)
+	if err != nil {
+		log.Println("databaseWorker:", action, "failed for database", databaseID, err)
+		w.setStatus(databaseID, models.DatabaseFailed, err)
+		return
+	}
+
+	if action == DatabaseActionDelete {
+		if _, err := w.DB.ExecContext(w.Ctx, "delete from databases where id = $1", databaseID); err != nil {
+			log.Println("databaseWorker: failed to delete database", databaseID, err)
+		}
+		return
+	}
+	w.setStatus(databaseID, status, nil)
+}
+
+// run carries out action and returns the database's status afterwards.
+func (w *databaseWorker) run(database *models.Database, action string) (string, error) {
+	password, err := w.Secrets.Decrypt(database.Password)
+	if err != nil {
+		return "", fmt.Errorf("failed to decrypt the password: %w", err)
+	}
+
+	sshClient, err := ConnectServer(w.Ctx, w.DB, database.ServerID)
+	if err != nil {
+		return "", err
+	}
+	defer sshClient.Close()
+
+	switch action {
+	case DatabaseActionStart:
+		return models.DatabaseRunning, databases.Start(sshClient, database, password)
+	case DatabaseActionStop:
+		return models.DatabaseStopped, databases.Stop(sshClient, database)
+	case DatabaseActionRestart:
+		return models.DatabaseRunning, databases.Restart(sshClient, database, password)
+	case DatabaseActionRecreate:
+		return models.DatabaseRunning, databases.Recreate(sshClient, database, password)
+	case DatabaseActionDelete:
+		return "", databases.Remove(sshClient, database)
+	default:
+		return "", fmt.Errorf("unknown action %q", action)
+	}
+}
+
+func (w *databaseWorker) setStatus(databaseID, status string, err error) {
+	var message *string
+	if err != nil {
+		value := err.Error()
+		message = &value
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update databases set status = $1, error = $2, updated_at = now() where id = $3",
+		status, message, databaseID,
+	); err != nil {
+		log.Println("databaseWorker: failed to update database", databaseID, err)
+	}
+}
+
+// ConnectServer opens an SSH connection to the server with the given id.
+func ConnectServer(ctx context.Context, db *sqlx.DB, serverID string) (*ssh.Client, error) {
+	var server struct {
+		Hostname string `db:"hostname"`
+		Port     int    `db:"port"`
+		Key      string `db:"key"`
+	}
+	query := `
+		select
+			s.hostname, s.port, k.key
+		from
+			servers s
+		inner join
+			keys k ON s.key_id = k.id
+		where
+			s.id = $1
+	`
+	if err := db.GetContext(ctx, &server, query, serverID); err != nil {
+		return nil, fmt.Errorf("failed to load server: %w", err)
+	}
+
+	sshClient := ssh.NewClient(server.Hostname, server.Port, "root", []byte(server.Key))
+	if err := sshClient.Connect(); err != nil {
+		return nil, fmt.Errorf("failed to connect to server: %w", err)
+	}
+	return sshClient, nil
+}
+
+// QueueDatabaseAction hands a database job to the database worker.
+func QueueDatabaseAction(redisClient *redis.Client, ctx context.Context, databaseID, action string) error {
+	return redisClient.LPush(ctx, DatabasePendingQueue, databaseID+":"+action).Err()
+}
@@ -0,0 +1,153 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DeploymentPendingQueue    = "deployment:pending"
+	DeploymentProcessingQueue = "deployment:processing"
+
+	// Only one deployment per application runs at a time; the others wait
+	// their turn in the queue.
+	deploymentLockPrefix  = "deployment_lock:"
+	deploymentLockTimeout = time.Hour
+	deploymentRetryDelay  = 5 * time.Second
+)
+
+type deploymentWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+	Runner      *deploy.Runner
+}
+
+func NewDeploymentWorker(db *sqlx.DB, redisClient *redis.Client, settingsStore settings.Store, secretCipher *secrets.Cipher, ctx context.Context) *deploymentWorker {
+	return &deploymentWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+		Runner:      deploy.NewRunner(db, redisClient, settingsStore, secretCipher, ctx),
+	}
+}
+
+func (w *deploymentWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *deploymentWorker) worker() {
+	for {
+		deploymentID, err := w.RedisClient.BRPopLPush(w.Ctx, DeploymentPendingQueue, DeploymentProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(deploymentID)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, DeploymentProcessingQueue, 1, deploymentID).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *deploymentWorker) process(deploymentID string) {
+	var applicationID string
+	if err := w.DB.GetContext(w.Ctx, &applicationID, "select application_id from deployments where id = $1", deploymentID); err != nil {
+		log.Println("deploymentWorker: failed to load deployment", deploymentID, err)
+		return
+	}
+
+	lockKey := deploymentLockPrefix + applicationID
+	locked, err := w.RedisClient.SetNX(w.Ctx, lockKey, deploymentID, deploymentLockTimeout).Result()
+	if err != nil {
+		log.Println("deploymentWorker: failed to lock application", applicationID, err)
+		return
+	}
+	if !locked {
+		time.Sleep(deploymentRetryDelay)
+		w.RedisClient.LPush(w.Ctx, DeploymentPendingQueue, deploymentID)
+		return
+	}
+	defer w.RedisClient.Del(w.Ctx, lockKey)
+
+	w.Runner.Run(deploymentID)
+}
+
+// DeploymentRequest describes a deployment to queue.
+type DeploymentRequest struct {
+	ApplicationID string
+	// CommitSHA pins the commit; nil deploys the head of the branch.
+	CommitSHA *string
+	Trigger   string
+	PreviewID *string
+	// TriggeredBy is the user who asked for the deployment, if any.
+	TriggeredBy *string
+	// RollbackOf redeploys an earlier deployment's images and config.
+	RollbackOf *string
+}
+
+// QueueDeployment records a new deployment and hands it to the deployment
+// worker.
+func QueueDeployment(db sqlx.QueryerContext, redisClient *redis.Client, ctx context.Context, request *DeploymentRequest) (*models.Deployment, error) {
+	trigger := request.Trigger
+	if trigger == "" {
+		trigger = models.TriggerManual
+	}
+
+	var deployment models.Deployment
+	if err := sqlx.GetContext(
+		ctx,
+		db,
+		&deployment,
+		`insert into deployments (application_id, commit_sha, trigger, preview_id, triggered_by, rollback_of)
+		values ($1, $2, $3, $4, $5, $6)
+		returning *`,
+		request.ApplicationID, request.CommitSHA, trigger, request.PreviewID, request.TriggeredBy, request.RollbackOf,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := redisClient.LPush(ctx, DeploymentPendingQueue, deployment.ID).Err(); err != nil {
+		return nil, err
+	}
+
+	return &deployment, nil
+}
+
+// QueuePushDeployments deploys commitSHA to every application that tracks
+// branch of the repository and has auto-deploy on.
+func QueuePushDeployments(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, sourceID, repositoryID, branch, commitSHA string) error {
+	var applicationIDs []string
+	query := `
+		select id from applications
+		where source_id = $1 and repository_id = $2 and branch = $3 and auto_deploy
+	`
+	if err := db.SelectContext(ctx, &applicationIDs, query, sourceID, repositoryID, branch); err != nil {
+		return err
+	}
+
+	for _, applicationID := range applicationIDs {
+		if _, err := QueueDeployment(db, redisClient, ctx, &DeploymentRequest{
+			ApplicationID: applicationID,
+			CommitSHA:     &commitSHA,
+			Trigger:       models.TriggerPush,
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/sources"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	gitPollTick = 15 * time.Second
+
+	// Held while a source is polled so that several API instances don't poll
+	// the same remote at once.
+	gitPollLockPrefix  = "git_poll_lock:"
+	gitPollLockTimeout = time.Minute
+)
+
+// gitPollWorker watches the tracked branch of git sources, which have no
+// webhooks, by running git ls-remote on each source's poll interval.
+type gitPollWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewGitPollWorker(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *gitPollWorker {
+	return &gitPollWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (w *gitPollWorker) Start() {
+	go func() {
+		ticker := time.NewTicker(gitPollTick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.Ctx.Done():
+				return
+			case <-ticker.C:
+				w.pollDue()
+			}
+		}
+	}()
+}
+
+func (w *gitPollWorker) pollDue() {
+	var sourceIDs []string
+	query := `
+		select
+			source_id
+		from
+			git_connections
+		where
+			poll_interval > 0
+			and (last_polled_at is null or last_polled_at + make_interval(secs => poll_interval) <= now())
+	`
+	if err := w.DB.SelectContext(w.Ctx, &sourceIDs, query); err != nil {
+		log.Println("gitPollWorker: failed to list due sources", err)
+		return
+	}
+
+	for _, sourceID := range sourceIDs {
+		locked, err := w.RedisClient.SetNX(w.Ctx, gitPollLockPrefix+sourceID, 1, gitPollLockTimeout).Result()
+		if err != nil || !locked {
+			continue
+		}
+
+		w.poll(sourceID)
+
+		w.RedisClient.Del(w.Ctx, gitPollLockPrefix+sourceID)
+	}
+}
+
+func (w *gitPollWorker) poll(sourceID string) {
+	connection, auth, err := sources.LoadGitConnection(w.Ctx, w.DB, sourceID)
+	if err != nil {
+		log.Println("gitPollWorker: failed to load connection", sourceID, err)
+		return
+	}
+
+	refs, err := git.LsRemote(w.Ctx, connection.RepositoryURL, auth, "refs/heads/"+connection.Branch)
+	if err == nil && len(refs) == 0 {
+		err = errBranchNotFound(connection.Branch)
+	}
+	if err != nil {
+		message := err.Error()
+		if _, err := w.DB.ExecContext(
+			w.Ctx,
+			"update git_connections set last_polled_at = now(), last_poll_error = $1 where source_id = $2",
+			message, sourceID,
+		); err != nil {
+			log.Println("gitPollWorker: failed to record poll error", sourceID, err)
+		}
+		return
+	}
+
+	sha := refs[0].SHA
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update git_connections set last_commit_sha = $1, last_polled_at = now(), last_poll_error = null where source_id = $2",
+		sha, sourceID,
+	); err != nil {
+		log.Println("gitPollWorker: failed to record poll", sourceID, err)
+		return
+	}
+
+	// The first poll only records where the branch is.
+	if connection.LastCommitSHA != nil && *connection.LastCommitSHA != sha {
+		// Git sources have a single repository, identified by the source id.
+		if err := QueuePushDeployments(w.DB, w.RedisClient, w.Ctx, sourceID, sourceID, connection.Branch, sha); err != nil {
+			log.Println("gitPollWorker: failed to queue deployments", sourceID, err)
+		}
+	}
+}
+
+type errBranchNotFound string
+
+func (e errBranchNotFound) Error() string {
+	return "branch " + string(e) + " not found on the remote"
+}
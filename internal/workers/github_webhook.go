@@ -0,0 +1,197 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/sources"
+	"github.com/mohit4bug/mo-sh/pkg/github"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	GithubWebhookPendingQueue    = "github_webhook:pending"
+	GithubWebhookProcessingQueue = "github_webhook:processing"
+)
+
+type githubWebhookWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewGithubWebhookWorker(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *githubWebhookWorker {
+	return &githubWebhookWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (w *githubWebhookWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *githubWebhookWorker) worker() {
+	for {
+		deliveryID, err := w.RedisClient.BRPopLPush(w.Ctx, GithubWebhookPendingQueue, GithubWebhookProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(deliveryID)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, GithubWebhookProcessingQueue, 1, deliveryID).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *githubWebhookWorker) process(deliveryID string) {
+	var delivery models.GithubWebhookDelivery
+	if err := w.DB.GetContext(w.Ctx, &delivery, "select * from github_webhook_deliveries where delivery_id = $1", deliveryID); err != nil {
+		log.Println("githubWebhookWorker: failed to load delivery", deliveryID, err)
+		return
+	}
+
+	status := models.WebhookDeliveryProcessed
+	var errMessage *string
+
+	if err := w.dispatch(&delivery); err != nil {
+		if errors.Is(err, github.ErrUnsupportedEvent) {
+			status = models.WebhookDeliveryIgnored
+		} else {
+			status = models.WebhookDeliveryFailed
+			message := err.Error()
+			errMessage = &message
+			log.Println("githubWebhookWorker: failed to handle", delivery.Event, deliveryID, err)
+		}
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update github_webhook_deliveries set status = $1, error = $2, processed_at = now() where id = $3",
+		status, errMessage, delivery.ID,
+	); err != nil {
+		log.Println("githubWebhookWorker: failed to update delivery", deliveryID, err)
+	}
+}
+
+func (w *githubWebhookWorker) dispatch(delivery *models.GithubWebhookDelivery) error {
+	event, err := github.ParseEvent(delivery.Event, delivery.Payload)
+	if err != nil {
+		return err
+	}
+
+	switch event := event.(type) {
+	case *github.PushEvent:
+		return w.handlePush(delivery.SourceID, event)
+	case *github.PullRequestEvent:
+		return w.handlePullRequest(delivery.SourceID, event)
+	case *github.InstallationEvent:
+		return w.handleInstallation(delivery.SourceID, event)
+	case *github.InstallationRepositoriesEvent:
+		return w.handleInstallationRepositories(delivery.SourceID, event)
+	default:
+		return fmt.Errorf("no handler for %T", event)
+	}
+}
+
+func (w *githubWebhookWorker) handlePush(sourceID string, event *github.PushEvent) error {
+	if event.Branch() == "" || event.Deleted {
+		return nil
+	}
+	return QueuePushDeployments(w.DB, w.RedisClient, w.Ctx, sourceID, strconv.FormatInt(event.Repository.ID, 10), event.Branch(), event.After)
+}
+
+func (w *githubWebhookWorker) handlePullRequest(sourceID string, event *github.PullRequestEvent) error {
+	repositoryID := strconv.FormatInt(event.Repository.ID, 10)
+
+	switch event.Action {
+	case "opened", "reopened", "synchronize":
+		// Fork pull requests would run someone else's code with the
+		// application's config, so they don't get previews.
+		if event.PullRequest.Head.Repo.ID != event.Repository.ID {
+			log.Printf("githubWebhookWorker: skipping preview for fork pull request #%d on %s", event.Number, event.Repository.FullName)
+			return nil
+		}
+
+		return QueuePreviewDeployments(w.DB, w.RedisClient, w.Ctx, &PullRequest{
+			SourceID:     sourceID,
+			RepositoryID: repositoryID,
+			Number:       event.Number,
+			Branch:       event.PullRequest.Head.Ref,
+			BaseBranch:   event.PullRequest.Base.Ref,
+			CommitSHA:    event.PullRequest.Head.SHA,
+		})
+	case "closed":
+		return RemovePreviews(w.DB, w.RedisClient, w.Ctx, sourceID, repositoryID, event.Number)
+	default:
+		return nil
+	}
+}
+
+func (w *githubWebhookWorker) handleInstallation(sourceID string, event *github.InstallationEvent) error {
+	switch event.Action {
+	case "deleted":
+		return sources.DeleteGithubInstallation(w.Ctx, w.DB, sourceID, event.Installation.ID)
+	case "suspend", "unsuspend":
+		return sources.SetGithubInstallationSuspended(w.Ctx, w.DB, sourceID, event.Installation.ID, event.Action == "suspend")
+	case "created", "new_permissions_accepted":
+		tx, err := w.DB.BeginTxx(w.Ctx, nil)
+		if err != nil {
+			return err
+		}
+		defer tx.Rollback()
+
+		rowID, err := sources.SaveGithubInstallation(w.Ctx, tx, sourceID, &event.Installation)
+		if err != nil {
+			return err
+		}
+
+		if err := sources.AddGithubRepositories(w.Ctx, tx, rowID, event.Repositories); err != nil {
+			return err
+		}
+
+		return tx.Commit()
+	default:
+		return nil
+	}
+}
+
+func (w *githubWebhookWorker) handleInstallationRepositories(sourceID string, event *github.InstallationRepositoriesEvent) error {
+	tx, err := w.DB.BeginTxx(w.Ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	installation := event.Installation
+	if event.RepositorySelection != "" {
+		installation.RepositorySelection = event.RepositorySelection
+	}
+
+	rowID, err := sources.SaveGithubInstallation(w.Ctx, tx, sourceID, &installation)
+	if err != nil {
+		return err
+	}
+
+	if err := sources.AddGithubRepositories(w.Ctx, tx, rowID, event.RepositoriesAdded); err != nil {
+		return err
+	}
+
+	if err := sources.RemoveGithubRepositories(w.Ctx, tx, rowID, event.RepositoriesRemoved); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
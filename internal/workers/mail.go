@@ -0,0 +1,111 @@
+package workers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/mohit4bug/mo-sh/pkg/mail"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	MailPendingQueue    = "mail:pending"
+	MailProcessingQueue = "mail:processing"
+	// MailFailedQueue keeps the latest messages that could not be sent
+	// after mailMaxAttempts, for inspection.
+	MailFailedQueue = "mail:failed"
+
+	mailMaxAttempts   = 5
+	mailRetryDelay    = 10 * time.Second
+	mailFailedEntries = 1000
+)
+
+// queuedMail is a message waiting to be sent. Attempts counts the failed
+// sends so far.
+type queuedMail struct {
+	mail.Message
+	Attempts int `json:"attempts,omitempty"`
+}
+
+type mailWorker struct {
+	RedisClient *redis.Client
+	Ctx         context.Context
+	Mailer      mail.Mailer
+}
+
+func NewMailWorker(redisClient *redis.Client, mailer mail.Mailer, ctx context.Context) *mailWorker {
+	return &mailWorker{
+		RedisClient: redisClient,
+		Ctx:         ctx,
+		Mailer:      mailer,
+	}
+}
+
+func (w *mailWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *mailWorker) worker() {
+	for {
+		payload, err := w.RedisClient.BRPopLPush(w.Ctx, MailPendingQueue, MailProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(payload)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, MailProcessingQueue, 1, payload).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+// process sends the queued message, putting it back on the queue after a
+// delay that grows with each failed attempt until mailMaxAttempts.
+func (w *mailWorker) process(payload string) {
+	var queued queuedMail
+	if err := json.Unmarshal([]byte(payload), &queued); err != nil {
+		log.Println("mailWorker: invalid message", err)
+		return
+	}
+
+	err := w.Mailer.Send(queued.Message)
+	if err == nil {
+		return
+	}
+
+	queued.Attempts++
+	retry, marshalErr := json.Marshal(queued)
+	if marshalErr != nil {
+		log.Println("mailWorker: failed to requeue message to", queued.To, marshalErr)
+		return
+	}
+
+	if queued.Attempts >= mailMaxAttempts {
+		log.Println("mailWorker: giving up on message to", queued.To, "after", queued.Attempts, "attempts", err)
+		w.RedisClient.LPush(w.Ctx, MailFailedQueue, retry)
+		w.RedisClient.LTrim(w.Ctx, MailFailedQueue, 0, mailFailedEntries-1)
+		return
+	}
+
+	log.Println("mailWorker: failed to send to", queued.To, "attempt", queued.Attempts, err)
+	time.Sleep(time.Duration(queued.Attempts) * mailRetryDelay)
+	if err := w.RedisClient.LPush(w.Ctx, MailPendingQueue, retry).Err(); err != nil {
+		log.Println("mailWorker: failed to requeue message to", queued.To, err)
+	}
+}
+
+// QueueMail hands msg to the mail worker.
+func QueueMail(redisClient *redis.Client, ctx context.Context, msg *mail.Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	return redisClient.LPush(ctx, MailPendingQueue, payload).Err()
+}
@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/mohit4bug/mo-sh/pkg/mail"
+)
+
+func TestQueuedMailPayload(t *testing.T) {
+	// Messages queued by QueueMail carry no attempts yet.
+	payload, err := json.Marshal(&mail.Message{To: "a@example.com", Subject: "Verify", Body: "..."})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var queued queuedMail
+	if err := json.Unmarshal(payload, &queued); err != nil {
+		t.Fatal(err)
+	}
+	if queued.To != "a@example.com" || queued.Subject != "Verify" || queued.Attempts != 0 {
+		t.Fatalf("queued = %+v", queued)
+	}
+
+	queued.Attempts++
+	retry, err := json.Marshal(queued)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(retry) != `{"to":"a@example.com","subject":"Verify","body":"...","attempts":1}` {
+		t.Fatalf("retry = %s", retry)
+	}
+}
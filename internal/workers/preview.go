@@ -0,0 +1,109 @@
+package workers
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/redis/go-redis/v9"
+)
+
+// PullRequest is what preview deployments need to know about a pull request,
+// whichever provider it came from.
+type PullRequest struct {
+	SourceID     string
+	RepositoryID string
+	Number       int
+	Branch       string
+	BaseBranch   string
+	CommitSHA    string
+}
+
+// QueuePreviewDeployments deploys the pull request's head to a preview of
+// every application that has previews on and tracks its base branch.
+func QueuePreviewDeployments(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, pullRequest *PullRequest) error {
+	var applications []models.Application
+	query := `
+		select * from applications
+		where source_id = $1 and repository_id = $2 and branch = $3
+			and preview_deployments and preview_domain is not null
+	`
+	if err := db.SelectContext(ctx, &applications, query, pullRequest.SourceID, pullRequest.RepositoryID, pullRequest.BaseBranch); err != nil {
+		return err
+	}
+
+	for _, application := range applications {
+		var preview models.Preview
+		query := `
+			insert into previews (application_id, pull_request_number, branch, commit_sha, domain)
+			values ($1, $2, $3, $4, $5)
+			on conflict (application_id, pull_request_number) do update
+			set branch = excluded.branch, commit_sha = excluded.commit_sha, closed_at = null, updated_at = now()
+			returning *
+		`
+		if err := db.GetContext(
+			ctx,
+			&preview,
+			query,
+			application.ID,
+			pullRequest.Number,
+			pullRequest.Branch,
+			pullRequest.CommitSHA,
+			deploy.PreviewDomain(*application.PreviewDomain, pullRequest.Number),
+		); err != nil {
+			return err
+		}
+
+		if _, err := QueueDeployment(db, redisClient, ctx, &DeploymentRequest{
+			ApplicationID: application.ID,
+			CommitSHA:     &pullRequest.CommitSHA,
+			Trigger:       models.TriggerPullRequest,
+			PreviewID:     &preview.ID,
+		}); err != nil {
+			return err
+		}
+
+		// Route the preview's domain, so its certificate is ready by the
+		// time it is up.
+		if err := QueueProxy(redisClient, ctx, application.ServerID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemovePreviews tears down the previews of a closed pull request.
+func RemovePreviews(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, sourceID, repositoryID string, number int) error {
+	var previews []struct {
+		ID       string `db:"id"`
+		ServerID string `db:"server_id"`
+	}
+	query := `
+		select
+			p.id, a.server_id
+		from
+			previews p
+		inner join
+			applications a ON a.id = p.application_id
+		where
+			a.source_id = $1 and a.repository_id = $2 and p.pull_request_number = $3
+	`
+	if err := db.SelectContext(ctx, &previews, query, sourceID, repositoryID, number); err != nil {
+		return err
+	}
+
+	var lastErr error
+	for _, preview := range previews {
+		if err := deploy.RemovePreview(db, redisClient, ctx, preview.ID); err != nil {
+			log.Println("workers: failed to remove preview", preview.ID, err)
+			lastErr = err
+		}
+		if err := QueueProxy(redisClient, ctx, preview.ServerID); err != nil {
+			lastErr = err
+		}
+	}
+	return lastErr
+}
@@ -0,0 +1,103 @@
+package workers
+
+import (
+	"context"
+	"log"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/proxy"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ProxyPendingQueue    = "proxy:pending"
+	ProxyProcessingQueue = "proxy:processing"
+)
+
+type proxyWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewProxyWorker(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *proxyWorker {
+	return &proxyWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (w *proxyWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *proxyWorker) worker() {
+	for {
+		serverID, err := w.RedisClient.BRPopLPush(w.Ctx, ProxyPendingQueue, ProxyProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.apply(serverID)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, ProxyProcessingQueue, 1, serverID).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *proxyWorker) apply(serverID string) {
+	type ServerWithKey struct {
+		models.Server
+		Key string `db:"key"`
+	}
+
+	query := `
+		select
+			s.*, k.key
+		from
+			servers s
+		inner join
+			keys k ON s.key_id = k.id
+		where
+			s.id = $1
+	`
+
+	var server ServerWithKey
+	if err := w.DB.GetContext(w.Ctx, &server, query, serverID); err != nil {
+		log.Println("proxyWorker: failed to load server", serverID, err)
+		return
+	}
+
+	sshClient := ssh.NewClient(server.Hostname, server.Port, "root", []byte(server.Key))
+	if err := sshClient.Connect(); err != nil {
+		log.Println("proxyWorker: failed to connect to server", serverID, err)
+		w.DB.ExecContext(
+			w.Ctx,
+			"update server_proxies set status = $1, error = $2, updated_at = now() where server_id = $3",
+			models.ProxyFailed, err.Error(), serverID,
+		)
+		return
+	}
+	defer sshClient.Close()
+
+	if err := proxy.Apply(w.Ctx, w.DB, sshClient, serverID); err != nil {
+		log.Println("proxyWorker: failed to apply proxy", serverID, err)
+	}
+}
+
+// QueueProxy asks the proxy worker to bring serverID's proxy and routes up to
+// date. A server already waiting isn't queued twice.
+func QueueProxy(redisClient *redis.Client, ctx context.Context, serverID string) error {
+	if err := redisClient.LRem(ctx, ProxyPendingQueue, 0, serverID).Err(); err != nil {
+		return err
+	}
+	return redisClient.LPush(ctx, ProxyPendingQueue, serverID).Err()
+}
@@ -0,0 +1,310 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/databases"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	RestorePendingQueue    = "restore:pending"
+	RestoreProcessingQueue = "restore:processing"
+)
+
+type restoreWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewRestoreWorker(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *restoreWorker {
+	return &restoreWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+func (w *restoreWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *restoreWorker) worker() {
+	for {
+		restoreID, err := w.RedisClient.BRPopLPush(w.Ctx, RestorePendingQueue, RestoreProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(restoreID)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, RestoreProcessingQueue, 1, restoreID).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *restoreWorker) process(restoreID string) {
+	var restore models.Restore
+	if err := w.DB.GetContext(w.Ctx, &restore, "select * from restores where id = $1", restoreID); err != nil {
+		log.Println("restoreWorker: failed to load restore", restoreID, err)
+		return
+	}
+	if restore.Status != models.BackupPending {
+		return
+	}
+
+	// Restores hold the database's lock like backups do.
+	lockKey := databaseLockPrefix + restore.DatabaseID
+	locked, err := w.RedisClient.SetNX(w.Ctx, lockKey, "restore", backupLockTimeout).Result()
+	if err != nil {
+		log.Println("restoreWorker: failed to lock database", restore.DatabaseID, err)
+		return
+	}
+	if !locked {
+		time.Sleep(databaseRetryDelay)
+		w.RedisClient.LPush(w.Ctx, RestorePendingQueue, restoreID)
+		return
+	}
+	defer w.RedisClient.Del(w.Ctx, lockKey)
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update restores set status = $1, started_at = now() where id = $2",
+		models.BackupRunning, restoreID,
+	); err != nil {
+		log.Println("restoreWorker: failed to update restore", restoreID, err)
+		return
+	}
+
+	logger := deploy.NewTableLogger(w.DB, w.Ctx, "restore_logs", "restore_id", restoreID)
+	err = logger.RedactError(w.run(&restore, logger))
+	if err != nil {
+		logger.Error(err.Error())
+	} else {
+		logger.System("Restore finished.")
+	}
+	logger.Close()
+
+	w.finish(&restore, err)
+}
+
+func (w *restoreWorker) run(restore *models.Restore, logger *deploy.Logger) error {
+	if restore.BackupID == nil {
+		return errors.New("the backup was deleted")
+	}
+
+	var backup models.Backup
+	if err := w.DB.GetContext(w.Ctx, &backup, "select * from backups where id = $1", *restore.BackupID); err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+	if backup.Status != models.BackupSucceeded {
+		return fmt.Errorf("the backup is %s", backup.Status)
+	}
+
+	var database models.Database
+	if err := w.DB.GetContext(w.Ctx, &database, "select * from databases where id = $1", restore.DatabaseID); err != nil {
+		return fmt.Errorf("failed to load database: %w", err)
+	}
+	if database.Type != backup.DatabaseType {
+		return fmt.Errorf("a %s backup can't be restored into a %s database", backup.DatabaseType, database.Type)
+	}
+
+	password, err := w.Secrets.Decrypt(database.Password)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt the password: %w", err)
+	}
+	logger.Redact(password)
+
+	_, client, err := storage.Load(w.Ctx, w.DB, w.Secrets, backup.DestinationID)
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := ConnectServer(w.Ctx, w.DB, database.ServerID)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	// Whatever happens, the database's status ends up saying whether its
+	// container runs.
+	defer w.settle(sshClient, &database)
+
+	// The dump is verified on the server before anything is touched, so a
+	// corrupt download never reaches the database.
+	logger.System("Downloading %s.", backup.ObjectKey)
+	body, err := client.Download(w.Ctx, backup.ObjectKey)
+	if err != nil {
+		return fmt.Errorf("failed to download the backup: %w", err)
+	}
+	dumpPath, checksum, err := databases.UploadDump(sshClient, body)
+	body.Close()
+	if err != nil {
+		return err
+	}
+	defer sshClient.RunCommand("rm -f " + git.ShellQuote(dumpPath))
+
+	if backup.Checksum != nil {
+		if checksum != *backup.Checksum {
+			return fmt.Errorf("the downloaded backup's checksum %s doesn't match the recorded %s", checksum, *backup.Checksum)
+		}
+		logger.System("Checksum verified.")
+	}
+
+	if restore.Sibling {
+		logger.System("Starting the new database %s.", database.Name)
+		if err := databases.Start(sshClient, &database, password); err != nil {
+			return err
+		}
+	}
+
+	if restore.StopApplications {
+		stopped, err := w.stopApplications(&database, logger)
+		defer w.startApplications(stopped, logger)
+		if err != nil {
+			return err
+		}
+	}
+
+	logger.System("Restoring into %s.", database.Name)
+	return databases.Restore(sshClient, &database, password, dumpPath, logger.Info)
+}
+
+// stopApplications stops the containers of the applications in database's
+// environment and returns them by server, for startApplications.
+func (w *restoreWorker) stopApplications(database *models.Database, logger *deploy.Logger) (map[string][]string, error) {
+	var applications []models.Application
+	if err := w.DB.SelectContext(
+		w.Ctx,
+		&applications,
+		"select * from applications where environment_id = $1 order by server_id",
+		database.EnvironmentID,
+	); err != nil {
+		return nil, fmt.Errorf("failed to list applications: %w", err)
+	}
+
+	stopped := map[string][]string{}
+	for _, application := range applications {
+		sshClient, err := ConnectServer(w.Ctx, w.DB, application.ServerID)
+		if err != nil {
+			return stopped, err
+		}
+
+		containers, _, err := sshClient.RunCommand("docker ps -q --filter " + git.ShellQuote("label=mo-sh.application="+application.ID))
+		ids := strings.Fields(containers)
+		if err == nil && len(ids) > 0 {
+			logger.System("Stopping %s.", application.Name)
+			var stderr string
+			_, stderr, err = sshClient.RunCommand("docker stop " + strings.Join(ids, " ") + " >/dev/null")
+			if err != nil {
+				err = fmt.Errorf("%w: %s", err, strings.TrimSpace(stderr))
+			}
+		}
+		sshClient.Close()
+
+		// Starting containers that didn't stop is harmless, so they're all
+		// started again even if stopping failed partway.
+		stopped[application.ServerID] = append(stopped[application.ServerID], ids...)
+		if err != nil {
+			return stopped, fmt.Errorf("failed to stop %s: %w", application.Name, err)
+		}
+	}
+
+	return stopped, nil
+}
+
+func (w *restoreWorker) startApplications(stopped map[string][]string, logger *deploy.Logger) {
+	for serverID, ids := range stopped {
+		if len(ids) == 0 {
+			continue
+		}
+
+		sshClient, err := ConnectServer(w.Ctx, w.DB, serverID)
+		if err != nil {
+			logger.Error(fmt.Sprintf("Failed to start applications again: %s", err))
+			continue
+		}
+
+		logger.System("Starting %d application container(s) again.", len(ids))
+		if _, stderr, err := sshClient.RunCommand("docker start " + strings.Join(ids, " ") + " >/dev/null"); err != nil {
+			logger.Error(fmt.Sprintf("Failed to start applications again: %s: %s", err, strings.TrimSpace(stderr)))
+		}
+		sshClient.Close()
+	}
+}
+
+// settle sets the database's status after a restore from whether its
+// container is running.
+func (w *restoreWorker) settle(sshClient *ssh.Client, database *models.Database) {
+	status, message := models.DatabaseRunning, (*string)(nil)
+	if !sshClient.CheckCommand("docker inspect -f '{{.State.Running}}' " + git.ShellQuote(databases.ContainerName(database.ID)) + " | grep -qx true") {
+		value := "The database isn't running after the restore"
+		status, message = models.DatabaseFailed, &value
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update databases set status = $1, error = $2, updated_at = now() where id = $3",
+		status, message, database.ID,
+	); err != nil {
+		log.Println("restoreWorker: failed to update database", database.ID, err)
+	}
+}
+
+func (w *restoreWorker) finish(restore *models.Restore, err error) {
+	var message *string
+	status := models.BackupSucceeded
+	if err != nil {
+		log.Println("restoreWorker: restore failed", restore.ID, err)
+		value := err.Error()
+		message = &value
+		status = models.BackupFailed
+
+		// A restore that failed before reaching the server leaves the
+		// database as it was, and a sibling never started.
+		databaseStatus, from := models.DatabaseRunning, models.DatabaseRestoring
+		if restore.Sibling {
+			databaseStatus, from = models.DatabaseFailed, models.DatabaseStarting
+		}
+		if _, err := w.DB.ExecContext(
+			w.Ctx,
+			"update databases set status = $1, error = $2, updated_at = now() where id = $3 and status = $4",
+			databaseStatus, message, restore.DatabaseID, from,
+		); err != nil {
+			log.Println("restoreWorker: failed to update database", restore.DatabaseID, err)
+		}
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update restores set status = $1, error = $2, finished_at = now() where id = $3",
+		status, message, restore.ID,
+	); err != nil {
+		log.Println("restoreWorker: failed to update restore", restore.ID, err)
+	}
+}
+
+// QueueRestore hands a restore to the restore worker.
+func QueueRestore(redisClient *redis.Client, ctx context.Context, restoreID string) error {
+	return redisClient.LPush(ctx, RestorePendingQueue, restoreID).Err()
+}
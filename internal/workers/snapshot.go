@@ -0,0 +1,263 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/internal/volumes"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	VolumeSnapshotPendingQueue    = "volume_snapshot:pending"
+	VolumeSnapshotProcessingQueue = "volume_snapshot:processing"
+)
+
+type volumeSnapshotWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewVolumeSnapshotWorker(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *volumeSnapshotWorker {
+	return &volumeSnapshotWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+func (w *volumeSnapshotWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *volumeSnapshotWorker) worker() {
+	for {
+		snapshotID, err := w.RedisClient.BRPopLPush(w.Ctx, VolumeSnapshotPendingQueue, VolumeSnapshotProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(snapshotID)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, VolumeSnapshotProcessingQueue, 1, snapshotID).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *volumeSnapshotWorker) process(snapshotID string) {
+	var snapshot models.VolumeSnapshot
+	if err := w.DB.GetContext(w.Ctx, &snapshot, "select * from volume_snapshots where id = $1", snapshotID); err != nil {
+		log.Println("volumeSnapshotWorker: failed to load snapshot", snapshotID, err)
+		return
+	}
+	if snapshot.Status != models.BackupPending {
+		return
+	}
+	if snapshot.VolumeID == nil {
+		w.finish(&snapshot, errors.New("the volume was deleted"))
+		return
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update volume_snapshots set status = $1, started_at = now() where id = $2",
+		models.BackupRunning, snapshotID,
+	); err != nil {
+		log.Println("volumeSnapshotWorker: failed to update snapshot", snapshotID, err)
+		return
+	}
+
+	w.finish(&snapshot, w.run(&snapshot))
+
+	if snapshot.ScheduleID != nil && snapshot.Status == models.BackupSucceeded {
+		if err := ApplySnapshotRetention(w.Ctx, w.DB, w.Secrets, *snapshot.ScheduleID); err != nil {
+			log.Println("volumeSnapshotWorker: failed to apply retention for schedule", *snapshot.ScheduleID, err)
+		}
+	}
+}
+
+// run streams a tarball of the volume from its application's server to the
+// destination, recording its size and checksum on snapshot.
+func (w *volumeSnapshotWorker) run(snapshot *models.VolumeSnapshot) error {
+	var volume models.Volume
+	if err := w.DB.GetContext(w.Ctx, &volume, "select * from volumes where id = $1", *snapshot.VolumeID); err != nil {
+		return fmt.Errorf("failed to load volume: %w", err)
+	}
+
+	var serverID string
+	if err := w.DB.GetContext(w.Ctx, &serverID, "select server_id from applications where id = $1", volume.ApplicationID); err != nil {
+		return fmt.Errorf("failed to load application: %w", err)
+	}
+
+	_, client, err := storage.Load(w.Ctx, w.DB, w.Secrets, snapshot.DestinationID)
+	if err != nil {
+		return err
+	}
+
+	sshClient, err := ConnectServer(w.Ctx, w.DB, serverID)
+	if err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	size, checksum, err := uploadArchive(w.Ctx, client, sshClient, snapshot.ObjectKey, func(writer io.Writer) error {
+		return volumes.Snapshot(sshClient, &volume, writer)
+	})
+	if err != nil {
+		return err
+	}
+
+	snapshot.Size, snapshot.Checksum = &size, &checksum
+	return nil
+}
+
+func (w *volumeSnapshotWorker) finish(snapshot *models.VolumeSnapshot, err error) {
+	var message *string
+	snapshot.Status = models.BackupSucceeded
+	if err != nil {
+		log.Println("volumeSnapshotWorker: snapshot failed", snapshot.ID, err)
+		value := err.Error()
+		message = &value
+		snapshot.Status = models.BackupFailed
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update volume_snapshots set status = $1, size = $2, checksum = $3, error = $4, finished_at = now() where id = $5",
+		snapshot.Status, snapshot.Size, snapshot.Checksum, message, snapshot.ID,
+	); err != nil {
+		log.Println("volumeSnapshotWorker: failed to update snapshot", snapshot.ID, err)
+	}
+}
+
+// QueueVolumeSnapshot records a pending snapshot of the volume to the
+// destination and hands it to the snapshot worker. scheduleID is nil for
+// snapshots taken on demand, which retention never deletes.
+func QueueVolumeSnapshot(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, volumeID, destinationID string, scheduleID *string) (*models.VolumeSnapshot, error) {
+	var destination models.StorageDestination
+	if err := db.GetContext(ctx, &destination, "select * from storage_destinations where id = $1", destinationID); err != nil {
+		return nil, err
+	}
+
+	name := fmt.Sprintf(
+		"%s-%s.tar.gz",
+		time.Now().UTC().Format("20060102T150405Z"),
+		shared.GenerateRandomString(8),
+	)
+
+	var snapshot models.VolumeSnapshot
+	query := `
+		insert into volume_snapshots (
+			volume_id, schedule_id, destination_id, status, object_key
+		) values (
+			$1, $2, $3, $4, $5
+		)
+		returning *
+	`
+	if err := db.GetContext(
+		ctx,
+		&snapshot,
+		query,
+		volumeID,
+		scheduleID,
+		destination.ID,
+		models.BackupPending,
+		storage.ObjectKey(&destination, "volumes", volumeID, name),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := redisClient.LPush(ctx, VolumeSnapshotPendingQueue, snapshot.ID).Err(); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+// ApplySnapshotRetention deletes the schedule's successful snapshots its
+// settings no longer cover, as ApplyRetention does for backups.
+func ApplySnapshotRetention(ctx context.Context, db *sqlx.DB, secretCipher *secrets.Cipher, scheduleID string) error {
+	var schedule models.VolumeSnapshotSchedule
+	if err := db.GetContext(ctx, &schedule, "select * from volume_snapshot_schedules where id = $1", scheduleID); err != nil {
+		return err
+	}
+	return applyRetention(ctx, db, secretCipher, "volume_snapshots", scheduleID, schedule.KeepDaily, schedule.KeepWeekly)
+}
+
+// volumeSnapshotScheduleWorker queues the snapshots of schedules that are
+// due.
+type volumeSnapshotScheduleWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewVolumeSnapshotScheduleWorker(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *volumeSnapshotScheduleWorker {
+	return &volumeSnapshotScheduleWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (w *volumeSnapshotScheduleWorker) Start() {
+	go func() {
+		ticker := time.NewTicker(backupScheduleTick)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-w.Ctx.Done():
+				return
+			case <-ticker.C:
+				w.queueDue()
+			}
+		}
+	}()
+}
+
+func (w *volumeSnapshotScheduleWorker) queueDue() {
+	now := time.Now().UTC()
+
+	var schedules []models.VolumeSnapshotSchedule
+	if err := w.DB.SelectContext(
+		w.Ctx,
+		&schedules,
+		"select * from volume_snapshot_schedules where enabled and next_run_at <= $1",
+		now,
+	); err != nil {
+		log.Println("volumeSnapshotScheduleWorker: failed to list due schedules", err)
+		return
+	}
+
+	for _, schedule := range schedules {
+		claimed, err := claimRun(w.Ctx, w.DB, "volume_snapshot_schedules", schedule.ID, schedule.Cron, schedule.NextRunAt, now)
+		if err != nil {
+			log.Println("volumeSnapshotScheduleWorker: failed to claim schedule", schedule.ID, err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+
+		if _, err := QueueVolumeSnapshot(w.DB, w.RedisClient, w.Ctx, schedule.VolumeID, schedule.DestinationID, &schedule.ID); err != nil {
+			log.Println("volumeSnapshotScheduleWorker: failed to queue snapshot for schedule", schedule.ID, err)
+		}
+	}
+}
@@ -0,0 +1,153 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/pkg/gitea"
+	"github.com/mohit4bug/mo-sh/pkg/gitlab"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	SourceWebhookPendingQueue    = "source_webhook:pending"
+	SourceWebhookProcessingQueue = "source_webhook:processing"
+)
+
+// sourceWebhookWorker handles webhooks from sources that aren't GitHub Apps.
+// Queue entries are source_webhook_deliveries ids.
+type sourceWebhookWorker struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewSourceWebhookWorker(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *sourceWebhookWorker {
+	return &sourceWebhookWorker{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (w *sourceWebhookWorker) Start(numWorkers int) {
+	for i := 0; i < numWorkers; i++ {
+		go w.worker()
+	}
+}
+
+func (w *sourceWebhookWorker) worker() {
+	for {
+		id, err := w.RedisClient.BRPopLPush(w.Ctx, SourceWebhookPendingQueue, SourceWebhookProcessingQueue, 0).Result()
+		if err != nil {
+			continue
+		}
+
+		w.process(id)
+
+		// Delete the task from the processing queue.
+		_, err = w.RedisClient.LRem(w.Ctx, SourceWebhookProcessingQueue, 1, id).Result()
+		if err != nil {
+			continue
+		}
+	}
+}
+
+func (w *sourceWebhookWorker) process(id string) {
+	var delivery struct {
+		models.SourceWebhookDelivery
+		SourceType string `db:"source_type"`
+	}
+	query := `
+		select
+			d.*, s.type as source_type
+		from
+			source_webhook_deliveries d
+		inner join
+			sources s ON s.id = d.source_id
+		where
+			d.id = $1
+	`
+	if err := w.DB.GetContext(w.Ctx, &delivery, query, id); err != nil {
+		log.Println("sourceWebhookWorker: failed to load delivery", id, err)
+		return
+	}
+
+	status := models.WebhookDeliveryProcessed
+	var errMessage *string
+
+	if err := w.dispatch(delivery.SourceType, &delivery.SourceWebhookDelivery); err != nil {
+		if errors.Is(err, gitlab.ErrUnsupportedEvent) || errors.Is(err, gitea.ErrUnsupportedEvent) {
+			status = models.WebhookDeliveryIgnored
+		} else {
+			status = models.WebhookDeliveryFailed
+			message := err.Error()
+			errMessage = &message
+			log.Println("sourceWebhookWorker: failed to handle", delivery.Event, id, err)
+		}
+	}
+
+	if _, err := w.DB.ExecContext(
+		w.Ctx,
+		"update source_webhook_deliveries set status = $1, error = $2, processed_at = now() where id = $3",
+		status, errMessage, id,
+	); err != nil {
+		log.Println("sourceWebhookWorker: failed to update delivery", id, err)
+	}
+}
+
+func (w *sourceWebhookWorker) dispatch(sourceType string, delivery *models.SourceWebhookDelivery) error {
+	switch sourceType {
+	case models.SourceTypeGitlab:
+		return w.dispatchGitlab(delivery)
+	case models.SourceTypeGitea:
+		return w.dispatchGitea(delivery)
+	default:
+		return fmt.Errorf("no webhook handler for %s sources", sourceType)
+	}
+}
+
+func (w *sourceWebhookWorker) dispatchGitlab(delivery *models.SourceWebhookDelivery) error {
+	event, err := gitlab.ParseEvent(delivery.Event, delivery.Payload)
+	if err != nil {
+		return err
+	}
+
+	switch event := event.(type) {
+	case *gitlab.PushEvent:
+		if event.Branch() == "" || event.Deleted() {
+			return nil
+		}
+		return QueuePushDeployments(w.DB, w.RedisClient, w.Ctx, delivery.SourceID, strconv.FormatInt(event.ProjectID, 10), event.Branch(), event.After)
+	case *gitlab.MergeRequestEvent:
+		log.Printf("sourceWebhookWorker: source %s merge request !%d %s on %s", delivery.SourceID, event.ObjectAttributes.IID, event.ObjectAttributes.Action, event.Project.PathWithNamespace)
+		return nil
+	default:
+		return fmt.Errorf("no handler for %T", event)
+	}
+}
+
+func (w *sourceWebhookWorker) dispatchGitea(delivery *models.SourceWebhookDelivery) error {
+	event, err := gitea.ParseEvent(delivery.Event, delivery.Payload)
+	if err != nil {
+		return err
+	}
+
+	switch event := event.(type) {
+	case *gitea.PushEvent:
+		if event.Branch() == "" || event.Deleted() {
+			return nil
+		}
+		return QueuePushDeployments(w.DB, w.RedisClient, w.Ctx, delivery.SourceID, strconv.FormatInt(event.Repository.ID, 10), event.Branch(), event.After)
+	case *gitea.PullRequestEvent:
+		log.Printf("sourceWebhookWorker: source %s pull request #%d %s on %s", delivery.SourceID, event.Number, event.Action, event.Repository.FullName)
+		return nil
+	default:
+		return fmt.Errorf("no handler for %T", event)
+	}
+}
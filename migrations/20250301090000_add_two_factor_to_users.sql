@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "users"
+    ADD COLUMN "totp_secret" TEXT,
+    ADD COLUMN "totp_enabled" BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN "totp_recovery_codes" JSONB NOT NULL DEFAULT '[]'::jsonb;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "users"
+    DROP COLUMN "totp_secret",
+    DROP COLUMN "totp_enabled",
+    DROP COLUMN "totp_recovery_codes";
+-- +goose StatementEnd
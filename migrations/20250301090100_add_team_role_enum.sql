@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE "public"."team_role" AS ENUM('owner', 'admin', 'member');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TYPE "public"."team_role";
+-- +goose StatementEnd
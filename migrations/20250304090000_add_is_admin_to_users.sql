@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "users" ADD COLUMN "is_admin" BOOLEAN NOT NULL DEFAULT FALSE;
+-- The first account on an instance administers it.
+UPDATE "users" SET "is_admin" = TRUE WHERE "id" = (SELECT "id" FROM "users" ORDER BY "created_at" LIMIT 1);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "users" DROP COLUMN "is_admin";
+-- +goose StatementEnd
@@ -0,0 +1,44 @@
+-- +goose Up
+-- +goose StatementBegin
+-- actor_id is deliberately not a foreign key: entries must outlive the users
+-- they describe, so the email is kept alongside it.
+CREATE TABLE "audit_logs" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "actor_id" UUID,
+    "actor_email" TEXT,
+    "action" TEXT NOT NULL,
+    "target_type" TEXT,
+    "target_id" TEXT,
+    "method" TEXT NOT NULL,
+    "path" TEXT NOT NULL,
+    "ip" TEXT NOT NULL,
+    "user_agent" TEXT NOT NULL,
+    "outcome" TEXT NOT NULL,
+    "status_code" INTEGER NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "audit_logs_created_at_idx" ON "audit_logs" ("created_at" DESC);
+CREATE INDEX "audit_logs_actor_id_idx" ON "audit_logs" ("actor_id");
+CREATE INDEX "audit_logs_target_idx" ON "audit_logs" ("target_type", "target_id");
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE FUNCTION "prevent_audit_log_changes"() RETURNS TRIGGER AS $$
+BEGIN
+    RAISE EXCEPTION 'audit_logs is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+-- +goose StatementBegin
+CREATE TRIGGER "audit_logs_append_only"
+    BEFORE UPDATE OR DELETE ON "audit_logs"
+    FOR EACH ROW EXECUTE FUNCTION "prevent_audit_log_changes"();
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "audit_logs";
+DROP FUNCTION "prevent_audit_log_changes";
+-- +goose StatementEnd
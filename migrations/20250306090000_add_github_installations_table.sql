@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "github_installations" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "source_id" UUID NOT NULL REFERENCES "sources"("id") ON DELETE CASCADE,
+    "installation_id" BIGINT NOT NULL,
+    "account_id" BIGINT NOT NULL,
+    "account_login" TEXT NOT NULL,
+    "account_type" TEXT NOT NULL,
+    "target_type" TEXT NOT NULL,
+    "repository_selection" TEXT NOT NULL,
+    "suspended_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("source_id", "installation_id")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "github_installations";
+-- +goose StatementEnd
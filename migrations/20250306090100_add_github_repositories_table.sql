@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "github_repositories" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "installation_id" UUID NOT NULL REFERENCES "github_installations"("id") ON DELETE CASCADE,
+    "repository_id" BIGINT NOT NULL,
+    "name" TEXT NOT NULL,
+    "full_name" TEXT NOT NULL,
+    "private" BOOLEAN NOT NULL,
+    "default_branch" TEXT NOT NULL DEFAULT '',
+    "html_url" TEXT NOT NULL DEFAULT '',
+    "clone_url" TEXT NOT NULL DEFAULT '',
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("installation_id", "repository_id")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "github_repositories";
+-- +goose StatementEnd
@@ -0,0 +1,14 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "instance_settings" (
+    "key" TEXT PRIMARY KEY,
+    "value" JSONB NOT NULL,
+    "updated_by" UUID REFERENCES "users"("id") ON DELETE SET NULL,
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "instance_settings";
+-- +goose StatementEnd
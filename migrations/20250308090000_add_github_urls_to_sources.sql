@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "sources"
+    ADD COLUMN "html_url" TEXT NOT NULL DEFAULT 'https://github.com',
+    ADD COLUMN "api_url" TEXT NOT NULL DEFAULT 'https://api.github.com',
+    ADD COLUMN "organization" TEXT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "sources"
+    DROP COLUMN "html_url",
+    DROP COLUMN "api_url",
+    DROP COLUMN "organization";
+-- +goose StatementEnd
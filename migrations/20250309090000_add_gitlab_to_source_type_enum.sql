@@ -0,0 +1,7 @@
+-- +goose NO TRANSACTION
+-- +goose Up
+ALTER TYPE "public"."source_type" ADD VALUE IF NOT EXISTS 'gitlab';
+
+-- +goose Down
+-- Postgres can't drop a value from an enum; leaving it is harmless.
+SELECT 1;
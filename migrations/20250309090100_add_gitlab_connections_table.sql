@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "gitlab_connections" (
+    "source_id" UUID PRIMARY KEY REFERENCES "sources"("id") ON DELETE CASCADE,
+    -- 'oauth' for an OAuth application, 'token' for a personal, project or
+    -- group access token.
+    "auth_type" TEXT NOT NULL,
+    "client_id" TEXT,
+    "client_secret" TEXT,
+    -- GitLab wants the redirect URI the token was issued for on refresh too.
+    "redirect_url" TEXT,
+    "access_token" TEXT,
+    "refresh_token" TEXT,
+    "token_expires_at" TIMESTAMP,
+    "webhook_secret" TEXT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "gitlab_connections";
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "source_webhooks" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "source_id" UUID NOT NULL REFERENCES "sources"("id") ON DELETE CASCADE,
+    "repository_id" TEXT NOT NULL,
+    "hook_id" TEXT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("source_id", "repository_id")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "source_webhooks";
+-- +goose StatementEnd
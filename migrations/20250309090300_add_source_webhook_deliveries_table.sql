@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "source_webhook_deliveries" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "source_id" UUID NOT NULL REFERENCES "sources"("id") ON DELETE CASCADE,
+    "delivery_id" TEXT NOT NULL,
+    "event" TEXT NOT NULL,
+    "payload" JSONB NOT NULL,
+    "status" TEXT NOT NULL DEFAULT 'pending',
+    "error" TEXT,
+    "received_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "processed_at" TIMESTAMP,
+    UNIQUE ("source_id", "delivery_id")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "source_webhook_deliveries";
+-- +goose StatementEnd
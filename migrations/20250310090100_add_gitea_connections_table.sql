@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "gitea_connections" (
+    "source_id" UUID PRIMARY KEY REFERENCES "sources"("id") ON DELETE CASCADE,
+    "access_token" TEXT NOT NULL,
+    "webhook_secret" TEXT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "gitea_connections";
+-- +goose StatementEnd
@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "git_connections" (
+    "source_id" UUID PRIMARY KEY REFERENCES "sources"("id") ON DELETE CASCADE,
+    "repository_url" TEXT NOT NULL,
+    -- Deploy key for SSH URLs; username and password for HTTPS URLs.
+    "key_id" UUID REFERENCES "keys"("id") ON DELETE SET NULL,
+    "username" TEXT,
+    "password" TEXT,
+    "branch" TEXT NOT NULL,
+    "poll_interval" INTEGER NOT NULL DEFAULT 300,
+    "last_commit_sha" TEXT,
+    "last_polled_at" TIMESTAMP,
+    "last_poll_error" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "git_connections";
+-- +goose StatementEnd
@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE "public"."build_type" AS ENUM('dockerfile');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TYPE "public"."build_type";
+-- +goose StatementEnd
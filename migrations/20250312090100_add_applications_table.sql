@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "applications" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "name" TEXT NOT NULL,
+    "source_id" UUID NOT NULL REFERENCES "sources"("id") ON DELETE RESTRICT,
+    -- The provider's repository id, as listed by the source.
+    "repository_id" TEXT NOT NULL,
+    "branch" TEXT NOT NULL,
+    "server_id" UUID NOT NULL REFERENCES "servers"("id") ON DELETE RESTRICT,
+    "build_type" "build_type" NOT NULL DEFAULT 'dockerfile',
+    "base_directory" TEXT NOT NULL DEFAULT '.',
+    "dockerfile_path" TEXT NOT NULL DEFAULT 'Dockerfile',
+    "ports" JSONB NOT NULL DEFAULT '[]',
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "applications";
+-- +goose StatementEnd
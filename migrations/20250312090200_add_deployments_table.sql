@@ -0,0 +1,22 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "deployments" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "application_id" UUID NOT NULL REFERENCES "applications"("id") ON DELETE CASCADE,
+    "status" TEXT NOT NULL DEFAULT 'queued',
+    -- Empty until the job resolves the branch head, unless a commit was asked for.
+    "commit_sha" TEXT,
+    "image_tag" TEXT,
+    "error" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "started_at" TIMESTAMP,
+    "finished_at" TIMESTAMP
+);
+
+CREATE INDEX "deployments_application_id_created_at_idx" ON "deployments" ("application_id", "created_at" DESC);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "deployments";
+-- +goose StatementEnd
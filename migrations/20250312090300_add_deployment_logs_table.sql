@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "deployment_logs" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "deployment_id" UUID NOT NULL REFERENCES "deployments"("id") ON DELETE CASCADE,
+    "type" TEXT NOT NULL,
+    "content" TEXT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "deployment_logs_deployment_id_id_idx" ON "deployment_logs" ("deployment_id", "id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "deployment_logs";
+-- +goose StatementEnd
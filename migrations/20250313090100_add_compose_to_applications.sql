@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "applications"
+    -- Path of the compose file in the repository, relative to base_directory.
+    ADD COLUMN "compose_path" TEXT NOT NULL DEFAULT 'docker-compose.yml',
+    -- When set, used instead of the compose file in the repository.
+    ADD COLUMN "compose_file" TEXT;
+
+ALTER TABLE "deployments"
+    ADD COLUMN "services" JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "deployments"
+    DROP COLUMN "services";
+
+ALTER TABLE "applications"
+    DROP COLUMN "compose_path",
+    DROP COLUMN "compose_file";
+-- +goose StatementEnd
@@ -0,0 +1,41 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "applications"
+    ADD COLUMN "auto_deploy" BOOLEAN NOT NULL DEFAULT TRUE,
+    ADD COLUMN "preview_deployments" BOOLEAN NOT NULL DEFAULT FALSE,
+    -- Previews are served on pr-<number>.<preview_domain>.
+    ADD COLUMN "preview_domain" TEXT;
+
+CREATE TABLE "previews" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "application_id" UUID NOT NULL REFERENCES "applications"("id") ON DELETE CASCADE,
+    "pull_request_number" INTEGER NOT NULL,
+    "branch" TEXT NOT NULL,
+    "commit_sha" TEXT NOT NULL,
+    "domain" TEXT NOT NULL,
+    -- The pull request comment carrying the preview URL, updated on each deploy.
+    "comment_id" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "closed_at" TIMESTAMP,
+    UNIQUE ("application_id", "pull_request_number")
+);
+
+ALTER TABLE "deployments"
+    ADD COLUMN "trigger" TEXT NOT NULL DEFAULT 'manual',
+    ADD COLUMN "preview_id" UUID REFERENCES "previews"("id") ON DELETE CASCADE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "deployments"
+    DROP COLUMN "trigger",
+    DROP COLUMN "preview_id";
+
+DROP TABLE "previews";
+
+ALTER TABLE "applications"
+    DROP COLUMN "auto_deploy",
+    DROP COLUMN "preview_deployments",
+    DROP COLUMN "preview_domain";
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "deployments"
+    -- What was deployed: ports, compose file and built images, so it can be
+    -- rolled back to later.
+    ADD COLUMN "config" JSONB,
+    ADD COLUMN "triggered_by" UUID REFERENCES "users"("id") ON DELETE SET NULL,
+    ADD COLUMN "rollback_of" UUID REFERENCES "deployments"("id") ON DELETE SET NULL;
+
+ALTER TABLE "applications"
+    -- How many previous deployments keep their images for rollbacks.
+    ADD COLUMN "image_retention" INTEGER NOT NULL DEFAULT 5;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "applications"
+    DROP COLUMN "image_retention";
+
+ALTER TABLE "deployments"
+    DROP COLUMN "config",
+    DROP COLUMN "triggered_by",
+    DROP COLUMN "rollback_of";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "applications"
+    ADD COLUMN "health_check" JSONB;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "applications"
+    DROP COLUMN "health_check";
+-- +goose StatementEnd
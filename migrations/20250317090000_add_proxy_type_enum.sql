@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE "public"."proxy_type" AS ENUM('traefik', 'caddy');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TYPE "public"."proxy_type";
+-- +goose StatementEnd
@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "server_proxies" (
+    "server_id" UUID PRIMARY KEY REFERENCES "servers"("id") ON DELETE CASCADE,
+    "type" "proxy_type" NOT NULL,
+    "acme_email" TEXT,
+    "acme_directory_url" TEXT NOT NULL DEFAULT 'https://acme-v02.api.letsencrypt.org/directory',
+    -- Extra root the proxy trusts for the ACME directory, such as Pebble's.
+    "acme_ca_certificate" TEXT,
+    "status" TEXT NOT NULL DEFAULT 'pending',
+    "error" TEXT,
+    "applied_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "server_proxies";
+-- +goose StatementEnd
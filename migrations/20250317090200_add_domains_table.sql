@@ -0,0 +1,20 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "domains" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "application_id" UUID NOT NULL REFERENCES "applications"("id") ON DELETE CASCADE,
+    "hostname" TEXT NOT NULL UNIQUE,
+    -- Container port traffic goes to; defaults to the application's first.
+    "port" INTEGER,
+    -- Compose service traffic goes to.
+    "service" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "domains_application_id_idx" ON "domains" ("application_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "domains";
+-- +goose StatementEnd
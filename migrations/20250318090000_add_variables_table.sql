@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "variables" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "application_id" UUID NOT NULL REFERENCES "applications"("id") ON DELETE CASCADE,
+    "key" TEXT NOT NULL,
+    -- Secret values are encrypted with the instance's ENCRYPTION_KEY.
+    "value" TEXT NOT NULL,
+    "is_secret" BOOLEAN NOT NULL DEFAULT FALSE,
+    "is_build_time" BOOLEAN NOT NULL DEFAULT FALSE,
+    "is_runtime" BOOLEAN NOT NULL DEFAULT TRUE,
+    -- Preview variables override the application's own in pull request previews.
+    "is_preview" BOOLEAN NOT NULL DEFAULT FALSE,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("application_id", "key", "is_preview")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "variables";
+-- +goose StatementEnd
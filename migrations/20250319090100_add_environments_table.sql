@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "environments" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "project_id" UUID NOT NULL REFERENCES "projects"("id") ON DELETE CASCADE,
+    "name" TEXT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("project_id", "name")
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "environments";
+-- +goose StatementEnd
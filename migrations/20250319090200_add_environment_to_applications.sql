@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "applications"
+    ADD COLUMN "environment_id" UUID REFERENCES "environments"("id") ON DELETE RESTRICT;
+
+-- Existing applications move to the production environment of a default
+-- project.
+INSERT INTO "projects" ("name")
+SELECT 'Default' WHERE EXISTS (SELECT 1 FROM "applications");
+
+INSERT INTO "environments" ("project_id", "name")
+SELECT "id", 'production' FROM "projects";
+
+UPDATE "applications" SET "environment_id" = (SELECT "id" FROM "environments" LIMIT 1);
+
+ALTER TABLE "applications" ALTER COLUMN "environment_id" SET NOT NULL;
+
+CREATE INDEX "applications_environment_id_idx" ON "applications" ("environment_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "applications" DROP COLUMN "environment_id";
+-- +goose StatementEnd
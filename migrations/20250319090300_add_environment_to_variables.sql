@@ -0,0 +1,21 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Variables belong to an application, or are shared by every application in
+-- an environment.
+ALTER TABLE "variables"
+    ALTER COLUMN "application_id" DROP NOT NULL,
+    ADD COLUMN "environment_id" UUID REFERENCES "environments"("id") ON DELETE CASCADE,
+    ADD CONSTRAINT "variables_scope_check" CHECK (("application_id" IS NULL) <> ("environment_id" IS NULL)),
+    ADD CONSTRAINT "variables_environment_id_key_is_preview_key" UNIQUE ("environment_id", "key", "is_preview");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DELETE FROM "variables" WHERE "application_id" IS NULL;
+
+ALTER TABLE "variables"
+    DROP CONSTRAINT "variables_environment_id_key_is_preview_key",
+    DROP CONSTRAINT "variables_scope_check",
+    DROP COLUMN "environment_id",
+    ALTER COLUMN "application_id" SET NOT NULL;
+-- +goose StatementEnd
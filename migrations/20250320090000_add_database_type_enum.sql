@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE "public"."database_type" AS ENUM('postgresql', 'mysql', 'mariadb', 'redis', 'mongodb');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TYPE "public"."database_type";
+-- +goose StatementEnd
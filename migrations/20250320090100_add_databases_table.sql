@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "databases" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "name" TEXT NOT NULL,
+    "type" "database_type" NOT NULL,
+    "version" TEXT NOT NULL,
+    "server_id" UUID NOT NULL REFERENCES "servers"("id") ON DELETE RESTRICT,
+    "environment_id" UUID NOT NULL REFERENCES "environments"("id") ON DELETE RESTRICT,
+    "username" TEXT NOT NULL,
+    -- Encrypted with the instance's ENCRYPTION_KEY.
+    "password" TEXT NOT NULL,
+    -- Empty for Redis, which has no named databases.
+    "database_name" TEXT,
+    "public_port" INT,
+    "status" TEXT NOT NULL DEFAULT 'starting',
+    "error" TEXT,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("server_id", "public_port")
+);
+
+CREATE INDEX "databases_environment_id_idx" ON "databases" ("environment_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "databases";
+-- +goose StatementEnd
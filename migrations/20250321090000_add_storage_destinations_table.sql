@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "storage_destinations" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "name" TEXT NOT NULL,
+    "endpoint" TEXT NOT NULL,
+    "region" TEXT NOT NULL DEFAULT 'us-east-1',
+    "bucket" TEXT NOT NULL,
+    "access_key_id" TEXT NOT NULL,
+    -- Encrypted with the instance's ENCRYPTION_KEY.
+    "secret_access_key" TEXT NOT NULL,
+    -- Prepended to the key of every object stored in the bucket.
+    "path_prefix" TEXT NOT NULL DEFAULT '',
+    "path_style" BOOLEAN NOT NULL DEFAULT TRUE,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "storage_destinations";
+-- +goose StatementEnd
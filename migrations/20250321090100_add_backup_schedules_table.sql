@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "backup_schedules" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "database_id" UUID NOT NULL REFERENCES "databases"("id") ON DELETE CASCADE,
+    "destination_id" UUID NOT NULL REFERENCES "storage_destinations"("id") ON DELETE RESTRICT,
+    -- A five-field cron expression, in UTC.
+    "cron" TEXT NOT NULL,
+    "keep_daily" INT NOT NULL DEFAULT 7,
+    "keep_weekly" INT NOT NULL DEFAULT 4,
+    "enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "next_run_at" TIMESTAMP NOT NULL,
+    "last_run_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "backup_schedules_database_id_idx" ON "backup_schedules" ("database_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "backup_schedules";
+-- +goose StatementEnd
@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "backups" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    -- Backups outlive their database so that they can still be restored.
+    "database_id" UUID REFERENCES "databases"("id") ON DELETE SET NULL,
+    "database_type" "database_type" NOT NULL,
+    "schedule_id" UUID REFERENCES "backup_schedules"("id") ON DELETE SET NULL,
+    "destination_id" UUID NOT NULL REFERENCES "storage_destinations"("id") ON DELETE RESTRICT,
+    "status" TEXT NOT NULL DEFAULT 'pending',
+    "object_key" TEXT NOT NULL,
+    "size" BIGINT,
+    -- Hex SHA-256 of the stored, compressed dump.
+    "checksum" TEXT,
+    "error" TEXT,
+    "started_at" TIMESTAMP,
+    "finished_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "backups_database_id_idx" ON "backups" ("database_id");
+CREATE INDEX "backups_schedule_id_idx" ON "backups" ("schedule_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "backups";
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Uploaded backups are dumps made elsewhere, stored so they can be restored.
+ALTER TABLE "backups" ADD COLUMN "uploaded" BOOLEAN NOT NULL DEFAULT FALSE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "backups" DROP COLUMN "uploaded";
+-- +goose StatementEnd
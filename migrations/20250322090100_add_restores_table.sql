@@ -0,0 +1,23 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "restores" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "backup_id" UUID REFERENCES "backups"("id") ON DELETE SET NULL,
+    -- The database restored into, which is a new one for sibling restores.
+    "database_id" UUID NOT NULL REFERENCES "databases"("id") ON DELETE CASCADE,
+    "sibling" BOOLEAN NOT NULL DEFAULT FALSE,
+    "stop_applications" BOOLEAN NOT NULL DEFAULT FALSE,
+    "status" TEXT NOT NULL DEFAULT 'pending',
+    "error" TEXT,
+    "started_at" TIMESTAMP,
+    "finished_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "restores_database_id_idx" ON "restores" ("database_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "restores";
+-- +goose StatementEnd
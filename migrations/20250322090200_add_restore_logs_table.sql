@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "restore_logs" (
+    "id" BIGSERIAL PRIMARY KEY,
+    "restore_id" UUID NOT NULL REFERENCES "restores"("id") ON DELETE CASCADE,
+    "type" TEXT NOT NULL,
+    "content" TEXT NOT NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "restore_logs_restore_id_id_idx" ON "restore_logs" ("restore_id", "id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "restore_logs";
+-- +goose StatementEnd
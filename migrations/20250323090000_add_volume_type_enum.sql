@@ -0,0 +1,9 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TYPE "public"."volume_type" AS ENUM('volume', 'bind');
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TYPE "public"."volume_type";
+-- +goose StatementEnd
@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "volumes" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "application_id" UUID NOT NULL REFERENCES "applications"("id") ON DELETE CASCADE,
+    "name" TEXT NOT NULL,
+    "type" "volume_type" NOT NULL,
+    -- Directory on the server that bind mounts mount. Named volumes are
+    -- called after their id, so renaming them only renames the record.
+    "host_path" TEXT,
+    "mount_path" TEXT NOT NULL,
+    -- Compose service the volume is mounted into.
+    "service" TEXT,
+    "read_only" BOOLEAN NOT NULL DEFAULT FALSE,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    UNIQUE ("application_id", "name")
+);
+
+CREATE UNIQUE INDEX "volumes_mount_idx" ON "volumes" ("application_id", COALESCE("service", ''), "mount_path");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "volumes";
+-- +goose StatementEnd
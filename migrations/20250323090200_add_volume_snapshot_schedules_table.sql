@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "volume_snapshot_schedules" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    "volume_id" UUID NOT NULL REFERENCES "volumes"("id") ON DELETE CASCADE,
+    "destination_id" UUID NOT NULL REFERENCES "storage_destinations"("id") ON DELETE RESTRICT,
+    -- A five-field cron expression, in UTC.
+    "cron" TEXT NOT NULL,
+    "keep_daily" INT NOT NULL DEFAULT 7,
+    "keep_weekly" INT NOT NULL DEFAULT 4,
+    "enabled" BOOLEAN NOT NULL DEFAULT TRUE,
+    "next_run_at" TIMESTAMP NOT NULL,
+    "last_run_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    "updated_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "volume_snapshot_schedules_volume_id_idx" ON "volume_snapshot_schedules" ("volume_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "volume_snapshot_schedules";
+-- +goose StatementEnd
@@ -0,0 +1,27 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "volume_snapshots" (
+    "id" UUID PRIMARY KEY DEFAULT uuid_generate_v4(),
+    -- Snapshots outlive their volume, like backups do their database.
+    "volume_id" UUID REFERENCES "volumes"("id") ON DELETE SET NULL,
+    "schedule_id" UUID REFERENCES "volume_snapshot_schedules"("id") ON DELETE SET NULL,
+    "destination_id" UUID NOT NULL REFERENCES "storage_destinations"("id") ON DELETE RESTRICT,
+    "status" TEXT NOT NULL DEFAULT 'pending',
+    "object_key" TEXT NOT NULL,
+    "size" BIGINT,
+    -- Hex SHA-256 of the stored tarball.
+    "checksum" TEXT,
+    "error" TEXT,
+    "started_at" TIMESTAMP,
+    "finished_at" TIMESTAMP,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX "volume_snapshots_volume_id_idx" ON "volume_snapshots" ("volume_id");
+CREATE INDEX "volume_snapshots_schedule_id_idx" ON "volume_snapshots" ("schedule_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "volume_snapshots";
+-- +goose StatementEnd
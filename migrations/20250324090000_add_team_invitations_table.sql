@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "team_invitations" (
+    "team_id" UUID NOT NULL REFERENCES "teams"("id") ON DELETE CASCADE,
+    "user_id" UUID NOT NULL REFERENCES "users"("id") ON DELETE CASCADE,
+    "role" "team_role" NOT NULL DEFAULT 'member',
+    "invited_by" UUID REFERENCES "users"("id") ON DELETE SET NULL,
+    "created_at" TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY ("team_id", "user_id")
+);
+
+CREATE INDEX "team_invitations_user_id_idx" ON "team_invitations" ("user_id");
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE "team_invitations";
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+-- The numeric id GitHub gave the app. Apps registered before it was kept
+-- have none.
+ALTER TABLE "github_apps" ADD COLUMN "app_id" BIGINT;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "github_apps" DROP COLUMN "app_id";
+-- +goose StatementEnd
@@ -0,0 +1,579 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/sources"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
+	"github.com/redis/go-redis/v9"
+)
+
+type ApplicationRepository interface {
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	Deploy(c *gin.Context)
+	FindPreviews(c *gin.Context)
+}
+
+type applicationRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewApplicationRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *applicationRepository {
+	return &applicationRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *applicationRepository) Create(c *gin.Context) {
+	audit.Action(c, "application.create")
+
+	var input models.CreateApplication
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.BuildType == "" {
+		input.BuildType = models.BuildTypeDockerfile
+	}
+
+	baseDirectory, err := cleanRepositoryPath(input.BaseDirectory, ".")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "baseDirectory " + err.Error()})
+		return
+	}
+	dockerfilePath, err := cleanRepositoryPath(input.DockerfilePath, "Dockerfile")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "dockerfilePath " + err.Error()})
+		return
+	}
+	composePath, err := cleanRepositoryPath(input.ComposePath, "docker-compose.yml")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "composePath " + err.Error()})
+		return
+	}
+
+	var composeFile *string
+	if strings.TrimSpace(input.ComposeFile) != "" {
+		if input.BuildType != models.BuildTypeCompose {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "composeFile requires the compose build type"})
+			return
+		}
+		composeFile = &input.ComposeFile
+	}
+
+	autoDeploy := true
+	if input.AutoDeploy != nil {
+		autoDeploy = *input.AutoDeploy
+	}
+
+	var previewDomain *string
+	if input.PreviewDomain != "" {
+		domain := strings.ToLower(input.PreviewDomain)
+		previewDomain = &domain
+	}
+	imageRetention := 5
+	if input.ImageRetention != nil {
+		imageRetention = *input.ImageRetention
+	}
+
+	if err := validateHealthCheck(input.HealthCheck, input.Ports); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.PreviewDeployments && previewDomain == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Preview deployments need a preview domain"})
+		return
+	}
+
+	// Make sure the source can actually see the repository before anything is
+	// deployed from it.
+	provider, err := sources.Load(r.DB, r.RedisClient, r.Ctx, input.SourceID)
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Source not found"})
+		case errors.Is(err, sources.ErrNotConnected):
+			c.JSON(http.StatusConflict, gin.H{"error": shared.ErrSourceNotConnected})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		}
+		return
+	}
+	if _, err := provider.GetRepository(input.RepositoryID); err != nil {
+		if sources.IsNotFound(err) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Repository not found"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSourceUnavailable})
+		return
+	}
+
+	application := &models.Application{
+		Name:               input.Name,
+		SourceID:           input.SourceID,
+		RepositoryID:       input.RepositoryID,
+		Branch:             input.Branch,
+		ServerID:           input.ServerID,
+		EnvironmentID:      input.EnvironmentID,
+		BuildType:          input.BuildType,
+		BaseDirectory:      baseDirectory,
+		DockerfilePath:     dockerfilePath,
+		Ports:              input.Ports,
+		ComposePath:        composePath,
+		ComposeFile:        composeFile,
+		AutoDeploy:         autoDeploy,
+		PreviewDeployments: input.PreviewDeployments,
+		PreviewDomain:      previewDomain,
+		ImageRetention:     imageRetention,
+		HealthCheck:        input.HealthCheck,
+	}
+
+	query := `
+		insert into applications (
+			name, source_id, repository_id, branch, server_id, environment_id, build_type, base_directory,
+			dockerfile_path, ports, compose_path, compose_file, auto_deploy, preview_deployments, preview_domain,
+			image_retention, health_check
+		) values (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+		)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		application,
+		query,
+		application.Name,
+		application.SourceID,
+		application.RepositoryID,
+		application.Branch,
+		application.ServerID,
+		application.EnvironmentID,
+		application.BuildType,
+		application.BaseDirectory,
+		application.DockerfilePath,
+		application.Ports,
+		application.ComposePath,
+		application.ComposeFile,
+		application.AutoDeploy,
+		application.PreviewDeployments,
+		application.PreviewDomain,
+		application.ImageRetention,
+		application.HealthCheck,
+	); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+			if strings.Contains(pqErr.Constraint, "environment") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Environment not found"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Server not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	audit.Target(c, "application", application.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"application": application},
+	})
+}
+
+// FindAll lists applications, only those in one environment with
+// environmentId.
+func (r *applicationRepository) FindAll(c *gin.Context) {
+	query, args := "select * from applications order by name", []any{}
+	if environmentID := c.Query("environmentId"); environmentID != "" {
+		query, args = "select * from applications where environment_id = $1 order by name", []any{environmentID}
+	}
+
+	var applications []models.Application = []models.Application{}
+	if err := r.DB.SelectContext(r.Ctx, &applications, query, args...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"applications": applications},
+	})
+}
+
+func (r *applicationRepository) FindByID(c *gin.Context) {
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"application": application},
+	})
+}
+
+func (r *applicationRepository) Update(c *gin.Context) {
+	audit.Action(c, "application.update")
+
+	var input models.UpdateApplication
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+
+	if input.Name != nil {
+		application.Name = *input.Name
+	}
+	if input.EnvironmentID != nil {
+		application.EnvironmentID = *input.EnvironmentID
+	}
+	if input.Branch != nil {
+		application.Branch = *input.Branch
+	}
+	if input.BaseDirectory != nil {
+		baseDirectory, err := cleanRepositoryPath(*input.BaseDirectory, ".")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "baseDirectory " + err.Error()})
+			return
+		}
+		application.BaseDirectory = baseDirectory
+	}
+	if input.DockerfilePath != nil {
+		dockerfilePath, err := cleanRepositoryPath(*input.DockerfilePath, "Dockerfile")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "dockerfilePath " + err.Error()})
+			return
+		}
+		application.DockerfilePath = dockerfilePath
+	}
+	if input.Ports != nil {
+		application.Ports = *input.Ports
+	}
+	if input.ComposePath != nil {
+		composePath, err := cleanRepositoryPath(*input.ComposePath, "docker-compose.yml")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "composePath " + err.Error()})
+			return
+		}
+		application.ComposePath = composePath
+	}
+	if input.ComposeFile != nil {
+		if strings.TrimSpace(*input.ComposeFile) == "" {
+			application.ComposeFile = nil
+		} else if application.BuildType != models.BuildTypeCompose {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "composeFile requires the compose build type"})
+			return
+		} else {
+			application.ComposeFile = input.ComposeFile
+		}
+	}
+	if input.AutoDeploy != nil {
+		application.AutoDeploy = *input.AutoDeploy
+	}
+	if input.PreviewDeployments != nil {
+		application.PreviewDeployments = *input.PreviewDeployments
+	}
+	if input.PreviewDomain != nil {
+		if *input.PreviewDomain == "" {
+			application.PreviewDomain = nil
+		} else if !isDomain(*input.PreviewDomain) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid preview domain"})
+			return
+		} else {
+			domain := strings.ToLower(*input.PreviewDomain)
+			application.PreviewDomain = &domain
+		}
+	}
+	if input.ImageRetention != nil {
+		application.ImageRetention = *input.ImageRetention
+	}
+	if input.RemoveHealthCheck {
+		application.HealthCheck = nil
+	} else if input.HealthCheck != nil {
+		application.HealthCheck = input.HealthCheck
+	}
+	if err := validateHealthCheck(application.HealthCheck, application.Ports); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if application.PreviewDeployments && application.PreviewDomain == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Preview deployments need a preview domain"})
+		return
+	}
+
+	if strings.TrimSpace(application.Name) == "" || strings.TrimSpace(application.Branch) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Name and branch can't be empty"})
+		return
+	}
+
+	query := `
+		update applications
+		set
+			name = $1, branch = $2, base_directory = $3, dockerfile_path = $4, ports = $5,
+			compose_path = $6, compose_file = $7, auto_deploy = $8, preview_deployments = $9, preview_domain = $10,
+			image_retention = $11, health_check = $12, environment_id = $13, updated_at = now()
+		where id = $14
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		application,
+		query,
+		application.Name,
+		application.Branch,
+		application.BaseDirectory,
+		application.DockerfilePath,
+		application.Ports,
+		application.ComposePath,
+		application.ComposeFile,
+		application.AutoDeploy,
+		application.PreviewDeployments,
+		application.PreviewDomain,
+		application.ImageRetention,
+		application.HealthCheck,
+		application.EnvironmentID,
+		application.ID,
+	); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Environment not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	// Ports and previews decide where domains are routed.
+	if err := workers.QueueProxy(r.RedisClient, r.Ctx, application.ServerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"application": application},
+	})
+}
+
+// Delete removes the application's containers from its server and then the
+// application. Pass force=true to delete it even if the server can't be
+// reached.
+func (r *applicationRepository) Delete(c *gin.Context) {
+	audit.Action(c, "application.delete")
+
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+
+	if err := r.removeContainers(application); err != nil && c.Query("force") != "true" {
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSSHConnection})
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from applications where id = $1", application.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := workers.QueueProxy(r.RedisClient, r.Ctx, application.ServerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+func (r *applicationRepository) Deploy(c *gin.Context) {
+	audit.Action(c, "application.deploy")
+
+	var input models.CreateDeployment
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+
+	var commitSHA *string
+	if input.CommitSHA != "" {
+		commitSHA = &input.CommitSHA
+	}
+
+	currentSession := c.MustGet("session").(*session.Session)
+
+	deployment, err := workers.QueueDeployment(r.DB, r.RedisClient, r.Ctx, &workers.DeploymentRequest{
+		ApplicationID: application.ID,
+		CommitSHA:     commitSHA,
+		Trigger:       models.TriggerManual,
+		TriggeredBy:   &currentSession.UserID,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"deployment": deployment},
+	})
+}
+
+func (r *applicationRepository) FindPreviews(c *gin.Context) {
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+
+	var previews []models.Preview = []models.Preview{}
+	query := `
+		select * from previews
+		where application_id = $1
+		order by closed_at is not null, updated_at desc
+	`
+	if err := r.DB.SelectContext(r.Ctx, &previews, query, application.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"previews": previews},
+	})
+}
+
+// findApplication loads the :applicationID application. On failure it has
+// already written the response.
+func (r *applicationRepository) findApplication(c *gin.Context) (*models.Application, bool) {
+	applicationID := c.Param("applicationID")
+	audit.Target(c, "application", applicationID)
+
+	var application models.Application
+	if err := r.DB.GetContext(r.Ctx, &application, "select * from applications where id = $1", applicationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &application, true
+}
+
+func (r *applicationRepository) removeContainers(application *models.Application) error {
+	var server struct {
+		Hostname string `db:"hostname"`
+		Port     int    `db:"port"`
+		Key      string `db:"key"`
+	}
+	query := `
+		select
+			s.hostname, s.port, k.key
+		from
+			servers s
+		inner join
+			keys k ON s.key_id = k.id
+		where
+			s.id = $1
+	`
+	if err := r.DB.GetContext(r.Ctx, &server, query, application.ServerID); err != nil {
+		return err
+	}
+
+	sshClient := ssh.NewClient(server.Hostname, server.Port, "root", []byte(server.Key))
+	if err := sshClient.Connect(); err != nil {
+		return err
+	}
+	defer sshClient.Close()
+
+	_, _, err := sshClient.RunCommand(deploy.RemoveApplicationCommand(application.ID))
+	return err
+}
+
+// validateHealthCheck checks what the binding tags can't: each check type's
+// own required fields.
+func validateHealthCheck(check *models.HealthCheck, ports models.Ports) error {
+	if check == nil {
+		return nil
+	}
+
+	switch check.Type {
+	case models.HealthCheckCommand:
+		if strings.TrimSpace(check.Command) == "" {
+			return errors.New("Command health checks need a command")
+		}
+	case models.HealthCheckHTTP, models.HealthCheckTCP:
+		if check.Port == 0 && len(ports) == 0 {
+			return errors.New("Health checks need a port when the application has none")
+		}
+	}
+	return nil
+}
+
+// isDomain reports whether value looks like a hostname Mo-SH can route.
+func isDomain(value string) bool {
+	if len(value) > 253 || !strings.Contains(value, ".") {
+		return false
+	}
+	for _, label := range strings.Split(value, ".") {
+		if label == "" || len(label) > 63 || strings.HasPrefix(label, "-") || strings.HasSuffix(label, "-") {
+			return false
+		}
+		for _, r := range label {
+			if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// cleanRepositoryPath normalizes a path inside the repository, rejecting
+// anything that would escape the checkout.
+func cleanRepositoryPath(value, fallback string) (string, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fallback, nil
+	}
+
+	cleaned := path.Clean(strings.TrimPrefix(value, "/"))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", errors.New("must stay inside the repository")
+	}
+	return cleaned, nil
+}
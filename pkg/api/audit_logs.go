@@ -0,0 +1,164 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	auditLogsDefaultPerPage = 50
+	auditLogsMaxPerPage     = 500
+)
+
+type AuditLogRepository interface {
+	FindAll(c *gin.Context)
+	Export(c *gin.Context)
+}
+
+type auditLogRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewAuditLogRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *auditLogRepository {
+	return &auditLogRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *auditLogRepository) FindAll(c *gin.Context) {
+	var filter models.AuditLogFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if filter.Page < 1 {
+		filter.Page = 1
+	}
+	if filter.PerPage < 1 {
+		filter.PerPage = auditLogsDefaultPerPage
+	}
+	filter.PerPage = min(filter.PerPage, auditLogsMaxPerPage)
+
+	where, args := auditLogWhere(&filter)
+
+	var total int
+	if err := r.DB.GetContext(r.Ctx, &total, "select count(*) from audit_logs"+where, args...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	query := fmt.Sprintf(
+		"select * from audit_logs%s order by created_at desc, id limit $%d offset $%d",
+		where, len(args)+1, len(args)+2,
+	)
+	args = append(args, filter.PerPage, (filter.Page-1)*filter.PerPage)
+
+	var logs []models.AuditLog = []models.AuditLog{}
+	if err := r.DB.SelectContext(r.Ctx, &logs, query, args...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data": gin.H{
+			"auditLogs": logs,
+			"page":      filter.Page,
+			"perPage":   filter.PerPage,
+			"total":     total,
+		},
+	})
+}
+
+// Export streams every matching entry as JSON lines, oldest first, for
+// ingestion into a SIEM.
+func (r *auditLogRepository) Export(c *gin.Context) {
+	var filter models.AuditLogFilter
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	where, args := auditLogWhere(&filter)
+
+	rows, err := r.DB.QueryxContext(r.Ctx, "select * from audit_logs"+where+" order by created_at, id", args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer rows.Close()
+
+	filename := fmt.Sprintf("audit-logs-%s.jsonl", time.Now().UTC().Format("20060102T150405Z"))
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	encoder := json.NewEncoder(c.Writer)
+	for rows.Next() {
+		var entry models.AuditLog
+		if err := rows.StructScan(&entry); err != nil {
+			log.Println("Export() failed to scan audit log", err)
+			return
+		}
+		if err := encoder.Encode(entry); err != nil {
+			return
+		}
+	}
+
+	if err := rows.Err(); err != nil {
+		log.Println("Export() failed to read audit logs", err)
+	}
+}
+
+func auditLogWhere(filter *models.AuditLogFilter) (string, []any) {
+	var conditions []string
+	var args []any
+
+	add := func(condition string, value any) {
+		args = append(args, value)
+		conditions = append(conditions, fmt.Sprintf(condition, len(args)))
+	}
+
+	if filter.ActorID != "" {
+		add("actor_id = $%d", filter.ActorID)
+	}
+	if filter.Action != "" {
+		add("action = $%d", filter.Action)
+	}
+	if filter.TargetType != "" {
+		add("target_type = $%d", filter.TargetType)
+	}
+	if filter.TargetID != "" {
+		add("target_id = $%d", filter.TargetID)
+	}
+	if filter.Outcome != "" {
+		add("outcome = $%d", filter.Outcome)
+	}
+	if !filter.From.IsZero() {
+		add("created_at >= $%d", filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		add("created_at < $%d", filter.To.UTC())
+	}
+
+	if len(conditions) == 0 {
+		return "", nil
+	}
+	return " where " + strings.Join(conditions, " and "), args
+}
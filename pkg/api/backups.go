@@ -0,0 +1,399 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/cron"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/redis/go-redis/v9"
+)
+
+type BackupRepository interface {
+	FindSchedules(c *gin.Context)
+	CreateSchedule(c *gin.Context)
+	UpdateSchedule(c *gin.Context)
+	DeleteSchedule(c *gin.Context)
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type backupRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewBackupRepository(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *backupRepository {
+	return &backupRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+func (r *backupRepository) FindSchedules(c *gin.Context) {
+	var schedules []models.BackupSchedule = []models.BackupSchedule{}
+	query := "select * from backup_schedules where database_id = $1 order by created_at"
+	if err := r.DB.SelectContext(r.Ctx, &schedules, query, c.Param("databaseID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"schedules": schedules},
+	})
+}
+
+func (r *backupRepository) CreateSchedule(c *gin.Context) {
+	databaseID := c.Param("databaseID")
+	audit.Action(c, "database.backup_schedule.create")
+	audit.Target(c, "database", databaseID)
+
+	var input models.CreateBackupSchedule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	nextRunAt, ok := nextRun(c, input.Cron)
+	if !ok {
+		return
+	}
+
+	schedule := models.BackupSchedule{
+		DatabaseID:    databaseID,
+		DestinationID: input.DestinationID,
+		Cron:          strings.TrimSpace(input.Cron),
+		KeepDaily:     7,
+		KeepWeekly:    4,
+		Enabled:       input.Enabled == nil || *input.Enabled,
+		NextRunAt:     nextRunAt,
+	}
+	if input.KeepDaily != nil {
+		schedule.KeepDaily = *input.KeepDaily
+	}
+	if input.KeepWeekly != nil {
+		schedule.KeepWeekly = *input.KeepWeekly
+	}
+
+	query := `
+		insert into backup_schedules (
+			database_id, destination_id, cron, keep_daily, keep_weekly, enabled, next_run_at
+		) values (
+			$1, $2, $3, $4, $5, $6, $7
+		)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&schedule,
+		query,
+		schedule.DatabaseID,
+		schedule.DestinationID,
+		schedule.Cron,
+		schedule.KeepDaily,
+		schedule.KeepWeekly,
+		schedule.Enabled,
+		schedule.NextRunAt,
+	); err != nil {
+		writeBackupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"schedule": schedule},
+	})
+}
+
+func (r *backupRepository) UpdateSchedule(c *gin.Context) {
+	databaseID := c.Param("databaseID")
+	audit.Action(c, "database.backup_schedule.update")
+	audit.Target(c, "database", databaseID)
+
+	var input models.UpdateBackupSchedule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, ok := r.findSchedule(c)
+	if !ok {
+		return
+	}
+
+	if input.Cron != nil {
+		if schedule.NextRunAt, ok = nextRun(c, *input.Cron); !ok {
+			return
+		}
+		schedule.Cron = strings.TrimSpace(*input.Cron)
+	}
+	if input.DestinationID != nil {
+		schedule.DestinationID = *input.DestinationID
+	}
+	if input.KeepDaily != nil {
+		schedule.KeepDaily = *input.KeepDaily
+	}
+	if input.KeepWeekly != nil {
+		schedule.KeepWeekly = *input.KeepWeekly
+	}
+	if input.Enabled != nil {
+		// A schedule turned back on starts from now rather than catching up.
+		if *input.Enabled && !schedule.Enabled && input.Cron == nil {
+			if schedule.NextRunAt, ok = nextRun(c, schedule.Cron); !ok {
+				return
+			}
+		}
+		schedule.Enabled = *input.Enabled
+	}
+
+	query := `
+		update backup_schedules
+		set destination_id = $1, cron = $2, keep_daily = $3, keep_weekly = $4, enabled = $5, next_run_at = $6, updated_at = now()
+		where id = $7
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		schedule,
+		query,
+		schedule.DestinationID,
+		schedule.Cron,
+		schedule.KeepDaily,
+		schedule.KeepWeekly,
+		schedule.Enabled,
+		schedule.NextRunAt,
+		schedule.ID,
+	); err != nil {
+		writeBackupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"schedule": schedule},
+	})
+}
+
+// DeleteSchedule removes a schedule. The backups it made are kept, and no
+// longer pruned.
+func (r *backupRepository) DeleteSchedule(c *gin.Context) {
+	databaseID := c.Param("databaseID")
+	audit.Action(c, "database.backup_schedule.delete")
+	audit.Target(c, "database", databaseID)
+
+	result, err := r.DB.ExecContext(
+		r.Ctx,
+		"delete from backup_schedules where id = $1 and database_id = $2",
+		c.Param("scheduleID"), databaseID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// Create queues a backup of the database now. Backups taken this way are
+// never pruned by retention.
+func (r *backupRepository) Create(c *gin.Context) {
+	databaseID := c.Param("databaseID")
+	audit.Action(c, "database.backup.create")
+	audit.Target(c, "database", databaseID)
+
+	var input models.CreateBackup
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var status string
+	if err := r.DB.GetContext(r.Ctx, &status, "select status from databases where id = $1", databaseID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if status != models.DatabaseRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "The database is " + status + ". Start it to back it up."})
+		return
+	}
+
+	backup, err := workers.QueueBackup(r.DB, r.RedisClient, r.Ctx, databaseID, input.DestinationID, nil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Storage destination not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"backup": backup},
+	})
+}
+
+// FindAll lists backups, newest first: those of one database under
+// /databases/:databaseID, or with databaseId.
+func (r *backupRepository) FindAll(c *gin.Context) {
+	databaseID := c.Param("databaseID")
+	if databaseID == "" {
+		databaseID = c.Query("databaseId")
+	}
+
+	query, args := "select * from backups order by created_at desc", []any{}
+	if databaseID != "" {
+		query, args = "select * from backups where database_id = $1 order by created_at desc", []any{databaseID}
+	}
+
+	var backups []models.Backup = []models.Backup{}
+	if err := r.DB.SelectContext(r.Ctx, &backups, query, args...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"backups": backups},
+	})
+}
+
+func (r *backupRepository) FindByID(c *gin.Context) {
+	backup, ok := r.findBackup(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"backup": backup},
+	})
+}
+
+// Delete removes a finished backup from storage and then its record.
+func (r *backupRepository) Delete(c *gin.Context) {
+	audit.Action(c, "backup.delete")
+
+	backup, ok := r.findBackup(c)
+	if !ok {
+		return
+	}
+	if backup.Status == models.BackupPending || backup.Status == models.BackupRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "The backup is " + backup.Status + ". Please wait."})
+		return
+	}
+
+	_, client, err := storage.Load(r.Ctx, r.DB, r.Secrets, backup.DestinationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Ctx, time.Minute)
+	defer cancel()
+
+	if err := client.Delete(ctx, backup.ObjectKey); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to delete the backup from storage: " + err.Error()})
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from backups where id = $1", backup.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// findSchedule loads the :databaseID database's :scheduleID schedule. On
+// failure it has already written the response.
+func (r *backupRepository) findSchedule(c *gin.Context) (*models.BackupSchedule, bool) {
+	var schedule models.BackupSchedule
+	query := "select * from backup_schedules where id = $1 and database_id = $2"
+	if err := r.DB.GetContext(r.Ctx, &schedule, query, c.Param("scheduleID"), c.Param("databaseID")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &schedule, true
+}
+
+// findBackup loads the :backupID backup. On failure it has already written
+// the response.
+func (r *backupRepository) findBackup(c *gin.Context) (*models.Backup, bool) {
+	backupID := c.Param("backupID")
+	audit.Target(c, "backup", backupID)
+
+	var backup models.Backup
+	if err := r.DB.GetContext(r.Ctx, &backup, "select * from backups where id = $1", backupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &backup, true
+}
+
+// nextRun parses a cron expression and returns when it next fires. On
+// failure it has already written the response.
+func nextRun(c *gin.Context, expression string) (time.Time, bool) {
+	schedule, err := cron.Parse(expression)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return time.Time{}, false
+	}
+
+	next := schedule.Next(time.Now().UTC())
+	if next.IsZero() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The schedule never runs"})
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+func writeBackupError(c *gin.Context, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+		if strings.Contains(pqErr.Constraint, "destination") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Storage destination not found"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+}
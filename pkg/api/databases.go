@@ -2,23 +2,394 @@ package api
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
 
+	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/databases"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
 	"github.com/redis/go-redis/v9"
 )
 
-type DatabaseRepository interface{}
+type DatabaseRepository interface {
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	Credentials(c *gin.Context)
+	Update(c *gin.Context)
+	Start(c *gin.Context)
+	Stop(c *gin.Context)
+	Restart(c *gin.Context)
+	Delete(c *gin.Context)
+}
 
 type databaseRepository struct {
 	DB          *sqlx.DB
 	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
 	Ctx         context.Context
 }
 
-func NewDatabaseRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *databaseRepository {
+func NewDatabaseRepository(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *databaseRepository {
 	return &databaseRepository{
 		DB:          db,
 		RedisClient: redisClient,
+		Secrets:     secretCipher,
 		Ctx:         ctx,
 	}
 }
+
+// Create records the database with generated credentials and queues
+// starting its container.
+func (r *databaseRepository) Create(c *gin.Context) {
+	audit.Action(c, "database.create")
+
+	var input models.CreateDatabase
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	engine, _ := databases.EngineFor(input.Type)
+
+	version := input.Version
+	if version == "" {
+		version = engine.DefaultVersion
+	}
+	if !databases.ValidVersion(version) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+
+	var databaseName *string
+	if input.Type != models.DatabaseTypeRedis {
+		name := input.DatabaseName
+		if name == "" {
+			name = "mo_sh"
+		}
+		if !databases.ValidDatabaseName(name) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid database name"})
+			return
+		}
+		databaseName = &name
+	}
+
+	username, encrypted, ok := newCredentials(c, r.Secrets, input.Type)
+	if !ok {
+		return
+	}
+
+	var publicPort *int
+	if input.PublicPort != 0 {
+		publicPort = &input.PublicPort
+	}
+
+	var database models.Database
+	query := `
+		insert into databases (
+			name, type, version, server_id, environment_id, username, password, database_name, public_port, status
+		) values (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+		)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&database,
+		query,
+		input.Name,
+		input.Type,
+		version,
+		input.ServerID,
+		input.EnvironmentID,
+		username,
+		encrypted,
+		databaseName,
+		publicPort,
+		models.DatabaseStarting,
+	); err != nil {
+		writeDatabaseError(c, err)
+		return
+	}
+
+	audit.Target(c, "database", database.ID)
+
+	if err := workers.QueueDatabaseAction(r.RedisClient, r.Ctx, database.ID, workers.DatabaseActionStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	connection, ok := r.connection(c, &database)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"database": database, "connection": connection},
+	})
+}
+
+// FindAll lists databases, only those in one environment with
+// environmentId.
+func (r *databaseRepository) FindAll(c *gin.Context) {
+	query, args := "select * from databases order by name", []any{}
+	if environmentID := c.Query("environmentId"); environmentID != "" {
+		query, args = "select * from databases where environment_id = $1 order by name", []any{environmentID}
+	}
+
+	var list []models.Database = []models.Database{}
+	if err := r.DB.SelectContext(r.Ctx, &list, query, args...); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"databases": list},
+	})
+}
+
+func (r *databaseRepository) FindByID(c *gin.Context) {
+	database, ok := r.findDatabase(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"database": database},
+	})
+}
+
+// Credentials returns the database's connection details, password included.
+// They are kept out of the other responses so every read is audited.
+func (r *databaseRepository) Credentials(c *gin.Context) {
+	audit.Action(c, "database.credentials.read")
+
+	database, ok := r.findDatabase(c)
+	if !ok {
+		return
+	}
+
+	connection, ok := r.connection(c, database)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"connection": connection},
+	})
+}
+
+func (r *databaseRepository) Update(c *gin.Context) {
+	audit.Action(c, "database.update")
+
+	var input models.UpdateDatabase
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	database, ok := r.findDatabase(c)
+	if !ok {
+		return
+	}
+
+	recreate := false
+	if input.Name != nil {
+		database.Name = *input.Name
+	}
+	if input.Version != nil && *input.Version != database.Version {
+		if !databases.ValidVersion(*input.Version) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+			return
+		}
+		database.Version = *input.Version
+		recreate = true
+	}
+	if input.RemovePublicPort {
+		recreate = recreate || database.PublicPort != nil
+		database.PublicPort = nil
+	} else if input.PublicPort != nil && (database.PublicPort == nil || *database.PublicPort != *input.PublicPort) {
+		database.PublicPort = input.PublicPort
+		recreate = true
+	}
+
+	if recreate && !r.idle(c, database) {
+		return
+	}
+	if recreate {
+		database.Status = models.DatabaseRestarting
+	}
+
+	query := `
+		update databases
+		set name = $1, version = $2, public_port = $3, status = $4, updated_at = now()
+		where id = $5
+		returning *
+	`
+	if err := r.DB.GetContext(r.Ctx, database, query, database.Name, database.Version, database.PublicPort, database.Status, database.ID); err != nil {
+		writeDatabaseError(c, err)
+		return
+	}
+
+	if recreate {
+		if err := workers.QueueDatabaseAction(r.RedisClient, r.Ctx, database.ID, workers.DatabaseActionRecreate); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"database": database},
+	})
+}
+
+func (r *databaseRepository) Start(c *gin.Context) {
+	audit.Action(c, "database.start")
+	r.queue(c, workers.DatabaseActionStart, models.DatabaseStarting)
+}
+
+func (r *databaseRepository) Stop(c *gin.Context) {
+	audit.Action(c, "database.stop")
+	r.queue(c, workers.DatabaseActionStop, models.DatabaseStopping)
+}
+
+func (r *databaseRepository) Restart(c *gin.Context) {
+	audit.Action(c, "database.restart")
+	r.queue(c, workers.DatabaseActionRestart, models.DatabaseRestarting)
+}
+
+// Delete queues removing the database's container and volume, and then the
+// database itself.
+func (r *databaseRepository) Delete(c *gin.Context) {
+	audit.Action(c, "database.delete")
+	r.queue(c, workers.DatabaseActionDelete, models.DatabaseDeleting)
+}
+
+// queue sets the database's status to the action in progress and hands the
+// action to the database worker.
+func (r *databaseRepository) queue(c *gin.Context, action, status string) {
+	database, ok := r.findDatabase(c)
+	if !ok {
+		return
+	}
+	if !r.idle(c, database) {
+		return
+	}
+
+	query := "update databases set status = $1, error = null, updated_at = now() where id = $2 returning *"
+	if err := r.DB.GetContext(r.Ctx, database, query, status, database.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := workers.QueueDatabaseAction(r.RedisClient, r.Ctx, database.ID, action); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"database": database},
+	})
+}
+
+// idle reports whether no job is running for database. Otherwise it has
+// already written the response.
+func (r *databaseRepository) idle(c *gin.Context, database *models.Database) bool {
+	switch database.Status {
+	case models.DatabaseRunning, models.DatabaseStopped, models.DatabaseFailed:
+		return true
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": "The database is " + database.Status + ". Please wait."})
+	return false
+}
+
+// newCredentials generates a username and a password, returned encrypted,
+// for a new database. On failure it has already written the response.
+func newCredentials(c *gin.Context, secretCipher *secrets.Cipher, databaseType string) (string, string, bool) {
+	username := "default"
+	if databaseType != models.DatabaseTypeRedis {
+		username = "mo_sh_" + shared.GenerateRandomString(8)
+	}
+
+	encrypted, err := secretCipher.Encrypt(shared.GenerateRandomString(32))
+	if err != nil {
+		if errors.Is(err, secrets.ErrNoKey) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": shared.ErrNoEncryptionKey})
+			return "", "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return "", "", false
+	}
+
+	return username, encrypted, true
+}
+
+// connection decrypts the database's password into its connection details.
+// On failure it has already written the response.
+func (r *databaseRepository) connection(c *gin.Context, database *models.Database) (*models.DatabaseConnection, bool) {
+	var hostname string
+	if err := r.DB.GetContext(r.Ctx, &hostname, "select hostname from servers where id = $1", database.ServerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	password, err := r.Secrets.Decrypt(database.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return databases.Connection(database, password, hostname), true
+}
+
+// findDatabase loads the :databaseID database. On failure it has already
+// written the response.
+func (r *databaseRepository) findDatabase(c *gin.Context) (*models.Database, bool) {
+	databaseID := c.Param("databaseID")
+	audit.Target(c, "database", databaseID)
+
+	var database models.Database
+	if err := r.DB.GetContext(r.Ctx, &database, "select * from databases where id = $1", databaseID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &database, true
+}
+
+func writeDatabaseError(c *gin.Context, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			c.JSON(http.StatusConflict, gin.H{"error": "Another database on the server uses that public port"})
+			return
+		case "foreign_key_violation":
+			if strings.Contains(pqErr.Constraint, "environment") {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Environment not found"})
+				return
+			}
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Server not found"})
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+}
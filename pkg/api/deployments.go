@@ -0,0 +1,243 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+	"github.com/redis/go-redis/v9"
+)
+
+// logStreamInterval is how often the log stream polls for new lines.
+const logStreamInterval = time.Second
+
+type DeploymentRepository interface {
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	FindLogs(c *gin.Context)
+	StreamLogs(c *gin.Context)
+	Rollback(c *gin.Context)
+}
+
+type deploymentRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewDeploymentRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *deploymentRepository {
+	return &deploymentRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *deploymentRepository) FindAll(c *gin.Context) {
+	applicationID := c.Param("applicationID")
+
+	var exists bool
+	if err := r.DB.QueryRowContext(r.Ctx, `select exists(select 1 from applications where id = $1)`, applicationID).Scan(&exists); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if !exists {
+		c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+		return
+	}
+
+	var deployments []models.Deployment = []models.Deployment{}
+	query := `
+		select * from deployments
+		where application_id = $1
+		order by created_at desc
+		limit 50
+	`
+	if err := r.DB.SelectContext(r.Ctx, &deployments, query, applicationID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"deployments": deployments},
+	})
+}
+
+func (r *deploymentRepository) FindByID(c *gin.Context) {
+	deployment, ok := r.findDeployment(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"deployment": deployment},
+	})
+}
+
+// FindLogs returns the deployment's log lines. Pass after=<id> to only get
+// lines written since the last one the client has seen.
+func (r *deploymentRepository) FindLogs(c *gin.Context) {
+	deployment, ok := r.findDeployment(c)
+	if !ok {
+		return
+	}
+
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after"})
+		return
+	}
+
+	logs, err := r.logsAfter(deployment.ID, after)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data": gin.H{
+			"status": deployment.Status,
+			"logs":   logs,
+		},
+	})
+}
+
+// StreamLogs streams log lines as server-sent events until the deployment
+// finishes, then sends a final "status" event.
+func (r *deploymentRepository) StreamLogs(c *gin.Context) {
+	deployment, ok := r.findDeployment(c)
+	if !ok {
+		return
+	}
+
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after"})
+		return
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	ticker := time.NewTicker(logStreamInterval)
+	defer ticker.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		// Read the status before the logs so lines written just before the
+		// deployment finished are never skipped.
+		var status string
+		if err := r.DB.QueryRowContext(r.Ctx, "select status from deployments where id = $1", deployment.ID).Scan(&status); err != nil {
+			return false
+		}
+
+		logs, err := r.logsAfter(deployment.ID, after)
+		if err != nil {
+			return false
+		}
+		for _, log := range logs {
+			c.SSEvent("log", log)
+			after = log.ID
+		}
+
+		if status == models.DeploymentSucceeded || status == models.DeploymentFailed {
+			c.SSEvent("status", status)
+			return false
+		}
+
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-ticker.C:
+			return true
+		}
+	})
+}
+
+// Rollback redeploys an earlier deployment's images and config without
+// rebuilding. Its images must still be on the server, within the
+// application's image retention, and its secrets unchanged.
+func (r *deploymentRepository) Rollback(c *gin.Context) {
+	audit.Action(c, "deployment.rollback")
+	audit.Target(c, "deployment", c.Param("deploymentID"))
+
+	target, ok := r.findDeployment(c)
+	if !ok {
+		return
+	}
+
+	if target.Status != models.DeploymentSucceeded || target.PreviewID != nil || target.Config == nil || target.CommitSHA == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only successful deployments of the application can be rolled back to"})
+		return
+	}
+	if target.Config.Env == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "This deployment predates recorded variables and can't be rolled back to"})
+		return
+	}
+
+	// Roll back to what a rollback itself ran.
+	rollbackOf := target.ID
+	if target.RollbackOf != nil {
+		rollbackOf = *target.RollbackOf
+	}
+
+	currentSession := c.MustGet("session").(*session.Session)
+
+	deployment, err := workers.QueueDeployment(r.DB, r.RedisClient, r.Ctx, &workers.DeploymentRequest{
+		ApplicationID: target.ApplicationID,
+		CommitSHA:     target.CommitSHA,
+		Trigger:       models.TriggerRollback,
+		TriggeredBy:   &currentSession.UserID,
+		RollbackOf:    &rollbackOf,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"deployment": deployment},
+	})
+}
+
+func (r *deploymentRepository) findDeployment(c *gin.Context) (*models.Deployment, bool) {
+	var deployment models.Deployment
+	if err := r.DB.GetContext(r.Ctx, &deployment, "select * from deployments where id = $1", c.Param("deploymentID")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &deployment, true
+}
+
+func (r *deploymentRepository) logsAfter(deploymentID string, after int64) ([]models.DeploymentLog, error) {
+	var logs []models.DeploymentLog = []models.DeploymentLog{}
+	query := `
+		select * from deployment_logs
+		where deployment_id = $1 and id > $2
+		order by id
+		limit 1000
+	`
+	if err := r.DB.SelectContext(r.Ctx, &logs, query, deploymentID, after); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
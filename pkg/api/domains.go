@@ -0,0 +1,157 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/redis/go-redis/v9"
+)
+
+type DomainRepository interface {
+	FindAll(c *gin.Context)
+	Create(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type domainRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewDomainRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *domainRepository {
+	return &domainRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *domainRepository) FindAll(c *gin.Context) {
+	var domains []models.Domain = []models.Domain{}
+	query := "select * from domains where application_id = $1 order by created_at"
+	if err := r.DB.SelectContext(r.Ctx, &domains, query, c.Param("applicationID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"domains": domains},
+	})
+}
+
+// Create points a domain at the application. Without a port, traffic goes
+// to the application's first container port; compose applications also name
+// the service to route to.
+func (r *domainRepository) Create(c *gin.Context) {
+	applicationID := c.Param("applicationID")
+	audit.Action(c, "application.domain.create")
+	audit.Target(c, "application", applicationID)
+
+	var input models.CreateDomain
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var application models.Application
+	if err := r.DB.GetContext(r.Ctx, &application, "select * from applications where id = $1", applicationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var port *int
+	if input.Port != 0 {
+		port = &input.Port
+	} else if application.BuildType == models.BuildTypeCompose || len(application.Ports) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A port is required"})
+		return
+	}
+
+	var service *string
+	if application.BuildType == models.BuildTypeCompose {
+		if strings.TrimSpace(input.Service) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Compose applications need a service"})
+			return
+		}
+		if !deploy.ValidServiceName(input.Service) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid service name"})
+			return
+		}
+		service = &input.Service
+	} else if input.Service != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service requires the compose build type"})
+		return
+	}
+
+	var domain models.Domain
+	query := `
+		insert into domains (application_id, hostname, port, service)
+		values ($1, $2, $3, $4)
+		returning *
+	`
+	if err := r.DB.GetContext(r.Ctx, &domain, query, application.ID, strings.ToLower(input.Hostname), port, service); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Domain is already in use"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := workers.QueueProxy(r.RedisClient, r.Ctx, application.ServerID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"domain": domain},
+	})
+}
+
+func (r *domainRepository) Delete(c *gin.Context) {
+	applicationID := c.Param("applicationID")
+	audit.Action(c, "application.domain.delete")
+	audit.Target(c, "application", applicationID)
+
+	var serverID string
+	query := `
+		delete from domains d
+		using applications a
+		where d.id = $1 and d.application_id = $2 and a.id = d.application_id
+		returning a.server_id
+	`
+	if err := r.DB.GetContext(r.Ctx, &serverID, query, c.Param("domainID"), applicationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := workers.QueueProxy(r.RedisClient, r.Ctx, serverID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
@@ -0,0 +1,278 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/redis/go-redis/v9"
+)
+
+type EnvironmentRepository interface {
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	Clone(c *gin.Context)
+}
+
+type environmentRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewEnvironmentRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *environmentRepository {
+	return &environmentRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *environmentRepository) Create(c *gin.Context) {
+	projectID := c.Param("projectID")
+	audit.Action(c, "environment.create")
+	audit.Target(c, "project", projectID)
+
+	var input models.CreateEnvironment
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var environment models.Environment
+	query := "insert into environments (project_id, name) values ($1, $2) returning *"
+	if err := r.DB.GetContext(r.Ctx, &environment, query, projectID, input.Name); err != nil {
+		r.writeInsertError(c, err)
+		return
+	}
+
+	audit.Target(c, "environment", environment.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"environment": environment},
+	})
+}
+
+func (r *environmentRepository) FindAll(c *gin.Context) {
+	var environments []models.Environment = []models.Environment{}
+	query := "select * from environments where project_id = $1 order by created_at"
+	if err := r.DB.SelectContext(r.Ctx, &environments, query, c.Param("projectID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"environments": environments},
+	})
+}
+
+// FindByID returns the environment along with its applications and
+// databases.
+func (r *environmentRepository) FindByID(c *gin.Context) {
+	environment, ok := r.findEnvironment(c)
+	if !ok {
+		return
+	}
+
+	var applications []models.Application = []models.Application{}
+	if err := r.DB.SelectContext(r.Ctx, &applications, "select * from applications where environment_id = $1 order by name", environment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var databases []models.Database = []models.Database{}
+	if err := r.DB.SelectContext(r.Ctx, &databases, "select * from databases where environment_id = $1 order by name", environment.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"environment": environment, "applications": applications, "databases": databases},
+	})
+}
+
+func (r *environmentRepository) Update(c *gin.Context) {
+	audit.Action(c, "environment.update")
+
+	var input models.UpdateEnvironment
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	environment, ok := r.findEnvironment(c)
+	if !ok {
+		return
+	}
+
+	query := "update environments set name = $1, updated_at = now() where id = $2 returning *"
+	if err := r.DB.GetContext(r.Ctx, environment, query, input.Name, environment.ID); err != nil {
+		r.writeInsertError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"environment": environment},
+	})
+}
+
+// Delete removes an environment and its shared variables. It must hold no
+// applications or databases.
+func (r *environmentRepository) Delete(c *gin.Context) {
+	audit.Action(c, "environment.delete")
+
+	environment, ok := r.findEnvironment(c)
+	if !ok {
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from environments where id = $1", environment.ID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Delete the environment's resources first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// Clone creates a new environment with copies of this one's variables and
+// applications. Nothing is deployed. Published ports, domains and previews
+// aren't copied, since they would clash with the originals'.
+func (r *environmentRepository) Clone(c *gin.Context) {
+	audit.Action(c, "environment.clone")
+
+	var input models.CloneEnvironment
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, ok := r.findEnvironment(c)
+	if !ok {
+		return
+	}
+
+	projectID := source.ProjectID
+	if input.ProjectID != "" {
+		projectID = input.ProjectID
+	}
+
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer tx.Rollback()
+
+	var environment models.Environment
+	query := "insert into environments (project_id, name) values ($1, $2) returning *"
+	if err := tx.GetContext(r.Ctx, &environment, query, projectID, input.Name); err != nil {
+		r.writeInsertError(c, err)
+		return
+	}
+
+	// Secret values are copied encrypted, as they are.
+	copyVariables := `
+		insert into variables (%s, key, value, is_secret, is_build_time, is_runtime, is_preview)
+		select $1, key, value, is_secret, is_build_time, is_runtime, is_preview
+		from variables where %s = $2
+	`
+	if _, err := tx.ExecContext(r.Ctx, fmt.Sprintf(copyVariables, "environment_id", "environment_id"), environment.ID, source.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var applications []models.Application
+	if err := tx.SelectContext(r.Ctx, &applications, "select * from applications where environment_id = $1 order by name", source.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	copyApplication := `
+		insert into applications (
+			name, source_id, repository_id, branch, server_id, environment_id, build_type, base_directory,
+			dockerfile_path, ports, compose_path, compose_file, auto_deploy, preview_deployments, preview_domain,
+			image_retention, health_check
+		)
+		select
+			name, source_id, repository_id, branch, server_id, $1, build_type, base_directory,
+			dockerfile_path, '[]', compose_path, compose_file, auto_deploy, false, null,
+			image_retention, health_check
+		from applications where id = $2
+		returning *
+	`
+	copies := make([]models.Application, len(applications))
+	for i, application := range applications {
+		if err := tx.GetContext(r.Ctx, &copies[i], copyApplication, environment.ID, application.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+		if _, err := tx.ExecContext(r.Ctx, fmt.Sprintf(copyVariables, "application_id", "application_id"), copies[i].ID, application.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"environment": environment, "applications": copies},
+	})
+}
+
+// findEnvironment loads the :environmentID environment. On failure it has
+// already written the response.
+func (r *environmentRepository) findEnvironment(c *gin.Context) (*models.Environment, bool) {
+	environmentID := c.Param("environmentID")
+	audit.Target(c, "environment", environmentID)
+
+	var environment models.Environment
+	if err := r.DB.GetContext(r.Ctx, &environment, "select * from environments where id = $1", environmentID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &environment, true
+}
+
+func (r *environmentRepository) writeInsertError(c *gin.Context, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) {
+		switch pqErr.Code.Name() {
+		case "unique_violation":
+			c.JSON(http.StatusConflict, gin.H{"error": "The project already has an environment with that name"})
+			return
+		case "foreign_key_violation":
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+}
@@ -13,6 +13,7 @@ import (
 	"github.com/jmoiron/sqlx"
 	"github.com/mohit4bug/mo-sh/internal/models"
 	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -38,6 +39,8 @@ func NewKeyRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Contex
 }
 
 func (r *keyRepository) Create(c *gin.Context) {
+	audit.Action(c, "key.create")
+
 	var input models.CreateKey
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -61,6 +64,8 @@ func (r *keyRepository) Create(c *gin.Context) {
 }
 
 func (r *keyRepository) FindAll(c *gin.Context) {
+	audit.Action(c, "key.list")
+
 	var keys []models.Key = []models.Key{}
 	if err := r.DB.SelectContext(r.Ctx, &keys, "SELECT * FROM keys"); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -75,6 +80,8 @@ func (r *keyRepository) FindAll(c *gin.Context) {
 
 func (r *keyRepository) FindByID(c *gin.Context) {
 	keyID := c.Param("keyID")
+	audit.Action(c, "key.read")
+	audit.Target(c, "key", keyID)
 
 	var key models.Key
 	if err := r.DB.GetContext(r.Ctx, &key, "select * from keys where id = $1", keyID); err != nil {
@@ -93,6 +100,8 @@ func (r *keyRepository) FindByID(c *gin.Context) {
 }
 
 func (r *keyRepository) GenerateKey(c *gin.Context) {
+	audit.Action(c, "key.generate")
+
 	var input models.GenerateKey
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -0,0 +1,211 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/redis/go-redis/v9"
+)
+
+type ProjectRepository interface {
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type projectRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewProjectRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *projectRepository {
+	return &projectRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+// Create adds a project along with its environments, production unless
+// others are given.
+func (r *projectRepository) Create(c *gin.Context) {
+	audit.Action(c, "project.create")
+
+	var input models.CreateProject
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	names := input.Environments
+	if len(names) == 0 {
+		names = []string{"production"}
+	}
+
+	var description *string
+	if strings.TrimSpace(input.Description) != "" {
+		description = &input.Description
+	}
+
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer tx.Rollback()
+
+	var project models.Project
+	query := "insert into projects (name, description) values ($1, $2) returning *"
+	if err := tx.GetContext(r.Ctx, &project, query, input.Name, description); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	environments := make([]models.Environment, len(names))
+	for i, name := range names {
+		query := "insert into environments (project_id, name) values ($1, $2) returning *"
+		if err := tx.GetContext(r.Ctx, &environments[i], query, project.ID, name); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Environment names must be unique"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	audit.Target(c, "project", project.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"project": project, "environments": environments},
+	})
+}
+
+func (r *projectRepository) FindAll(c *gin.Context) {
+	var projects []models.Project = []models.Project{}
+	if err := r.DB.SelectContext(r.Ctx, &projects, "select * from projects order by name"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"projects": projects},
+	})
+}
+
+func (r *projectRepository) FindByID(c *gin.Context) {
+	project, ok := r.findProject(c)
+	if !ok {
+		return
+	}
+
+	var environments []models.Environment = []models.Environment{}
+	if err := r.DB.SelectContext(r.Ctx, &environments, "select * from environments where project_id = $1 order by created_at", project.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"project": project, "environments": environments},
+	})
+}
+
+func (r *projectRepository) Update(c *gin.Context) {
+	audit.Action(c, "project.update")
+
+	var input models.UpdateProject
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	project, ok := r.findProject(c)
+	if !ok {
+		return
+	}
+
+	if input.Name != nil {
+		project.Name = *input.Name
+	}
+	if input.Description != nil {
+		if strings.TrimSpace(*input.Description) == "" {
+			project.Description = nil
+		} else {
+			project.Description = input.Description
+		}
+	}
+
+	query := "update projects set name = $1, description = $2, updated_at = now() where id = $3 returning *"
+	if err := r.DB.GetContext(r.Ctx, project, query, project.Name, project.Description, project.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"project": project},
+	})
+}
+
+// Delete removes a project and its environments, which must hold no
+// applications or databases.
+func (r *projectRepository) Delete(c *gin.Context) {
+	audit.Action(c, "project.delete")
+
+	project, ok := r.findProject(c)
+	if !ok {
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from projects where id = $1", project.ID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Delete the project's resources first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// findProject loads the :projectID project. On failure it has already
+// written the response.
+func (r *projectRepository) findProject(c *gin.Context) (*models.Project, bool) {
+	projectID := c.Param("projectID")
+	audit.Target(c, "project", projectID)
+
+	var project models.Project
+	if err := r.DB.GetContext(r.Ctx, &project, "select * from projects where id = $1", projectID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &project, true
+}
@@ -0,0 +1,140 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/redis/go-redis/v9"
+)
+
+type ProxyRepository interface {
+	Find(c *gin.Context)
+	Configure(c *gin.Context)
+	Apply(c *gin.Context)
+}
+
+type proxyRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewProxyRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *proxyRepository {
+	return &proxyRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *proxyRepository) Find(c *gin.Context) {
+	var proxy models.ServerProxy
+	if err := r.DB.GetContext(r.Ctx, &proxy, "select * from server_proxies where server_id = $1", c.Param("serverID")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"proxy": proxy},
+	})
+}
+
+// Configure sets the server's proxy and queues installing it. Switching
+// between Traefik and Caddy replaces the running proxy.
+func (r *proxyRepository) Configure(c *gin.Context) {
+	serverID := c.Param("serverID")
+	audit.Action(c, "server.proxy.configure")
+	audit.Target(c, "server", serverID)
+
+	var input models.ConfigureProxy
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	directoryURL := input.ACMEDirectoryURL
+	if directoryURL == "" {
+		directoryURL = models.DefaultACMEDirectoryURL
+	}
+
+	var email, caCertificate *string
+	if input.ACMEEmail != "" {
+		email = &input.ACMEEmail
+	}
+	if strings.TrimSpace(input.ACMECACertificate) != "" {
+		if !strings.Contains(input.ACMECACertificate, "-----BEGIN CERTIFICATE-----") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "acmeCaCertificate must be PEM encoded"})
+			return
+		}
+		caCertificate = &input.ACMECACertificate
+	}
+
+	var proxy models.ServerProxy
+	query := `
+		insert into server_proxies (server_id, type, acme_email, acme_directory_url, acme_ca_certificate)
+		values ($1, $2, $3, $4, $5)
+		on conflict (server_id) do update
+		set
+			type = excluded.type, acme_email = excluded.acme_email, acme_directory_url = excluded.acme_directory_url,
+			acme_ca_certificate = excluded.acme_ca_certificate, status = 'pending', error = null, updated_at = now()
+		returning *
+	`
+	if err := r.DB.GetContext(r.Ctx, &proxy, query, serverID, input.Type, email, directoryURL, caCertificate); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := workers.QueueProxy(r.RedisClient, r.Ctx, serverID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"proxy": proxy},
+	})
+}
+
+// Apply queues rewriting the proxy's config, for example after a failure.
+func (r *proxyRepository) Apply(c *gin.Context) {
+	serverID := c.Param("serverID")
+	audit.Action(c, "server.proxy.apply")
+	audit.Target(c, "server", serverID)
+
+	result, err := r.DB.ExecContext(r.Ctx, "update server_proxies set status = 'pending', updated_at = now() where server_id = $1", serverID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+		return
+	}
+
+	if err := workers.QueueProxy(r.RedisClient, r.Ctx, serverID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "OK"})
+}
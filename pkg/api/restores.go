@@ -0,0 +1,413 @@
+package api
+
+import (
+	"bufio"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/databases"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/redis/go-redis/v9"
+)
+
+type RestoreRepository interface {
+	Create(c *gin.Context)
+	Upload(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	FindLogs(c *gin.Context)
+}
+
+type restoreRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewRestoreRepository(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *restoreRepository {
+	return &restoreRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+// Create queues restoring a backup into the database, or into a new sibling
+// of it.
+func (r *restoreRepository) Create(c *gin.Context) {
+	audit.Action(c, "database.restore.create")
+
+	var input models.CreateRestore
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	database, ok := r.findDatabase(c)
+	if !ok {
+		return
+	}
+
+	var backup models.Backup
+	if err := r.DB.GetContext(r.Ctx, &backup, "select * from backups where id = $1", input.BackupID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Backup not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if backup.Status != models.BackupSucceeded {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only successful backups can be restored"})
+		return
+	}
+	if backup.DatabaseType != database.Type {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The backup is of a " + backup.DatabaseType + " database"})
+		return
+	}
+
+	if !r.restorable(c, database, input.StopApplications, input.Sibling) {
+		return
+	}
+
+	r.queue(c, database, backup.ID, input.StopApplications, input.Sibling)
+}
+
+// Upload stores an uploaded dump in a destination as a backup and queues
+// restoring it. SQL dumps and Redis snapshots may be gzip-compressed or not;
+// MongoDB dumps are archives made with mongodump --archive --gzip.
+func (r *restoreRepository) Upload(c *gin.Context) {
+	audit.Action(c, "database.restore.upload")
+
+	var input models.UploadRestore
+	if err := c.ShouldBind(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	header, err := c.FormFile("dump")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A dump file is required"})
+		return
+	}
+
+	database, ok := r.findDatabase(c)
+	if !ok {
+		return
+	}
+	if !r.restorable(c, database, input.StopApplications, input.Sibling) {
+		return
+	}
+
+	destination, client, err := storage.Load(r.Ctx, r.DB, r.Secrets, input.DestinationID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Storage destination not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	file, err := header.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer file.Close()
+
+	buffered := bufio.NewReader(file)
+	dump := io.Reader(buffered)
+	if database.Type != models.DatabaseTypeMongoDB {
+		dump = compressed(buffered)
+	}
+	if closer, ok := dump.(io.Closer); ok {
+		// Stops the compressor if the upload gives up.
+		defer closer.Close()
+	}
+
+	key := storage.ObjectKey(destination, "databases", database.ID, fmt.Sprintf(
+		"%s-%s-upload.%s",
+		time.Now().UTC().Format("20060102T150405Z"),
+		shared.GenerateRandomString(8),
+		databases.DumpExtension(database.Type),
+	))
+
+	hash := sha256.New()
+	size, err := client.Upload(r.Ctx, key, io.TeeReader(dump, hash))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to store the dump: " + err.Error()})
+		return
+	}
+	checksum := hex.EncodeToString(hash.Sum(nil))
+
+	var backup models.Backup
+	query := `
+		insert into backups (
+			database_id, database_type, destination_id, status, object_key, uploaded, size, checksum, started_at, finished_at
+		) values (
+			$1, $2, $3, $4, $5, true, $6, $7, now(), now()
+		)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&backup,
+		query,
+		database.ID,
+		database.Type,
+		destination.ID,
+		models.BackupSucceeded,
+		key,
+		size,
+		checksum,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	r.queue(c, database, backup.ID, input.StopApplications, input.Sibling)
+}
+
+// FindAll lists the database's restores, newest first, including those into
+// its siblings.
+func (r *restoreRepository) FindAll(c *gin.Context) {
+	databaseID := c.Param("databaseID")
+
+	var restores []models.Restore = []models.Restore{}
+	query := `
+		select r.* from restores r
+		left join backups b on b.id = r.backup_id
+		where r.database_id = $1 or (r.sibling and b.database_id = $1)
+		order by r.created_at desc
+		limit 50
+	`
+	if err := r.DB.SelectContext(r.Ctx, &restores, query, databaseID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"restores": restores},
+	})
+}
+
+func (r *restoreRepository) FindByID(c *gin.Context) {
+	restore, ok := r.findRestore(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"restore": restore},
+	})
+}
+
+// FindLogs returns the restore's log lines. Pass after=<id> to only get lines
+// written since the last one the client has seen.
+func (r *restoreRepository) FindLogs(c *gin.Context) {
+	restore, ok := r.findRestore(c)
+	if !ok {
+		return
+	}
+
+	after, err := strconv.ParseInt(c.DefaultQuery("after", "0"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid after"})
+		return
+	}
+
+	var logs []models.RestoreLog = []models.RestoreLog{}
+	query := `
+		select * from restore_logs
+		where restore_id = $1 and id > $2
+		order by id
+		limit 1000
+	`
+	if err := r.DB.SelectContext(r.Ctx, &logs, query, restore.ID, after); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data": gin.H{
+			"status": restore.Status,
+			"logs":   logs,
+		},
+	})
+}
+
+// queue records the restore and hands it to the restore worker. Restoring in
+// place needs the database running and marks it restoring; a sibling is
+// created first and started by the worker.
+func (r *restoreRepository) queue(c *gin.Context, database *models.Database, backupID string, stopApplications, sibling bool) {
+	target := database
+	if sibling {
+		var ok bool
+		if target, ok = r.createSibling(c, database); !ok {
+			return
+		}
+	} else {
+		query := `
+			update databases set status = $1, error = null, updated_at = now()
+			where id = $2 and status = $3
+			returning *
+		`
+		if err := r.DB.GetContext(r.Ctx, target, query, models.DatabaseRestoring, database.ID, models.DatabaseRunning); err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				c.JSON(http.StatusConflict, gin.H{"error": "The database is " + database.Status + ". Start it to restore into it."})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+	}
+
+	var restore models.Restore
+	query := `
+		insert into restores (backup_id, database_id, sibling, stop_applications, status)
+		values ($1, $2, $3, $4, $5)
+		returning *
+	`
+	if err := r.DB.GetContext(r.Ctx, &restore, query, backupID, target.ID, sibling, stopApplications, models.BackupPending); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := workers.QueueRestore(r.RedisClient, r.Ctx, restore.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"restore": restore, "database": target},
+	})
+}
+
+// restorable checks the restore's options against the database before
+// anything is stored. Otherwise it has already written the response.
+func (r *restoreRepository) restorable(c *gin.Context, database *models.Database, stopApplications, sibling bool) bool {
+	if sibling && stopApplications {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Restoring into a sibling doesn't stop applications"})
+		return false
+	}
+	if !sibling && database.Status != models.DatabaseRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "The database is " + database.Status + ". Start it to restore into it."})
+		return false
+	}
+	return true
+}
+
+// createSibling records a new database like database, on the same server and
+// in the same environment, with its own credentials and no public port. On
+// failure it has already written the response.
+func (r *restoreRepository) createSibling(c *gin.Context, database *models.Database) (*models.Database, bool) {
+	username, encrypted, ok := newCredentials(c, r.Secrets, database.Type)
+	if !ok {
+		return nil, false
+	}
+
+	var sibling models.Database
+	query := `
+		insert into databases (
+			name, type, version, server_id, environment_id, username, password, database_name, status
+		) values (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9
+		)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&sibling,
+		query,
+		database.Name+"-restore-"+time.Now().UTC().Format("20060102150405"),
+		database.Type,
+		database.Version,
+		database.ServerID,
+		database.EnvironmentID,
+		username,
+		encrypted,
+		database.DatabaseName,
+		models.DatabaseStarting,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &sibling, true
+}
+
+// findDatabase loads the :databaseID database. On failure it has already
+// written the response.
+func (r *restoreRepository) findDatabase(c *gin.Context) (*models.Database, bool) {
+	databaseID := c.Param("databaseID")
+	audit.Target(c, "database", databaseID)
+
+	var database models.Database
+	if err := r.DB.GetContext(r.Ctx, &database, "select * from databases where id = $1", databaseID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &database, true
+}
+
+// findRestore loads the :restoreID restore. On failure it has already written
+// the response.
+func (r *restoreRepository) findRestore(c *gin.Context) (*models.Restore, bool) {
+	var restore models.Restore
+	if err := r.DB.GetContext(r.Ctx, &restore, "select * from restores where id = $1", c.Param("restoreID")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &restore, true
+}
+
+// compressed returns r gzip-compressed, unless it already is.
+func compressed(r *bufio.Reader) io.Reader {
+	if magic, err := r.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		return r
+	}
+
+	reader, writer := io.Pipe()
+	go func() {
+		gz := gzip.NewWriter(writer)
+		_, err := io.Copy(gz, r)
+		if err == nil {
+			err = gz.Close()
+		}
+		writer.CloseWithError(err)
+	}()
+	return reader
+}
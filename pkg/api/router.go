@@ -2,46 +2,231 @@ package api
 
 import (
 	"context"
+	"log"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	"github.com/mohit4bug/mo-sh/internal/middlewares"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/config"
+	"github.com/mohit4bug/mo-sh/pkg/ratelimit"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
 	"github.com/redis/go-redis/v9"
 )
 
-func NewRouter(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *gin.Engine {
-	userRepository := NewUserRepository(db, redisClient, ctx)
+func NewRouter(db *sqlx.DB, redisClient *redis.Client, cfg *config.Config, settingsStore settings.Store, secretCipher *secrets.Cipher, ctx context.Context) *gin.Engine {
+	userRepository := NewUserRepository(db, redisClient, cfg, settingsStore, ctx)
+	twoFactorRepository := NewTwoFactorRepository(db, redisClient, ctx)
+	ssoRepository := NewSSORepository(db, redisClient, cfg, settingsStore, ctx)
+	teamRepository := NewTeamRepository(db, redisClient, ctx)
 	keyRepository := NewKeyRepository(db, redisClient, ctx)
 	serverRepository := NewServerRepository(db, redisClient, ctx)
-	sourceRepository := NewSourceRepository(db, redisClient, ctx)
-	webhookRepository := NewWebhookRepository(db, redisClient, ctx)
+	sourceRepository := NewSourceRepository(db, redisClient, settingsStore, ctx)
+	webhookRepository := NewWebhookRepository(db, redisClient, settingsStore, ctx)
+	auditLogRepository := NewAuditLogRepository(db, redisClient, ctx)
+	settingRepository := NewSettingRepository(db, redisClient, settingsStore, ctx)
+	applicationRepository := NewApplicationRepository(db, redisClient, ctx)
+	deploymentRepository := NewDeploymentRepository(db, redisClient, ctx)
+	proxyRepository := NewProxyRepository(db, redisClient, ctx)
+	domainRepository := NewDomainRepository(db, redisClient, ctx)
+	variableRepository := NewVariableRepository(db, redisClient, secretCipher, ctx)
+	projectRepository := NewProjectRepository(db, redisClient, ctx)
+	environmentRepository := NewEnvironmentRepository(db, redisClient, ctx)
+	databaseRepository := NewDatabaseRepository(db, redisClient, secretCipher, ctx)
+	storageDestinationRepository := NewStorageDestinationRepository(db, redisClient, secretCipher, ctx)
+	backupRepository := NewBackupRepository(db, redisClient, secretCipher, ctx)
+	restoreRepository := NewRestoreRepository(db, redisClient, secretCipher, ctx)
+	volumeRepository := NewVolumeRepository(db, redisClient, ctx)
+	volumeSnapshotRepository := NewVolumeSnapshotRepository(db, redisClient, secretCipher, ctx)
+
+	auth := middlewares.Auth(redisClient, ctx)
+	twoFactor := middlewares.TwoFactor(db, ctx)
+	admin := middlewares.Admin(db, ctx)
+
+	limiter := ratelimit.NewLimiter(redisClient, ctx)
+	authRateLimit := middlewares.RateLimit(limiter, "auth", cfg.RateLimit.AuthIPLimit, cfg.RateLimit.AuthIPWindow, middlewares.ByIP)
 
 	r := gin.Default()
-	r.Use(middlewares.Cors())
+	if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatal(err)
+	}
+	r.Use(middlewares.Cors(settingsStore))
+
+	auditor := middlewares.Audit(audit.NewRecorder(db, ctx))
+
+	// Webhook deliveries come from a few provider addresses that would share
+	// one per-IP bucket, and GitHub doesn't redeliver rejected ones. Their
+	// signatures are checked instead.
+	hooks := r.Group("/api/v1")
+	hooks.Use(auditor)
+	{
+		hooks.POST("/webhooks/github/:sourceID", webhookRepository.HandleGithubEvent)
+		hooks.POST("/webhooks/gitlab/:sourceID", webhookRepository.HandleGitlabEvent)
+		hooks.POST("/webhooks/gitea/:sourceID", webhookRepository.HandleGiteaEvent)
+	}
 
 	v1 := r.Group("/api/v1")
+	v1.Use(auditor)
+	v1.Use(middlewares.RateLimit(limiter, "api", cfg.RateLimit.UserLimit, cfg.RateLimit.UserWindow, middlewares.BySessionOrIP(redisClient, ctx)))
 	{
-		v1.POST("/login", userRepository.Login)
-		v1.POST("/register", userRepository.Register)
+		v1.POST("/login", authRateLimit, userRepository.Login)
+		v1.POST("/login/2fa", authRateLimit, userRepository.LoginTwoFactor)
+		v1.POST("/register", authRateLimit, userRepository.Register)
+		v1.POST("/verify-email", authRateLimit, userRepository.VerifyEmail)
+		v1.POST("/verify-email/resend", authRateLimit, auth, userRepository.ResendEmailVerification)
+		v1.POST("/password/forgot", authRateLimit, userRepository.ForgotPassword)
+		v1.POST("/password/reset", authRateLimit, userRepository.ResetPassword)
 
-		v1.POST("/keys", middlewares.Auth(redisClient, ctx), keyRepository.Create)
-		v1.GET("/keys", middlewares.Auth(redisClient, ctx), keyRepository.FindAll)
-		v1.GET("/keys/:keyID", middlewares.Auth(redisClient, ctx), keyRepository.FindByID)
-		v1.POST("/keys/generate", middlewares.Auth(redisClient, ctx), keyRepository.GenerateKey)
+		v1.GET("/auth/oidc/login", authRateLimit, ssoRepository.OIDCLogin)
+		v1.GET("/auth/oidc/callback", authRateLimit, ssoRepository.OIDCCallback)
+		v1.GET("/auth/github/:sourceID/login", authRateLimit, ssoRepository.GithubLogin)
+		v1.GET("/auth/github/callback", authRateLimit, ssoRepository.GithubCallback)
 
-		v1.POST("/servers", middlewares.Auth(redisClient, ctx), serverRepository.Create)
-		v1.GET("/servers", middlewares.Auth(redisClient, ctx), serverRepository.FindAll)
-		v1.GET("/servers/:serverID", middlewares.Auth(redisClient, ctx), serverRepository.FindByID)
-		v1.GET("/servers/:serverID/queue-docker-install", middlewares.Auth(redisClient, ctx), serverRepository.QueueDockerInstall)
-		v1.GET("/servers/:serverID/pending-logs", middlewares.Auth(redisClient, ctx), serverRepository.GetPendingLogs)
+		// Enrollment routes skip the TwoFactor middleware so that users blocked by a
+		// team policy can still turn it on.
+		v1.POST("/2fa/enroll", auth, twoFactorRepository.Enroll)
+		v1.POST("/2fa/confirm", auth, twoFactorRepository.Confirm)
+		v1.POST("/2fa/disable", auth, twoFactor, twoFactorRepository.Disable)
+		v1.POST("/2fa/recovery-codes", auth, twoFactor, twoFactorRepository.RegenerateRecoveryCodes)
 
-		v1.POST("/sources", middlewares.Auth(redisClient, ctx), sourceRepository.Create)
-		v1.GET("/sources", middlewares.Auth(redisClient, ctx), sourceRepository.FindAll)
-		v1.GET("/sources/:sourceID", middlewares.Auth(redisClient, ctx), sourceRepository.FindByID)
-		v1.GET("/sources/:sourceID/register-github-app", middlewares.Auth(redisClient, ctx), sourceRepository.RegisterGithubApp)
+		v1.POST("/teams", auth, twoFactor, teamRepository.Create)
+		v1.GET("/teams", auth, twoFactor, teamRepository.FindAll)
+		v1.GET("/teams/:teamID", auth, twoFactor, teamRepository.FindByID)
+		v1.POST("/teams/:teamID/members", auth, twoFactor, teamRepository.AddMember)
+		v1.PUT("/teams/:teamID/security", auth, twoFactor, teamRepository.UpdateSecurity)
+		v1.GET("/team-invitations", auth, twoFactor, teamRepository.FindInvitations)
+		v1.POST("/team-invitations/:teamID/accept", auth, twoFactor, teamRepository.AcceptInvitation)
+		v1.DELETE("/team-invitations/:teamID", auth, twoFactor, teamRepository.DeclineInvitation)
 
-		v1.GET("/webhooks/github/redirect", webhookRepository.HandleGithubRedirect)
+		v1.GET("/audit-logs", auth, twoFactor, admin, auditLogRepository.FindAll)
+		v1.GET("/audit-logs/export", auth, twoFactor, admin, auditLogRepository.Export)
+
+		v1.GET("/settings", auth, twoFactor, admin, settingRepository.Find)
+		v1.PUT("/settings", auth, twoFactor, admin, settingRepository.Update)
+
+		v1.POST("/keys", auth, twoFactor, keyRepository.Create)
+		v1.GET("/keys", auth, twoFactor, keyRepository.FindAll)
+		v1.GET("/keys/:keyID", auth, twoFactor, keyRepository.FindByID)
+		v1.POST("/keys/generate", auth, twoFactor, keyRepository.GenerateKey)
+
+		v1.POST("/servers", auth, twoFactor, serverRepository.Create)
+		v1.GET("/servers", auth, twoFactor, serverRepository.FindAll)
+		v1.GET("/servers/:serverID", auth, twoFactor, serverRepository.FindByID)
+		v1.GET("/servers/:serverID/queue-docker-install", auth, twoFactor, serverRepository.QueueDockerInstall)
+		v1.GET("/servers/:serverID/pending-logs", auth, twoFactor, serverRepository.GetPendingLogs)
+		v1.GET("/servers/:serverID/proxy", auth, twoFactor, proxyRepository.Find)
+		v1.PUT("/servers/:serverID/proxy", auth, twoFactor, proxyRepository.Configure)
+		v1.POST("/servers/:serverID/proxy/apply", auth, twoFactor, proxyRepository.Apply)
 
+		v1.POST("/sources", auth, twoFactor, sourceRepository.Create)
+		v1.GET("/sources", auth, twoFactor, sourceRepository.FindAll)
+		v1.GET("/sources/:sourceID", auth, twoFactor, sourceRepository.FindByID)
+		v1.PATCH("/sources/:sourceID", auth, twoFactor, sourceRepository.Update)
+		v1.GET("/sources/:sourceID/register-github-app", auth, twoFactor, sourceRepository.RegisterGithubApp)
+		v1.PUT("/sources/:sourceID/gitlab", auth, twoFactor, sourceRepository.ConnectGitlab)
+		v1.GET("/sources/:sourceID/gitlab/authorize", auth, twoFactor, sourceRepository.AuthorizeGitlab)
+		v1.PUT("/sources/:sourceID/gitea", auth, twoFactor, sourceRepository.ConnectGitea)
+		v1.PUT("/sources/:sourceID/git", auth, twoFactor, sourceRepository.ConnectGit)
+		v1.POST("/sources/:sourceID/git/check", auth, twoFactor, sourceRepository.CheckGitAccess)
+		v1.GET("/sources/:sourceID/installations", auth, twoFactor, sourceRepository.FindInstallations)
+		v1.GET("/sources/:sourceID/repositories", auth, twoFactor, sourceRepository.FindRepositories)
+		v1.GET("/sources/:sourceID/repositories/:repositoryID/branches", auth, twoFactor, sourceRepository.FindBranches)
+		v1.GET("/sources/:sourceID/repositories/:repositoryID/commits", auth, twoFactor, sourceRepository.FindCommits)
+		v1.POST("/sources/:sourceID/repositories/:repositoryID/webhook", auth, twoFactor, sourceRepository.RegisterWebhook)
+
+		v1.POST("/projects", auth, twoFactor, projectRepository.Create)
+		v1.GET("/projects", auth, twoFactor, projectRepository.FindAll)
+		v1.GET("/projects/:projectID", auth, twoFactor, projectRepository.FindByID)
+		v1.PATCH("/projects/:projectID", auth, twoFactor, projectRepository.Update)
+		v1.DELETE("/projects/:projectID", auth, twoFactor, projectRepository.Delete)
+		v1.POST("/projects/:projectID/environments", auth, twoFactor, environmentRepository.Create)
+		v1.GET("/projects/:projectID/environments", auth, twoFactor, environmentRepository.FindAll)
+
+		v1.GET("/environments/:environmentID", auth, twoFactor, environmentRepository.FindByID)
+		v1.PATCH("/environments/:environmentID", auth, twoFactor, environmentRepository.Update)
+		v1.DELETE("/environments/:environmentID", auth, twoFactor, environmentRepository.Delete)
+		v1.POST("/environments/:environmentID/clone", auth, twoFactor, environmentRepository.Clone)
+		v1.GET("/environments/:environmentID/variables", auth, twoFactor, variableRepository.FindAll)
+		v1.POST("/environments/:environmentID/variables", auth, twoFactor, variableRepository.Create)
+		v1.PATCH("/environments/:environmentID/variables/:variableID", auth, twoFactor, variableRepository.Update)
+		v1.DELETE("/environments/:environmentID/variables/:variableID", auth, twoFactor, variableRepository.Delete)
+		v1.POST("/environments/:environmentID/variables/import", auth, twoFactor, variableRepository.Import)
+		v1.GET("/environments/:environmentID/variables/export", auth, twoFactor, variableRepository.Export)
+
+		v1.POST("/applications", auth, twoFactor, applicationRepository.Create)
+		v1.GET("/applications", auth, twoFactor, applicationRepository.FindAll)
+		v1.GET("/applications/:applicationID", auth, twoFactor, applicationRepository.FindByID)
+		v1.PATCH("/applications/:applicationID", auth, twoFactor, applicationRepository.Update)
+		v1.DELETE("/applications/:applicationID", auth, twoFactor, applicationRepository.Delete)
+		v1.POST("/applications/:applicationID/deploy", auth, twoFactor, applicationRepository.Deploy)
+		v1.GET("/applications/:applicationID/deployments", auth, twoFactor, deploymentRepository.FindAll)
+		v1.GET("/applications/:applicationID/previews", auth, twoFactor, applicationRepository.FindPreviews)
+		v1.GET("/applications/:applicationID/domains", auth, twoFactor, domainRepository.FindAll)
+		v1.POST("/applications/:applicationID/domains", auth, twoFactor, domainRepository.Create)
+		v1.DELETE("/applications/:applicationID/domains/:domainID", auth, twoFactor, domainRepository.Delete)
+		v1.GET("/applications/:applicationID/variables", auth, twoFactor, variableRepository.FindAll)
+		v1.POST("/applications/:applicationID/variables", auth, twoFactor, variableRepository.Create)
+		v1.PATCH("/applications/:applicationID/variables/:variableID", auth, twoFactor, variableRepository.Update)
+		v1.DELETE("/applications/:applicationID/variables/:variableID", auth, twoFactor, variableRepository.Delete)
+		v1.POST("/applications/:applicationID/variables/import", auth, twoFactor, variableRepository.Import)
+		v1.GET("/applications/:applicationID/variables/export", auth, twoFactor, variableRepository.Export)
+		v1.GET("/applications/:applicationID/volumes", auth, twoFactor, volumeRepository.FindAll)
+		v1.POST("/applications/:applicationID/volumes", auth, twoFactor, volumeRepository.Create)
+		v1.GET("/applications/:applicationID/volumes/usage", auth, twoFactor, volumeRepository.Usage)
+		v1.PATCH("/applications/:applicationID/volumes/:volumeID", auth, twoFactor, volumeRepository.Update)
+		v1.DELETE("/applications/:applicationID/volumes/:volumeID", auth, twoFactor, volumeRepository.Delete)
+		v1.GET("/applications/:applicationID/volumes/:volumeID/snapshot-schedules", auth, twoFactor, volumeSnapshotRepository.FindSchedules)
+		v1.POST("/applications/:applicationID/volumes/:volumeID/snapshot-schedules", auth, twoFactor, volumeSnapshotRepository.CreateSchedule)
+		v1.PATCH("/applications/:applicationID/volumes/:volumeID/snapshot-schedules/:scheduleID", auth, twoFactor, volumeSnapshotRepository.UpdateSchedule)
+		v1.DELETE("/applications/:applicationID/volumes/:volumeID/snapshot-schedules/:scheduleID", auth, twoFactor, volumeSnapshotRepository.DeleteSchedule)
+		v1.GET("/applications/:applicationID/volumes/:volumeID/snapshots", auth, twoFactor, volumeSnapshotRepository.FindAll)
+		v1.POST("/applications/:applicationID/volumes/:volumeID/snapshots", auth, twoFactor, volumeSnapshotRepository.Create)
+
+		v1.POST("/databases", auth, twoFactor, databaseRepository.Create)
+		v1.GET("/databases", auth, twoFactor, databaseRepository.FindAll)
+		v1.GET("/databases/:databaseID", auth, twoFactor, databaseRepository.FindByID)
+		v1.GET("/databases/:databaseID/credentials", auth, twoFactor, databaseRepository.Credentials)
+		v1.PATCH("/databases/:databaseID", auth, twoFactor, databaseRepository.Update)
+		v1.DELETE("/databases/:databaseID", auth, twoFactor, databaseRepository.Delete)
+		v1.POST("/databases/:databaseID/start", auth, twoFactor, databaseRepository.Start)
+		v1.POST("/databases/:databaseID/stop", auth, twoFactor, databaseRepository.Stop)
+		v1.POST("/databases/:databaseID/restart", auth, twoFactor, databaseRepository.Restart)
+		v1.GET("/databases/:databaseID/backup-schedules", auth, twoFactor, backupRepository.FindSchedules)
+		v1.POST("/databases/:databaseID/backup-schedules", auth, twoFactor, backupRepository.CreateSchedule)
+		v1.PATCH("/databases/:databaseID/backup-schedules/:scheduleID", auth, twoFactor, backupRepository.UpdateSchedule)
+		v1.DELETE("/databases/:databaseID/backup-schedules/:scheduleID", auth, twoFactor, backupRepository.DeleteSchedule)
+		v1.GET("/databases/:databaseID/backups", auth, twoFactor, backupRepository.FindAll)
+		v1.POST("/databases/:databaseID/backups", auth, twoFactor, backupRepository.Create)
+		v1.GET("/databases/:databaseID/restores", auth, twoFactor, restoreRepository.FindAll)
+		v1.POST("/databases/:databaseID/restores", auth, twoFactor, restoreRepository.Create)
+		v1.POST("/databases/:databaseID/restores/upload", auth, twoFactor, restoreRepository.Upload)
+
+		v1.GET("/backups", auth, twoFactor, backupRepository.FindAll)
+		v1.GET("/backups/:backupID", auth, twoFactor, backupRepository.FindByID)
+		v1.DELETE("/backups/:backupID", auth, twoFactor, backupRepository.Delete)
+
+		v1.GET("/restores/:restoreID", auth, twoFactor, restoreRepository.FindByID)
+		v1.GET("/restores/:restoreID/logs", auth, twoFactor, restoreRepository.FindLogs)
+
+		v1.GET("/volume-snapshots/:snapshotID", auth, twoFactor, volumeSnapshotRepository.FindByID)
+		v1.DELETE("/volume-snapshots/:snapshotID", auth, twoFactor, volumeSnapshotRepository.Delete)
+
+		v1.POST("/storage-destinations", auth, twoFactor, storageDestinationRepository.Create)
+		v1.GET("/storage-destinations", auth, twoFactor, storageDestinationRepository.FindAll)
+		v1.GET("/storage-destinations/:destinationID", auth, twoFactor, storageDestinationRepository.FindByID)
+		v1.PATCH("/storage-destinations/:destinationID", auth, twoFactor, storageDestinationRepository.Update)
+		v1.DELETE("/storage-destinations/:destinationID", auth, twoFactor, storageDestinationRepository.Delete)
+		v1.POST("/storage-destinations/:destinationID/test", auth, twoFactor, storageDestinationRepository.Test)
+
+		v1.GET("/deployments/:deploymentID", auth, twoFactor, deploymentRepository.FindByID)
+		v1.GET("/deployments/:deploymentID/logs", auth, twoFactor, deploymentRepository.FindLogs)
+		v1.GET("/deployments/:deploymentID/logs/stream", auth, twoFactor, deploymentRepository.StreamLogs)
+		v1.POST("/deployments/:deploymentID/rollback", auth, twoFactor, deploymentRepository.Rollback)
+
+		v1.GET("/webhooks/github/redirect", webhookRepository.HandleGithubRedirect)
+		v1.GET("/webhooks/github/:sourceID/setup", webhookRepository.HandleGithubSetup)
+		v1.GET("/webhooks/gitlab/redirect", webhookRepository.HandleGitlabRedirect)
 	}
 
 	return r
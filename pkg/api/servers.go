@@ -12,6 +12,7 @@ import (
 	"github.com/mohit4bug/mo-sh/internal/models"
 	"github.com/mohit4bug/mo-sh/internal/shared"
 	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
 	"github.com/mohit4bug/mo-sh/pkg/ssh"
 	"github.com/redis/go-redis/v9"
 )
@@ -38,6 +39,8 @@ func NewServerRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Con
 }
 
 func (r *serverRepository) Create(c *gin.Context) {
+	audit.Action(c, "server.create")
+
 	var input models.CreateServer
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -96,6 +99,8 @@ func (r *serverRepository) FindByID(c *gin.Context) {
 
 func (r *serverRepository) QueueDockerInstall(c *gin.Context) {
 	serverID := c.Param("serverID")
+	audit.Action(c, "server.docker_install.queue")
+	audit.Target(c, "server", serverID)
 
 	type ServerWithKey struct {
 		models.Server
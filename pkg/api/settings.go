@@ -0,0 +1,101 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
+	"github.com/redis/go-redis/v9"
+)
+
+type SettingRepository interface {
+	Find(c *gin.Context)
+	Update(c *gin.Context)
+}
+
+type settingRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Settings    settings.Store
+	Ctx         context.Context
+}
+
+func NewSettingRepository(db *sqlx.DB, redisClient *redis.Client, settingsStore settings.Store, ctx context.Context) *settingRepository {
+	return &settingRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Settings:    settingsStore,
+		Ctx:         ctx,
+	}
+}
+
+func (r *settingRepository) Find(c *gin.Context) {
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"settings": instanceSettings},
+	})
+}
+
+func (r *settingRepository) Update(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+	audit.Action(c, "settings.update")
+
+	var input settings.Update
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	for name, value := range map[string]*string{"apiUrl": input.APIURL, "uiUrl": input.UIURL} {
+		if value == nil {
+			continue
+		}
+
+		*value = strings.TrimSuffix(strings.TrimSpace(*value), "/")
+		if !isBaseURL(*value) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": name + " must be an absolute http(s) URL"})
+			return
+		}
+	}
+
+	if input.GithubAppEvents != nil {
+		for _, event := range *input.GithubAppEvents {
+			if strings.TrimSpace(event) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "githubAppEvents can't contain empty events"})
+				return
+			}
+		}
+	}
+
+	instanceSettings, err := r.Settings.Update(&input, currentSession.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"settings": instanceSettings},
+	})
+}
+
+func isBaseURL(value string) bool {
+	parsed, err := url.Parse(value)
+	if err != nil {
+		return false
+	}
+	return (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != "" && parsed.RawQuery == ""
+}
@@ -4,54 +4,111 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"html/template"
+	"io"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
 	"github.com/mohit4bug/mo-sh/internal/models"
 	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/sources"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/git"
+	"github.com/mohit4bug/mo-sh/pkg/gitea"
+	"github.com/mohit4bug/mo-sh/pkg/github"
+	"github.com/mohit4bug/mo-sh/pkg/gitlab"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
+	"github.com/mohit4bug/mo-sh/pkg/ssh"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	defaultCommitsLimit = 20
+
+	gitlabOAuthStatePrefix  = "gitlab_oauth_state:"
+	gitlabOAuthStateTimeout = 10 * time.Minute
+
+	defaultGitPollInterval = 300
+	minGitPollInterval     = 60
+)
+
 type SourceRepository interface {
 	Create(c *gin.Context)
 	FindAll(c *gin.Context)
 	FindByID(c *gin.Context)
+	Update(c *gin.Context)
 	RegisterGithubApp(c *gin.Context)
+	ConnectGitlab(c *gin.Context)
+	AuthorizeGitlab(c *gin.Context)
+	ConnectGitea(c *gin.Context)
+	ConnectGit(c *gin.Context)
+	CheckGitAccess(c *gin.Context)
+	FindInstallations(c *gin.Context)
+	FindRepositories(c *gin.Context)
+	FindBranches(c *gin.Context)
+	FindCommits(c *gin.Context)
+	RegisterWebhook(c *gin.Context)
 }
 
 type sourceRepository struct {
 	DB          *sqlx.DB
 	RedisClient *redis.Client
+	Settings    settings.Store
 	Ctx         context.Context
 }
 
-func NewSourceRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *sourceRepository {
+func NewSourceRepository(db *sqlx.DB, redisClient *redis.Client, settingsStore settings.Store, ctx context.Context) *sourceRepository {
 	return &sourceRepository{
 		DB:          db,
 		RedisClient: redisClient,
+		Settings:    settingsStore,
 		Ctx:         ctx,
 	}
 }
 
 func (r *sourceRepository) Create(c *gin.Context) {
+	audit.Action(c, "source.create")
+
 	var input models.CreateSource
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	htmlURL, apiURL, err := normalizeSourceURLs(input.Type, input.HTMLURL, input.APIURL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var organization *string
+	if input.Organization != "" {
+		if !github.ValidOrganization(input.Organization) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization"})
+			return
+		}
+		organization = &input.Organization
+	}
+
 	newSource := &models.Source{
-		Name: input.Name,
-		Type: input.Type,
+		Name:         input.Name,
+		Type:         input.Type,
+		HTMLURL:      htmlURL,
+		APIURL:       apiURL,
+		Organization: organization,
 	}
 
-	_, err := r.DB.ExecContext(
+	_, err = r.DB.ExecContext(
 		r.Ctx,
-		"insert into sources (name, type) values ($1, $2)",
-		newSource.Name, newSource.Type,
+		"insert into sources (name, type, html_url, api_url, organization) values ($1, $2, $3, $4, $5)",
+		newSource.Name, newSource.Type, newSource.HTMLURL, newSource.APIURL, newSource.Organization,
 	)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -100,11 +157,97 @@ func (r *sourceRepository) FindByID(c *gin.Context) {
 	})
 }
 
+// Update changes a source's name and, until its GitHub App is registered,
+// which GitHub instance and account the app will be created on.
+func (r *sourceRepository) Update(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "source.update")
+
+	var input models.UpdateSource
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var source models.Source
+	if err := r.DB.GetContext(r.Ctx, &source, "select * from sources where id = $1", sourceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if input.Name != nil {
+		if strings.TrimSpace(*input.Name) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Name can't be empty"})
+			return
+		}
+		source.Name = *input.Name
+	}
+
+	if input.HTMLURL != nil || input.APIURL != nil || input.Organization != nil {
+		// The app's credentials only work against the instance and account it
+		// was created on.
+		if source.HasGithubApp {
+			c.JSON(http.StatusConflict, gin.H{"error": "The GitHub App for this source is already registered"})
+			return
+		}
+
+		htmlURL, apiURL := source.HTMLURL, ""
+		if input.HTMLURL != nil {
+			htmlURL = *input.HTMLURL
+		}
+		if input.APIURL != nil {
+			apiURL = *input.APIURL
+		} else if input.HTMLURL == nil {
+			apiURL = source.APIURL
+		}
+
+		var err error
+		source.HTMLURL, source.APIURL, err = normalizeSourceURLs(source.Type, htmlURL, apiURL)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		if input.Organization != nil {
+			source.Organization = nil
+			if *input.Organization != "" {
+				if !github.ValidOrganization(*input.Organization) {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid organization"})
+					return
+				}
+				source.Organization = input.Organization
+			}
+		}
+	}
+
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&source,
+		`update sources set name = $1, html_url = $2, api_url = $3, organization = $4, updated_at = now()
+		where id = $5 returning *`,
+		source.Name, source.HTMLURL, source.APIURL, source.Organization, sourceID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"source": source},
+	})
+}
+
 func (r *sourceRepository) RegisterGithubApp(c *gin.Context) {
 	sourceID := c.Param("sourceID")
+	audit.Action(c, "github_app.register.start")
+	audit.Target(c, "source", sourceID)
 
 	var source models.Source
-	if err := r.DB.QueryRowContext(r.Ctx, "select * from sources where id = $1", sourceID).Scan(&source.ID, &source.Name, &source.Type, &source.HasGithubApp, &source.CreatedAt, &source.UpdatedAt); err != nil {
+	if err := r.DB.GetContext(r.Ctx, &source, "select * from sources where id = $1", sourceID); err != nil {
 		if err == sql.ErrNoRows {
 			c.JSON(http.StatusNotFound, gin.H{"error": "Source not found"})
 			return
@@ -113,10 +256,20 @@ func (r *sourceRepository) RegisterGithubApp(c *gin.Context) {
 		return
 	}
 
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
 	state := shared.GenerateRandomString(32)
-	action := fmt.Sprintf("https://github.com/settings/apps/new?state=%s", state)
+	var organization string
+	if source.Organization != nil {
+		organization = *source.Organization
+	}
+	action := github.NewAppURL(source.HTMLURL, organization, state)
 
-	err := r.RedisClient.Set(r.Ctx, state, sourceID, 0).Err()
+	err = r.RedisClient.Set(r.Ctx, state, sourceID, 0).Err()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -124,13 +277,25 @@ func (r *sourceRepository) RegisterGithubApp(c *gin.Context) {
 
 	manifest := gin.H{
 		"name":         source.Name,
-		"url":          "https://example.com",
-		"redirect_url": "http://localhost:8000/api/v1/webhooks/github/redirect",
+		"url":          instanceSettings.UIURL,
+		"redirect_url": instanceSettings.APIURL + "/api/v1/webhooks/github/redirect",
+		"hook_attributes": gin.H{
+			"url":    fmt.Sprintf("%s/api/v1/webhooks/github/%s", instanceSettings.APIURL, sourceID),
+			"active": true,
+		},
+		"default_events": instanceSettings.GithubAppEvents,
+		// Lets members sign in to Mo-SH with their GitHub account.
+		"callback_urls":   []string{instanceSettings.APIURL + "/api/v1/auth/github/callback"},
+		"setup_url":       fmt.Sprintf("%s/api/v1/webhooks/github/%s/setup", instanceSettings.APIURL, sourceID),
+		"setup_on_update": true,
 		"default_permissions": gin.H{
 			"contents":       "read",
 			"metadata":       "read",
 			"emails":         "read",
 			"administration": "read",
+			// Deployments report commit statuses and comment preview URLs.
+			"statuses":      "write",
+			"pull_requests": "write",
 		},
 	}
 
@@ -154,3 +319,703 @@ func (r *sourceRepository) RegisterGithubApp(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 	}
 }
+
+// ConnectGitlab stores the credentials of a GitLab source: either an access
+// token, which is checked right away, or an OAuth application that still has
+// to be authorized through AuthorizeGitlab.
+func (r *sourceRepository) ConnectGitlab(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "gitlab.connect")
+	audit.Target(c, "source", sourceID)
+
+	var input models.ConnectGitlab
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, ok := r.findSourceOfType(c, sourceID, models.SourceTypeGitlab)
+	if !ok {
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var authType string
+	var clientID, clientSecret, redirectURL, accessToken *string
+	switch {
+	case input.AccessToken != "":
+		if _, err := gitlab.NewClient(r.Ctx, source.APIURL, input.AccessToken).CurrentUser(); err != nil {
+			var apiErr *gitlab.APIError
+			if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "GitLab rejected the access token"})
+				return
+			}
+			c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSourceUnavailable})
+			return
+		}
+		authType, accessToken = models.GitlabAuthToken, &input.AccessToken
+	case input.ClientID != "" && input.ClientSecret != "":
+		callbackURL := gitlabRedirectURL(instanceSettings)
+		authType, clientID, clientSecret, redirectURL = models.GitlabAuthOAuth, &input.ClientID, &input.ClientSecret, &callbackURL
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide an access token or an OAuth application's client ID and secret"})
+		return
+	}
+
+	// Keep the webhook secret across reconnects so registered webhooks keep
+	// working.
+	query := `
+		insert into gitlab_connections (
+			source_id, auth_type, client_id, client_secret, redirect_url, access_token, webhook_secret
+		) values (
+			$1, $2, $3, $4, $5, $6, $7
+		)
+		on conflict (source_id) do update set
+			auth_type = excluded.auth_type,
+			client_id = excluded.client_id,
+			client_secret = excluded.client_secret,
+			redirect_url = excluded.redirect_url,
+			access_token = excluded.access_token,
+			refresh_token = null,
+			token_expires_at = null,
+			updated_at = now()
+		returning *
+	`
+
+	var connection models.GitlabConnection
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&connection,
+		query,
+		sourceID, authType, clientID, clientSecret, redirectURL, accessToken, shared.GenerateRandomString(32),
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"connection": connection},
+	})
+}
+
+// AuthorizeGitlab sends the browser to GitLab to authorize the source's OAuth
+// application. GitLab redirects back to HandleGitlabRedirect.
+func (r *sourceRepository) AuthorizeGitlab(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "gitlab.authorize.start")
+	audit.Target(c, "source", sourceID)
+
+	source, ok := r.findSourceOfType(c, sourceID, models.SourceTypeGitlab)
+	if !ok {
+		return
+	}
+
+	var connection models.GitlabConnection
+	if err := r.DB.GetContext(r.Ctx, &connection, "select * from gitlab_connections where source_id = $1", sourceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusConflict, gin.H{"error": shared.ErrSourceNotConnected})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if connection.AuthType != models.GitlabAuthOAuth || connection.ClientID == nil || connection.RedirectURL == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This source uses an access token"})
+		return
+	}
+
+	state := shared.GenerateRandomString(32)
+	if err := r.RedisClient.Set(r.Ctx, gitlabOAuthStatePrefix+state, sourceID, gitlabOAuthStateTimeout).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.Redirect(http.StatusFound, gitlab.AuthorizeURL(source.HTMLURL, *connection.ClientID, *connection.RedirectURL, state))
+}
+
+// ConnectGitea stores the access token of a Gitea or Forgejo source after
+// checking that the instance accepts it.
+func (r *sourceRepository) ConnectGitea(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "gitea.connect")
+	audit.Target(c, "source", sourceID)
+
+	var input models.ConnectGitea
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	source, ok := r.findSourceOfType(c, sourceID, models.SourceTypeGitea)
+	if !ok {
+		return
+	}
+
+	if _, err := gitea.NewClient(r.Ctx, source.APIURL, input.AccessToken).CurrentUser(); err != nil {
+		var apiErr *gitea.APIError
+		if errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnauthorized {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Gitea rejected the access token"})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSourceUnavailable})
+		return
+	}
+
+	// Keep the webhook secret across reconnects so registered webhooks keep
+	// working.
+	query := `
+		insert into gitea_connections (source_id, access_token, webhook_secret)
+		values ($1, $2, $3)
+		on conflict (source_id) do update set
+			access_token = excluded.access_token,
+			updated_at = now()
+		returning *
+	`
+
+	var connection models.GiteaConnection
+	if err := r.DB.GetContext(r.Ctx, &connection, query, sourceID, input.AccessToken, shared.GenerateRandomString(32)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"connection": connection},
+	})
+}
+
+// ConnectGit points a git source at a remote and the credentials to reach it.
+// The tracked branch is polled for new commits every pollInterval seconds.
+func (r *sourceRepository) ConnectGit(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "git.connect")
+	audit.Target(c, "source", sourceID)
+
+	var input models.ConnectGit
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	input.RepositoryURL = strings.TrimSpace(input.RepositoryURL)
+	if err := git.ValidateURL(input.RepositoryURL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pollInterval := defaultGitPollInterval
+	if input.PollInterval != nil {
+		pollInterval = *input.PollInterval
+	}
+	if pollInterval != 0 && pollInterval < minGitPollInterval {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("pollInterval must be 0 or at least %d seconds", minGitPollInterval)})
+		return
+	}
+
+	var keyID, username, password *string
+	if git.IsSSHURL(input.RepositoryURL) {
+		if input.KeyID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "SSH repository URLs need a deploy key"})
+			return
+		}
+		keyID = &input.KeyID
+	} else if input.Username != "" || input.Password != "" {
+		username, password = &input.Username, &input.Password
+	}
+
+	if _, ok := r.findSourceOfType(c, sourceID, models.SourceTypeGit); !ok {
+		return
+	}
+
+	query := `
+		insert into git_connections (source_id, repository_url, key_id, username, password, branch, poll_interval)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		on conflict (source_id) do update set
+			repository_url = excluded.repository_url,
+			key_id = excluded.key_id,
+			username = excluded.username,
+			password = excluded.password,
+			branch = excluded.branch,
+			poll_interval = excluded.poll_interval,
+			last_commit_sha = null,
+			last_polled_at = null,
+			last_poll_error = null,
+			updated_at = now()
+		returning *
+	`
+
+	var connection models.GitConnection
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&connection,
+		query,
+		sourceID, input.RepositoryURL, keyID, username, password, input.Branch, pollInterval,
+	); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Key not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"connection": connection},
+	})
+}
+
+// CheckGitAccess runs git ls-remote against a git source's remote, from Mo-SH
+// itself or from one of the servers, which may see a different network.
+func (r *sourceRepository) CheckGitAccess(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+
+	var input models.CheckGitAccess
+	if err := c.ShouldBindJSON(&input); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	connection, auth, err := sources.LoadGitConnection(r.Ctx, r.DB, sourceID)
+	if err != nil {
+		if errors.Is(err, sources.ErrNotConnected) {
+			c.JSON(http.StatusConflict, gin.H{"error": shared.ErrSourceNotConnected})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var refs []git.Ref
+	if input.ServerID == "" {
+		refs, err = git.LsRemote(r.Ctx, connection.RepositoryURL, auth)
+	} else {
+		refs, err = r.lsRemoteOnServer(c, input.ServerID, connection.RepositoryURL, auth)
+		if c.Writer.Written() {
+			return
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "OK",
+			"data":    gin.H{"ok": false, "error": err.Error()},
+		})
+		return
+	}
+
+	branches := []sources.Branch{}
+	var trackedSHA *string
+	for _, ref := range refs {
+		name := ref.Branch()
+		if name == "" {
+			continue
+		}
+		branches = append(branches, sources.Branch{Name: name, SHA: ref.SHA})
+		if name == connection.Branch {
+			sha := ref.SHA
+			trackedSHA = &sha
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data": gin.H{
+			"ok":         true,
+			"branches":   branches,
+			"trackedSha": trackedSHA,
+		},
+	})
+}
+
+// lsRemoteOnServer runs git ls-remote over SSH on serverID. Errors about the
+// server itself have already been written to the response.
+func (r *sourceRepository) lsRemoteOnServer(c *gin.Context, serverID, remote string, auth git.Auth) ([]git.Ref, error) {
+	var server struct {
+		Hostname string `db:"hostname"`
+		Port     int    `db:"port"`
+		Key      string `db:"key"`
+	}
+	query := `
+		select
+			s.hostname, s.port, k.key
+		from
+			servers s
+		inner join
+			keys k ON s.key_id = k.id
+		where
+			s.id = $1
+	`
+	if err := r.DB.GetContext(r.Ctx, &server, query, serverID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, err
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, err
+	}
+
+	script := git.LsRemoteCommand(remote, auth)
+
+	sshClient := ssh.NewClient(server.Hostname, server.Port, "root", []byte(server.Key))
+	if err := sshClient.Connect(); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSSHConnection})
+		return nil, err
+	}
+	defer sshClient.Close()
+
+	var stdout strings.Builder
+	stderr, err := sshClient.RunWithOutput(script, git.CredentialsInput(auth), &stdout)
+	if err != nil {
+		message := strings.TrimSpace(stderr)
+		if auth.Password != "" {
+			message = strings.ReplaceAll(message, auth.Password, "***")
+		}
+		return nil, fmt.Errorf("git ls-remote: %s", message)
+	}
+
+	return git.ParseLsRemote(stdout.String()), nil
+}
+
+func (r *sourceRepository) FindInstallations(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+
+	var installations []models.GithubInstallation = []models.GithubInstallation{}
+	if err := r.DB.SelectContext(
+		r.Ctx,
+		&installations,
+		"select * from github_installations where source_id = $1 order by account_login",
+		sourceID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"installations": installations},
+	})
+}
+
+// FindRepositories lists the repositories the source can access. For GitHub
+// that is the list kept in sync by installation webhooks; pass refresh=true to
+// resync it first. q searches by name.
+func (r *sourceRepository) FindRepositories(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+
+	provider, ok := r.provider(c)
+	if !ok {
+		return
+	}
+
+	if c.Query("refresh") == "true" {
+		if err := r.syncGithubInstallations(sourceID); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrGithubUnavailable})
+			return
+		}
+	}
+
+	repositories, err := provider.ListRepositories(c.Query("q"))
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSourceUnavailable})
+		return
+	}
+	if repositories == nil {
+		repositories = []sources.Repository{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"repositories": repositories},
+	})
+}
+
+func (r *sourceRepository) FindBranches(c *gin.Context) {
+	provider, ok := r.provider(c)
+	if !ok {
+		return
+	}
+
+	branches, err := provider.ListBranches(c.Param("repositoryID"))
+	if err != nil {
+		if sources.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSourceUnavailable})
+		return
+	}
+	if branches == nil {
+		branches = []sources.Branch{}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"branches": branches},
+	})
+}
+
+// RegisterWebhook adds a webhook for push and merge request events to a
+// repository. Only sources without an app-wide webhook need this.
+func (r *sourceRepository) RegisterWebhook(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	repositoryID := c.Param("repositoryID")
+	audit.Action(c, "source.webhook.register")
+	audit.Target(c, "source", sourceID)
+
+	provider, ok := r.provider(c)
+	if !ok {
+		return
+	}
+
+	registrar, ok := provider.(sources.WebhookRegistrar)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Webhooks for this source are managed by its app"})
+		return
+	}
+
+	var webhook models.SourceWebhook
+	err := r.DB.GetContext(r.Ctx, &webhook, "select * from source_webhooks where source_id = $1 and repository_id = $2", sourceID, repositoryID)
+	if err == nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "OK",
+			"data":    gin.H{"webhook": webhook},
+		})
+		return
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var source models.Source
+	if err := r.DB.GetContext(r.Ctx, &source, "select * from sources where id = $1", sourceID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	hookURL := fmt.Sprintf("%s/api/v1/webhooks/%s/%s", instanceSettings.APIURL, source.Type, sourceID)
+	hookID, err := registrar.RegisterWebhook(repositoryID, hookURL)
+	if err != nil {
+		if sources.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSourceUnavailable})
+		return
+	}
+
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&webhook,
+		"insert into source_webhooks (source_id, repository_id, hook_id) values ($1, $2, $3) returning *",
+		sourceID, repositoryID, hookID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"webhook": webhook},
+	})
+}
+
+func (r *sourceRepository) FindCommits(c *gin.Context) {
+	client, repository, ok := r.githubRepository(c)
+	if !ok {
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultCommitsLimit)))
+	if err != nil || limit < 1 || limit > 100 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 100"})
+		return
+	}
+
+	branch := c.DefaultQuery("branch", repository.DefaultBranch)
+	commits, err := client.ListCommits(repository.InstallationID, repository.FullName, branch, limit)
+	if err != nil {
+		if github.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrGithubUnavailable})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"commits": commits},
+	})
+}
+
+type githubRepositoryWithInstallation struct {
+	FullName       string `db:"full_name"`
+	DefaultBranch  string `db:"default_branch"`
+	InstallationID int64  `db:"installation_id"`
+}
+
+// githubRepository resolves the :repositoryID of a source to the installation
+// that grants access to it. On failure it has already written the response.
+func (r *sourceRepository) githubRepository(c *gin.Context) (*github.Client, *githubRepositoryWithInstallation, bool) {
+	sourceID := c.Param("sourceID")
+
+	query := `
+		select
+			gr.full_name, gr.default_branch, gi.installation_id
+		from
+			github_repositories gr
+		inner join
+			github_installations gi ON gi.id = gr.installation_id
+		where
+			gi.source_id = $1 and gr.repository_id = $2
+	`
+
+	var repository githubRepositoryWithInstallation
+	if err := r.DB.GetContext(r.Ctx, &repository, query, sourceID, c.Param("repositoryID")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, nil, false
+	}
+
+	client, err := github.LoadClient(r.DB, r.RedisClient, r.Ctx, sourceID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, nil, false
+	}
+
+	return client, &repository, true
+}
+
+// findSourceOfType loads a source and checks its type. On failure it has
+// already written the response.
+func (r *sourceRepository) findSourceOfType(c *gin.Context, sourceID, sourceType string) (*models.Source, bool) {
+	var source models.Source
+	if err := r.DB.GetContext(r.Ctx, &source, "select * from sources where id = $1", sourceID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	if source.Type != sourceType {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "This action isn't available for " + source.Type + " sources"})
+		return nil, false
+	}
+
+	return &source, true
+}
+
+func gitlabRedirectURL(instanceSettings *settings.Settings) string {
+	return instanceSettings.APIURL + "/api/v1/webhooks/gitlab/redirect"
+}
+
+// provider loads the source's provider. On failure it has already written
+// the response.
+func (r *sourceRepository) provider(c *gin.Context) (sources.Provider, bool) {
+	provider, err := sources.Load(r.DB, r.RedisClient, r.Ctx, c.Param("sourceID"))
+	if err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+		case errors.Is(err, sources.ErrNotConnected):
+			c.JSON(http.StatusConflict, gin.H{"error": shared.ErrSourceNotConnected})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		}
+		return nil, false
+	}
+	return provider, true
+}
+
+func (r *sourceRepository) syncGithubInstallations(sourceID string) error {
+	client, err := github.LoadClient(r.DB, r.RedisClient, r.Ctx, sourceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			// Not a GitHub source; there is nothing to sync.
+			return nil
+		}
+		return err
+	}
+
+	var installationIDs []int64
+	if err := r.DB.SelectContext(
+		r.Ctx,
+		&installationIDs,
+		"select installation_id from github_installations where source_id = $1 and suspended_at is null",
+		sourceID,
+	); err != nil {
+		return err
+	}
+
+	for _, installationID := range installationIDs {
+		if err := sources.SyncGithubInstallation(r.Ctx, r.DB, client, installationID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// normalizeSourceURLs fills in the public defaults for a source type and
+// derives the API URL of a self-hosted instance from its web URL when it
+// isn't given.
+func normalizeSourceURLs(sourceType, htmlURL, apiURL string) (string, string, error) {
+	htmlURL = strings.TrimSuffix(strings.TrimSpace(htmlURL), "/")
+	apiURL = strings.TrimSuffix(strings.TrimSpace(apiURL), "/")
+
+	switch sourceType {
+	case models.SourceTypeGit:
+		// The remote is set with ConnectGit; there is no API.
+		return "", "", nil
+	case models.SourceTypeGitea:
+		// Gitea and Forgejo are always self-hosted.
+		if htmlURL == "" {
+			return "", "", errors.New("htmlUrl is required for gitea sources")
+		}
+		if apiURL == "" {
+			apiURL = gitea.APIURLFor(htmlURL)
+		}
+	case models.SourceTypeGitlab:
+		if htmlURL == "" {
+			htmlURL = gitlab.DefaultHTMLURL
+		}
+		if apiURL == "" {
+			apiURL = gitlab.APIURLFor(htmlURL)
+		}
+	default:
+		if htmlURL == "" {
+			htmlURL = github.DefaultHTMLURL
+		}
+		if apiURL == "" {
+			apiURL = github.APIURLFor(htmlURL)
+		}
+	}
+
+	if !isBaseURL(htmlURL) {
+		return "", "", errors.New("htmlUrl must be an absolute http(s) URL")
+	}
+	if !isBaseURL(apiURL) {
+		return "", "", errors.New("apiUrl must be an absolute http(s) URL")
+	}
+
+	return htmlURL, apiURL, nil
+}
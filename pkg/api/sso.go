@@ -0,0 +1,553 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/config"
+	"github.com/mohit4bug/mo-sh/pkg/oidc"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	ssoStatePrefix  = "sso_state:"
+	ssoStateTimeout = 10 * time.Minute
+
+	identityProviderOIDC   = "oidc"
+	identityProviderGithub = "github"
+)
+
+var (
+	errSSONotConfigured   = errors.New("oidc is not configured")
+	errSSONoAccount       = errors.New("no account for this identity")
+	errSSOEmailUnverified = errors.New("identity provider did not verify the email")
+)
+
+type ssoState struct {
+	CodeVerifier string `json:"codeVerifier"`
+	Nonce        string `json:"nonce,omitempty"`
+	SourceID     string `json:"sourceId,omitempty"`
+}
+
+type SSORepository interface {
+	OIDCLogin(c *gin.Context)
+	OIDCCallback(c *gin.Context)
+	GithubLogin(c *gin.Context)
+	GithubCallback(c *gin.Context)
+}
+
+type ssoRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Config      *config.Config
+	Settings    settings.Store
+	Ctx         context.Context
+
+	providerMu sync.Mutex
+	provider   *oidc.Provider
+}
+
+func NewSSORepository(db *sqlx.DB, redisClient *redis.Client, cfg *config.Config, settingsStore settings.Store, ctx context.Context) *ssoRepository {
+	return &ssoRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Config:      cfg,
+		Settings:    settingsStore,
+		Ctx:         ctx,
+	}
+}
+
+func (r *ssoRepository) OIDCLogin(c *gin.Context) {
+	provider, err := r.oidcProvider()
+	if err != nil {
+		if errors.Is(err, errSSONotConfigured) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Single sign-on is not configured."})
+			return
+		}
+		log.Println("OIDCLogin() discovery failed", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Couldn't reach the identity provider."})
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	verifier, challenge := oidc.GenerateCodeVerifier()
+	state := shared.GenerateRandomString(32)
+	nonce := shared.GenerateRandomString(32)
+
+	if err := r.saveState(state, ssoState{CodeVerifier: verifier, Nonce: nonce}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.Redirect(http.StatusFound, provider.AuthCodeURL(oidcCallbackURL(instanceSettings), state, nonce, challenge))
+}
+
+func (r *ssoRepository) OIDCCallback(c *gin.Context) {
+	audit.Action(c, "user.login.oidc")
+
+	state, ok := r.loadState(c)
+	if !ok {
+		return
+	}
+
+	provider, err := r.oidcProvider()
+	if err != nil {
+		r.redirectWithError(c, "sso_unavailable")
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	token, err := provider.Exchange(r.Ctx, oidcCallbackURL(instanceSettings), c.Query("code"), state.CodeVerifier)
+	if err != nil {
+		log.Println("OIDCCallback() token exchange failed", err)
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	claims, err := provider.VerifyIDToken(r.Ctx, token.IDToken, state.Nonce)
+	if err != nil {
+		log.Println("OIDCCallback() invalid id token", err)
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	user, err := r.resolveUser(identityProviderOIDC, claims.Subject, claims.Email, claims.EmailVerified)
+	if err != nil {
+		r.redirectForResolveError(c, err)
+		return
+	}
+
+	if err := r.applyGroupMappings(user.ID, claims.Groups(r.Config.OIDC.GroupsClaim)); err != nil {
+		log.Println("OIDCCallback() group mapping failed", err)
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	r.finishLogin(c, user)
+}
+
+func (r *ssoRepository) GithubLogin(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+
+	var clientID, htmlURL string
+	if err := r.DB.QueryRowContext(
+		r.Ctx,
+		"select ga.client_id, s.html_url from github_apps ga inner join sources s on s.id = ga.source_id where ga.source_id = $1",
+		sourceID,
+	).Scan(&clientID, &htmlURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	verifier, challenge := oidc.GenerateCodeVerifier()
+	state := shared.GenerateRandomString(32)
+
+	if err := r.saveState(state, ssoState{CodeVerifier: verifier, SourceID: sourceID}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	params := url.Values{}
+	params.Set("client_id", clientID)
+	params.Set("redirect_uri", githubLoginCallbackURL(instanceSettings))
+	params.Set("state", state)
+	params.Set("code_challenge", challenge)
+	params.Set("code_challenge_method", "S256")
+
+	c.Redirect(http.StatusFound, htmlURL+"/login/oauth/authorize?"+params.Encode())
+}
+
+func (r *ssoRepository) GithubCallback(c *gin.Context) {
+	audit.Action(c, "user.login.github")
+
+	state, ok := r.loadState(c)
+	if !ok {
+		return
+	}
+
+	var clientID, clientSecret, htmlURL, apiURL string
+	if err := r.DB.QueryRowContext(
+		r.Ctx,
+		"select ga.client_id, ga.client_secret, s.html_url, s.api_url from github_apps ga inner join sources s on s.id = ga.source_id where ga.source_id = $1",
+		state.SourceID,
+	).Scan(&clientID, &clientSecret, &htmlURL, &apiURL); err != nil {
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	accessToken, err := r.exchangeGithubCode(htmlURL, githubLoginCallbackURL(instanceSettings), clientID, clientSecret, c.Query("code"), state.CodeVerifier)
+	if err != nil {
+		log.Println("GithubCallback() token exchange failed", err)
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	var githubUser struct {
+		ID    int64  `json:"id"`
+		Login string `json:"login"`
+	}
+	if err := r.githubGet(apiURL, accessToken, "/user", &githubUser); err != nil {
+		log.Println("GithubCallback() failed to fetch user", err)
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := r.githubGet(apiURL, accessToken, "/user/emails", &emails); err != nil {
+		log.Println("GithubCallback() failed to fetch emails", err)
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	var email string
+	var verified bool
+	for _, e := range emails {
+		if e.Primary {
+			email, verified = e.Email, e.Verified
+			break
+		}
+	}
+
+	user, err := r.resolveUser(identityProviderGithub, strconv.FormatInt(githubUser.ID, 10), email, verified)
+	if err != nil {
+		r.redirectForResolveError(c, err)
+		return
+	}
+
+	r.finishLogin(c, user)
+}
+
+// oidcProvider discovers the provider on first use and caches it. A failed
+// discovery is retried on the next login.
+func (r *ssoRepository) oidcProvider() (*oidc.Provider, error) {
+	r.providerMu.Lock()
+	defer r.providerMu.Unlock()
+
+	if r.Config.OIDC.Issuer == "" {
+		return nil, errSSONotConfigured
+	}
+
+	if r.provider != nil {
+		return r.provider, nil
+	}
+
+	provider, err := oidc.Discover(
+		r.Ctx,
+		r.Config.OIDC.Issuer,
+		r.Config.OIDC.ClientID,
+		r.Config.OIDC.ClientSecret,
+		r.Config.OIDC.Scopes,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	r.provider = provider
+	return provider, nil
+}
+
+func (r *ssoRepository) saveState(state string, value ssoState) error {
+	payload, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return r.RedisClient.Set(r.Ctx, ssoStatePrefix+state, payload, ssoStateTimeout).Err()
+}
+
+// loadState consumes the state from the callback query. On failure it has
+// already redirected the browser back to the UI.
+func (r *ssoRepository) loadState(c *gin.Context) (*ssoState, bool) {
+	if c.Query("error") != "" {
+		r.redirectWithError(c, "sso_denied")
+		return nil, false
+	}
+
+	if c.Query("state") == "" || c.Query("code") == "" {
+		r.redirectWithError(c, "sso_failed")
+		return nil, false
+	}
+
+	payload, err := r.RedisClient.GetDel(r.Ctx, ssoStatePrefix+c.Query("state")).Result()
+	if err != nil {
+		r.redirectWithError(c, "sso_expired")
+		return nil, false
+	}
+
+	var state ssoState
+	if err := json.Unmarshal([]byte(payload), &state); err != nil {
+		r.redirectWithError(c, "sso_failed")
+		return nil, false
+	}
+
+	return &state, true
+}
+
+// resolveUser finds the user linked to an external identity. Unlinked
+// identities are linked to the account with the same verified email, or
+// provisioned as a new user when auto-provisioning is enabled.
+func (r *ssoRepository) resolveUser(provider, subject, email string, emailVerified bool) (*models.User, error) {
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	var user models.User
+	query := `
+		select
+			u.*
+		from
+			users u
+		inner join
+			user_identities i ON i.user_id = u.id
+		where
+			i.provider = $1 and i.subject = $2
+	`
+	err = tx.GetContext(r.Ctx, &user, query, provider, subject)
+	if err == nil {
+		return &user, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	// Linking on an unverified email would let anyone who can set an arbitrary
+	// email at the IdP take over the matching local account.
+	if email == "" || !emailVerified {
+		return nil, errSSOEmailUnverified
+	}
+
+	err = tx.GetContext(r.Ctx, &user, "select * from users where lower(email) = lower($1)", email)
+	if errors.Is(err, sql.ErrNoRows) {
+		if !r.Config.SSOAutoProvision {
+			return nil, errSSONoAccount
+		}
+
+		// An empty password hash never matches, so provisioned users can only
+		// sign in through SSO until they reset their password.
+		err = tx.GetContext(
+			r.Ctx,
+			&user,
+			"insert into users (email, password_hash, email_verified_at, is_admin) values ($1, '', now(), not exists(select 1 from users)) returning *",
+			email,
+		)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(
+		r.Ctx,
+		"insert into user_identities (user_id, provider, subject, email) values ($1, $2, $3, $4)",
+		user.ID, provider, subject, email,
+	); err != nil {
+		return nil, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// applyGroupMappings adds the user to every team mapped from one of their IdP
+// groups. It only grants access; removing a group at the IdP doesn't remove
+// the team membership.
+func (r *ssoRepository) applyGroupMappings(userID string, groups []string) error {
+	roles := make(map[string]models.TeamRole)
+	for _, mapping := range r.Config.OIDC.GroupMappings {
+		if !slices.Contains(groups, mapping.Group) {
+			continue
+		}
+
+		role := models.TeamRole(mapping.Role)
+		if role != models.TeamRoleAdmin && role != models.TeamRoleMember {
+			continue
+		}
+
+		if roles[mapping.TeamID] != models.TeamRoleAdmin {
+			roles[mapping.TeamID] = role
+		}
+	}
+
+	query := `
+		insert into team_members (team_id, user_id, role)
+		values ($1, $2, $3)
+		on conflict (team_id, user_id) do update set role = excluded.role, updated_at = now()
+		where team_members.role <> 'owner'
+	`
+	for teamID, role := range roles {
+		if _, err := r.DB.ExecContext(r.Ctx, query, teamID, userID, role); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// finishLogin starts a session, or hands over to the two-factor step when the
+// user has it enabled, and sends the browser back to the UI.
+func (r *ssoRepository) finishLogin(c *gin.Context, user *models.User) {
+	if user.TotpEnabled {
+		token := shared.GenerateRandomString(32)
+		if err := r.RedisClient.Set(r.Ctx, loginTwoFactorKey(token), user.ID, loginTwoFactorTimeout).Err(); err != nil {
+			r.redirectWithError(c, "sso_failed")
+			return
+		}
+
+		// Kept out of the URL, where history, logs and Referer headers
+		// would pick it up.
+		setLoginTwoFactorCookie(c, token)
+		r.redirectToUI(c, "/login/2fa")
+		return
+	}
+
+	if err := createSession(c, r.RedisClient, r.Ctx, user.ID); err != nil {
+		r.redirectWithError(c, "sso_failed")
+		return
+	}
+
+	r.redirectToUI(c, "/")
+}
+
+func (r *ssoRepository) redirectForResolveError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, errSSONoAccount):
+		r.redirectWithError(c, "sso_no_account")
+	case errors.Is(err, errSSOEmailUnverified):
+		r.redirectWithError(c, "sso_email_unverified")
+	default:
+		log.Println("resolveUser() failed", err)
+		r.redirectWithError(c, "sso_failed")
+	}
+}
+
+func (r *ssoRepository) redirectWithError(c *gin.Context, code string) {
+	r.redirectToUI(c, "/login?error="+code)
+}
+
+func (r *ssoRepository) redirectToUI(c *gin.Context, path string) {
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	c.Redirect(http.StatusFound, instanceSettings.UIURL+path)
+}
+
+func oidcCallbackURL(instanceSettings *settings.Settings) string {
+	return instanceSettings.APIURL + "/api/v1/auth/oidc/callback"
+}
+
+func githubLoginCallbackURL(instanceSettings *settings.Settings) string {
+	return instanceSettings.APIURL + "/api/v1/auth/github/callback"
+}
+
+func (r *ssoRepository) exchangeGithubCode(htmlURL, redirectURL, clientID, clientSecret, code, codeVerifier string) (string, error) {
+	form := url.Values{}
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	form.Set("code_verifier", codeVerifier)
+
+	req, err := http.NewRequestWithContext(r.Ctx, http.MethodPost, htmlURL+"/login/oauth/access_token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Mo-SH")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		Error       string `json:"error"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	if body.Error != "" || body.AccessToken == "" {
+		return "", fmt.Errorf("github oauth: %s", body.Error)
+	}
+
+	return body.AccessToken, nil
+}
+
+func (r *ssoRepository) githubGet(apiURL, accessToken, path string, out any) error {
+	req, err := http.NewRequestWithContext(r.Ctx, http.MethodGet, apiURL+path, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "Mo-SH")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("github api %s returned %s", path, resp.Status)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
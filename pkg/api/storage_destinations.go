@@ -0,0 +1,311 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/redis/go-redis/v9"
+)
+
+type StorageDestinationRepository interface {
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	Test(c *gin.Context)
+}
+
+type storageDestinationRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewStorageDestinationRepository(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *storageDestinationRepository {
+	return &storageDestinationRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+func (r *storageDestinationRepository) Create(c *gin.Context) {
+	audit.Action(c, "storage_destination.create")
+
+	var input models.CreateStorageDestination
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	destination := models.StorageDestination{
+		Name:        input.Name,
+		Endpoint:    input.Endpoint,
+		Region:      input.Region,
+		Bucket:      input.Bucket,
+		AccessKeyID: input.AccessKeyID,
+		PathPrefix:  input.PathPrefix,
+		PathStyle:   input.PathStyle == nil || *input.PathStyle,
+	}
+	if destination.Region == "" {
+		destination.Region = "us-east-1"
+	}
+	if !validDestination(c, &destination) {
+		return
+	}
+
+	var ok bool
+	if destination.SecretAccessKey, ok = r.encrypt(c, input.SecretAccessKey); !ok {
+		return
+	}
+
+	query := `
+		insert into storage_destinations (
+			name, endpoint, region, bucket, access_key_id, secret_access_key, path_prefix, path_style
+		) values (
+			$1, $2, $3, $4, $5, $6, $7, $8
+		)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&destination,
+		query,
+		destination.Name,
+		destination.Endpoint,
+		destination.Region,
+		destination.Bucket,
+		destination.AccessKeyID,
+		destination.SecretAccessKey,
+		destination.PathPrefix,
+		destination.PathStyle,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	audit.Target(c, "storage_destination", destination.ID)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"destination": destination},
+	})
+}
+
+func (r *storageDestinationRepository) FindAll(c *gin.Context) {
+	var list []models.StorageDestination = []models.StorageDestination{}
+	if err := r.DB.SelectContext(r.Ctx, &list, "select * from storage_destinations order by name"); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"destinations": list},
+	})
+}
+
+func (r *storageDestinationRepository) FindByID(c *gin.Context) {
+	destination, ok := r.findDestination(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"destination": destination},
+	})
+}
+
+func (r *storageDestinationRepository) Update(c *gin.Context) {
+	audit.Action(c, "storage_destination.update")
+
+	var input models.UpdateStorageDestination
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	destination, ok := r.findDestination(c)
+	if !ok {
+		return
+	}
+
+	if input.Name != nil {
+		destination.Name = *input.Name
+	}
+	if input.Endpoint != nil {
+		destination.Endpoint = *input.Endpoint
+	}
+	if input.Region != nil {
+		destination.Region = *input.Region
+	}
+	if input.Bucket != nil {
+		destination.Bucket = *input.Bucket
+	}
+	if input.AccessKeyID != nil {
+		destination.AccessKeyID = *input.AccessKeyID
+	}
+	if input.PathPrefix != nil {
+		destination.PathPrefix = *input.PathPrefix
+	}
+	if input.PathStyle != nil {
+		destination.PathStyle = *input.PathStyle
+	}
+	if !validDestination(c, destination) {
+		return
+	}
+	if input.SecretAccessKey != nil {
+		if destination.SecretAccessKey, ok = r.encrypt(c, *input.SecretAccessKey); !ok {
+			return
+		}
+	}
+
+	query := `
+		update storage_destinations
+		set name = $1, endpoint = $2, region = $3, bucket = $4, access_key_id = $5,
+			secret_access_key = $6, path_prefix = $7, path_style = $8, updated_at = now()
+		where id = $9
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		destination,
+		query,
+		destination.Name,
+		destination.Endpoint,
+		destination.Region,
+		destination.Bucket,
+		destination.AccessKeyID,
+		destination.SecretAccessKey,
+		destination.PathPrefix,
+		destination.PathStyle,
+		destination.ID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"destination": destination},
+	})
+}
+
+// Delete removes a destination that no backups or schedules use. Objects
+// in the bucket are left alone.
+func (r *storageDestinationRepository) Delete(c *gin.Context) {
+	audit.Action(c, "storage_destination.delete")
+
+	destination, ok := r.findDestination(c)
+	if !ok {
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from storage_destinations where id = $1", destination.ID); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+			c.JSON(http.StatusConflict, gin.H{"error": "Delete the backups and schedules that use the destination first"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// Test checks that the destination's bucket is reachable with its
+// credentials.
+func (r *storageDestinationRepository) Test(c *gin.Context) {
+	destination, ok := r.findDestination(c)
+	if !ok {
+		return
+	}
+
+	client, err := storage.Client(destination, r.Secrets)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Ctx, 15*time.Second)
+	defer cancel()
+
+	if err := client.CheckBucket(ctx); err != nil {
+		c.JSON(http.StatusOK, gin.H{
+			"message": "OK",
+			"data":    gin.H{"ok": false, "error": err.Error()},
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"ok": true},
+	})
+}
+
+// encrypt encrypts the secret access key. On failure it has already written
+// the response.
+func (r *storageDestinationRepository) encrypt(c *gin.Context, secretAccessKey string) (string, bool) {
+	encrypted, err := r.Secrets.Encrypt(secretAccessKey)
+	if err != nil {
+		if errors.Is(err, secrets.ErrNoKey) {
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": shared.ErrNoEncryptionKey})
+			return "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return "", false
+	}
+	return encrypted, true
+}
+
+// validDestination checks the fields binding can't. Otherwise it has already
+// written the response.
+func validDestination(c *gin.Context, destination *models.StorageDestination) bool {
+	endpoint, err := url.Parse(destination.Endpoint)
+	if err != nil || (endpoint.Scheme != "http" && endpoint.Scheme != "https") || endpoint.Host == "" || (endpoint.Path != "" && endpoint.Path != "/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The endpoint must be an http or https URL without a path"})
+		return false
+	}
+	if !storage.ValidPathPrefix(destination.PathPrefix) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid path prefix"})
+		return false
+	}
+	return true
+}
+
+// findDestination loads the :destinationID destination. On failure it has
+// already written the response.
+func (r *storageDestinationRepository) findDestination(c *gin.Context) (*models.StorageDestination, bool) {
+	destinationID := c.Param("destinationID")
+	audit.Target(c, "storage_destination", destinationID)
+
+	var destination models.StorageDestination
+	if err := r.DB.GetContext(r.Ctx, &destination, "select * from storage_destinations where id = $1", destinationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &destination, true
+}
@@ -0,0 +1,362 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+	"github.com/redis/go-redis/v9"
+)
+
+type TeamRepository interface {
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	AddMember(c *gin.Context)
+	UpdateSecurity(c *gin.Context)
+	FindInvitations(c *gin.Context)
+	AcceptInvitation(c *gin.Context)
+	DeclineInvitation(c *gin.Context)
+}
+
+type teamRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewTeamRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *teamRepository {
+	return &teamRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *teamRepository) Create(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	var input models.CreateTeam
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer tx.Rollback()
+
+	var teamID string
+	if err := tx.QueryRowContext(r.Ctx, "insert into teams (name) values ($1) returning id", input.Name).Scan(&teamID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if _, err := tx.ExecContext(
+		r.Ctx,
+		"insert into team_members (team_id, user_id, role) values ($1, $2, $3)",
+		teamID, currentSession.UserID, models.TeamRoleOwner,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"team": gin.H{"id": teamID}},
+	})
+}
+
+func (r *teamRepository) FindAll(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	query := `
+		select
+			t.*
+		from
+			teams t
+		inner join
+			team_members tm ON tm.team_id = t.id
+		where
+			tm.user_id = $1
+	`
+
+	var teams []models.Team = []models.Team{}
+	if err := r.DB.SelectContext(r.Ctx, &teams, query, currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"teams": teams},
+	})
+}
+
+func (r *teamRepository) FindByID(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+	teamID := c.Param("teamID")
+
+	if _, err := findTeamRole(r.Ctx, r.DB, teamID, currentSession.UserID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var team models.Team
+	if err := r.DB.GetContext(r.Ctx, &team, "select * from teams where id = $1", teamID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	query := `
+		select
+			tm.*, u.email, u.totp_enabled
+		from
+			team_members tm
+		inner join
+			users u ON u.id = tm.user_id
+		where
+			tm.team_id = $1
+	`
+
+	var members []models.TeamMember = []models.TeamMember{}
+	if err := r.DB.SelectContext(r.Ctx, &members, query, teamID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"team": team, "members": members},
+	})
+}
+
+func (r *teamRepository) AddMember(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+	teamID := c.Param("teamID")
+
+	var input models.AddTeamMember
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Role != models.TeamRoleAdmin && input.Role != models.TeamRoleMember {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Role must be admin or member"})
+		return
+	}
+
+	if !r.requireTeamAdmin(c, teamID, currentSession.UserID) {
+		return
+	}
+
+	var userID string
+	if err := r.DB.QueryRowContext(r.Ctx, "select id from users where email = $1", input.Email).Scan(&userID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	// Members only change role. Anyone else is invited, and joins, along
+	// with the team's security requirements, only once they accept.
+	result, err := r.DB.ExecContext(
+		r.Ctx,
+		"update team_members set role = $1, updated_at = now() where team_id = $2 and user_id = $3 and role <> 'owner'",
+		input.Role, teamID, userID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if updated, _ := result.RowsAffected(); updated > 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "OK"})
+		return
+	}
+	if _, err := findTeamRole(r.Ctx, r.DB, teamID, userID); err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "The owner's role can't be changed"})
+		return
+	}
+
+	query := `
+		insert into team_invitations (team_id, user_id, role, invited_by)
+		values ($1, $2, $3, $4)
+		on conflict (team_id, user_id) do update set role = excluded.role, invited_by = excluded.invited_by
+	`
+	if _, err := r.DB.ExecContext(r.Ctx, query, teamID, userID, input.Role, currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "OK"})
+}
+
+func (r *teamRepository) UpdateSecurity(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+	teamID := c.Param("teamID")
+
+	var input models.UpdateTeamSecurity
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !r.requireTeamAdmin(c, teamID, currentSession.UserID) {
+		return
+	}
+
+	if _, err := r.DB.ExecContext(
+		r.Ctx,
+		"update teams set require_two_factor = $1, updated_at = now() where id = $2",
+		*input.RequireTwoFactor, teamID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// FindInvitations lists the current user's pending team invitations.
+func (r *teamRepository) FindInvitations(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	query := `
+		select
+			ti.*, t.name as team_name
+		from
+			team_invitations ti
+		inner join
+			teams t ON t.id = ti.team_id
+		where
+			ti.user_id = $1
+		order by
+			ti.created_at desc
+	`
+
+	var invitations []models.TeamInvitation = []models.TeamInvitation{}
+	if err := r.DB.SelectContext(r.Ctx, &invitations, query, currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"invitations": invitations},
+	})
+}
+
+// AcceptInvitation makes the current user a member of the team they were
+// invited to, with the invited role.
+func (r *teamRepository) AcceptInvitation(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+	teamID := c.Param("teamID")
+
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer tx.Rollback()
+
+	var role models.TeamRole
+	if err := tx.QueryRowContext(
+		r.Ctx,
+		"delete from team_invitations where team_id = $1 and user_id = $2 returning role",
+		teamID, currentSession.UserID,
+	).Scan(&role); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if _, err := tx.ExecContext(
+		r.Ctx,
+		"insert into team_members (team_id, user_id, role) values ($1, $2, $3) on conflict (team_id, user_id) do nothing",
+		teamID, currentSession.UserID, role,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+func (r *teamRepository) DeclineInvitation(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	result, err := r.DB.ExecContext(
+		r.Ctx,
+		"delete from team_invitations where team_id = $1 and user_id = $2",
+		c.Param("teamID"), currentSession.UserID,
+	)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if deleted, _ := result.RowsAffected(); deleted == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// requireTeamAdmin writes an error response and returns false unless userID
+// is an owner or admin of teamID.
+func (r *teamRepository) requireTeamAdmin(c *gin.Context, teamID, userID string) bool {
+	role, err := findTeamRole(r.Ctx, r.DB, teamID, userID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return false
+	}
+
+	if role != models.TeamRoleOwner && role != models.TeamRoleAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": shared.ErrForbidden})
+		return false
+	}
+
+	return true
+}
+
+func findTeamRole(ctx context.Context, db *sqlx.DB, teamID, userID string) (models.TeamRole, error) {
+	var role models.TeamRole
+	err := db.QueryRowContext(
+		ctx,
+		"select role from team_members where team_id = $1 and user_id = $2",
+		teamID, userID,
+	).Scan(&role)
+	return role, err
+}
@@ -0,0 +1,316 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/session"
+	"github.com/mohit4bug/mo-sh/pkg/totp"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	totpIssuer = "Mo-SH"
+
+	loginTwoFactorTimeout     = 5 * time.Minute
+	loginTwoFactorMaxAttempts = 5
+
+	// SSO logins hand the pending two-factor token to the UI in this
+	// cookie, scoped to the one route that reads it.
+	loginTwoFactorCookie     = "login_2fa_token"
+	loginTwoFactorCookiePath = "/api/v1/login/2fa"
+
+	recoveryCodeCount = 10
+)
+
+type TwoFactorRepository interface {
+	Enroll(c *gin.Context)
+	Confirm(c *gin.Context)
+	Disable(c *gin.Context)
+	RegenerateRecoveryCodes(c *gin.Context)
+}
+
+type twoFactorRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewTwoFactorRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *twoFactorRepository {
+	return &twoFactorRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *twoFactorRepository) Enroll(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	var user models.User
+	if err := r.DB.GetContext(r.Ctx, &user, "select * from users where id = $1", currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled."})
+		return
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	// The secret stays unconfirmed (totp_enabled = false) until the user proves
+	// their authenticator app produces valid codes for it.
+	if _, err := r.DB.ExecContext(
+		r.Ctx,
+		"update users set totp_secret = $1, updated_at = now() where id = $2",
+		secret, user.ID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data": gin.H{
+			"secret":          secret,
+			"provisioningUri": totp.ProvisioningURI(secret, totpIssuer, user.Email),
+		},
+	})
+}
+
+func (r *twoFactorRepository) Confirm(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	var input models.ConfirmTwoFactor
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := r.DB.GetContext(r.Ctx, &user, "select * from users where id = $1", currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is already enabled."})
+		return
+	}
+
+	if user.TotpSecret == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Start enrollment before confirming two-factor authentication."})
+		return
+	}
+
+	if !verifyTotp(r.Ctx, r.RedisClient, &user, input.Code) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": shared.ErrInvalidTwoFactor})
+		return
+	}
+
+	codes, hashes := generateRecoveryCodes()
+	if _, err := r.DB.ExecContext(
+		r.Ctx,
+		"update users set totp_enabled = true, totp_recovery_codes = $1, updated_at = now() where id = $2",
+		hashes, user.ID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"recoveryCodes": codes},
+	})
+}
+
+func (r *twoFactorRepository) Disable(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	var input models.DisableTwoFactor
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := r.DB.GetContext(r.Ctx, &user, "select * from users where id = $1", currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if !user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled."})
+		return
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": shared.ErrInvalidCredentials})
+		return
+	}
+
+	if !verifyTotp(r.Ctx, r.RedisClient, &user, input.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": shared.ErrInvalidTwoFactor})
+		return
+	}
+
+	var required bool
+	query := `
+		select exists(
+			select 1
+			from team_members tm
+			inner join teams t on t.id = tm.team_id
+			where tm.user_id = $1 and t.require_two_factor
+		)
+	`
+	if err := r.DB.QueryRowContext(r.Ctx, query, user.ID).Scan(&required); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if required {
+		c.JSON(http.StatusForbidden, gin.H{"error": shared.ErrTwoFactorRequired})
+		return
+	}
+
+	if _, err := r.DB.ExecContext(
+		r.Ctx,
+		`update users set totp_enabled = false, totp_secret = null, totp_recovery_codes = '[]'::jsonb, updated_at = now() where id = $1`,
+		user.ID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+func (r *twoFactorRepository) RegenerateRecoveryCodes(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	var input models.ConfirmTwoFactor
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := r.DB.GetContext(r.Ctx, &user, "select * from users where id = $1", currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if !user.TotpEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Two-factor authentication is not enabled."})
+		return
+	}
+
+	if !verifyTotp(r.Ctx, r.RedisClient, &user, input.Code) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": shared.ErrInvalidTwoFactor})
+		return
+	}
+
+	codes, hashes := generateRecoveryCodes()
+	if _, err := r.DB.ExecContext(
+		r.Ctx,
+		"update users set totp_recovery_codes = $1, updated_at = now() where id = $2",
+		hashes, user.ID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"recoveryCodes": codes},
+	})
+}
+
+func loginTwoFactorKey(token string) string {
+	return "login_2fa:" + token
+}
+
+// setLoginTwoFactorCookie sets, or with an empty token clears, the cookie
+// carrying an SSO login's pending two-factor token.
+func setLoginTwoFactorCookie(c *gin.Context, token string) {
+	maxAge := int(loginTwoFactorTimeout.Seconds())
+	if token == "" {
+		maxAge = -1
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(loginTwoFactorCookie, token, maxAge, loginTwoFactorCookiePath, "", false, true)
+}
+
+// verifyTotp validates code against the user's secret and remembers the
+// matched time step so the same code can't be replayed within its window.
+func verifyTotp(ctx context.Context, redisClient *redis.Client, user *models.User, code string) bool {
+	if user.TotpSecret == nil {
+		return false
+	}
+
+	counter, ok := totp.Validate(code, *user.TotpSecret, time.Now())
+	if !ok {
+		return false
+	}
+
+	key := fmt.Sprintf("totp_used:%s:%d", user.ID, counter)
+	fresh, err := redisClient.SetNX(ctx, key, 1, 3*totp.Period).Result()
+	if err != nil {
+		return false
+	}
+
+	return fresh
+}
+
+// useRecoveryCode consumes a matching recovery code so it can't be used again.
+func useRecoveryCode(ctx context.Context, db *sqlx.DB, user *models.User, code string) (bool, error) {
+	hash := shared.HashToken(normalizeRecoveryCode(code))
+
+	query := `
+		update users
+		set totp_recovery_codes = totp_recovery_codes - $1::text, updated_at = now()
+		where id = $2 and totp_recovery_codes ? $1::text
+	`
+	result, err := db.ExecContext(ctx, query, hash, user.ID)
+	if err != nil {
+		return false, err
+	}
+
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return affected == 1, nil
+}
+
+func generateRecoveryCodes() ([]string, models.RecoveryCodes) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make(models.RecoveryCodes, recoveryCodeCount)
+
+	for i := range codes {
+		raw := shared.GenerateRandomString(10)
+		codes[i] = raw[:5] + "-" + raw[5:]
+		hashes[i] = shared.HashToken(raw)
+	}
+
+	return codes, hashes
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
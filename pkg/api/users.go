@@ -3,36 +3,77 @@ package api
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"log"
 	"net/http"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/middlewares"
 	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/config"
+	"github.com/mohit4bug/mo-sh/pkg/mail"
+	"github.com/mohit4bug/mo-sh/pkg/ratelimit"
 	"github.com/mohit4bug/mo-sh/pkg/session"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
 	"github.com/redis/go-redis/v9"
 	"golang.org/x/crypto/bcrypt"
 )
 
+const (
+	emailVerificationTokenPrefix = "email_verification:"
+	emailVerificationTimeout     = 24 * time.Hour
+
+	passwordResetTokenPrefix = "password_reset:"
+	passwordResetTimeout     = time.Hour
+)
+
 type UserRepository interface {
 	Register(c *gin.Context)
+	VerifyEmail(c *gin.Context)
+	ResendEmailVerification(c *gin.Context)
+	ForgotPassword(c *gin.Context)
+	ResetPassword(c *gin.Context)
 	Login(c *gin.Context)
+	LoginTwoFactor(c *gin.Context)
 }
 
 type userRepository struct {
 	DB          *sqlx.DB
 	RedisClient *redis.Client
+	Config      *config.Config
+	Settings    settings.Store
 	Ctx         context.Context
+	Limiter     ratelimit.Limiter
+	Lockout     ratelimit.Lockout
 }
 
-func NewUserRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *userRepository {
+func NewUserRepository(db *sqlx.DB, redisClient *redis.Client, cfg *config.Config, settingsStore settings.Store, ctx context.Context) *userRepository {
 	return &userRepository{
 		DB:          db,
 		RedisClient: redisClient,
+		Config:      cfg,
+		Settings:    settingsStore,
 		Ctx:         ctx,
+		Limiter:     ratelimit.NewLimiter(redisClient, ctx),
+		Lockout: ratelimit.NewLockout(
+			redisClient,
+			ctx,
+			cfg.RateLimit.LockoutThreshold,
+			cfg.RateLimit.LockoutBase,
+			cfg.RateLimit.LockoutMax,
+		),
 	}
 }
 
 func (r *userRepository) Register(c *gin.Context) {
+	audit.Action(c, "user.register")
+
 	var input models.RegisterUser
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
@@ -63,32 +104,263 @@ func (r *userRepository) Register(c *gin.Context) {
 
 	if err := r.DB.QueryRowContext(
 		r.Ctx,
-		"insert into users (email, password_hash) values ($1, $2)",
+		// The first account on an instance administers it.
+		"insert into users (email, password_hash, is_admin) values ($1, $2, not exists(select 1 from users)) returning id",
 		newUser.Email,
 		newUser.PasswordHash,
-	).Err(); err != nil {
+	).Scan(&newUser.ID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	audit.Actor(c, newUser.ID)
+
+	// The account is usable either way; a failed email can be resent later.
+	if err := r.sendEmailVerification(newUser); err != nil {
+		log.Println("Register() failed to send verification email", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+func (r *userRepository) VerifyEmail(c *gin.Context) {
+	var input models.VerifyEmail
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := r.consumeToken(emailVerificationTokenPrefix, input.Token)
+	if err != nil {
+		if err == redis.Nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": shared.ErrInvalidToken})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if _, err := r.DB.ExecContext(
+		r.Ctx,
+		"update users set email_verified_at = coalesce(email_verified_at, now()), updated_at = now() where id = $1",
+		userID,
+	); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
 
 	c.JSON(http.StatusOK, gin.H{"message": "OK"})
 }
 
+func (r *userRepository) ResendEmailVerification(c *gin.Context) {
+	currentSession := c.MustGet("session").(*session.Session)
+
+	var user models.User
+	if err := r.DB.GetContext(r.Ctx, &user, "select * from users where id = $1", currentSession.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if user.EmailVerifiedAt != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Email is already verified."})
+		return
+	}
+
+	if err := r.sendEmailVerification(&user); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+func (r *userRepository) ForgotPassword(c *gin.Context) {
+	var input models.ForgotPassword
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := r.DB.GetContext(r.Ctx, &user, "select * from users where email = $1", input.Email); err != nil {
+		// Respond the same way for unknown emails so this can't be used to find
+		// out which accounts exist.
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusOK, gin.H{"message": "OK"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	token, err := r.issueToken(passwordResetTokenPrefix, user.ID, passwordResetTimeout)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	msg, err := mail.Render("reset_password", user.Email, gin.H{
+		"Email":     user.Email,
+		"URL":       fmt.Sprintf("%s/reset-password?token=%s", instanceSettings.UIURL, token),
+		"ExpiresIn": "1 hour",
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := workers.QueueMail(r.RedisClient, r.Ctx, msg); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+func (r *userRepository) ResetPassword(c *gin.Context) {
+	audit.Action(c, "user.password.reset")
+
+	var input models.ResetPassword
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	userID, err := r.consumeToken(passwordResetTokenPrefix, input.Token)
+	if err != nil {
+		if err == redis.Nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": shared.ErrInvalidToken})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	passwordHash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	// Receiving the reset email proves ownership of the address as well.
+	var email string
+	if err := r.DB.QueryRowContext(
+		r.Ctx,
+		`update users
+		set password_hash = $1, email_verified_at = coalesce(email_verified_at, now()), updated_at = now()
+		where id = $2
+		returning email`,
+		string(passwordHash), userID,
+	).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": shared.ErrInvalidToken})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	sessionStore := session.NewSessionStore(r.RedisClient, r.Ctx)
+	if err := sessionStore.DeleteAllForUser(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if err := r.Lockout.Reset(loginAccountKey(email)); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+func (r *userRepository) sendEmailVerification(user *models.User) error {
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		return err
+	}
+
+	token, err := r.issueToken(emailVerificationTokenPrefix, user.ID, emailVerificationTimeout)
+	if err != nil {
+		return err
+	}
+
+	msg, err := mail.Render("verify_email", user.Email, gin.H{
+		"Email":     user.Email,
+		"URL":       fmt.Sprintf("%s/verify-email?token=%s", instanceSettings.UIURL, token),
+		"ExpiresIn": "24 hours",
+	})
+	if err != nil {
+		return err
+	}
+
+	return workers.QueueMail(r.RedisClient, r.Ctx, msg)
+}
+
+// issueToken stores a single-use token for userID. Only its hash is kept in
+// Redis, so a leaked dump can't be used to take over accounts.
+func (r *userRepository) issueToken(prefix, userID string, ttl time.Duration) (string, error) {
+	token := shared.GenerateRandomString(64)
+	if err := r.RedisClient.Set(r.Ctx, prefix+shared.HashToken(token), userID, ttl).Err(); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// consumeToken returns the user a token was issued for and invalidates it. It
+// returns redis.Nil for unknown, used or expired tokens.
+func (r *userRepository) consumeToken(prefix, token string) (string, error) {
+	return r.RedisClient.GetDel(r.Ctx, prefix+shared.HashToken(token)).Result()
+}
+
 func (r *userRepository) Login(c *gin.Context) {
+	audit.Action(c, "user.login")
+
 	var input models.LoginUser
 	if err := c.ShouldBindJSON(&input); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	accountKey := loginAccountKey(input.Email)
+
+	// As with the middleware, a limit of 0 disables it.
+	if limit := r.Config.RateLimit.LoginAccountLimit; limit > 0 {
+		result, err := r.Limiter.Allow("login:"+accountKey, limit, r.Config.RateLimit.LoginAccountWindow)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+		if !middlewares.WriteRateLimit(c, result) {
+			return
+		}
+	}
+
+	lockedFor, err := r.Lockout.Check(accountKey)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if lockedFor > 0 {
+		middlewares.WriteRetryAfter(c, lockedFor, shared.ErrAccountLocked)
+		return
+	}
+
 	var user models.User
 	if err := r.DB.QueryRowContext(
 		r.Ctx,
-		"select id, email, password_hash from users where email = $1",
+		"select id, email, password_hash, totp_enabled from users where email = $1",
 		input.Email,
-	).Scan(&user.ID, &user.Email, &user.PasswordHash); err != nil {
+	).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.TotpEnabled); err != nil {
 		if err == sql.ErrNoRows {
-			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+			// Count failures for unknown emails too, so lockouts don't reveal which
+			// accounts exist.
+			r.failLogin(c, accountKey, shared.ErrInvalidCredentials)
 			return
 		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
@@ -96,24 +368,170 @@ func (r *userRepository) Login(c *gin.Context) {
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(input.Password)); err != nil {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
+		r.failLogin(c, accountKey, shared.ErrInvalidCredentials)
 		return
 	}
 
-	sessionDuration := session.DefaultSessionTimeout // Keep session and cookie duration same.
-	sessionStore := session.NewSessionStore(r.RedisClient, r.Ctx)
-	session, err := sessionStore.Create(user.ID)
+	if user.TotpEnabled {
+		token := shared.GenerateRandomString(32)
+		if err := r.RedisClient.Set(r.Ctx, loginTwoFactorKey(token), user.ID, loginTwoFactorTimeout).Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message": "OK",
+			"data": gin.H{
+				"twoFactorRequired": true,
+				"twoFactorToken":    token,
+			},
+		})
+		return
+	}
+
+	if err := r.Lockout.Reset(accountKey); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	r.startSession(c, user.ID)
+}
+
+func (r *userRepository) LoginTwoFactor(c *gin.Context) {
+	audit.Action(c, "user.login.2fa")
+
+	var input models.VerifyLoginTwoFactor
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Code == "" && input.RecoveryCode == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Either code or recoveryCode is required"})
+		return
+	}
+
+	// SSO logins pass the token in a cookie rather than the body.
+	if input.Token == "" {
+		input.Token, _ = c.Cookie(loginTwoFactorCookie)
+	}
+	if input.Token == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "token is required"})
+		return
+	}
+
+	userID, err := r.RedisClient.Get(r.Ctx, loginTwoFactorKey(input.Token)).Result()
+	if err != nil {
+		if err == redis.Nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Login attempt expired. Please sign in again."})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	attemptsKey := loginTwoFactorKey(input.Token) + ":attempts"
+	attempts, err := r.RedisClient.Incr(r.Ctx, attemptsKey).Result()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	r.RedisClient.Expire(r.Ctx, attemptsKey, loginTwoFactorTimeout)
+
+	if attempts > loginTwoFactorMaxAttempts {
+		r.RedisClient.Del(r.Ctx, loginTwoFactorKey(input.Token), attemptsKey)
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Too many attempts. Please sign in again."})
+		return
+	}
+
+	var user models.User
+	if err := r.DB.GetContext(r.Ctx, &user, "select * from users where id = $1", userID); err != nil {
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": shared.ErrInvalidCredentials})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	accountKey := loginAccountKey(user.Email)
+	audit.Actor(c, user.ID)
+
+	if input.RecoveryCode != "" {
+		used, err := useRecoveryCode(r.Ctx, r.DB, &user, input.RecoveryCode)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+		if !used {
+			r.failLogin(c, accountKey, shared.ErrInvalidTwoFactor)
+			return
+		}
+	} else if !verifyTotp(r.Ctx, r.RedisClient, &user, input.Code) {
+		r.failLogin(c, accountKey, shared.ErrInvalidTwoFactor)
+		return
+	}
+
+	if err := r.RedisClient.Del(r.Ctx, loginTwoFactorKey(input.Token), attemptsKey).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	setLoginTwoFactorCookie(c, "")
+
+	if err := r.Lockout.Reset(accountKey); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	r.startSession(c, user.ID)
+}
+
+// failLogin records a failed attempt against accountKey and responds with
+// either message or, once the account is locked, a 429 with Retry-After.
+func (r *userRepository) failLogin(c *gin.Context, accountKey string, message string) {
+	lockedFor, err := r.Lockout.Fail(accountKey)
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if lockedFor > 0 {
+		middlewares.WriteRetryAfter(c, lockedFor, shared.ErrAccountLocked)
+		return
+	}
+
+	c.JSON(http.StatusUnauthorized, gin.H{"error": message})
+}
+
+func (r *userRepository) startSession(c *gin.Context, userID string) {
+	if err := createSession(c, r.RedisClient, r.Ctx, userID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	c.SetSameSite(http.SameSiteLaxMode)
-	c.SetCookie("session_id", session.ID, int(sessionDuration.Seconds()), "/", "", false, true)
 	c.JSON(http.StatusOK, gin.H{
 		"message": "OK",
 		"data": gin.H{"user": gin.H{
-			"id": user.ID,
+			"id": userID,
 		}},
 	})
 }
+
+// createSession starts a session for userID and sets the session cookie.
+func createSession(c *gin.Context, redisClient *redis.Client, ctx context.Context, userID string) error {
+	sessionDuration := session.DefaultSessionTimeout // Keep session and cookie duration same.
+	sessionStore := session.NewSessionStore(redisClient, ctx)
+	session, err := sessionStore.Create(userID)
+	if err != nil {
+		return err
+	}
+	audit.Actor(c, userID)
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie("session_id", session.ID, int(sessionDuration.Seconds()), "/", "", false, true)
+	return nil
+}
+
+func loginAccountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
@@ -0,0 +1,381 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/deploy"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/dotenv"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/redis/go-redis/v9"
+)
+
+type VariableRepository interface {
+	FindAll(c *gin.Context)
+	Create(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	Import(c *gin.Context)
+	Export(c *gin.Context)
+}
+
+type variableRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewVariableRepository(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *variableRepository {
+	return &variableRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+// variableScope is what a request's variables belong to: the :applicationID
+// application or the :environmentID environment.
+type variableScope struct {
+	Column string
+	ID     string
+	// Target is the audit log target type.
+	Target string
+}
+
+func scopeOf(c *gin.Context) *variableScope {
+	if environmentID := c.Param("environmentID"); environmentID != "" {
+		return &variableScope{Column: "environment_id", ID: environmentID, Target: "environment"}
+	}
+	return &variableScope{Column: "application_id", ID: c.Param("applicationID"), Target: "application"}
+}
+
+func (s *variableScope) audit(c *gin.Context, action string) {
+	audit.Action(c, s.Target+".variable."+action)
+	audit.Target(c, s.Target, s.ID)
+}
+
+func (s *variableScope) assign(variable *models.Variable) {
+	id := s.ID
+	if s.Column == "environment_id" {
+		variable.EnvironmentID = &id
+	} else {
+		variable.ApplicationID = &id
+	}
+}
+
+func (r *variableRepository) FindAll(c *gin.Context) {
+	scope := scopeOf(c)
+
+	var variables []models.Variable = []models.Variable{}
+	query := fmt.Sprintf("select * from variables where %s = $1 order by is_preview, key", scope.Column)
+	if err := r.DB.SelectContext(r.Ctx, &variables, query, scope.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"variables": variables},
+	})
+}
+
+func (r *variableRepository) Create(c *gin.Context) {
+	scope := scopeOf(c)
+	scope.audit(c, "create")
+
+	var input models.CreateVariable
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variable := models.Variable{
+		Key:         input.Key,
+		Value:       input.Value,
+		IsSecret:    input.IsSecret,
+		IsBuildTime: input.IsBuildTime != nil && *input.IsBuildTime,
+		IsRuntime:   input.IsRuntime == nil || *input.IsRuntime,
+		IsPreview:   input.IsPreview,
+	}
+	scope.assign(&variable)
+	if !r.prepare(c, &variable) {
+		return
+	}
+
+	query := `
+		insert into variables (application_id, environment_id, key, value, is_secret, is_build_time, is_runtime, is_preview)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&variable,
+		query,
+		variable.ApplicationID,
+		variable.EnvironmentID,
+		variable.Key,
+		variable.Value,
+		variable.IsSecret,
+		variable.IsBuildTime,
+		variable.IsRuntime,
+		variable.IsPreview,
+	); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) {
+			switch pqErr.Code.Name() {
+			case "unique_violation":
+				c.JSON(http.StatusConflict, gin.H{"error": "Variable already exists"})
+				return
+			case "foreign_key_violation":
+				c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+				return
+			}
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"variable": variable},
+	})
+}
+
+func (r *variableRepository) Update(c *gin.Context) {
+	scope := scopeOf(c)
+	scope.audit(c, "update")
+
+	var input models.UpdateVariable
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var variable models.Variable
+	query := fmt.Sprintf("select * from variables where id = $1 and %s = $2", scope.Column)
+	if err := r.DB.GetContext(r.Ctx, &variable, query, c.Param("variableID"), scope.ID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	// A secret's stored value is ciphertext; only encrypt a new one.
+	valueChanged := input.Value != nil
+	if valueChanged {
+		variable.Value = *input.Value
+	}
+	if input.IsBuildTime != nil {
+		variable.IsBuildTime = *input.IsBuildTime
+	}
+	if input.IsRuntime != nil {
+		variable.IsRuntime = *input.IsRuntime
+	}
+	if valueChanged {
+		if !r.prepare(c, &variable) {
+			return
+		}
+	} else if !variable.IsBuildTime && !variable.IsRuntime {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A variable must be available at build time, runtime or both"})
+		return
+	}
+
+	query = `
+		update variables
+		set value = $1, is_build_time = $2, is_runtime = $3, updated_at = now()
+		where id = $4
+		returning *
+	`
+	if err := r.DB.GetContext(r.Ctx, &variable, query, variable.Value, variable.IsBuildTime, variable.IsRuntime, variable.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"variable": variable},
+	})
+}
+
+func (r *variableRepository) Delete(c *gin.Context) {
+	scope := scopeOf(c)
+	scope.audit(c, "delete")
+
+	query := fmt.Sprintf("delete from variables where id = $1 and %s = $2", scope.Column)
+	result, err := r.DB.ExecContext(r.Ctx, query, c.Param("variableID"), scope.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if rows, _ := result.RowsAffected(); rows == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// Import adds or replaces every variable in a .env file, all with the same
+// flags.
+func (r *variableRepository) Import(c *gin.Context) {
+	scope := scopeOf(c)
+	scope.audit(c, "import")
+
+	var input models.ImportVariables
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	parsed, err := dotenv.Parse(input.Content)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	variables := make([]models.Variable, 0, len(parsed))
+	for _, pair := range parsed {
+		variable := models.Variable{
+			Key:         pair.Key,
+			Value:       pair.Value,
+			IsSecret:    input.IsSecret,
+			IsBuildTime: input.IsBuildTime != nil && *input.IsBuildTime,
+			IsRuntime:   input.IsRuntime == nil || *input.IsRuntime,
+			IsPreview:   input.IsPreview,
+		}
+		scope.assign(&variable)
+		if !r.prepare(c, &variable) {
+			return
+		}
+		variables = append(variables, variable)
+	}
+
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer tx.Rollback()
+
+	query := fmt.Sprintf(`
+		insert into variables (application_id, environment_id, key, value, is_secret, is_build_time, is_runtime, is_preview)
+		values ($1, $2, $3, $4, $5, $6, $7, $8)
+		on conflict (%s, key, is_preview) do update
+		set
+			value = excluded.value, is_secret = excluded.is_secret, is_build_time = excluded.is_build_time,
+			is_runtime = excluded.is_runtime, updated_at = now()
+		returning *
+	`, scope.Column)
+	for i := range variables {
+		variable := &variables[i]
+		if err := tx.GetContext(
+			r.Ctx,
+			variable,
+			query,
+			variable.ApplicationID,
+			variable.EnvironmentID,
+			variable.Key,
+			variable.Value,
+			variable.IsSecret,
+			variable.IsBuildTime,
+			variable.IsRuntime,
+			variable.IsPreview,
+		); err != nil {
+			var pqErr *pq.Error
+			if errors.As(err, &pqErr) && pqErr.Code.Name() == "foreign_key_violation" {
+				c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"variables": variables},
+	})
+}
+
+// Export writes the variables, or the preview ones with preview=true, as a
+// .env file. Secrets are listed without their values.
+func (r *variableRepository) Export(c *gin.Context) {
+	scope := scopeOf(c)
+	preview := c.Query("preview") == "true"
+
+	var variables []models.Variable
+	query := fmt.Sprintf("select * from variables where %s = $1 and is_preview = $2 order by key", scope.Column)
+	if err := r.DB.SelectContext(r.Ctx, &variables, query, scope.ID, preview); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	var plain []dotenv.Variable
+	var secretKeys []string
+	for _, variable := range variables {
+		if variable.IsSecret {
+			secretKeys = append(secretKeys, variable.Key)
+			continue
+		}
+		plain = append(plain, dotenv.Variable{Key: variable.Key, Value: variable.Value})
+	}
+
+	content := dotenv.Format(plain)
+	for _, key := range secretKeys {
+		content += fmt.Sprintf("# %s is a secret and isn't exported\n", key)
+	}
+
+	c.Header("Content-Disposition", `attachment; filename=".env"`)
+	c.Data(http.StatusOK, "text/plain; charset=utf-8", []byte(content))
+}
+
+// prepare validates variable and encrypts its value if it is a secret. On
+// failure it has already written the response.
+func (r *variableRepository) prepare(c *gin.Context, variable *models.Variable) bool {
+	if err := deploy.ValidateVariableKey(variable.Key); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	if !variable.IsBuildTime && !variable.IsRuntime {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A variable must be available at build time, runtime or both"})
+		return false
+	}
+	if strings.ContainsRune(variable.Value, 0) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": variable.Key + " contains a NUL byte"})
+		return false
+	}
+
+	if variable.IsSecret {
+		ciphertext, err := r.Secrets.Encrypt(variable.Value)
+		if err != nil {
+			if errors.Is(err, secrets.ErrNoKey) {
+				c.JSON(http.StatusServiceUnavailable, gin.H{"error": shared.ErrNoEncryptionKey})
+				return false
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+			return false
+		}
+		variable.Value = ciphertext
+	}
+
+	return true
+}
@@ -0,0 +1,376 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/storage"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/secrets"
+	"github.com/redis/go-redis/v9"
+)
+
+type VolumeSnapshotRepository interface {
+	FindSchedules(c *gin.Context)
+	CreateSchedule(c *gin.Context)
+	UpdateSchedule(c *gin.Context)
+	DeleteSchedule(c *gin.Context)
+	Create(c *gin.Context)
+	FindAll(c *gin.Context)
+	FindByID(c *gin.Context)
+	Delete(c *gin.Context)
+}
+
+type volumeSnapshotRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Secrets     *secrets.Cipher
+	Ctx         context.Context
+}
+
+func NewVolumeSnapshotRepository(db *sqlx.DB, redisClient *redis.Client, secretCipher *secrets.Cipher, ctx context.Context) *volumeSnapshotRepository {
+	return &volumeSnapshotRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Secrets:     secretCipher,
+		Ctx:         ctx,
+	}
+}
+
+func (r *volumeSnapshotRepository) FindSchedules(c *gin.Context) {
+	volumeID, ok := r.findVolume(c)
+	if !ok {
+		return
+	}
+
+	var schedules []models.VolumeSnapshotSchedule = []models.VolumeSnapshotSchedule{}
+	query := "select * from volume_snapshot_schedules where volume_id = $1 order by created_at"
+	if err := r.DB.SelectContext(r.Ctx, &schedules, query, volumeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"schedules": schedules},
+	})
+}
+
+func (r *volumeSnapshotRepository) CreateSchedule(c *gin.Context) {
+	audit.Action(c, "application.volume_snapshot_schedule.create")
+
+	var input models.CreateBackupSchedule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	volumeID, ok := r.findVolume(c)
+	if !ok {
+		return
+	}
+
+	nextRunAt, ok := nextRun(c, input.Cron)
+	if !ok {
+		return
+	}
+
+	schedule := models.VolumeSnapshotSchedule{
+		VolumeID:      volumeID,
+		DestinationID: input.DestinationID,
+		Cron:          strings.TrimSpace(input.Cron),
+		KeepDaily:     7,
+		KeepWeekly:    4,
+		Enabled:       input.Enabled == nil || *input.Enabled,
+		NextRunAt:     nextRunAt,
+	}
+	if input.KeepDaily != nil {
+		schedule.KeepDaily = *input.KeepDaily
+	}
+	if input.KeepWeekly != nil {
+		schedule.KeepWeekly = *input.KeepWeekly
+	}
+
+	query := `
+		insert into volume_snapshot_schedules (
+			volume_id, destination_id, cron, keep_daily, keep_weekly, enabled, next_run_at
+		) values (
+			$1, $2, $3, $4, $5, $6, $7
+		)
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		&schedule,
+		query,
+		schedule.VolumeID,
+		schedule.DestinationID,
+		schedule.Cron,
+		schedule.KeepDaily,
+		schedule.KeepWeekly,
+		schedule.Enabled,
+		schedule.NextRunAt,
+	); err != nil {
+		writeBackupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"schedule": schedule},
+	})
+}
+
+func (r *volumeSnapshotRepository) UpdateSchedule(c *gin.Context) {
+	audit.Action(c, "application.volume_snapshot_schedule.update")
+
+	var input models.UpdateBackupSchedule
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	schedule, ok := r.findSchedule(c)
+	if !ok {
+		return
+	}
+
+	if input.Cron != nil {
+		if schedule.NextRunAt, ok = nextRun(c, *input.Cron); !ok {
+			return
+		}
+		schedule.Cron = strings.TrimSpace(*input.Cron)
+	}
+	if input.DestinationID != nil {
+		schedule.DestinationID = *input.DestinationID
+	}
+	if input.KeepDaily != nil {
+		schedule.KeepDaily = *input.KeepDaily
+	}
+	if input.KeepWeekly != nil {
+		schedule.KeepWeekly = *input.KeepWeekly
+	}
+	if input.Enabled != nil {
+		// A schedule turned back on starts from now rather than catching up.
+		if *input.Enabled && !schedule.Enabled && input.Cron == nil {
+			if schedule.NextRunAt, ok = nextRun(c, schedule.Cron); !ok {
+				return
+			}
+		}
+		schedule.Enabled = *input.Enabled
+	}
+
+	query := `
+		update volume_snapshot_schedules
+		set destination_id = $1, cron = $2, keep_daily = $3, keep_weekly = $4, enabled = $5, next_run_at = $6, updated_at = now()
+		where id = $7
+		returning *
+	`
+	if err := r.DB.GetContext(
+		r.Ctx,
+		schedule,
+		query,
+		schedule.DestinationID,
+		schedule.Cron,
+		schedule.KeepDaily,
+		schedule.KeepWeekly,
+		schedule.Enabled,
+		schedule.NextRunAt,
+		schedule.ID,
+	); err != nil {
+		writeBackupError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"schedule": schedule},
+	})
+}
+
+// DeleteSchedule removes a schedule. The snapshots it made are kept, and no
+// longer pruned.
+func (r *volumeSnapshotRepository) DeleteSchedule(c *gin.Context) {
+	audit.Action(c, "application.volume_snapshot_schedule.delete")
+
+	schedule, ok := r.findSchedule(c)
+	if !ok {
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from volume_snapshot_schedules where id = $1", schedule.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// Create queues a snapshot of the volume now. Snapshots taken this way are
+// never pruned by retention.
+func (r *volumeSnapshotRepository) Create(c *gin.Context) {
+	audit.Action(c, "application.volume_snapshot.create")
+
+	var input models.CreateVolumeSnapshot
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	volumeID, ok := r.findVolume(c)
+	if !ok {
+		return
+	}
+
+	snapshot, err := workers.QueueVolumeSnapshot(r.DB, r.RedisClient, r.Ctx, volumeID, input.DestinationID, nil)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Storage destination not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"message": "OK",
+		"data":    gin.H{"snapshot": snapshot},
+	})
+}
+
+// FindAll lists the volume's snapshots, newest first.
+func (r *volumeSnapshotRepository) FindAll(c *gin.Context) {
+	volumeID, ok := r.findVolume(c)
+	if !ok {
+		return
+	}
+
+	var snapshots []models.VolumeSnapshot = []models.VolumeSnapshot{}
+	query := "select * from volume_snapshots where volume_id = $1 order by created_at desc"
+	if err := r.DB.SelectContext(r.Ctx, &snapshots, query, volumeID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"snapshots": snapshots},
+	})
+}
+
+func (r *volumeSnapshotRepository) FindByID(c *gin.Context) {
+	snapshot, ok := r.findSnapshot(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"snapshot": snapshot},
+	})
+}
+
+// Delete removes a finished snapshot from storage and then its record.
+func (r *volumeSnapshotRepository) Delete(c *gin.Context) {
+	audit.Action(c, "volume_snapshot.delete")
+
+	snapshot, ok := r.findSnapshot(c)
+	if !ok {
+		return
+	}
+	if snapshot.Status == models.BackupPending || snapshot.Status == models.BackupRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "The snapshot is " + snapshot.Status + ". Please wait."})
+		return
+	}
+
+	_, client, err := storage.Load(r.Ctx, r.DB, r.Secrets, snapshot.DestinationID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Ctx, time.Minute)
+	defer cancel()
+
+	if err := client.Delete(ctx, snapshot.ObjectKey); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": "Failed to delete the snapshot from storage: " + err.Error()})
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from volume_snapshots where id = $1", snapshot.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// findVolume checks that :volumeID belongs to the :applicationID application
+// and returns its id. On failure it has already written the response.
+func (r *volumeSnapshotRepository) findVolume(c *gin.Context) (string, bool) {
+	applicationID := c.Param("applicationID")
+	audit.Target(c, "application", applicationID)
+
+	var volumeID string
+	query := "select id from volumes where id = $1 and application_id = $2"
+	if err := r.DB.GetContext(r.Ctx, &volumeID, query, c.Param("volumeID"), applicationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return "", false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return "", false
+	}
+
+	return volumeID, true
+}
+
+// findSchedule loads the :volumeID volume's :scheduleID schedule. On failure
+// it has already written the response.
+func (r *volumeSnapshotRepository) findSchedule(c *gin.Context) (*models.VolumeSnapshotSchedule, bool) {
+	volumeID, ok := r.findVolume(c)
+	if !ok {
+		return nil, false
+	}
+
+	var schedule models.VolumeSnapshotSchedule
+	query := "select * from volume_snapshot_schedules where id = $1 and volume_id = $2"
+	if err := r.DB.GetContext(r.Ctx, &schedule, query, c.Param("scheduleID"), volumeID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &schedule, true
+}
+
+// findSnapshot loads the :snapshotID snapshot. On failure it has already
+// written the response.
+func (r *volumeSnapshotRepository) findSnapshot(c *gin.Context) (*models.VolumeSnapshot, bool) {
+	snapshotID := c.Param("snapshotID")
+	audit.Target(c, "volume_snapshot", snapshotID)
+
+	var snapshot models.VolumeSnapshot
+	if err := r.DB.GetContext(r.Ctx, &snapshot, "select * from volume_snapshots where id = $1", snapshotID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &snapshot, true
+}
@@ -0,0 +1,383 @@
+package api
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/volumes"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/redis/go-redis/v9"
+)
+
+type VolumeRepository interface {
+	FindAll(c *gin.Context)
+	Create(c *gin.Context)
+	Update(c *gin.Context)
+	Delete(c *gin.Context)
+	Usage(c *gin.Context)
+}
+
+type volumeRepository struct {
+	DB          *sqlx.DB
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewVolumeRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *volumeRepository {
+	return &volumeRepository{
+		DB:          db,
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (r *volumeRepository) FindAll(c *gin.Context) {
+	var volumes []models.Volume = []models.Volume{}
+	query := "select * from volumes where application_id = $1 order by created_at"
+	if err := r.DB.SelectContext(r.Ctx, &volumes, query, c.Param("applicationID")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"volumes": volumes},
+	})
+}
+
+// Create adds a volume and creates it on the application's server. It is
+// mounted from the next deployment on.
+func (r *volumeRepository) Create(c *gin.Context) {
+	audit.Action(c, "application.volume.create")
+
+	var input models.CreateVolume
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+
+	volume := models.Volume{
+		ApplicationID: application.ID,
+		Name:          strings.TrimSpace(input.Name),
+		Type:          input.Type,
+		MountPath:     input.MountPath,
+		ReadOnly:      input.ReadOnly,
+	}
+	if input.HostPath != "" {
+		volume.HostPath = &input.HostPath
+	}
+	if input.Service != "" {
+		volume.Service = &input.Service
+	}
+	if !validVolume(c, application, &volume) {
+		return
+	}
+
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		insert into volumes (application_id, name, type, host_path, mount_path, service, read_only)
+		values ($1, $2, $3, $4, $5, $6, $7)
+		returning *
+	`
+	if err := tx.GetContext(
+		r.Ctx,
+		&volume,
+		query,
+		volume.ApplicationID,
+		volume.Name,
+		volume.Type,
+		volume.HostPath,
+		volume.MountPath,
+		volume.Service,
+		volume.ReadOnly,
+	); err != nil {
+		writeVolumeError(c, err)
+		return
+	}
+
+	// The record is only kept once the volume exists on the server.
+	sshClient, err := workers.ConnectServer(r.Ctx, r.DB, application.ServerID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSSHConnection})
+		return
+	}
+	defer sshClient.Close()
+
+	if err := volumes.Ensure(sshClient, []models.Volume{volume}); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"message": "OK",
+		"data":    gin.H{"volume": volume},
+	})
+}
+
+// Update renames a volume or changes where it is mounted, which takes
+// effect with the next deployment. A bind mount's new host path moves its
+// directory on the server right away.
+func (r *volumeRepository) Update(c *gin.Context) {
+	audit.Action(c, "application.volume.update")
+
+	var input models.UpdateVolume
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+	volume, ok := r.findVolume(c)
+	if !ok {
+		return
+	}
+
+	previousHostPath := volume.HostPath
+	if input.Name != nil {
+		volume.Name = strings.TrimSpace(*input.Name)
+	}
+	if input.HostPath != nil {
+		volume.HostPath = input.HostPath
+	}
+	if input.MountPath != nil {
+		volume.MountPath = *input.MountPath
+	}
+	if input.Service != nil {
+		volume.Service = input.Service
+	}
+	if input.ReadOnly != nil {
+		volume.ReadOnly = *input.ReadOnly
+	}
+	if !validVolume(c, application, volume) {
+		return
+	}
+
+	tx, err := r.DB.BeginTxx(r.Ctx, nil)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	defer tx.Rollback()
+
+	query := `
+		update volumes
+		set name = $1, host_path = $2, mount_path = $3, service = $4, read_only = $5, updated_at = now()
+		where id = $6
+		returning *
+	`
+	if err := tx.GetContext(
+		r.Ctx,
+		volume,
+		query,
+		volume.Name,
+		volume.HostPath,
+		volume.MountPath,
+		volume.Service,
+		volume.ReadOnly,
+		volume.ID,
+	); err != nil {
+		writeVolumeError(c, err)
+		return
+	}
+
+	if volume.Type == models.VolumeTypeBind && *volume.HostPath != *previousHostPath {
+		sshClient, err := workers.ConnectServer(r.Ctx, r.DB, application.ServerID)
+		if err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSSHConnection})
+			return
+		}
+		defer sshClient.Close()
+
+		if err := volumes.Move(sshClient, *previousHostPath, *volume.HostPath); err != nil {
+			c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"volume": volume},
+	})
+}
+
+// Delete removes a volume from the application, and a named volume's data
+// from the server unless a container still uses it, in which case the next
+// deployment removes it. Bind mounted directories are left on the server.
+func (r *volumeRepository) Delete(c *gin.Context) {
+	audit.Action(c, "application.volume.delete")
+
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+	volume, ok := r.findVolume(c)
+	if !ok {
+		return
+	}
+
+	if _, err := r.DB.ExecContext(r.Ctx, "delete from volumes where id = $1", volume.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if volume.Type == models.VolumeTypeVolume {
+		if sshClient, err := workers.ConnectServer(r.Ctx, r.DB, application.ServerID); err == nil {
+			sshClient.RunCommand(volumes.RemoveCommand(volume.ID))
+			sshClient.Close()
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "OK"})
+}
+
+// Usage reports how much disk each of the application's volumes takes on
+// its server.
+func (r *volumeRepository) Usage(c *gin.Context) {
+	application, ok := r.findApplication(c)
+	if !ok {
+		return
+	}
+
+	var list []models.Volume
+	query := "select * from volumes where application_id = $1 order by created_at"
+	if err := r.DB.SelectContext(r.Ctx, &list, query, application.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	sshClient, err := workers.ConnectServer(r.Ctx, r.DB, application.ServerID)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSSHConnection})
+		return
+	}
+	defer sshClient.Close()
+
+	usage, err := volumes.Usage(sshClient, list)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "OK",
+		"data":    gin.H{"usage": usage},
+	})
+}
+
+// findApplication loads the :applicationID application. On failure it has
+// already written the response.
+func (r *volumeRepository) findApplication(c *gin.Context) (*models.Application, bool) {
+	applicationID := c.Param("applicationID")
+	audit.Target(c, "application", applicationID)
+
+	var application models.Application
+	if err := r.DB.GetContext(r.Ctx, &application, "select * from applications where id = $1", applicationID); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &application, true
+}
+
+// findVolume loads the :applicationID application's :volumeID volume. On
+// failure it has already written the response.
+func (r *volumeRepository) findVolume(c *gin.Context) (*models.Volume, bool) {
+	var volume models.Volume
+	query := "select * from volumes where id = $1 and application_id = $2"
+	if err := r.DB.GetContext(r.Ctx, &volume, query, c.Param("volumeID"), c.Param("applicationID")); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return nil, false
+	}
+
+	return &volume, true
+}
+
+// validVolume checks what the binding tags can't: paths, and that compose
+// applications, and only they, name the service to mount into. On failure
+// it has already written the response.
+func validVolume(c *gin.Context, application *models.Application, volume *models.Volume) bool {
+	if volume.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A name is required"})
+		return false
+	}
+	if !volumes.ValidPath(volume.MountPath) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "mountPath must be an absolute path without commas or colons"})
+		return false
+	}
+
+	if volume.Type == models.VolumeTypeBind {
+		if volume.HostPath == nil || !volumes.ValidPath(*volume.HostPath) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "hostPath must be an absolute path without commas or colons"})
+			return false
+		}
+	} else if volume.HostPath != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hostPath requires the bind type"})
+		return false
+	}
+
+	if application.BuildType == models.BuildTypeCompose {
+		if volume.Service == nil || strings.TrimSpace(*volume.Service) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Compose applications need a service"})
+			return false
+		}
+	} else if volume.Service != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "service requires the compose build type"})
+		return false
+	}
+
+	return true
+}
+
+func writeVolumeError(c *gin.Context, err error) {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code.Name() == "unique_violation" {
+		if strings.Contains(pqErr.Constraint, "mount") {
+			c.JSON(http.StatusConflict, gin.H{"error": "Another volume is mounted there"})
+			return
+		}
+		c.JSON(http.StatusConflict, gin.H{"error": "Volume name is already taken"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+}
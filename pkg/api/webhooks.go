@@ -2,36 +2,66 @@ package api
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"github.com/jmoiron/sqlx"
 	"github.com/mohit4bug/mo-sh/internal/models"
+	"github.com/mohit4bug/mo-sh/internal/shared"
+	"github.com/mohit4bug/mo-sh/internal/sources"
+	"github.com/mohit4bug/mo-sh/internal/workers"
+	"github.com/mohit4bug/mo-sh/pkg/audit"
+	"github.com/mohit4bug/mo-sh/pkg/gitea"
+	"github.com/mohit4bug/mo-sh/pkg/github"
+	"github.com/mohit4bug/mo-sh/pkg/gitlab"
+	"github.com/mohit4bug/mo-sh/pkg/settings"
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// GitHub caps webhook payloads at 25 MB.
+	maxGithubWebhookPayload = 25 << 20
+
+	// GitLab's default limit for webhook payloads.
+	maxGitlabWebhookPayload = 25 << 20
+
+	maxGiteaWebhookPayload = 25 << 20
+)
+
 type WebhookRepository interface {
 	HandleGithubRedirect(c *gin.Context)
+	HandleGithubSetup(c *gin.Context)
+	HandleGithubEvent(c *gin.Context)
+	HandleGitlabRedirect(c *gin.Context)
+	HandleGitlabEvent(c *gin.Context)
+	HandleGiteaEvent(c *gin.Context)
 }
 
 type webhookRepository struct {
 	DB          *sqlx.DB
 	RedisClient *redis.Client
+	Settings    settings.Store
 	Ctx         context.Context
 }
 
-func NewWebhookRepository(db *sqlx.DB, redisClient *redis.Client, ctx context.Context) *webhookRepository {
+func NewWebhookRepository(db *sqlx.DB, redisClient *redis.Client, settingsStore settings.Store, ctx context.Context) *webhookRepository {
 	return &webhookRepository{
 		DB:          db,
 		RedisClient: redisClient,
+		Settings:    settingsStore,
 		Ctx:         ctx,
 	}
 }
 
 func (r *webhookRepository) HandleGithubRedirect(c *gin.Context) {
+	audit.Action(c, "github_app.register")
+
 	state := c.Query("state")
 	code := c.Query("code")
 
@@ -50,135 +80,425 @@ func (r *webhookRepository) HandleGithubRedirect(c *gin.Context) {
 		return
 	}
 
+	audit.Target(c, "source", sourceID)
+
 	if err = r.RedisClient.Del(r.Ctx, state).Err(); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	url := fmt.Sprintf("https://api.github.com/app-manifests/%s/conversions", code)
-	req, err := http.NewRequest("POST", url, nil)
+	var apiURL string
+	if err := r.DB.QueryRowContext(r.Ctx, "select api_url from sources where id = $1", sourceID).Scan(&apiURL); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	app, err := github.ConvertManifest(r.Ctx, apiURL, code)
 	if err != nil {
+		var apiErr *github.APIError
+		switch {
+		case github.IsNotFound(err):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+		case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusUnprocessableEntity:
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed, or the endpoint has been spammed."})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		}
+		return
+	}
+
+	if err := storeGithubApp(r.Ctx, r.DB, sourceID, app); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	req.Header.Set("Accept", "application/vnd.github+json")
-	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
-	req.Header.Set("User-Agent", "Mo-SH")
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.Redirect(http.StatusFound, instanceSettings.UIURL+"/sources/"+sourceID)
+}
 
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
+// storeGithubApp records the app created for sourceID, with its private key
+// as an external key.
+func storeGithubApp(ctx context.Context, db *sqlx.DB, sourceID string, app *github.App) error {
+	permissionsJSON, err := json.Marshal(app.Permissions)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return err
+	}
+
+	eventsJSON, err := json.Marshal(app.Events)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var keyID string
+	if err = tx.QueryRowContext(
+		ctx,
+		`INSERT INTO keys (name, key, is_external) VALUES ($1, $2, $3) RETURNING id`,
+		fmt.Sprintf("gh-%s", app.Name),
+		app.PEM,
+		true,
+	).Scan(&keyID); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(
+		ctx,
+		`INSERT INTO github_apps (
+			app_id, slug, client_id, node_id, owner, name, description, external_url,
+			html_url, created_at, updated_at, permissions, events, source_id,
+			client_secret, webhook_secret, key_id
+		) VALUES (
+			$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17
+		)`,
+		app.ID,
+		app.Slug,
+		app.ClientID,
+		app.NodeID,
+		[]byte(app.Owner),
+		app.Name,
+		app.Description,
+		app.ExternalURL,
+		app.HTMLURL,
+		app.CreatedAt,
+		app.UpdatedAt,
+		permissionsJSON,
+		eventsJSON,
+		sourceID,
+		app.ClientSecret,
+		app.WebhookSecret,
+		keyID,
+	); err != nil {
+		return err
+	}
+
+	if _, err = tx.ExecContext(ctx, `update sources set has_github_app = true where id = $1`, sourceID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// HandleGithubSetup is where GitHub sends the browser after the app has been
+// installed on an account or its repository access changed.
+func (r *webhookRepository) HandleGithubSetup(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "github_app.install")
+	audit.Target(c, "source", sourceID)
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
 		return
 	}
-	defer resp.Body.Close()
 
-	switch resp.StatusCode {
-	case http.StatusNotFound:
-		c.JSON(http.StatusNotFound, gin.H{"error": "Resource not found"})
+	clientURL := instanceSettings.UIURL + "/sources/" + sourceID
+
+	// Members without permission to install only request it; there is no
+	// installation yet until an owner approves.
+	if c.Query("setup_action") == "request" {
+		c.Redirect(http.StatusFound, clientURL)
 		return
-	case http.StatusUnprocessableEntity:
-		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "Validation failed, or the endpoint has been spammed."})
+	}
+
+	installationID, err := strconv.ParseInt(c.Query("installation_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid installation_id"})
 		return
-	case http.StatusCreated:
-		var githubAppResponse models.GithubAppResponse
-		if err := json.NewDecoder(resp.Body).Decode(&githubAppResponse); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+
+	client, err := github.LoadClient(r.DB, r.RedisClient, r.Ctx, sourceID)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
 
-		ownerJSON, err := json.Marshal(githubAppResponse.Owner)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	// installation_id comes from the query string, so it is only trusted once
+	// GitHub confirms it belongs to this app.
+	if err := sources.SyncGithubInstallation(r.Ctx, r.DB, client, installationID); err != nil {
+		if github.IsNotFound(err) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
 			return
 		}
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrGithubUnavailable})
+		return
+	}
+
+	c.Redirect(http.StatusFound, clientURL)
+}
+
+func (r *webhookRepository) HandleGithubEvent(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "github.webhook.receive")
+	audit.Target(c, "source", sourceID)
 
-		permissionsJSON, err := json.Marshal(githubAppResponse.Permissions)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	event := c.GetHeader("X-GitHub-Event")
+	deliveryID := c.GetHeader("X-GitHub-Delivery")
+	if event == "" || deliveryID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing X-GitHub-Event or X-GitHub-Delivery header"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGithubWebhookPayload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Couldn't read request body"})
+		return
+	}
+
+	var webhookSecret string
+	if err := r.DB.QueryRowContext(r.Ctx, "select webhook_secret from github_apps where source_id = $1", sourceID).Scan(&webhookSecret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if !github.VerifySignature(webhookSecret, body, c.GetHeader("X-Hub-Signature-256")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	var action *string
+	if value := github.EventAction(body); value != "" {
+		action = &value
+	}
+
+	// GitHub redelivers on timeouts and manual retries; the unique delivery id
+	// makes sure each delivery is only handled once.
+	query := `
+		insert into github_webhook_deliveries (source_id, delivery_id, event, action, payload)
+		values ($1, $2, $3, $4, $5)
+		on conflict (delivery_id) do nothing
+	`
+	result, err := r.DB.ExecContext(r.Ctx, query, sourceID, deliveryID, event, action, string(body))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	inserted, err := result.RowsAffected()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+	if inserted == 0 {
+		c.JSON(http.StatusOK, gin.H{"message": "Duplicate delivery ignored."})
+		return
+	}
 
-		eventsJSON, err := json.Marshal(githubAppResponse.Events)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	if err := r.RedisClient.LPush(r.Ctx, workers.GithubWebhookPendingQueue, deliveryID).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "OK"})
+}
+
+// HandleGitlabRedirect is where GitLab sends the browser after the source's
+// OAuth application has been authorized.
+func (r *webhookRepository) HandleGitlabRedirect(c *gin.Context) {
+	audit.Action(c, "gitlab.authorize")
+
+	state := c.Query("state")
+	code := c.Query("code")
+	if state == "" || code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Bad request"})
+		return
+	}
+
+	sourceID, err := r.RedisClient.GetDel(r.Ctx, gitlabOAuthStatePrefix+state).Result()
+	if err != nil {
+		if err == redis.Nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	audit.Target(c, "source", sourceID)
 
-		tx, err := r.DB.BeginTx(r.Ctx, nil)
-		if err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var connection struct {
+		HTMLURL      string `db:"html_url"`
+		ClientID     string `db:"client_id"`
+		ClientSecret string `db:"client_secret"`
+		RedirectURL  string `db:"redirect_url"`
+	}
+	query := `
+		select
+			s.html_url, gc.client_id, gc.client_secret, gc.redirect_url
+		from
+			gitlab_connections gc
+		inner join
+			sources s ON s.id = gc.source_id
+		where
+			gc.source_id = $1 and gc.auth_type = $2
+	`
+	if err := r.DB.GetContext(r.Ctx, &connection, query, sourceID, models.GitlabAuthOAuth); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
 			return
 		}
-		defer tx.Rollback()
-
-		var keyID string
-		if err = tx.QueryRowContext(
-			r.Ctx,
-			`INSERT INTO keys (name, key, is_external) VALUES ($1, $2, $3) RETURNING id`,
-			fmt.Sprintf("gh-%s", githubAppResponse.Name),
-			githubAppResponse.PEM,
-			true,
-		).Scan(&keyID); err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	token, err := gitlab.ExchangeCode(r.Ctx, connection.HTMLURL, connection.ClientID, connection.ClientSecret, connection.RedirectURL, code)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": shared.ErrSourceUnavailable})
+		return
+	}
+
+	if err := sources.SaveGitlabToken(r.Ctx, r.DB, sourceID, token); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	instanceSettings, err := r.Settings.Get()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	c.Redirect(http.StatusFound, instanceSettings.UIURL+"/sources/"+sourceID)
+}
+
+func (r *webhookRepository) HandleGitlabEvent(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "gitlab.webhook.receive")
+	audit.Target(c, "source", sourceID)
+
+	event := c.GetHeader("X-Gitlab-Event")
+	if event == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing X-Gitlab-Event header"})
+		return
+	}
+
+	var webhookSecret string
+	if err := r.DB.QueryRowContext(r.Ctx, "select webhook_secret from gitlab_connections where source_id = $1", sourceID).Scan(&webhookSecret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
+
+	if !gitlab.VerifyToken(webhookSecret, c.GetHeader("X-Gitlab-Token")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGitlabWebhookPayload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Couldn't read request body"})
+		return
+	}
+
+	// Retries carry the same Idempotency-Key; older GitLab versions only send
+	// a per-attempt event UUID.
+	deliveryID := c.GetHeader("Idempotency-Key")
+	if deliveryID == "" {
+		deliveryID = c.GetHeader("X-Gitlab-Event-UUID")
+	}
+	if deliveryID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing Idempotency-Key or X-Gitlab-Event-UUID header"})
+		return
+	}
+
+	r.saveSourceDelivery(c, sourceID, deliveryID, event, body)
+}
 
-		_, err = tx.ExecContext(
-			r.Ctx,
-			`INSERT INTO github_apps (
-				slug, client_id, node_id, owner, name, description, external_url, 
-				html_url, created_at, updated_at, permissions, events, source_id, 
-				client_secret, webhook_secret, key_id
-			) VALUES (
-				$1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16
-			)`,
-			githubAppResponse.Slug,
-			githubAppResponse.ClientID,
-			githubAppResponse.NodeID,
-			ownerJSON,
-			githubAppResponse.Name,
-			githubAppResponse.Description,
-			githubAppResponse.ExternalURL,
-			githubAppResponse.HTMLURL,
-			githubAppResponse.CreatedAt,
-			githubAppResponse.UpdatedAt,
-			permissionsJSON,
-			eventsJSON,
-			sourceID,
-			githubAppResponse.ClientSecret,
-			githubAppResponse.WebhookSecret,
-			keyID,
-		)
-		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// HandleGiteaEvent receives webhooks from Gitea and Forgejo. Forgejo sends
+// both its own headers and the Gitea ones.
+func (r *webhookRepository) HandleGiteaEvent(c *gin.Context) {
+	sourceID := c.Param("sourceID")
+	audit.Action(c, "gitea.webhook.receive")
+	audit.Target(c, "source", sourceID)
+
+	event := firstHeader(c, "X-Gitea-Event", "X-Forgejo-Event")
+	deliveryID := firstHeader(c, "X-Gitea-Delivery", "X-Forgejo-Delivery")
+	if event == "" || deliveryID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Missing X-Gitea-Event or X-Gitea-Delivery header"})
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxGiteaWebhookPayload))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Couldn't read request body"})
+		return
+	}
+
+	var webhookSecret string
+	if err := r.DB.QueryRowContext(r.Ctx, "select webhook_secret from gitea_connections where source_id = $1", sourceID).Scan(&webhookSecret); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": shared.ErrNotFound})
 			return
 		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
+		return
+	}
 
-		_, err = tx.ExecContext(
-			r.Ctx,
-			`update sources set has_github_app = true where id = $1`,
-			sourceID,
-		)
-		if err != nil {
-			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
-			return
+	if !gitea.VerifySignature(webhookSecret, body, firstHeader(c, "X-Gitea-Signature", "X-Forgejo-Signature")) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid signature"})
+		return
+	}
+
+	r.saveSourceDelivery(c, sourceID, deliveryID, event, body)
+}
+
+func firstHeader(c *gin.Context, names ...string) string {
+	for _, name := range names {
+		if value := c.GetHeader(name); value != "" {
+			return value
 		}
+	}
+	return ""
+}
 
-		if err = tx.Commit(); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+// saveSourceDelivery stores a verified delivery from a non-GitHub source once
+// and queues it for the source webhook worker.
+func (r *webhookRepository) saveSourceDelivery(c *gin.Context, sourceID, deliveryID, event string, body []byte) {
+	query := `
+		insert into source_webhook_deliveries (source_id, delivery_id, event, payload)
+		values ($1, $2, $3, $4)
+		on conflict (source_id, delivery_id) do nothing
+		returning id
+	`
+
+	var id string
+	if err := r.DB.GetContext(r.Ctx, &id, query, sourceID, deliveryID, event, string(body)); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusOK, gin.H{"message": "Duplicate delivery ignored."})
 			return
 		}
-
-		clientURL := "http://localhost:3000/sources/" + sourceID
-		c.Redirect(http.StatusFound, clientURL)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
 		return
-	default:
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+	}
+
+	if err := r.RedisClient.LPush(r.Ctx, workers.SourceWebhookPendingQueue, id).Err(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": shared.ErrInternalServer})
 		return
 	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "OK"})
 }
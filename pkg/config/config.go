@@ -0,0 +1,238 @@
+package config
+
+import (
+	"bufio"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RateLimitConfig struct {
+	// Requests allowed per IP on the login and register endpoints.
+	AuthIPLimit  int
+	AuthIPWindow time.Duration
+
+	// Login attempts allowed per account, regardless of source IP. A limit of
+	// 0 disables it.
+	LoginAccountLimit  int
+	LoginAccountWindow time.Duration
+
+	// Failed logins before an account is locked. Every further failure doubles
+	// the lockout, starting at LockoutBase and capped at LockoutMax.
+	LockoutThreshold int
+	LockoutBase      time.Duration
+	LockoutMax       time.Duration
+
+	// Requests allowed per user (or per IP when signed out) on the rest of the
+	// API. A limit of 0 disables it.
+	UserLimit  int
+	UserWindow time.Duration
+}
+
+type SMTPEncryption string
+
+const (
+	SMTPEncryptionNone     SMTPEncryption = "none"
+	SMTPEncryptionStartTLS SMTPEncryption = "starttls"
+	SMTPEncryptionTLS      SMTPEncryption = "tls"
+)
+
+// Defaults match the MailHog container in docker-compose.yml.
+type SMTPConfig struct {
+	Host       string
+	Port       int
+	Username   string
+	Password   string
+	From       string
+	Encryption SMTPEncryption
+}
+
+// OIDCGroupMapping grants Role on TeamID to users whose ID token lists Group.
+type OIDCGroupMapping struct {
+	Group  string
+	TeamID string
+	Role   string
+}
+
+type OIDCConfig struct {
+	// Login with OIDC is disabled when no issuer is set.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	Scopes       []string
+
+	GroupsClaim   string
+	GroupMappings []OIDCGroupMapping
+}
+
+type Config struct {
+	// Public base URL of this API and of the web UI. These are only defaults;
+	// admins can override them at runtime through instance settings.
+	APIURL string
+	UIURL  string
+
+	// Events the GitHub App subscribes to when it is registered.
+	GithubAppEvents []string
+
+	// Create a local user on first single sign-on (OIDC or GitHub) when no
+	// account matches the email.
+	SSOAutoProvision bool
+
+	// Proxies, as IPs or CIDRs, whose X-Forwarded-For header is believed for
+	// the client's IP. With none, the connection's address is used, so that
+	// clients can't pick the IP that per-IP rate limits count.
+	TrustedProxies []string
+
+	// Encrypts secrets, such as secret environment variables, stored in the
+	// database. Changing it makes existing secrets unreadable.
+	EncryptionKey string
+
+	RateLimit RateLimitConfig
+	SMTP      SMTPConfig
+	OIDC      OIDCConfig
+}
+
+// Load reads the configuration from environment variables, falling back to
+// KEY=VALUE lines in the file named by MO_SH_CONFIG_FILE (mo-sh.env by default).
+func Load() *Config {
+	fileValues = readConfigFile(getEnv("MO_SH_CONFIG_FILE", "mo-sh.env"))
+
+	return &Config{
+		APIURL: strings.TrimSuffix(getEnv("API_URL", "http://localhost:8000"), "/"),
+		UIURL:  strings.TrimSuffix(getEnv("UI_URL", "http://localhost:3000"), "/"),
+
+		GithubAppEvents: strings.Split(getEnv("GITHUB_APP_EVENTS", "push,pull_request"), ","),
+
+		SSOAutoProvision: getEnvBool("SSO_AUTO_PROVISION", false),
+
+		TrustedProxies: getEnvList("TRUSTED_PROXIES"),
+
+		EncryptionKey: getEnv("ENCRYPTION_KEY", ""),
+
+		RateLimit: RateLimitConfig{
+			AuthIPLimit:        getEnvInt("RATE_LIMIT_AUTH_IP_LIMIT", 20),
+			AuthIPWindow:       getEnvDuration("RATE_LIMIT_AUTH_IP_WINDOW", time.Minute),
+			LoginAccountLimit:  getEnvInt("RATE_LIMIT_LOGIN_ACCOUNT_LIMIT", 10),
+			LoginAccountWindow: getEnvDuration("RATE_LIMIT_LOGIN_ACCOUNT_WINDOW", 15*time.Minute),
+			LockoutThreshold:   getEnvInt("RATE_LIMIT_LOCKOUT_THRESHOLD", 5),
+			LockoutBase:        getEnvDuration("RATE_LIMIT_LOCKOUT_BASE", time.Minute),
+			LockoutMax:         getEnvDuration("RATE_LIMIT_LOCKOUT_MAX", time.Hour),
+			UserLimit:          getEnvInt("RATE_LIMIT_USER_LIMIT", 300),
+			UserWindow:         getEnvDuration("RATE_LIMIT_USER_WINDOW", time.Minute),
+		},
+		SMTP: SMTPConfig{
+			Host:       getEnv("SMTP_HOST", "localhost"),
+			Port:       getEnvInt("SMTP_PORT", 1025),
+			Username:   getEnv("SMTP_USERNAME", ""),
+			Password:   getEnv("SMTP_PASSWORD", ""),
+			From:       getEnv("SMTP_FROM", "Mo-SH <no-reply@mo-sh.local>"),
+			Encryption: SMTPEncryption(getEnv("SMTP_ENCRYPTION", string(SMTPEncryptionNone))),
+		},
+		OIDC: OIDCConfig{
+			Issuer:        getEnv("OIDC_ISSUER", ""),
+			ClientID:      getEnv("OIDC_CLIENT_ID", ""),
+			ClientSecret:  getEnv("OIDC_CLIENT_SECRET", ""),
+			Scopes:        strings.Fields(getEnv("OIDC_SCOPES", "openid email profile")),
+			GroupsClaim:   getEnv("OIDC_GROUPS_CLAIM", "groups"),
+			GroupMappings: parseGroupMappings(getEnv("OIDC_GROUP_MAPPINGS", "")),
+		},
+	}
+}
+
+var fileValues map[string]string
+
+func readConfigFile(path string) map[string]string {
+	values := make(map[string]string)
+
+	file, err := os.Open(path)
+	if err != nil {
+		return values
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		key, value, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		values[strings.TrimSpace(key)] = strings.Trim(strings.TrimSpace(value), `"'`)
+	}
+
+	return values
+}
+
+func getEnv(key, fallback string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	if value, ok := fileValues[key]; ok && value != "" {
+		return value
+	}
+	return fallback
+}
+
+func getEnvInt(key string, fallback int) int {
+	value, err := strconv.Atoi(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+func getEnvBool(key string, fallback bool) bool {
+	value, err := strconv.ParseBool(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// getEnvList reads a comma-separated list, which is nil when unset.
+func getEnvList(key string) []string {
+	var values []string
+	for _, value := range strings.Split(getEnv(key, ""), ",") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func getEnvDuration(key string, fallback time.Duration) time.Duration {
+	value, err := time.ParseDuration(getEnv(key, ""))
+	if err != nil {
+		return fallback
+	}
+	return value
+}
+
+// parseGroupMappings reads "group=teamID:role" pairs separated by semicolons.
+// Malformed entries are skipped.
+func parseGroupMappings(value string) []OIDCGroupMapping {
+	var mappings []OIDCGroupMapping
+	for _, entry := range strings.Split(value, ";") {
+		group, target, ok := strings.Cut(strings.TrimSpace(entry), "=")
+		if !ok {
+			continue
+		}
+
+		teamID, role, ok := strings.Cut(target, ":")
+		if !ok || group == "" || teamID == "" {
+			continue
+		}
+
+		mappings = append(mappings, OIDCGroupMapping{
+			Group:  group,
+			TeamID: teamID,
+			Role:   role,
+		})
+	}
+	return mappings
+}
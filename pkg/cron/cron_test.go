@@ -0,0 +1,97 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// A Wednesday.
+	start := time.Date(2025, 1, 1, 10, 7, 30, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		from       time.Time
+		want       time.Time
+	}{
+		{"*/15 * * * *", start, time.Date(2025, 1, 1, 10, 15, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2025, 1, 1, 10, 45, 0, 0, time.UTC), time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"5/15 * * * *", start, time.Date(2025, 1, 1, 10, 20, 0, 0, time.UTC)},
+		{"0 3 * * *", start, time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{"30 2 1-5 * *", start, time.Date(2025, 1, 2, 2, 30, 0, 0, time.UTC)},
+		{"@hourly", start, time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@monthly", start, time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 1 *", start, time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)},
+		// Sundays, as 0 or 7.
+		{"0 0 * * 0", start, time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", start, time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		// With both day fields restricted, either one matching is enough:
+		// Friday the 3rd comes before the 15th.
+		{"0 0 15 * 5", start, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 2 * 5", start, time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC)},
+		// With one of them a star, the other one decides.
+		{"0 0 * * 5", start, time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 15 * *", start, time.Date(2025, 1, 15, 0, 0, 0, 0, time.UTC)},
+		// As in Vixie cron, a field starting with a star counts as one, so
+		// both have to match: the 31st is the first Friday among 1, 11, 21, 31.
+		{"0 0 */10 * 5", start, time.Date(2025, 1, 31, 0, 0, 0, 0, time.UTC)},
+		// Only leap years have a February 29th.
+		{"0 0 29 2 *", start, time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Exactly on a firing time, the next one is returned.
+		{"0 * * * *", time.Date(2025, 1, 1, 10, 0, 0, 0, time.UTC), time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		schedule, err := Parse(tt.expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", tt.expression, err)
+		}
+		if got := schedule.Next(tt.from); !got.Equal(tt.want) {
+			t.Errorf("Next(%q, %s) = %s, want %s", tt.expression, tt.from, got, tt.want)
+		}
+	}
+}
+
+func TestNextNever(t *testing.T) {
+	for _, expression := range []string{"0 0 30 2 *", "0 0 31 4,6,9,11 *"} {
+		schedule, err := Parse(expression)
+		if err != nil {
+			t.Fatalf("Parse(%q) = %v", expression, err)
+		}
+		if got := schedule.Next(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+			t.Errorf("Next(%q) = %s, want the zero time", expression, got)
+		}
+	}
+}
+
+func TestNextKeepsLocation(t *testing.T) {
+	location := time.FixedZone("UTC+5", 5*60*60)
+	schedule, err := Parse("0 9 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got := schedule.Next(time.Date(2025, 1, 1, 10, 0, 0, 0, location))
+	if want := time.Date(2025, 1, 2, 9, 0, 0, 0, location); !got.Equal(want) || got.Location() != location {
+		t.Fatalf("Next() = %s, want %s", got, want)
+	}
+}
+
+func TestParseRejects(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"* * * * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"a * * * *",
+	} {
+		if _, err := Parse(expression); err == nil {
+			t.Errorf("Parse(%q) = nil, want an error", expression)
+		}
+	}
+}
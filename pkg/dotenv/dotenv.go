@@ -0,0 +1,120 @@
+// Package dotenv reads and writes variables in the .env format.
+package dotenv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Variable is one KEY=value pair.
+type Variable struct {
+	Key   string
+	Value string
+}
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidKey reports whether key can be used as an environment variable name.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// Parse reads KEY=value lines, in order. Lines may start with "export ".
+// Double-quoted values may span lines and use \n, \t, \", \\ and \$ escapes;
+// single-quoted values are taken literally; unquoted values end at " #".
+func Parse(content string) ([]Variable, error) {
+	var variables []Variable
+
+	lines := strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		lineNumber := i + 1
+		line := strings.TrimSpace(lines[i])
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimPrefix(line, "export ")
+
+		key, value, ok := strings.Cut(line, "=")
+		key = strings.TrimSpace(key)
+		if !ok || !ValidKey(key) {
+			return nil, fmt.Errorf("line %d: expected KEY=value", lineNumber)
+		}
+		value = strings.TrimLeft(value, " \t")
+
+		if value != "" && (value[0] == '"' || value[0] == '\'') {
+			quote := value[0]
+			raw := value[1:]
+			// Keep reading lines until the closing quote.
+			for {
+				end := closingQuote(raw, quote)
+				if end >= 0 {
+					rest := strings.TrimSpace(raw[end+1:])
+					if rest != "" && !strings.HasPrefix(rest, "#") {
+						return nil, fmt.Errorf("line %d: unexpected text after the closing quote", lineNumber)
+					}
+					raw = raw[:end]
+					break
+				}
+				i++
+				if i == len(lines) {
+					return nil, fmt.Errorf("line %d: missing closing quote", lineNumber)
+				}
+				raw += "\n" + lines[i]
+			}
+			if quote == '"' {
+				raw = unescape(raw)
+			}
+			value = raw
+		} else {
+			if index := strings.Index(value, " #"); index >= 0 {
+				value = value[:index]
+			}
+			value = strings.TrimSpace(value)
+		}
+
+		variables = append(variables, Variable{Key: key, Value: value})
+	}
+
+	return variables, nil
+}
+
+// Format writes variables as .env lines, quoting values that need it.
+func Format(variables []Variable) string {
+	var b strings.Builder
+	for _, variable := range variables {
+		b.WriteString(variable.Key)
+		b.WriteByte('=')
+		b.WriteString(quote(variable.Value))
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
+
+func closingQuote(value string, quote byte) int {
+	for i := 0; i < len(value); i++ {
+		if quote == '"' && value[i] == '\\' {
+			i++
+			continue
+		}
+		if value[i] == quote {
+			return i
+		}
+	}
+	return -1
+}
+
+var unescaper = strings.NewReplacer(`\n`, "\n", `\r`, "\r", `\t`, "\t", `\"`, `"`, `\\`, `\`, `\$`, `$`)
+
+func unescape(value string) string {
+	return unescaper.Replace(value)
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`, `$`, `\$`)
+
+func quote(value string) string {
+	if value == "" || strings.ContainsAny(value, " \t\r\n#\"'\\$=") {
+		return `"` + escaper.Replace(value) + `"`
+	}
+	return value
+}
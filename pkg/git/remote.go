@@ -0,0 +1,294 @@
+package git
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const lsRemoteTimeout = 30 * time.Second
+
+// credentialHelper answers git's credential requests from the environment,
+// which keeps HTTP(S) credentials out of remote URLs and command lines.
+const credentialHelper = `!f() { test "$1" = get && printf 'username=%s\npassword=%s\n' "$MO_SH_GIT_USERNAME" "$MO_SH_GIT_PASSWORD"; }; f`
+
+// Auth holds the credentials for a remote: a deploy key for SSH URLs, or a
+// username and password (or token) for HTTPS URLs.
+type Auth struct {
+	PrivateKey []byte
+	Username   string
+	Password   string
+}
+
+type Ref struct {
+	SHA  string `json:"sha"`
+	Name string `json:"name"`
+}
+
+// Branch returns the branch name for refs/heads/ refs, or "".
+func (r Ref) Branch() string {
+	branch, _ := strings.CutPrefix(r.Name, "refs/heads/")
+	if branch == r.Name {
+		return ""
+	}
+	return branch
+}
+
+func IsSSHURL(remote string) bool {
+	if strings.HasPrefix(remote, "ssh://") {
+		return true
+	}
+	// scp-like syntax: git@host:owner/repo.git
+	return !strings.Contains(remote, "://") && strings.Contains(remote, "@") && strings.Contains(remote, ":")
+}
+
+// ValidateURL accepts SSH (ssh:// or scp-like) and HTTP(S) remotes. Local
+// paths and other transports aren't allowed since remotes are user input.
+func ValidateURL(remote string) error {
+	if strings.HasPrefix(remote, "-") || strings.ContainsAny(remote, " \t\n") {
+		return errors.New("invalid repository URL")
+	}
+	if IsSSHURL(remote) {
+		return nil
+	}
+
+	parsed, err := url.Parse(remote)
+	if err != nil || (parsed.Scheme != "https" && parsed.Scheme != "http") || parsed.Host == "" {
+		return errors.New("repository URL must be an SSH or HTTP(S) URL")
+	}
+	return nil
+}
+
+// AuthenticatedURL embeds basic-auth credentials in an HTTP(S) remote, as
+// sources hand out clone URLs. SSH remotes are returned unchanged. Split the
+// credentials off again with SplitCredentials before running git.
+func AuthenticatedURL(remote string, auth Auth) (string, error) {
+	if IsSSHURL(remote) || !auth.hasCredentials() {
+		return remote, nil
+	}
+
+	parsed, err := url.Parse(remote)
+	if err != nil {
+		return "", err
+	}
+	parsed.User = url.UserPassword(auth.Username, auth.Password)
+	return parsed.String(), nil
+}
+
+// SplitCredentials removes basic-auth credentials from an HTTP(S) remote and
+// returns them as an Auth.
+func SplitCredentials(remote string) (string, Auth, error) {
+	if IsSSHURL(remote) {
+		return remote, Auth{}, nil
+	}
+
+	parsed, err := url.Parse(remote)
+	if err != nil {
+		return "", Auth{}, err
+	}
+	if parsed.User == nil {
+		return remote, Auth{}, nil
+	}
+
+	password, _ := parsed.User.Password()
+	auth := Auth{Username: parsed.User.Username(), Password: password}
+	parsed.User = nil
+	return parsed.String(), auth, nil
+}
+
+// CredentialsInput is the stdin that scripts from LsRemoteCommand and
+// CloneCommand read auth's username and password from. It is nil when auth
+// has none.
+func CredentialsInput(auth Auth) io.Reader {
+	if !auth.hasCredentials() {
+		return nil
+	}
+	return strings.NewReader(auth.Username + "\n" + auth.Password + "\n")
+}
+
+func (a Auth) hasCredentials() bool {
+	return a.Username != "" || a.Password != ""
+}
+
+// credentialArgs configures git to take credentials from credentialHelper
+// alone, skipping any helper configured on the machine.
+func credentialArgs(auth Auth) []string {
+	if !auth.hasCredentials() {
+		return nil
+	}
+	return []string{"-c", "credential.helper=", "-c", "credential.helper=" + credentialHelper}
+}
+
+// credentialScript reads the credentials CredentialsInput provides into the
+// environment credentialHelper reads them from.
+func credentialScript(auth Auth) string {
+	if !auth.hasCredentials() {
+		return ""
+	}
+	return "IFS= read -r MO_SH_GIT_USERNAME\nIFS= read -r MO_SH_GIT_PASSWORD\nexport MO_SH_GIT_USERNAME MO_SH_GIT_PASSWORD\n"
+}
+
+// LsRemote lists the refs of remote from this machine. With no refs given it
+// lists branches only.
+func LsRemote(ctx context.Context, remote string, auth Auth, refs ...string) ([]Ref, error) {
+	ctx, cancel := context.WithTimeout(ctx, lsRemoteTimeout)
+	defer cancel()
+
+	args := append(credentialArgs(auth), "ls-remote")
+	if len(refs) == 0 {
+		args = append(args, "--heads")
+	}
+	args = append(args, "--", remote)
+	args = append(args, refs...)
+
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Env = append(os.Environ(), "GIT_TERMINAL_PROMPT=0")
+	if auth.hasCredentials() {
+		cmd.Env = append(cmd.Env, "MO_SH_GIT_USERNAME="+auth.Username, "MO_SH_GIT_PASSWORD="+auth.Password)
+	}
+
+	if len(auth.PrivateKey) > 0 {
+		keyFile, err := os.CreateTemp("", "mo-sh-deploy-key-*")
+		if err != nil {
+			return nil, err
+		}
+		defer os.Remove(keyFile.Name())
+
+		if _, err := keyFile.Write(normalizeKey(auth.PrivateKey)); err != nil {
+			keyFile.Close()
+			return nil, err
+		}
+		if err := keyFile.Close(); err != nil {
+			return nil, err
+		}
+
+		cmd.Env = append(cmd.Env, "GIT_SSH_COMMAND="+sshCommand(keyFile.Name()))
+	}
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("git ls-remote: %s", redact(strings.TrimSpace(stderr.String()), auth))
+	}
+
+	return ParseLsRemote(stdout.String()), nil
+}
+
+// LsRemoteCommand is a shell script that runs git ls-remote on another machine
+// over SSH, with CredentialsInput(auth) as its stdin. Its output is parsed
+// with ParseLsRemote.
+func LsRemoteCommand(remote string, auth Auth, refs ...string) string {
+	args := append([]string{"git"}, credentialArgs(auth)...)
+	args = append(args, "ls-remote")
+	if len(refs) == 0 {
+		args = append(args, "--heads")
+	}
+	args = append(args, "--", remote)
+	args = append(args, refs...)
+
+	quoted := make([]string, len(args))
+	for i, arg := range args {
+		quoted[i] = ShellQuote(arg)
+	}
+
+	var script strings.Builder
+	script.WriteString("set -e\n")
+	script.WriteString(environmentScript(auth))
+	script.WriteString(strings.Join(quoted, " ") + "\n")
+
+	return script.String()
+}
+
+// environmentScript sets up git to authenticate with auth without prompting.
+func environmentScript(auth Auth) string {
+	var script strings.Builder
+	script.WriteString("export GIT_TERMINAL_PROMPT=0\n")
+	script.WriteString(credentialScript(auth))
+	if len(auth.PrivateKey) > 0 {
+		script.WriteString(WriteKeyScript("MO_SH_KEY_FILE", auth.PrivateKey))
+		script.WriteString(`export GIT_SSH_COMMAND="` + sshCommand(`$MO_SH_KEY_FILE`) + `"` + "\n")
+	}
+	return script.String()
+}
+
+// WriteKeyScript writes privateKey to a private temp file whose path is stored
+// in the shell variable name, and removes it when the script exits.
+func WriteKeyScript(name string, privateKey []byte) string {
+	return fmt.Sprintf(
+		"%[1]s=$(mktemp)\ntrap 'rm -f \"$%[1]s\"' EXIT\nchmod 600 \"$%[1]s\"\ncat > \"$%[1]s\" <<'MO_SH_KEY'\n%[2]sMO_SH_KEY\n",
+		name, normalizeKey(privateKey),
+	)
+}
+
+func ParseLsRemote(output string) []Ref {
+	var refs []Ref
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		sha, name, ok := strings.Cut(scanner.Text(), "\t")
+		if !ok {
+			continue
+		}
+		refs = append(refs, Ref{SHA: sha, Name: name})
+	}
+	return refs
+}
+
+// ShellQuote quotes s for a POSIX shell.
+func ShellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func sshCommand(keyFile string) string {
+	// Hosts are added on first use; deploy targets are rarely in known_hosts.
+	return "ssh -i " + keyFile + " -o IdentitiesOnly=yes -o StrictHostKeyChecking=accept-new -o BatchMode=yes"
+}
+
+// normalizeKey makes sure the key ends with a newline, which OpenSSH requires.
+func normalizeKey(key []byte) []byte {
+	if len(key) > 0 && key[len(key)-1] != '\n' {
+		return append(append([]byte{}, key...), '\n')
+	}
+	return key
+}
+
+// redact keeps passwords out of error messages, since git echoes the URL.
+func redact(message string, auth Auth) string {
+	if auth.Password != "" {
+		message = strings.ReplaceAll(message, auth.Password, "***")
+		message = strings.ReplaceAll(message, url.QueryEscape(auth.Password), "***")
+	}
+	return message
+}
+
+// CloneCommand is a shell script that fetches a single commit of remote into
+// dir, which must not exist yet, with CredentialsInput(auth) as its stdin.
+// ref is a branch name or a commit SHA. The checked out SHA is printed on a
+// line of its own, prefixed with CommitMarker.
+func CloneCommand(remote string, auth Auth, dir, ref string) string {
+	var fetchArgs strings.Builder
+	for _, arg := range credentialArgs(auth) {
+		fetchArgs.WriteString(ShellQuote(arg) + " ")
+	}
+
+	var script strings.Builder
+	script.WriteString(environmentScript(auth))
+	fmt.Fprintf(&script, "mkdir -p %s\n", ShellQuote(dir))
+	fmt.Fprintf(&script, "git -C %s init -q\n", ShellQuote(dir))
+	fmt.Fprintf(&script, "git -C %s %sfetch -q --depth 1 -- %s %s\n", ShellQuote(dir), fetchArgs.String(), ShellQuote(remote), ShellQuote(ref))
+	fmt.Fprintf(&script, "git -C %s checkout -q FETCH_HEAD\n", ShellQuote(dir))
+	fmt.Fprintf(&script, "echo %s$(git -C %s rev-parse HEAD)\n", CommitMarker, ShellQuote(dir))
+	return script.String()
+}
+
+// CommitMarker prefixes the commit SHA CloneCommand prints.
+const CommitMarker = "MO_SH_COMMIT="
@@ -0,0 +1,220 @@
+package gitea
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// Gitea caps page size at 50 by default.
+	pageSize = 50
+
+	// Upper bound on pages fetched when listing, so a huge organization can't
+	// keep a request busy forever.
+	maxPages = 40
+)
+
+type APIError struct {
+	StatusCode int
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gitea: %d %s", e.StatusCode, e.Message)
+}
+
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// APIURLFor returns the REST API root of a Gitea or Forgejo instance.
+func APIURLFor(htmlURL string) string {
+	return strings.TrimSuffix(htmlURL, "/") + "/api/v1"
+}
+
+// Client talks to the Gitea (or Forgejo) REST API with an access token.
+type Client struct {
+	Ctx        context.Context
+	APIURL     string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewClient(ctx context.Context, apiURL, token string) *Client {
+	return &Client{
+		Ctx:        ctx,
+		APIURL:     strings.TrimSuffix(apiURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type User struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+}
+
+type Repository struct {
+	ID            int64  `json:"id"`
+	Name          string `json:"name"`
+	FullName      string `json:"full_name"`
+	Private       bool   `json:"private"`
+	DefaultBranch string `json:"default_branch"`
+	HTMLURL       string `json:"html_url"`
+	CloneURL      string `json:"clone_url"`
+	SSHURL        string `json:"ssh_url"`
+	Owner         User   `json:"owner"`
+}
+
+type Branch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+	Protected bool `json:"protected"`
+}
+
+type Hook struct {
+	ID int64 `json:"id"`
+}
+
+func (c *Client) CurrentUser() (*User, error) {
+	var user User
+	if err := c.Do(http.MethodGet, "/user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListRepositories lists the repositories the token's user owns or has been
+// given access to, directly or through an organization team.
+func (c *Client) ListRepositories() ([]Repository, error) {
+	var repositories []Repository
+	for page := 1; page <= maxPages; page++ {
+		var response []Repository
+
+		path := fmt.Sprintf("/user/repos?limit=%d&page=%d", pageSize, page)
+		if err := c.Do(http.MethodGet, path, nil, &response); err != nil {
+			return nil, err
+		}
+
+		repositories = append(repositories, response...)
+		if len(response) < pageSize {
+			break
+		}
+	}
+	return repositories, nil
+}
+
+func (c *Client) GetRepository(repositoryID string) (*Repository, error) {
+	var repository Repository
+	if err := c.Do(http.MethodGet, "/repositories/"+url.PathEscape(repositoryID), nil, &repository); err != nil {
+		return nil, err
+	}
+	return &repository, nil
+}
+
+func (c *Client) ListBranches(fullName string) ([]Branch, error) {
+	var branches []Branch
+	for page := 1; page <= maxPages; page++ {
+		var response []Branch
+
+		path := fmt.Sprintf("/repos/%s/branches?limit=%d&page=%d", fullName, pageSize, page)
+		if err := c.Do(http.MethodGet, path, nil, &response); err != nil {
+			return nil, err
+		}
+
+		branches = append(branches, response...)
+		if len(response) < pageSize {
+			break
+		}
+	}
+	return branches, nil
+}
+
+// CreateHook registers a repository webhook for push and pull request events
+// whose payloads are signed with secret.
+func (c *Client) CreateHook(fullName, hookURL, secret string) (*Hook, error) {
+	body := map[string]any{
+		"type":   "gitea",
+		"active": true,
+		"events": []string{EventPush, EventPullRequest},
+		"config": map[string]string{
+			"url":          hookURL,
+			"content_type": "json",
+			"secret":       secret,
+		},
+	}
+
+	var hook Hook
+	if err := c.Do(http.MethodPost, fmt.Sprintf("/repos/%s/hooks", fullName), body, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (c *Client) DeleteHook(fullName string, hookID int64) error {
+	return c.Do(http.MethodDelete, fmt.Sprintf("/repos/%s/hooks/%d", fullName, hookID), nil, nil)
+}
+
+// CloneURL embeds the token in a repository's HTTPS clone URL so git can fetch
+// private repositories.
+func (c *Client) CloneURL(cloneURL string) (string, error) {
+	parsed, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	parsed.User = url.UserPassword("oauth2", c.Token)
+
+	return parsed.String(), nil
+}
+
+func (c *Client) Do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(c.Ctx, method, c.APIURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Mo-SH")
+	req.Header.Set("Authorization", "token "+c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
@@ -0,0 +1,165 @@
+package gitea
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+)
+
+const testToken = "token"
+
+func newTestClient(t *testing.T, handler http.Handler) *Client {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "token "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(map[string]string{"message": "token is required"})
+			return
+		}
+		handler.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return NewClient(context.Background(), APIURLFor(server.URL), testToken)
+}
+
+func TestListRepositoriesPages(t *testing.T) {
+	const total = pageSize + 3
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/user/repos" {
+			http.NotFound(w, r)
+			return
+		}
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+		repositories := []Repository{}
+		for id := (page-1)*limit + 1; id <= min(page*limit, total); id++ {
+			repositories = append(repositories, Repository{ID: int64(id), FullName: fmt.Sprintf("owner/repo-%d", id)})
+		}
+		json.NewEncoder(w).Encode(repositories)
+	}))
+
+	repositories, err := client.ListRepositories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(repositories) != total {
+		t.Fatalf("got %d repositories, want %d", len(repositories), total)
+	}
+	if last := repositories[total-1]; last.ID != total || last.FullName != fmt.Sprintf("owner/repo-%d", total) {
+		t.Fatalf("last repository = %+v", last)
+	}
+}
+
+func TestGetRepository(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repositories/7" {
+			w.WriteHeader(http.StatusNotFound)
+			json.NewEncoder(w).Encode(map[string]string{"message": "not found"})
+			return
+		}
+		json.NewEncoder(w).Encode(Repository{ID: 7, FullName: "owner/app", DefaultBranch: "main"})
+	}))
+
+	repository, err := client.GetRepository("7")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if repository.FullName != "owner/app" || repository.DefaultBranch != "main" {
+		t.Fatalf("repository = %+v", repository)
+	}
+
+	_, err = client.GetRepository("8")
+	if !IsNotFound(err) {
+		t.Fatalf("GetRepository() of a missing repository = %v, want a not found error", err)
+	}
+}
+
+func TestListBranches(t *testing.T) {
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/repos/owner/app/branches" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`[
+			{"name": "main", "commit": {"id": "abc"}, "protected": true},
+			{"name": "dev", "commit": {"id": "def"}, "protected": false}
+		]`))
+	}))
+
+	branches, err := client.ListBranches("owner/app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(branches) != 2 {
+		t.Fatalf("got %d branches, want 2", len(branches))
+	}
+	if branches[0].Name != "main" || branches[0].Commit.ID != "abc" || !branches[0].Protected {
+		t.Fatalf("branches[0] = %+v", branches[0])
+	}
+}
+
+func TestCreateHook(t *testing.T) {
+	var received struct {
+		Type   string            `json:"type"`
+		Active bool              `json:"active"`
+		Events []string          `json:"events"`
+		Config map[string]string `json:"config"`
+	}
+
+	client := newTestClient(t, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v1/repos/owner/app/hooks" {
+			http.NotFound(w, r)
+			return
+		}
+		if r.Header.Get("Content-Type") != "application/json" {
+			w.WriteHeader(http.StatusUnsupportedMediaType)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&received); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+		json.NewEncoder(w).Encode(Hook{ID: 42})
+	}))
+
+	hook, err := client.CreateHook("owner/app", "https://mo-sh.example/webhooks", "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hook.ID != 42 {
+		t.Fatalf("hook id = %d, want 42", hook.ID)
+	}
+
+	if received.Type != "gitea" || !received.Active {
+		t.Fatalf("hook = %+v", received)
+	}
+	if len(received.Events) != 2 || received.Events[0] != EventPush || received.Events[1] != EventPullRequest {
+		t.Fatalf("events = %v", received.Events)
+	}
+	if received.Config["url"] != "https://mo-sh.example/webhooks" || received.Config["secret"] != "secret" || received.Config["content_type"] != "json" {
+		t.Fatalf("config = %v", received.Config)
+	}
+}
+
+func TestDoReturnsAPIError(t *testing.T) {
+	client := newTestClient(t, http.NotFoundHandler())
+	client.Token = "wrong"
+
+	_, err := client.CurrentUser()
+	apiErr, ok := err.(*APIError)
+	if !ok {
+		t.Fatalf("CurrentUser() = %v, want an *APIError", err)
+	}
+	if apiErr.StatusCode != http.StatusUnauthorized || apiErr.Message != "token is required" {
+		t.Fatalf("error = %+v", apiErr)
+	}
+}
@@ -0,0 +1,108 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	EventPush        = "push"
+	EventPullRequest = "pull_request"
+)
+
+var ErrUnsupportedEvent = errors.New("gitea: unsupported event")
+
+// VerifySignature checks an X-Gitea-Signature (or X-Forgejo-Signature) header,
+// the hex HMAC-SHA256 of body keyed with the webhook secret.
+func VerifySignature(secret string, body []byte, header string) bool {
+	if secret == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(header, "sha256="))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+type Commit struct {
+	ID        string `json:"id"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+	URL       string `json:"url"`
+	Author    struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+type PushEvent struct {
+	Ref        string     `json:"ref"`
+	Before     string     `json:"before"`
+	After      string     `json:"after"`
+	Repository Repository `json:"repository"`
+	Pusher     User       `json:"pusher"`
+	Commits    []Commit   `json:"commits"`
+}
+
+// Branch returns the pushed branch, or "" for tag pushes.
+func (e *PushEvent) Branch() string {
+	branch, ok := strings.CutPrefix(e.Ref, "refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// Deleted reports whether the push removed the branch.
+func (e *PushEvent) Deleted() bool {
+	return strings.Trim(e.After, "0") == ""
+}
+
+type PullRequestEvent struct {
+	Action      string `json:"action"`
+	Number      int64  `json:"number"`
+	PullRequest struct {
+		Title   string `json:"title"`
+		HTMLURL string `json:"html_url"`
+		State   string `json:"state"`
+		Merged  bool   `json:"merged"`
+		Head    struct {
+			Ref string `json:"ref"`
+			SHA string `json:"sha"`
+		} `json:"head"`
+		Base struct {
+			Ref string `json:"ref"`
+		} `json:"base"`
+	} `json:"pull_request"`
+	Repository Repository `json:"repository"`
+	Sender     User       `json:"sender"`
+}
+
+// ParseEvent decodes a webhook payload based on its X-Gitea-Event header.
+func ParseEvent(event string, payload []byte) (any, error) {
+	var target any
+	switch event {
+	case EventPush:
+		target = &PushEvent{}
+	case EventPullRequest:
+		target = &PullRequestEvent{}
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+
+	if err := json.Unmarshal(payload, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
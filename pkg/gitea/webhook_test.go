@@ -0,0 +1,80 @@
+package gitea
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	signature := sign("secret", body)
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		header string
+		want   bool
+	}{
+		{"valid", "secret", body, signature, true},
+		{"valid with prefix", "secret", body, "sha256=" + signature, true},
+		{"another secret", "other", body, signature, false},
+		{"modified body", "secret", []byte(`{"ref":"refs/heads/dev"}`), signature, false},
+		{"no secret configured", "", body, sign("", body), false},
+		{"missing header", "secret", body, "", false},
+		{"not hex", "secret", body, "zz", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, tt.body, tt.header); got != tt.want {
+				t.Fatalf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParsePushEvent(t *testing.T) {
+	payload := []byte(`{
+		"ref": "refs/heads/main",
+		"before": "abc",
+		"after": "def",
+		"repository": {"id": 7, "full_name": "owner/app"},
+		"commits": [{"id": "def", "message": "Fix"}]
+	}`)
+
+	event, err := ParseEvent(EventPush, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	push, ok := event.(*PushEvent)
+	if !ok {
+		t.Fatalf("event = %T, want *PushEvent", event)
+	}
+	if push.Branch() != "main" || push.Deleted() || push.Repository.ID != 7 || len(push.Commits) != 1 {
+		t.Fatalf("push = %+v", push)
+	}
+
+	tag := PushEvent{Ref: "refs/tags/v1", After: "def"}
+	if tag.Branch() != "" {
+		t.Fatalf("Branch() of a tag push = %q, want empty", tag.Branch())
+	}
+	deleted := PushEvent{Ref: "refs/heads/main", After: "0000000000000000000000000000000000000000"}
+	if !deleted.Deleted() {
+		t.Fatal("Deleted() = false for a zero after SHA")
+	}
+}
+
+func TestParseUnsupportedEvent(t *testing.T) {
+	if _, err := ParseEvent("issues", []byte(`{}`)); !errors.Is(err, ErrUnsupportedEvent) {
+		t.Fatalf("ParseEvent() = %v, want %v", err, ErrUnsupportedEvent)
+	}
+}
@@ -0,0 +1,246 @@
+package github
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/pkg/jwt"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	DefaultAPIURL = "https://api.github.com"
+
+	// Installation tokens live for an hour; stop using a cached one this long
+	// before it expires so in-flight requests don't fail halfway.
+	tokenExpiryMargin = 5 * time.Minute
+)
+
+type APIError struct {
+	StatusCode int
+	Message    string `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("github: %d %s", e.StatusCode, e.Message)
+}
+
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// Client talks to the GitHub API as the GitHub App registered for a source,
+// either as the app itself or as one of its installations.
+type Client struct {
+	RedisClient *redis.Client
+	Ctx         context.Context
+	SourceID    string
+	ClientID    string
+	APIURL      string
+	HTTPClient  *http.Client
+	privateKey  *rsa.PrivateKey
+}
+
+func NewClient(redisClient *redis.Client, ctx context.Context, sourceID, clientID, apiURL string, privateKeyPEM []byte) (*Client, error) {
+	privateKey, err := parsePrivateKey(privateKeyPEM)
+	if err != nil {
+		return nil, err
+	}
+
+	if apiURL == "" {
+		apiURL = DefaultAPIURL
+	}
+
+	return &Client{
+		RedisClient: redisClient,
+		Ctx:         ctx,
+		SourceID:    sourceID,
+		ClientID:    clientID,
+		APIURL:      strings.TrimSuffix(apiURL, "/"),
+		HTTPClient:  &http.Client{Timeout: 30 * time.Second},
+		privateKey:  privateKey,
+	}, nil
+}
+
+// LoadClient builds a Client from the GitHub App stored for sourceID. It
+// returns sql.ErrNoRows if the source has no app.
+func LoadClient(db *sqlx.DB, redisClient *redis.Client, ctx context.Context, sourceID string) (*Client, error) {
+	var app struct {
+		ClientID string `db:"client_id"`
+		Key      string `db:"key"`
+		APIURL   string `db:"api_url"`
+	}
+
+	query := `
+		select
+			ga.client_id, k.key, s.api_url
+		from
+			github_apps ga
+		inner join
+			keys k ON k.id = ga.key_id
+		inner join
+			sources s ON s.id = ga.source_id
+		where
+			ga.source_id = $1
+	`
+	if err := db.GetContext(ctx, &app, query, sourceID); err != nil {
+		return nil, err
+	}
+
+	return NewClient(redisClient, ctx, sourceID, app.ClientID, app.APIURL, []byte(app.Key))
+}
+
+// AppJWT signs a short-lived JWT that authenticates as the app itself.
+func (c *Client) AppJWT() (string, error) {
+	now := time.Now()
+	claims := map[string]any{
+		// Backdated to allow for clock drift, as GitHub recommends.
+		"iat": now.Add(-60 * time.Second).Unix(),
+		"exp": now.Add(9 * time.Minute).Unix(),
+		"iss": c.ClientID,
+	}
+	return jwt.SignRS256(claims, c.privateKey)
+}
+
+// InstallationToken returns an access token for installationID, minting a new
+// one only when the cached token is missing or about to expire.
+func (c *Client) InstallationToken(installationID int64) (string, error) {
+	cacheKey := fmt.Sprintf("github:installation_token:%s:%d", c.SourceID, installationID)
+
+	token, err := c.RedisClient.Get(c.Ctx, cacheKey).Result()
+	if err == nil {
+		return token, nil
+	}
+	if err != redis.Nil {
+		return "", err
+	}
+
+	var response struct {
+		Token     string    `json:"token"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+	path := fmt.Sprintf("/app/installations/%d/access_tokens", installationID)
+	if err := c.AppDo(http.MethodPost, path, nil, &response); err != nil {
+		return "", err
+	}
+
+	if ttl := time.Until(response.ExpiresAt) - tokenExpiryMargin; ttl > 0 {
+		if err := c.RedisClient.Set(c.Ctx, cacheKey, response.Token, ttl).Err(); err != nil {
+			return "", err
+		}
+	}
+
+	return response.Token, nil
+}
+
+// AppDo performs a request authenticated as the app.
+func (c *Client) AppDo(method, path string, body, out any) error {
+	token, err := c.AppJWT()
+	if err != nil {
+		return err
+	}
+	return c.do(method, path, "Bearer "+token, body, out)
+}
+
+// Do performs a request authenticated as installationID.
+func (c *Client) Do(installationID int64, method, path string, body, out any) error {
+	token, err := c.InstallationToken(installationID)
+	if err != nil {
+		return err
+	}
+	return c.do(method, path, "token "+token, body, out)
+}
+
+// CloneURL returns an HTTPS clone URL for a repository that embeds a fresh
+// installation token, so git can fetch private repositories.
+func (c *Client) CloneURL(installationID int64, cloneURL string) (string, error) {
+	token, err := c.InstallationToken(installationID)
+	if err != nil {
+		return "", err
+	}
+
+	parsed, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	parsed.User = url.UserPassword("x-access-token", token)
+
+	return parsed.String(), nil
+}
+
+func (c *Client) do(method, path, authorization string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(c.Ctx, method, c.APIURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "Mo-SH")
+	req.Header.Set("Authorization", authorization)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+func parsePrivateKey(privateKeyPEM []byte) (*rsa.PrivateKey, error) {
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("github: failed to decode app private key")
+	}
+
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, errors.New("github: app private key is not an RSA key")
+	}
+	return rsaKey, nil
+}
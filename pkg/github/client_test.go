@@ -0,0 +1,226 @@
+package github
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mohit4bug/mo-sh/pkg/jwt"
+	"github.com/redis/go-redis/v9"
+)
+
+// fakeRedis speaks just enough RESP for the token cache: GET and SET with
+// an expiry, which it records instead of enforcing.
+type fakeRedis struct {
+	mu     sync.Mutex
+	values map[string]string
+	ttls   map[string]time.Duration
+}
+
+func newFakeRedis(t *testing.T) (*fakeRedis, *redis.Client) {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	fake := &fakeRedis{values: map[string]string{}, ttls: map[string]time.Duration{}}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go fake.serve(conn)
+		}
+	}()
+
+	client := redis.NewClient(&redis.Options{Addr: listener.Addr().String(), DisableIdentity: true})
+	t.Cleanup(func() { client.Close() })
+	return fake, client
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+
+	for {
+		command, err := readCommand(reader)
+		if err != nil {
+			return
+		}
+
+		f.mu.Lock()
+		switch strings.ToUpper(command[0]) {
+		case "GET":
+			if value, ok := f.values[command[1]]; ok {
+				fmt.Fprintf(conn, "$%d\r\n%s\r\n", len(value), value)
+			} else {
+				io.WriteString(conn, "$-1\r\n")
+			}
+		case "SET":
+			f.values[command[1]] = command[2]
+			if len(command) == 5 {
+				n, _ := strconv.Atoi(command[4])
+				unit := time.Second
+				if strings.EqualFold(command[3], "px") {
+					unit = time.Millisecond
+				}
+				f.ttls[command[1]] = time.Duration(n) * unit
+			}
+			io.WriteString(conn, "+OK\r\n")
+		default:
+			fmt.Fprintf(conn, "-ERR unknown command '%s'\r\n", command[0])
+		}
+		f.mu.Unlock()
+	}
+}
+
+func readCommand(reader *bufio.Reader) ([]string, error) {
+	line, err := reader.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	count, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "*")))
+	if err != nil {
+		return nil, err
+	}
+
+	command := make([]string, count)
+	for i := range command {
+		if line, err = reader.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(strings.TrimPrefix(line, "$")))
+		if err != nil {
+			return nil, err
+		}
+		value := make([]byte, size+2)
+		if _, err := io.ReadFull(reader, value); err != nil {
+			return nil, err
+		}
+		command[i] = string(value[:size])
+	}
+	return command, nil
+}
+
+func newTestClient(t *testing.T, redisClient *redis.Client, apiURL string) (*Client, *rsa.PrivateKey) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)})
+
+	client, err := NewClient(redisClient, context.Background(), "source-1", "Iv1.abc", apiURL, keyPEM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client, key
+}
+
+func TestInstallationTokenIsCached(t *testing.T) {
+	fake, redisClient := newFakeRedis(t)
+
+	var minted int
+	var key *rsa.PrivateKey
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.URL.Path != "/app/installations/42/access_tokens" {
+			t.Errorf("request = %s %s", r.Method, r.URL.Path)
+		}
+
+		token, err := jwt.Parse(strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer "))
+		if err != nil || token.Verify(&key.PublicKey) != nil {
+			t.Errorf("the request wasn't signed with the app's key: %v", err)
+		}
+
+		minted++
+		json.NewEncoder(w).Encode(map[string]any{
+			"token":      fmt.Sprintf("ghs_%d", minted),
+			"expires_at": time.Now().Add(time.Hour).UTC().Format(time.RFC3339),
+		})
+	}))
+	defer server.Close()
+
+	client, key := newTestClient(t, redisClient, server.URL)
+
+	for range 3 {
+		token, err := client.InstallationToken(42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != "ghs_1" {
+			t.Fatalf("InstallationToken() = %s, want the cached ghs_1", token)
+		}
+	}
+	if minted != 1 {
+		t.Fatalf("minted %d tokens, want 1", minted)
+	}
+
+	// Cached until the margin before it expires.
+	ttl := fake.ttls["github:installation_token:source-1:42"]
+	if ttl <= 50*time.Minute || ttl > time.Hour-tokenExpiryMargin {
+		t.Fatalf("cached for %s", ttl)
+	}
+}
+
+func TestInstallationTokenNearExpiryIsNotCached(t *testing.T) {
+	fake, redisClient := newFakeRedis(t)
+
+	var minted int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		minted++
+		json.NewEncoder(w).Encode(map[string]any{
+			"token":      fmt.Sprintf("ghs_%d", minted),
+			"expires_at": time.Now().Add(time.Minute).UTC().Format(time.RFC3339),
+		})
+	}))
+	defer server.Close()
+
+	client, _ := newTestClient(t, redisClient, server.URL)
+
+	for want := 1; want <= 2; want++ {
+		token, err := client.InstallationToken(42)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != fmt.Sprintf("ghs_%d", want) {
+			t.Fatalf("InstallationToken() = %s, want a fresh token", token)
+		}
+	}
+	if len(fake.values) != 0 {
+		t.Fatalf("cached %v", fake.values)
+	}
+}
+
+func TestInstallationTokenError(t *testing.T) {
+	_, redisClient := newFakeRedis(t)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"message": "Not Found"}`))
+	}))
+	defer server.Close()
+
+	client, _ := newTestClient(t, redisClient, server.URL)
+	if _, err := client.InstallationToken(42); !IsNotFound(err) {
+		t.Fatalf("InstallationToken() = %v, want a not found error", err)
+	}
+}
@@ -0,0 +1,61 @@
+package github
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// App is a GitHub App as created from a manifest, including the credentials
+// GitHub only hands out in that one response.
+type App struct {
+	ID            int64             `json:"id"`
+	Slug          string            `json:"slug"`
+	NodeID        string            `json:"node_id"`
+	Owner         json.RawMessage   `json:"owner"`
+	Name          string            `json:"name"`
+	Description   string            `json:"description"`
+	ExternalURL   string            `json:"external_url"`
+	HTMLURL       string            `json:"html_url"`
+	CreatedAt     time.Time         `json:"created_at"`
+	UpdatedAt     time.Time         `json:"updated_at"`
+	Permissions   map[string]string `json:"permissions"`
+	Events        []string          `json:"events"`
+	ClientID      string            `json:"client_id"`
+	ClientSecret  string            `json:"client_secret"`
+	WebhookSecret string            `json:"webhook_secret"`
+	PEM           string            `json:"pem"`
+}
+
+// ConvertManifest exchanges the code GitHub redirects back with, after an app
+// was created from a manifest, for the app. The code is single use.
+func ConvertManifest(ctx context.Context, apiURL, code string) (*App, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ManifestConversionURL(apiURL, code), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("X-GitHub-Api-Version", "2022-11-28")
+	req.Header.Set("User-Agent", "Mo-SH")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return nil, apiErr
+	}
+
+	var app App
+	if err := json.NewDecoder(resp.Body).Decode(&app); err != nil {
+		return nil, err
+	}
+	return &app, nil
+}
@@ -0,0 +1,104 @@
+package github
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// Upper bound on pages fetched when listing, so a huge organization can't
+// keep a request busy forever.
+const maxPages = 20
+
+// ErrIncompleteListing is returned when GitHub stops returning repositories
+// before the total it reported, e.g. because the list changed while paging.
+var ErrIncompleteListing = errors.New("github: repository listing is incomplete")
+
+type Branch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		SHA string `json:"sha"`
+	} `json:"commit"`
+	Protected bool `json:"protected"`
+}
+
+type RepositoryCommit struct {
+	SHA     string `json:"sha"`
+	HTMLURL string `json:"html_url"`
+	Commit  struct {
+		Message string `json:"message"`
+		Author  struct {
+			Name  string `json:"name"`
+			Email string `json:"email"`
+			Date  string `json:"date"`
+		} `json:"author"`
+	} `json:"commit"`
+}
+
+func (c *Client) GetInstallation(installationID int64) (*Installation, error) {
+	var installation Installation
+	if err := c.AppDo(http.MethodGet, fmt.Sprintf("/app/installations/%d", installationID), nil, &installation); err != nil {
+		return nil, err
+	}
+	return &installation, nil
+}
+
+// ListInstallationRepositories returns every repository the installation
+// can access. Callers drop repositories missing from it, so unlike the other
+// listings it pages until done and fails rather than return a partial list.
+func (c *Client) ListInstallationRepositories(installationID int64) ([]Repository, error) {
+	var repositories []Repository
+	for page := 1; ; page++ {
+		var response struct {
+			TotalCount   int          `json:"total_count"`
+			Repositories []Repository `json:"repositories"`
+		}
+
+		path := fmt.Sprintf("/installation/repositories?per_page=100&page=%d", page)
+		if err := c.Do(installationID, http.MethodGet, path, nil, &response); err != nil {
+			return nil, err
+		}
+
+		repositories = append(repositories, response.Repositories...)
+		if len(repositories) >= response.TotalCount {
+			return repositories, nil
+		}
+		if len(response.Repositories) < 100 {
+			return nil, ErrIncompleteListing
+		}
+	}
+}
+
+func (c *Client) ListBranches(installationID int64, fullName string) ([]Branch, error) {
+	var branches []Branch
+	for page := 1; page <= maxPages; page++ {
+		var response []Branch
+
+		path := fmt.Sprintf("/repos/%s/branches?per_page=100&page=%d", fullName, page)
+		if err := c.Do(installationID, http.MethodGet, path, nil, &response); err != nil {
+			return nil, err
+		}
+
+		branches = append(branches, response...)
+		if len(response) < 100 {
+			break
+		}
+	}
+	return branches, nil
+}
+
+func (c *Client) ListCommits(installationID int64, fullName, branch string, limit int) ([]RepositoryCommit, error) {
+	params := url.Values{}
+	params.Set("per_page", fmt.Sprintf("%d", limit))
+	if branch != "" {
+		params.Set("sha", branch)
+	}
+
+	var commits []RepositoryCommit
+	path := fmt.Sprintf("/repos/%s/commits?%s", fullName, params.Encode())
+	if err := c.Do(installationID, http.MethodGet, path, nil, &commits); err != nil {
+		return nil, err
+	}
+	return commits, nil
+}
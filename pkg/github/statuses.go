@@ -0,0 +1,45 @@
+package github
+
+import (
+	"fmt"
+	"net/http"
+)
+
+type CommitStatus struct {
+	State       string `json:"state"`
+	TargetURL   string `json:"target_url,omitempty"`
+	Description string `json:"description,omitempty"`
+	Context     string `json:"context"`
+}
+
+type IssueComment struct {
+	ID      int64  `json:"id"`
+	Body    string `json:"body"`
+	HTMLURL string `json:"html_url"`
+}
+
+// CreateCommitStatus sets a status on sha. GitHub keeps the latest status per
+// context, so reporting again with the same context replaces it.
+func (c *Client) CreateCommitStatus(installationID int64, fullName, sha string, status *CommitStatus) error {
+	path := fmt.Sprintf("/repos/%s/statuses/%s", fullName, sha)
+	return c.Do(installationID, http.MethodPost, path, status, nil)
+}
+
+// CreateIssueComment comments on an issue or pull request.
+func (c *Client) CreateIssueComment(installationID int64, fullName string, number int, body string) (*IssueComment, error) {
+	var comment IssueComment
+	path := fmt.Sprintf("/repos/%s/issues/%d/comments", fullName, number)
+	if err := c.Do(installationID, http.MethodPost, path, map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
+
+func (c *Client) UpdateIssueComment(installationID int64, fullName string, commentID int64, body string) (*IssueComment, error) {
+	var comment IssueComment
+	path := fmt.Sprintf("/repos/%s/issues/comments/%d", fullName, commentID)
+	if err := c.Do(installationID, http.MethodPatch, path, map[string]string{"body": body}, &comment); err != nil {
+		return nil, err
+	}
+	return &comment, nil
+}
@@ -0,0 +1,42 @@
+package github
+
+import (
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+const DefaultHTMLURL = "https://github.com"
+
+var organizationPattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9-]{0,38})$`)
+
+// APIURLFor guesses the REST API root for a GitHub web URL. github.com has its
+// own API host; GitHub Enterprise Server serves it under /api/v3.
+func APIURLFor(htmlURL string) string {
+	htmlURL = strings.TrimSuffix(htmlURL, "/")
+	if htmlURL == "" || htmlURL == DefaultHTMLURL {
+		return DefaultAPIURL
+	}
+	return htmlURL + "/api/v3"
+}
+
+// NewAppURL is where the app manifest form is posted, under organization when
+// one is given and under the signed-in user's account otherwise.
+func NewAppURL(htmlURL, organization, state string) string {
+	htmlURL = strings.TrimSuffix(htmlURL, "/")
+	if organization != "" {
+		return fmt.Sprintf("%s/organizations/%s/settings/apps/new?state=%s", htmlURL, url.PathEscape(organization), url.QueryEscape(state))
+	}
+	return fmt.Sprintf("%s/settings/apps/new?state=%s", htmlURL, url.QueryEscape(state))
+}
+
+// ManifestConversionURL exchanges the code GitHub hands back after an app is
+// created from a manifest for the app's credentials.
+func ManifestConversionURL(apiURL, code string) string {
+	return fmt.Sprintf("%s/app-manifests/%s/conversions", strings.TrimSuffix(apiURL, "/"), url.PathEscape(code))
+}
+
+func ValidOrganization(organization string) bool {
+	return organizationPattern.MatchString(organization)
+}
@@ -0,0 +1,174 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	EventPush                     = "push"
+	EventPullRequest              = "pull_request"
+	EventInstallation             = "installation"
+	EventInstallationRepositories = "installation_repositories"
+	EventPing                     = "ping"
+)
+
+var ErrUnsupportedEvent = errors.New("github: unsupported event")
+
+// VerifySignature checks an X-Hub-Signature-256 header against the HMAC-SHA256
+// of body keyed with the app's webhook secret.
+func VerifySignature(secret string, body []byte, header string) bool {
+	signature, ok := strings.CutPrefix(header, "sha256=")
+	if !ok || secret == "" {
+		return false
+	}
+
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
+
+type Account struct {
+	ID    int64  `json:"id"`
+	Login string `json:"login"`
+	Type  string `json:"type"`
+}
+
+type Repository struct {
+	ID            int64   `json:"id"`
+	Name          string  `json:"name"`
+	FullName      string  `json:"full_name"`
+	Private       bool    `json:"private"`
+	HTMLURL       string  `json:"html_url"`
+	CloneURL      string  `json:"clone_url"`
+	SSHURL        string  `json:"ssh_url"`
+	DefaultBranch string  `json:"default_branch"`
+	Owner         Account `json:"owner"`
+}
+
+type Installation struct {
+	ID                  int64   `json:"id"`
+	Account             Account `json:"account"`
+	TargetType          string  `json:"target_type"`
+	RepositorySelection string  `json:"repository_selection"`
+}
+
+type Commit struct {
+	ID        string `json:"id"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+	URL       string `json:"url"`
+	Author    struct {
+		Name     string `json:"name"`
+		Email    string `json:"email"`
+		Username string `json:"username"`
+	} `json:"author"`
+}
+
+type PushEvent struct {
+	Ref          string        `json:"ref"`
+	Before       string        `json:"before"`
+	After        string        `json:"after"`
+	Created      bool          `json:"created"`
+	Deleted      bool          `json:"deleted"`
+	Forced       bool          `json:"forced"`
+	HeadCommit   *Commit       `json:"head_commit"`
+	Repository   Repository    `json:"repository"`
+	Sender       Account       `json:"sender"`
+	Installation *Installation `json:"installation"`
+}
+
+// Branch returns the branch name for branch pushes, or "" for tags.
+func (e *PushEvent) Branch() string {
+	branch, ok := strings.CutPrefix(e.Ref, "refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+type PullRequest struct {
+	ID      int64  `json:"id"`
+	Number  int    `json:"number"`
+	State   string `json:"state"`
+	Title   string `json:"title"`
+	HTMLURL string `json:"html_url"`
+	Merged  bool   `json:"merged"`
+	Head    struct {
+		Ref  string     `json:"ref"`
+		SHA  string     `json:"sha"`
+		Repo Repository `json:"repo"`
+	} `json:"head"`
+	Base struct {
+		Ref  string     `json:"ref"`
+		SHA  string     `json:"sha"`
+		Repo Repository `json:"repo"`
+	} `json:"base"`
+}
+
+type PullRequestEvent struct {
+	Action       string        `json:"action"`
+	Number       int           `json:"number"`
+	PullRequest  PullRequest   `json:"pull_request"`
+	Repository   Repository    `json:"repository"`
+	Sender       Account       `json:"sender"`
+	Installation *Installation `json:"installation"`
+}
+
+type InstallationEvent struct {
+	Action       string       `json:"action"`
+	Installation Installation `json:"installation"`
+	Repositories []Repository `json:"repositories"`
+	Sender       Account      `json:"sender"`
+}
+
+type InstallationRepositoriesEvent struct {
+	Action              string       `json:"action"`
+	Installation        Installation `json:"installation"`
+	RepositorySelection string       `json:"repository_selection"`
+	RepositoriesAdded   []Repository `json:"repositories_added"`
+	RepositoriesRemoved []Repository `json:"repositories_removed"`
+	Sender              Account      `json:"sender"`
+}
+
+// ParseEvent decodes payload into the typed event for eventType, as sent in
+// the X-GitHub-Event header.
+func ParseEvent(eventType string, payload []byte) (any, error) {
+	var event any
+	switch eventType {
+	case EventPush:
+		event = &PushEvent{}
+	case EventPullRequest:
+		event = &PullRequestEvent{}
+	case EventInstallation:
+		event = &InstallationEvent{}
+	case EventInstallationRepositories:
+		event = &InstallationRepositoriesEvent{}
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+
+	if err := json.Unmarshal(payload, event); err != nil {
+		return nil, err
+	}
+	return event, nil
+}
+
+// EventAction returns the "action" field most payloads carry, if any.
+func EventAction(payload []byte) string {
+	var body struct {
+		Action string `json:"action"`
+	}
+	json.Unmarshal(payload, &body)
+	return body.Action
+}
@@ -0,0 +1,60 @@
+package github
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"testing"
+)
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := []byte(`{"ref":"refs/heads/main"}`)
+	signature := sign("secret", body)
+
+	tests := []struct {
+		name   string
+		secret string
+		body   []byte
+		header string
+		want   bool
+	}{
+		{"valid", "secret", body, signature, true},
+		{"another secret", "other", body, signature, false},
+		{"tampered body", "secret", []byte(`{"ref":"refs/heads/dev"}`), signature, false},
+		{"tampered signature", "secret", body, signature[:len(signature)-2] + "00", false},
+		{"missing prefix", "secret", body, signature[len("sha256="):], false},
+		{"sha1 prefix", "secret", body, "sha1=" + signature[len("sha256="):], false},
+		{"no secret configured", "", body, sign("", body), false},
+		{"missing header", "secret", body, "", false},
+		{"not hex", "secret", body, "sha256=zz", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifySignature(tt.secret, tt.body, tt.header); got != tt.want {
+				t.Fatalf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseEvent(t *testing.T) {
+	event, err := ParseEvent(EventPush, []byte(`{"ref": "refs/heads/main", "after": "def", "installation": {"id": 42}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	push, ok := event.(*PushEvent)
+	if !ok || push.Branch() != "main" || push.After != "def" || push.Installation == nil || push.Installation.ID != 42 {
+		t.Fatalf("ParseEvent() = %#v", event)
+	}
+
+	if _, err := ParseEvent("star", []byte(`{}`)); !errors.Is(err, ErrUnsupportedEvent) {
+		t.Fatalf("ParseEvent(star) = %v, want ErrUnsupportedEvent", err)
+	}
+}
@@ -0,0 +1,227 @@
+package gitlab
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultHTMLURL = "https://gitlab.com"
+
+	// Upper bound on pages fetched when listing, so a huge group can't keep a
+	// request busy forever.
+	maxPages = 20
+)
+
+type APIError struct {
+	StatusCode int
+	Message    any `json:"message"`
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("gitlab: %d %v", e.StatusCode, e.Message)
+}
+
+func IsNotFound(err error) bool {
+	var apiErr *APIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// APIURLFor returns the REST API root of a GitLab instance.
+func APIURLFor(htmlURL string) string {
+	return strings.TrimSuffix(htmlURL, "/") + "/api/v4"
+}
+
+// Client talks to the GitLab REST API with an OAuth access token or a
+// personal, project or group access token.
+type Client struct {
+	Ctx        context.Context
+	APIURL     string
+	Token      string
+	HTTPClient *http.Client
+}
+
+func NewClient(ctx context.Context, apiURL, token string) *Client {
+	return &Client{
+		Ctx:        ctx,
+		APIURL:     strings.TrimSuffix(apiURL, "/"),
+		Token:      token,
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+type Project struct {
+	ID                int64  `json:"id"`
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	Visibility        string `json:"visibility"`
+	DefaultBranch     string `json:"default_branch"`
+	WebURL            string `json:"web_url"`
+	HTTPURLToRepo     string `json:"http_url_to_repo"`
+	SSHURLToRepo      string `json:"ssh_url_to_repo"`
+}
+
+type Branch struct {
+	Name   string `json:"name"`
+	Commit struct {
+		ID string `json:"id"`
+	} `json:"commit"`
+	Protected bool `json:"protected"`
+}
+
+type User struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+type Hook struct {
+	ID  int64  `json:"id"`
+	URL string `json:"url"`
+}
+
+// CurrentUser returns the user the token belongs to. Project and group access
+// tokens belong to a bot user.
+func (c *Client) CurrentUser() (*User, error) {
+	var user User
+	if err := c.Do(http.MethodGet, "/user", nil, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ListProjects lists the projects the token's user is a member of. For project
+// and group access tokens that is the project or the group's projects.
+func (c *Client) ListProjects(search string) ([]Project, error) {
+	var projects []Project
+	for page := 1; page <= maxPages; page++ {
+		params := url.Values{}
+		params.Set("membership", "true")
+		params.Set("order_by", "path")
+		params.Set("sort", "asc")
+		params.Set("per_page", "100")
+		params.Set("page", fmt.Sprint(page))
+		if search != "" {
+			params.Set("search", search)
+		}
+
+		var response []Project
+		if err := c.Do(http.MethodGet, "/projects?"+params.Encode(), nil, &response); err != nil {
+			return nil, err
+		}
+
+		projects = append(projects, response...)
+		if len(response) < 100 {
+			break
+		}
+	}
+	return projects, nil
+}
+
+func (c *Client) GetProject(projectID string) (*Project, error) {
+	var project Project
+	if err := c.Do(http.MethodGet, "/projects/"+url.PathEscape(projectID), nil, &project); err != nil {
+		return nil, err
+	}
+	return &project, nil
+}
+
+func (c *Client) ListBranches(projectID string) ([]Branch, error) {
+	var branches []Branch
+	for page := 1; page <= maxPages; page++ {
+		var response []Branch
+
+		path := fmt.Sprintf("/projects/%s/repository/branches?per_page=100&page=%d", url.PathEscape(projectID), page)
+		if err := c.Do(http.MethodGet, path, nil, &response); err != nil {
+			return nil, err
+		}
+
+		branches = append(branches, response...)
+		if len(response) < 100 {
+			break
+		}
+	}
+	return branches, nil
+}
+
+// CreateHook registers a project webhook for push and merge request events.
+// GitLab sends secretToken back in the X-Gitlab-Token header.
+func (c *Client) CreateHook(projectID, hookURL, secretToken string) (*Hook, error) {
+	body := map[string]any{
+		"url":                     hookURL,
+		"token":                   secretToken,
+		"push_events":             true,
+		"merge_requests_events":   true,
+		"enable_ssl_verification": strings.HasPrefix(hookURL, "https://"),
+	}
+
+	var hook Hook
+	if err := c.Do(http.MethodPost, fmt.Sprintf("/projects/%s/hooks", url.PathEscape(projectID)), body, &hook); err != nil {
+		return nil, err
+	}
+	return &hook, nil
+}
+
+func (c *Client) DeleteHook(projectID string, hookID int64) error {
+	return c.Do(http.MethodDelete, fmt.Sprintf("/projects/%s/hooks/%d", url.PathEscape(projectID), hookID), nil, nil)
+}
+
+// CloneURL embeds the token in a project's HTTPS clone URL so git can fetch
+// private projects. GitLab accepts any username with a token password.
+func (c *Client) CloneURL(cloneURL string) (string, error) {
+	parsed, err := url.Parse(cloneURL)
+	if err != nil {
+		return "", err
+	}
+	parsed.User = url.UserPassword("oauth2", c.Token)
+
+	return parsed.String(), nil
+}
+
+func (c *Client) Do(method, path string, body, out any) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(c.Ctx, method, c.APIURL+path, reader)
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Mo-SH")
+	req.Header.Set("Authorization", "Bearer "+c.Token)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusBadRequest {
+		apiErr := &APIError{StatusCode: resp.StatusCode}
+		json.NewDecoder(resp.Body).Decode(apiErr)
+		return apiErr
+	}
+
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(out)
+}
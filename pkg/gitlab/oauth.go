@@ -0,0 +1,95 @@
+package gitlab
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Scope needed to list projects and manage their webhooks.
+const oauthScope = "api"
+
+type Token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+	CreatedAt    int64  `json:"created_at"`
+}
+
+// ExpiresAt is when the access token stops working, or the zero time if
+// GitLab didn't say.
+func (t *Token) ExpiresAt() time.Time {
+	if t.ExpiresIn == 0 {
+		return time.Time{}
+	}
+	createdAt := time.Now()
+	if t.CreatedAt != 0 {
+		createdAt = time.Unix(t.CreatedAt, 0)
+	}
+	return createdAt.Add(time.Duration(t.ExpiresIn) * time.Second)
+}
+
+func AuthorizeURL(htmlURL, clientID, redirectURL, state string) string {
+	params := url.Values{}
+	params.Set("client_id", clientID)
+	params.Set("redirect_uri", redirectURL)
+	params.Set("response_type", "code")
+	params.Set("scope", oauthScope)
+	params.Set("state", state)
+
+	return strings.TrimSuffix(htmlURL, "/") + "/oauth/authorize?" + params.Encode()
+}
+
+func ExchangeCode(ctx context.Context, htmlURL, clientID, clientSecret, redirectURL, code string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", redirectURL)
+	return requestToken(ctx, htmlURL, clientID, clientSecret, form)
+}
+
+func RefreshToken(ctx context.Context, htmlURL, clientID, clientSecret, redirectURL, refreshToken string) (*Token, error) {
+	form := url.Values{}
+	form.Set("grant_type", "refresh_token")
+	form.Set("refresh_token", refreshToken)
+	form.Set("redirect_uri", redirectURL)
+	return requestToken(ctx, htmlURL, clientID, clientSecret, form)
+}
+
+func requestToken(ctx context.Context, htmlURL, clientID, clientSecret string, form url.Values) (*Token, error) {
+	form.Set("client_id", clientID)
+	form.Set("client_secret", clientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, strings.TrimSuffix(htmlURL, "/")+"/oauth/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Mo-SH")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("gitlab oauth: token endpoint returned %s", resp.Status)
+	}
+
+	var token Token
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, err
+	}
+	if token.AccessToken == "" {
+		return nil, fmt.Errorf("gitlab oauth: no access token in response")
+	}
+
+	return &token, nil
+}
@@ -0,0 +1,108 @@
+package gitlab
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"strings"
+)
+
+const (
+	EventPush         = "Push Hook"
+	EventMergeRequest = "Merge Request Hook"
+)
+
+var ErrUnsupportedEvent = errors.New("gitlab: unsupported event")
+
+// VerifyToken checks the X-Gitlab-Token header against the secret token the
+// webhook was registered with. GitLab doesn't sign payloads.
+func VerifyToken(secret, header string) bool {
+	if secret == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(secret), []byte(header)) == 1
+}
+
+type EventProject struct {
+	ID                int64  `json:"id"`
+	Name              string `json:"name"`
+	PathWithNamespace string `json:"path_with_namespace"`
+	DefaultBranch     string `json:"default_branch"`
+	WebURL            string `json:"web_url"`
+	GitHTTPURL        string `json:"git_http_url"`
+}
+
+type Commit struct {
+	ID        string `json:"id"`
+	Message   string `json:"message"`
+	Timestamp string `json:"timestamp"`
+	URL       string `json:"url"`
+	Author    struct {
+		Name  string `json:"name"`
+		Email string `json:"email"`
+	} `json:"author"`
+}
+
+type PushEvent struct {
+	ObjectKind   string       `json:"object_kind"`
+	Ref          string       `json:"ref"`
+	Before       string       `json:"before"`
+	After        string       `json:"after"`
+	CheckoutSHA  string       `json:"checkout_sha"`
+	UserUsername string       `json:"user_username"`
+	ProjectID    int64        `json:"project_id"`
+	Project      EventProject `json:"project"`
+	Commits      []Commit     `json:"commits"`
+}
+
+// Branch returns the pushed branch, or "" for tag pushes.
+func (e *PushEvent) Branch() string {
+	branch, ok := strings.CutPrefix(e.Ref, "refs/heads/")
+	if !ok {
+		return ""
+	}
+	return branch
+}
+
+// Deleted reports whether the push removed the branch.
+func (e *PushEvent) Deleted() bool {
+	return strings.Trim(e.After, "0") == ""
+}
+
+type MergeRequestEvent struct {
+	ObjectKind string       `json:"object_kind"`
+	Project    EventProject `json:"project"`
+	User       struct {
+		Username string `json:"username"`
+	} `json:"user"`
+	ObjectAttributes struct {
+		IID          int64  `json:"iid"`
+		Title        string `json:"title"`
+		State        string `json:"state"`
+		Action       string `json:"action"`
+		SourceBranch string `json:"source_branch"`
+		TargetBranch string `json:"target_branch"`
+		URL          string `json:"url"`
+		LastCommit   struct {
+			ID string `json:"id"`
+		} `json:"last_commit"`
+	} `json:"object_attributes"`
+}
+
+// ParseEvent decodes a webhook payload based on its X-Gitlab-Event header.
+func ParseEvent(event string, payload []byte) (any, error) {
+	var target any
+	switch event {
+	case EventPush:
+		target = &PushEvent{}
+	case EventMergeRequest:
+		target = &MergeRequestEvent{}
+	default:
+		return nil, ErrUnsupportedEvent
+	}
+
+	if err := json.Unmarshal(payload, target); err != nil {
+		return nil, err
+	}
+	return target, nil
+}
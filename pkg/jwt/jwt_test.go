@@ -0,0 +1,140 @@
+package jwt
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSignRS256Verify(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := SignRS256(map[string]string{"sub": "user"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := Parse(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Header.Alg != "RS256" {
+		t.Fatalf("alg = %q, want RS256", token.Header.Alg)
+	}
+	if err := token.Verify(&key.PublicKey); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	var claims map[string]string
+	if err := json.Unmarshal(token.Claims, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims["sub"] != "user" {
+		t.Fatalf("sub = %q, want user", claims["sub"])
+	}
+}
+
+func TestVerifyRejects(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := SignRS256(map[string]string{"sub": "user"}, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(raw, ".")
+	tampered := parts[0] + "." + encoding.EncodeToString([]byte(`{"sub":"admin"}`)) + "." + parts[2]
+	none := encoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + parts[1] + "."
+
+	tests := []struct {
+		name string
+		raw  string
+		key  crypto.PublicKey
+		want error
+	}{
+		{"wrong key", raw, &other.PublicKey, ErrInvalidSignature},
+		{"tampered claims", tampered, &key.PublicKey, ErrInvalidSignature},
+		{"alg none", none, &key.PublicKey, ErrUnsupportedAlg},
+		{"key of another type", raw, &ecKey.PublicKey, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token, err := Parse(tt.raw)
+			if err != nil {
+				t.Fatal(err)
+			}
+			err = token.Verify(tt.key)
+			if err == nil {
+				t.Fatal("Verify() = nil, want an error")
+			}
+			if tt.want != nil && !errors.Is(err, tt.want) {
+				t.Fatalf("Verify() = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyES256(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := encoding.EncodeToString([]byte(`{"alg":"ES256"}`)) + "." + encoding.EncodeToString([]byte(`{"sub":"user"}`))
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token, err := Parse(signed + "." + encoding.EncodeToString(signature))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := token.Verify(&key.PublicKey); err != nil {
+		t.Fatalf("Verify() = %v", err)
+	}
+
+	token.signature = token.signature[:63]
+	if err := token.Verify(&key.PublicKey); !errors.Is(err, ErrInvalidSignature) {
+		t.Fatalf("Verify() with a short signature = %v, want %v", err, ErrInvalidSignature)
+	}
+}
+
+func TestParseMalformed(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"a.b",
+		"a.b.c.d",
+		"!!.e30.",
+		"e30.!!.",
+		encoding.EncodeToString([]byte("not json")) + ".e30.",
+	} {
+		if _, err := Parse(raw); !errors.Is(err, ErrMalformed) {
+			t.Errorf("Parse(%q) = %v, want %v", raw, err, ErrMalformed)
+		}
+	}
+}
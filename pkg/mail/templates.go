@@ -0,0 +1,30 @@
+package mail
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// Render builds a message from templates/emails/<name>.txt. The first line of
+// the template is the subject, everything after the blank line is the body.
+func Render(name, to string, data any) (*Message, error) {
+	tmpl, err := template.ParseFiles(filepath.Join("templates", "emails", name+".txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	subject, body, _ := strings.Cut(buf.String(), "\n\n")
+
+	return &Message{
+		To:      to,
+		Subject: strings.TrimPrefix(strings.TrimSpace(subject), "Subject: "),
+		Body:    body,
+	}, nil
+}
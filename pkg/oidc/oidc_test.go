@@ -0,0 +1,197 @@
+package oidc
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID = "mo-sh"
+	testKeyID    = "key-1"
+	testNonce    = "nonce"
+)
+
+// testIssuer serves discovery and a JWKS with one RSA key, and signs ID
+// tokens with it.
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	issuer := &testIssuer{key: key}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer.server.URL,
+			"authorization_endpoint": issuer.server.URL + "/authorize",
+			"token_endpoint":         issuer.server.URL + "/token",
+			"jwks_uri":               issuer.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": testKeyID,
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+
+	return issuer
+}
+
+func (i *testIssuer) provider(t *testing.T) *Provider {
+	t.Helper()
+
+	provider, err := Discover(context.Background(), i.server.URL, testClientID, "secret", []string{"openid"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return provider
+}
+
+func (i *testIssuer) claims() map[string]any {
+	now := time.Now()
+	return map[string]any{
+		"iss":   i.server.URL,
+		"sub":   "subject",
+		"aud":   testClientID,
+		"exp":   now.Add(time.Hour).Unix(),
+		"iat":   now.Unix(),
+		"nonce": testNonce,
+		"email": "user@example.com",
+	}
+}
+
+func sign(t *testing.T, key *rsa.PrivateKey, header, claims map[string]any) string {
+	t.Helper()
+
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claimsJSON, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(headerJSON) + "." + base64.RawURLEncoding.EncodeToString(claimsJSON)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestVerifyIDToken(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := issuer.provider(t)
+
+	claims := issuer.claims()
+	claims["aud"] = []string{"other", testClientID}
+	claims["groups"] = []string{"admins"}
+	raw := sign(t, issuer.key, map[string]any{"alg": "RS256", "kid": testKeyID}, claims)
+
+	verified, err := provider.VerifyIDToken(context.Background(), raw, testNonce)
+	if err != nil {
+		t.Fatalf("VerifyIDToken() = %v", err)
+	}
+	if verified.Subject != "subject" || verified.Email != "user@example.com" {
+		t.Fatalf("claims = %+v", verified)
+	}
+	if groups := verified.Groups("groups"); len(groups) != 1 || groups[0] != "admins" {
+		t.Fatalf("Groups() = %v, want [admins]", groups)
+	}
+}
+
+func TestVerifyIDTokenRejects(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := issuer.provider(t)
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	header := map[string]any{"alg": "RS256", "kid": testKeyID}
+	tests := []struct {
+		name   string
+		header map[string]any
+		key    *rsa.PrivateKey
+		modify func(claims map[string]any)
+		want   string
+	}{
+		{"signed by another key", header, otherKey, nil, "invalid signature"},
+		{"unknown key id", map[string]any{"alg": "RS256", "kid": "key-2"}, issuer.key, nil, "unknown signing key"},
+		{"unsigned", map[string]any{"alg": "none", "kid": testKeyID}, issuer.key, nil, "unsupported signing algorithm"},
+		{"another issuer", header, issuer.key, func(c map[string]any) { c["iss"] = "https://evil.example" }, "unexpected issuer"},
+		{"another audience", header, issuer.key, func(c map[string]any) { c["aud"] = "other" }, "not issued for this client"},
+		{"expired", header, issuer.key, func(c map[string]any) { c["exp"] = time.Now().Add(-2 * leeway).Unix() }, "expired"},
+		{"issued in the future", header, issuer.key, func(c map[string]any) { c["iat"] = time.Now().Add(2 * leeway).Unix() }, "future"},
+		{"another nonce", header, issuer.key, func(c map[string]any) { c["nonce"] = "replayed" }, "nonce mismatch"},
+		{"no subject", header, issuer.key, func(c map[string]any) { delete(c, "sub") }, "no subject"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := issuer.claims()
+			if tt.modify != nil {
+				tt.modify(claims)
+			}
+			raw := sign(t, tt.key, tt.header, claims)
+
+			_, err := provider.VerifyIDToken(context.Background(), raw, testNonce)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("VerifyIDToken() = %v, want an error containing %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestVerifyIDTokenWithinLeeway(t *testing.T) {
+	issuer := newTestIssuer(t)
+	provider := issuer.provider(t)
+
+	claims := issuer.claims()
+	claims["exp"] = time.Now().Add(-leeway / 2).Unix()
+	raw := sign(t, issuer.key, map[string]any{"alg": "RS256", "kid": testKeyID}, claims)
+
+	if _, err := provider.VerifyIDToken(context.Background(), raw, testNonce); err != nil {
+		t.Fatalf("VerifyIDToken() = %v", err)
+	}
+}
+
+func TestDiscoverRejectsIssuerMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"issuer": "https://evil.example"})
+	}))
+	defer server.Close()
+
+	if _, err := Discover(context.Background(), server.URL, testClientID, "secret", nil); err == nil {
+		t.Fatal("Discover() = nil, want an issuer mismatch")
+	}
+}
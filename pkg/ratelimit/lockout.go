@@ -0,0 +1,86 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Failures are forgotten after this long without another failed attempt.
+const failureMemory = 24 * time.Hour
+
+type Lockout interface {
+	Check(key string) (time.Duration, error)
+	Fail(key string) (time.Duration, error)
+	Reset(key string) error
+}
+
+type lockout struct {
+	RedisClient *redis.Client
+	Ctx         context.Context
+	Threshold   int
+	Base        time.Duration
+	Max         time.Duration
+}
+
+func NewLockout(redisClient *redis.Client, ctx context.Context, threshold int, base, max time.Duration) *lockout {
+	return &lockout{
+		RedisClient: redisClient,
+		Ctx:         ctx,
+		Threshold:   threshold,
+		Base:        base,
+		Max:         max,
+	}
+}
+
+// Check returns how much longer key stays locked, or zero if it isn't.
+func (l *lockout) Check(key string) (time.Duration, error) {
+	ttl, err := l.RedisClient.PTTL(l.Ctx, lockKey(key)).Result()
+	if err != nil {
+		return 0, err
+	}
+	if ttl < 0 {
+		return 0, nil
+	}
+	return ttl, nil
+}
+
+// Fail records a failed attempt and returns the lockout it triggered, if any.
+func (l *lockout) Fail(key string) (time.Duration, error) {
+	pipe := l.RedisClient.TxPipeline()
+	incr := pipe.Incr(l.Ctx, failuresKey(key))
+	pipe.Expire(l.Ctx, failuresKey(key), failureMemory)
+	if _, err := pipe.Exec(l.Ctx); err != nil {
+		return 0, err
+	}
+
+	failures := int(incr.Val())
+	if failures < l.Threshold {
+		return 0, nil
+	}
+
+	duration := l.Base
+	for i := l.Threshold; i < failures && duration < l.Max; i++ {
+		duration *= 2
+	}
+	duration = min(duration, l.Max)
+
+	if err := l.RedisClient.Set(l.Ctx, lockKey(key), failures, duration).Err(); err != nil {
+		return 0, err
+	}
+
+	return duration, nil
+}
+
+func (l *lockout) Reset(key string) error {
+	return l.RedisClient.Del(l.Ctx, failuresKey(key), lockKey(key)).Err()
+}
+
+func failuresKey(key string) string {
+	return "lockout:failures:" + key
+}
+
+func lockKey(key string) string {
+	return "lockout:locked:" + key
+}
@@ -0,0 +1,60 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Fixed window counter: the first hit in a window sets the expiry.
+var allowScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if count == 1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return {count, redis.call("PTTL", KEYS[1])}
+`)
+
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+type Limiter interface {
+	Allow(key string, limit int, window time.Duration) (*Result, error)
+}
+
+type limiter struct {
+	RedisClient *redis.Client
+	Ctx         context.Context
+}
+
+func NewLimiter(redisClient *redis.Client, ctx context.Context) *limiter {
+	return &limiter{
+		RedisClient: redisClient,
+		Ctx:         ctx,
+	}
+}
+
+func (l *limiter) Allow(key string, limit int, window time.Duration) (*Result, error) {
+	values, err := allowScript.Run(l.Ctx, l.RedisClient, []string{"ratelimit:" + key}, window.Milliseconds()).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	count, ttl := int(values[0]), time.Duration(values[1])*time.Millisecond
+
+	result := &Result{
+		Allowed:   count <= limit,
+		Limit:     limit,
+		Remaining: max(limit-count, 0),
+	}
+	if !result.Allowed {
+		result.RetryAfter = ttl
+	}
+
+	return result, nil
+}
@@ -0,0 +1,359 @@
+// Package s3 is a small client for S3-compatible object storage, such as AWS
+// S3 and MinIO, covering what backups need: streaming uploads, downloads and
+// deletes.
+package s3
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// partSize is the size of each part of a multipart upload. S3 needs at least
+// 5 MiB for every part but the last.
+const partSize = 8 << 20
+
+type Client struct {
+	// Endpoint is the storage's base URL, such as https://s3.amazonaws.com
+	// or http://localhost:9000 for MinIO.
+	Endpoint        string
+	Region          string
+	Bucket          string
+	AccessKeyID     string
+	SecretAccessKey string
+	// PathStyle addresses the bucket in the path rather than the host name,
+	// which MinIO and most self-hosted storage expect.
+	PathStyle  bool
+	HTTPClient *http.Client
+}
+
+// Error is an error response from the storage.
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3: unexpected status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("s3: %s: %s", e.Code, e.Message)
+}
+
+// IsNotFound reports whether err means the bucket or object doesn't exist.
+func IsNotFound(err error) bool {
+	var s3Err *Error
+	return errors.As(err, &s3Err) && s3Err.StatusCode == http.StatusNotFound
+}
+
+// CheckBucket checks that the bucket exists and the credentials can reach it.
+func (c *Client) CheckBucket(ctx context.Context) error {
+	response, err := c.do(ctx, http.MethodHead, "", nil, nil)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+// Upload stores everything read from r under key and returns its size.
+// Objects larger than one part are sent as a multipart upload, so r never
+// needs to fit in memory.
+func (c *Client) Upload(ctx context.Context, key string, r io.Reader) (int64, error) {
+	buffer := make([]byte, partSize)
+
+	n, err := io.ReadFull(r, buffer)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return int64(n), c.put(ctx, key, buffer[:n])
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	uploadID, err := c.createMultipartUpload(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+
+	size, err := c.uploadParts(ctx, key, uploadID, buffer, r)
+	if err != nil {
+		// Don't leave the parts behind; they are billed until aborted.
+		abortCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if response, abortErr := c.do(abortCtx, http.MethodDelete, key, url.Values{"uploadId": {uploadID}}, nil); abortErr == nil {
+			response.Body.Close()
+		}
+		return 0, err
+	}
+
+	return size, nil
+}
+
+// Download opens the object stored under key. The caller closes it.
+func (c *Client) Download(ctx context.Context, key string) (io.ReadCloser, error) {
+	response, err := c.do(ctx, http.MethodGet, key, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	return response.Body, nil
+}
+
+// Delete removes the object stored under key. Deleting a missing object
+// succeeds.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	response, err := c.do(ctx, http.MethodDelete, key, nil, nil)
+	if err != nil {
+		if IsNotFound(err) {
+			return nil
+		}
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func (c *Client) put(ctx context.Context, key string, body []byte) error {
+	response, err := c.do(ctx, http.MethodPut, key, nil, body)
+	if err != nil {
+		return err
+	}
+	response.Body.Close()
+	return nil
+}
+
+func (c *Client) createMultipartUpload(ctx context.Context, key string) (string, error) {
+	response, err := c.do(ctx, http.MethodPost, key, url.Values{"uploads": {""}}, nil)
+	if err != nil {
+		return "", err
+	}
+	defer response.Body.Close()
+
+	var result struct {
+		UploadID string `xml:"UploadId"`
+	}
+	if err := xml.NewDecoder(response.Body).Decode(&result); err != nil {
+		return "", err
+	}
+	if result.UploadID == "" {
+		return "", errors.New("s3: no upload ID in response")
+	}
+	return result.UploadID, nil
+}
+
+type completedPart struct {
+	PartNumber int    `xml:"PartNumber"`
+	ETag       string `xml:"ETag"`
+}
+
+// uploadParts sends first, which is a full part, and then the rest of r.
+func (c *Client) uploadParts(ctx context.Context, key, uploadID string, first []byte, r io.Reader) (int64, error) {
+	var parts []completedPart
+	var size int64
+
+	// Once r is drained, ReadFull returns no bytes and the loop ends.
+	for number, part := 1, first; len(part) > 0; number++ {
+		query := url.Values{"partNumber": {strconv.Itoa(number)}, "uploadId": {uploadID}}
+		response, err := c.do(ctx, http.MethodPut, key, query, part)
+		if err != nil {
+			return 0, err
+		}
+		response.Body.Close()
+
+		parts = append(parts, completedPart{PartNumber: number, ETag: response.Header.Get("ETag")})
+		size += int64(len(part))
+
+		n, err := io.ReadFull(r, first)
+		if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+			return 0, err
+		}
+		part = first[:n]
+	}
+
+	return size, c.completeMultipartUpload(ctx, key, uploadID, parts)
+}
+
+func (c *Client) completeMultipartUpload(ctx context.Context, key, uploadID string, parts []completedPart) error {
+	body, err := xml.Marshal(struct {
+		XMLName xml.Name        `xml:"CompleteMultipartUpload"`
+		Parts   []completedPart `xml:"Part"`
+	}{Parts: parts})
+	if err != nil {
+		return err
+	}
+
+	response, err := c.do(ctx, http.MethodPost, key, url.Values{"uploadId": {uploadID}}, body)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	// Completing can fail after a 200, with the error in the body.
+	payload, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if bytes.Contains(payload, []byte("<Error>")) {
+		s3Err := &Error{StatusCode: response.StatusCode}
+		xml.Unmarshal(payload, s3Err)
+		return s3Err
+	}
+
+	return nil
+}
+
+// do sends a signed request and returns the response if it succeeded.
+func (c *Client) do(ctx context.Context, method, key string, query url.Values, body []byte) (*http.Response, error) {
+	endpoint, err := url.Parse(strings.TrimSuffix(c.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+
+	host := endpoint.Host
+	path := "/" + encodePath(key)
+	if c.PathStyle {
+		path = "/" + c.Bucket
+		if key != "" {
+			path += "/" + encodePath(key)
+		}
+	} else {
+		host = c.Bucket + "." + host
+	}
+
+	rawQuery := encodeQuery(query)
+	target := endpoint.Scheme + "://" + host + path
+	if rawQuery != "" {
+		target += "?" + rawQuery
+	}
+
+	request, err := http.NewRequestWithContext(ctx, method, target, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = int64(len(body))
+	c.sign(request, host, path, rawQuery, body, time.Now())
+
+	httpClient := c.HTTPClient
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	response, err := httpClient.Do(request)
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode >= 300 {
+		defer response.Body.Close()
+		s3Err := &Error{StatusCode: response.StatusCode}
+		if method != http.MethodHead {
+			payload, _ := io.ReadAll(io.LimitReader(response.Body, 64<<10))
+			xml.Unmarshal(payload, s3Err)
+		}
+		return nil, s3Err
+	}
+
+	return response, nil
+}
+
+// sign adds AWS Signature Version 4 headers to request.
+func (c *Client) sign(request *http.Request, host, path, rawQuery string, body []byte, now time.Time) {
+	now = now.UTC()
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	payloadHash := sha256.Sum256(body)
+	payloadHex := hex.EncodeToString(payloadHash[:])
+
+	request.Header.Set("X-Amz-Date", amzDate)
+	request.Header.Set("X-Amz-Content-Sha256", payloadHex)
+
+	signedHeaders := "host;x-amz-content-sha256;x-amz-date"
+	canonicalHeaders := "host:" + host + "\n" +
+		"x-amz-content-sha256:" + payloadHex + "\n" +
+		"x-amz-date:" + amzDate + "\n"
+
+	canonicalRequest := strings.Join([]string{
+		request.Method,
+		path,
+		rawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHex,
+	}, "\n")
+
+	region := c.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	scope := date + "/" + region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+c.SecretAccessKey), date)
+	key = hmacSHA256(key, region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	request.Header.Set("Authorization", fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		c.AccessKeyID, scope, signedHeaders, signature,
+	))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// encodePath escapes each segment of an object key as SigV4 expects.
+func encodePath(key string) string {
+	segments := strings.Split(key, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+// encodeQuery sorts and escapes query parameters as SigV4 expects.
+func encodeQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var pairs []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			pairs = append(pairs, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(pairs, "&")
+}
+
+// escape percent-encodes everything but unreserved characters.
+func escape(value string) string {
+	var b strings.Builder
+	for _, c := range []byte(value) {
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
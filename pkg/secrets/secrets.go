@@ -0,0 +1,80 @@
+// Package secrets encrypts values stored in the database, such as secret
+// environment variables, with a key from the instance's config.
+package secrets
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrNoKey     = errors.New("secrets: no encryption key is configured")
+	ErrMalformed = errors.New("secrets: malformed ciphertext")
+)
+
+// prefix versions the format, leaving room to rotate keys later.
+const prefix = "v1:"
+
+type Cipher struct {
+	aead cipher.AEAD
+}
+
+// NewCipher derives an AES-256-GCM key from key. A Cipher without a key
+// fails every call with ErrNoKey.
+func NewCipher(key string) *Cipher {
+	if key == "" {
+		return &Cipher{}
+	}
+
+	sum := sha256.Sum256([]byte(key))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		panic(err)
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+	return &Cipher{aead: aead}
+}
+
+func (c *Cipher) Encrypt(plaintext string) (string, error) {
+	if c.aead == nil {
+		return "", ErrNoKey
+	}
+
+	nonce := make([]byte, c.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := c.aead.Seal(nonce, nonce, []byte(plaintext), nil)
+
+	return prefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *Cipher) Decrypt(ciphertext string) (string, error) {
+	if c.aead == nil {
+		return "", ErrNoKey
+	}
+
+	encoded, ok := strings.CutPrefix(ciphertext, prefix)
+	if !ok {
+		return "", ErrMalformed
+	}
+	sealed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", ErrMalformed
+	}
+
+	nonce, sealed := sealed[:c.aead.NonceSize()], sealed[c.aead.NonceSize():]
+	plaintext, err := c.aead.Open(nil, nonce, sealed, nil)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
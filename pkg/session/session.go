@@ -18,6 +18,7 @@ const DefaultSessionTimeout = 7 * 24 * time.Hour
 type Store interface {
 	Create(userID string) (*Session, error)
 	Delete(sessionID string) error
+	DeleteAllForUser(userID string) error
 	FindByID(sessionID string) (*Session, error)
 }
 
@@ -40,7 +41,13 @@ func (s *store) Create(userID string) (*Session, error) {
 		UserID: userID,
 	}
 
-	if err := s.RedisClient.Set(s.Ctx, sessionID, userID, DefaultSessionTimeout).Err(); err != nil {
+	// Track the user's sessions so they can all be revoked at once, e.g. after a
+	// password reset.
+	pipe := s.RedisClient.TxPipeline()
+	pipe.Set(s.Ctx, sessionID, userID, DefaultSessionTimeout)
+	pipe.SAdd(s.Ctx, userSessionsKey(userID), sessionID)
+	pipe.Expire(s.Ctx, userSessionsKey(userID), DefaultSessionTimeout)
+	if _, err := pipe.Exec(s.Ctx); err != nil {
 		return nil, err
 	}
 
@@ -48,7 +55,29 @@ func (s *store) Create(userID string) (*Session, error) {
 }
 
 func (s *store) Delete(sessionID string) error {
-	return s.RedisClient.Del(s.Ctx, sessionID).Err()
+	userID, err := s.RedisClient.Get(s.Ctx, sessionID).Result()
+	if err != nil {
+		if err == redis.Nil {
+			return nil
+		}
+		return err
+	}
+
+	pipe := s.RedisClient.TxPipeline()
+	pipe.Del(s.Ctx, sessionID)
+	pipe.SRem(s.Ctx, userSessionsKey(userID), sessionID)
+	_, err = pipe.Exec(s.Ctx)
+	return err
+}
+
+func (s *store) DeleteAllForUser(userID string) error {
+	sessionIDs, err := s.RedisClient.SMembers(s.Ctx, userSessionsKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := append(sessionIDs, userSessionsKey(userID))
+	return s.RedisClient.Del(s.Ctx, keys...).Err()
 }
 
 func (s *store) FindByID(sessionID string) (*Session, error) {
@@ -61,3 +90,7 @@ func (s *store) FindByID(sessionID string) (*Session, error) {
 		UserID: userID,
 	}, nil
 }
+
+func userSessionsKey(userID string) string {
+	return "user_sessions:" + userID
+}
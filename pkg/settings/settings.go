@@ -0,0 +1,158 @@
+package settings
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/mohit4bug/mo-sh/pkg/config"
+)
+
+const (
+	keyAPIURL          = "api_url"
+	keyUIURL           = "ui_url"
+	keyGithubAppEvents = "github_app_events"
+
+	// Settings are read on most requests (CORS), so keep them in memory for a
+	// little while. Updates through this store take effect immediately.
+	cacheTimeout = 30 * time.Second
+)
+
+type Settings struct {
+	APIURL          string   `json:"apiUrl"`
+	UIURL           string   `json:"uiUrl"`
+	GithubAppEvents []string `json:"githubAppEvents"`
+}
+
+// Update holds the settings to change; nil fields are left as they are.
+type Update struct {
+	APIURL          *string   `json:"apiUrl"`
+	UIURL           *string   `json:"uiUrl"`
+	GithubAppEvents *[]string `json:"githubAppEvents"`
+}
+
+type Store interface {
+	Get() (*Settings, error)
+	Update(update *Update, userID string) (*Settings, error)
+}
+
+type store struct {
+	DB     *sqlx.DB
+	Config *config.Config
+	Ctx    context.Context
+
+	mu       sync.Mutex
+	cached   *Settings
+	cachedAt time.Time
+}
+
+func NewStore(db *sqlx.DB, cfg *config.Config, ctx context.Context) *store {
+	return &store{
+		DB:     db,
+		Config: cfg,
+		Ctx:    ctx,
+	}
+}
+
+// Get returns the settings stored in the database, falling back to the
+// config file and environment for anything an admin hasn't set.
+func (s *store) Get() (*Settings, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.cached != nil && time.Since(s.cachedAt) < cacheTimeout {
+		settings := *s.cached
+		return &settings, nil
+	}
+
+	settings, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	s.cached, s.cachedAt = settings, time.Now()
+	cached := *settings
+	return &cached, nil
+}
+
+func (s *store) Update(update *Update, userID string) (*Settings, error) {
+	values := map[string]any{}
+	if update.APIURL != nil {
+		values[keyAPIURL] = *update.APIURL
+	}
+	if update.UIURL != nil {
+		values[keyUIURL] = *update.UIURL
+	}
+	if update.GithubAppEvents != nil {
+		values[keyGithubAppEvents] = *update.GithubAppEvents
+	}
+
+	tx, err := s.DB.BeginTxx(s.Ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	query := `
+		insert into instance_settings (key, value, updated_by)
+		values ($1, $2, $3)
+		on conflict (key) do update set value = excluded.value, updated_by = excluded.updated_by, updated_at = now()
+	`
+	for key, value := range values {
+		payload, err := json.Marshal(value)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := tx.ExecContext(s.Ctx, query, key, string(payload), userID); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	s.cached = nil
+	s.mu.Unlock()
+
+	return s.Get()
+}
+
+func (s *store) load() (*Settings, error) {
+	settings := &Settings{
+		APIURL:          s.Config.APIURL,
+		UIURL:           s.Config.UIURL,
+		GithubAppEvents: s.Config.GithubAppEvents,
+	}
+
+	var rows []struct {
+		Key   string `db:"key"`
+		Value []byte `db:"value"`
+	}
+	if err := s.DB.SelectContext(s.Ctx, &rows, "select key, value from instance_settings"); err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		var target any
+		switch row.Key {
+		case keyAPIURL:
+			target = &settings.APIURL
+		case keyUIURL:
+			target = &settings.UIURL
+		case keyGithubAppEvents:
+			target = &settings.GithubAppEvents
+		default:
+			continue
+		}
+
+		if err := json.Unmarshal(row.Value, target); err != nil {
+			return nil, err
+		}
+	}
+
+	return settings, nil
+}
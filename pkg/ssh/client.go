@@ -2,8 +2,10 @@ package ssh
 
 import (
 	"bufio"
+	"bytes"
 	"fmt"
 	"io"
+	"sync"
 	"time"
 
 	"golang.org/x/crypto/ssh"
@@ -121,6 +123,12 @@ func (c *Client) RunCommand(cmd string) (string, string, error) {
 }
 
 func (c *Client) ExecuteWithStreams(cmd string, stdoutCallback, stderrCallback func(string)) error {
+	return c.ExecuteWithStdin(cmd, nil, stdoutCallback, stderrCallback)
+}
+
+// ExecuteWithStdin is ExecuteWithStreams with stdin connected to r, for
+// feeding a restore its dump while its output is logged.
+func (c *Client) ExecuteWithStdin(cmd string, r io.Reader, stdoutCallback, stderrCallback func(string)) error {
 	if c.conn == nil {
 		return fmt.Errorf("not connected")
 	}
@@ -131,6 +139,8 @@ func (c *Client) ExecuteWithStreams(cmd string, stdoutCallback, stderrCallback f
 	}
 	defer session.Close()
 
+	session.Stdin = r
+
 	stdout, err := session.StdoutPipe()
 	if err != nil {
 		return err
@@ -145,12 +155,65 @@ func (c *Client) ExecuteWithStreams(cmd string, stdoutCallback, stderrCallback f
 		return err
 	}
 
-	go streamOutput(stdout, stdoutCallback)
-	go streamOutput(stderr, stderrCallback)
+	// Drain both streams before Wait so the last lines aren't lost.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		streamOutput(stdout, stdoutCallback)
+	}()
+	go func() {
+		defer wg.Done()
+		streamOutput(stderr, stderrCallback)
+	}()
+	wg.Wait()
 
 	return session.Wait()
 }
 
+// RunWithStdin runs cmd with stdin connected to r, for uploading files and
+// piping dumps without going through the command line.
+func (c *Client) RunWithStdin(cmd string, r io.Reader) (string, error) {
+	if c.conn == nil {
+		return "", fmt.Errorf("not connected")
+	}
+
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stdin = r
+	session.Stderr = &stderr
+
+	err = session.Run(cmd)
+	return stderr.String(), err
+}
+
+// RunWithOutput runs cmd with stdin connected to r, which may be nil, and
+// its stdout copied to w, for streaming dumps and archives off the server.
+func (c *Client) RunWithOutput(cmd string, r io.Reader, w io.Writer) (string, error) {
+	if c.conn == nil {
+		return "", fmt.Errorf("not connected")
+	}
+
+	session, err := c.conn.NewSession()
+	if err != nil {
+		return "", err
+	}
+	defer session.Close()
+
+	var stderr bytes.Buffer
+	session.Stdin = r
+	session.Stdout = w
+	session.Stderr = &stderr
+
+	err = session.Run(cmd)
+	return stderr.String(), err
+}
+
 func (c *Client) CheckCommand(cmd string) bool {
 	if c.conn == nil {
 		return false
@@ -167,6 +230,8 @@ func (c *Client) CheckCommand(cmd string) bool {
 
 func streamOutput(reader io.Reader, callback func(string)) {
 	scanner := bufio.NewScanner(reader)
+	// Build output can have very long lines.
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
 		callback(scanner.Text())
 	}